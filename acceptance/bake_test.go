@@ -2,6 +2,7 @@ package acceptance_test
 
 import (
 	"archive/zip"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -311,6 +312,79 @@ var _ = Describe("bake command", func() {
 		})
 	})
 
+	Context("when the --manifest flag is provided", func() {
+		BeforeEach(func() {
+			commandWithArgs = append(commandWithArgs,
+				"--manifest", "kiln",
+				"--stemcells-directory", singleStemcellDirectory,
+			)
+		})
+
+		It("writes a manifest of the tile contents next to the tile", func() {
+			command := exec.Command(pathToMain, commandWithArgs...)
+
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(0))
+
+			contents, err := ioutil.ReadFile(outputFile + ".manifest.json")
+			Expect(err).NotTo(HaveOccurred())
+
+			var manifest struct {
+				Name     string `json:"name"`
+				Releases []struct {
+					Name string `json:"name"`
+					SHA1 string `json:"sha1"`
+				} `json:"releases"`
+				StemcellCriteria []struct {
+					OS string `json:"os"`
+				} `json:"stemcell_criteria"`
+			}
+			Expect(json.Unmarshal(contents, &manifest)).To(Succeed())
+
+			Expect(manifest.Name).To(Equal("cool-product-name"))
+			Expect(manifest.Releases).To(HaveLen(2))
+			Expect(manifest.Releases[0].Name).To(Equal("cf"))
+			Expect(manifest.Releases[0].SHA1).To(Equal(cfSHA1))
+			Expect(manifest.Releases[1].Name).To(Equal("diego"))
+			Expect(manifest.Releases[1].SHA1).To(Equal(diegoSHA1))
+			Expect(manifest.StemcellCriteria).To(HaveLen(1))
+			Expect(manifest.StemcellCriteria[0].OS).To(Equal("ubuntu-trusty"))
+		})
+	})
+
+	Context("when the --reproducible flag is provided", func() {
+		BeforeEach(func() {
+			commandWithArgs = append(commandWithArgs,
+				"--reproducible",
+				"--stemcells-directory", singleStemcellDirectory,
+			)
+		})
+
+		It("generates the same tile byte for byte each time", func() {
+			command := exec.Command(pathToMain, commandWithArgs...)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+
+			firstTile, err := ioutil.ReadFile(outputFile)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(os.Remove(outputFile)).To(Succeed())
+
+			command = exec.Command(pathToMain, commandWithArgs...)
+			session, err = gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+
+			secondTile, err := ioutil.ReadFile(outputFile)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(secondTile).To(Equal(firstTile))
+		})
+	})
+
 	Context("when the --kilnfile flag is provided", func() {
 
 		It("generates a tile with the correct metadata including the stemcell criteria from the Kilnfile.lock", func() {
@@ -7,6 +7,7 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha1"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
@@ -636,6 +637,9 @@ stemcell_criteria:
 		io.Copy(s, strings.NewReader(compiledReleaseCContents))
 		releaseCSha1 := hex.EncodeToString(s.Sum(nil))
 
+		releaseASHA256 := fmt.Sprintf("%x", sha256.Sum256([]byte(compiledReleaseAContents)))
+		releaseCSHA256 := fmt.Sprintf("%x", sha256.Sum256([]byte(compiledReleaseCContents)))
+
 		Expect(updatedLockfile).To(Equal(cargo.KilnfileLock{
 			Releases: []cargo.ReleaseLock{
 				{
@@ -644,6 +648,7 @@ stemcell_criteria:
 					RemoteSource: compiledReleasesID,
 					RemotePath:   "release-a/release-a-1.2.3-ubuntu-trusty-22.tgz",
 					SHA1:         releaseASha1,
+					SHA256:       releaseASHA256,
 				},
 				{
 					Name:         "release-b",
@@ -658,9 +663,10 @@ stemcell_criteria:
 					RemoteSource: compiledReleasesID,
 					RemotePath:   "release-c/release-c-2.3.4-ubuntu-trusty-22.tgz",
 					SHA1:         releaseCSha1,
+					SHA256:       releaseCSHA256,
 				},
 			},
-			Stemcell: cargo.Stemcell{OS: "ubuntu-trusty", Version: "22"},
+			Stemcells: cargo.StemcellCriteria{{OS: "ubuntu-trusty", Version: "22"}},
 		}))
 	})
 })
@@ -45,6 +45,7 @@ some_bosh_variables:
 name: cool-product-name
 post_deploy_errands:
 - name: smoke-tests
+  colocated: true
 product_version: 1.2.3
 some_property_blueprints:
 - name: some_templated_property_blueprint
@@ -18,15 +18,22 @@ Usage: kiln [options] <command> [<args>]
 
 Commands:
   bake                    bakes a tile
+  cache                   manages the shared release cache
   compile-built-releases  compiles built releases and uploads them
+  diff                    compares two tiles or Kilnfile.lock files
   fetch                   fetches releases
   find-release-version    prints a json string of a remote release satisfying the Kilnfile version and stemcell constraints
   help                    prints this usage information
+  inspect                 describes the contents of a tile
   publish                 publish tile on Pivnet
+  release-notes           renders release notes from Kilnfile.lock changes
   sync-with-local         update the Kilnfile.lock based on local releases
+  update-all              bumps all releases to their newest versions
   update-release          bumps a release to a new version
   update-stemcell         updates Kilnfile.lock with stemcell info
-  upload-release          uploads a BOSH release to an s3 release_source
+  upload-release          uploads a BOSH release to a release_source
+  validate                validates tile metadata
+  verify-lock             verifies the releases in Kilnfile.lock
   version                 prints the kiln release version
 `
 
@@ -45,14 +52,17 @@ Command Arguments:
   --instance-groups-directory, -ig   string (variadic)  path to a directory containing instance groups
   --jobs-directory, -j               string (variadic)  path to a directory containing jobs
   --kilnfile, -kf                    string             path to Kilnfile  (NOTE: mutually exclusive with --stemcell-directory)
-  --metadata, -m                     string (required)  path to the metadata file
+  --manifest                         string (variadic)  writes a manifest of the tile contents next to the output file (kiln, cyclonedx or spdx)
+  --metadata, -m                     string             path to the metadata file (defaults to bake.metadata in the Kilnfile)
   --metadata-only, -mo               bool               don't build a tile, output the metadata to stdout
   --migrations-directory, -md        string (variadic)  path to a directory containing migrations
   --output-file, -o                  string             path to where the tile will be output
   --properties-directory, -pd        string (variadic)  path to a directory containing property blueprints
   --releases-directory, -rd          string (variadic)  path to a directory containing release tarballs
+  --reproducible                     bool               bakes the same tile byte for byte from the same inputs, dated SOURCE_DATE_EPOCH when it is set
   --runtime-configs-directory, -rcd  string (variadic)  path to a directory containing runtime configs
   --sha256                           bool               calculates a SHA256 checksum of the output file
+  --skip-validation                  bool               skips checking the metadata for mistakes Ops Manager would reject
   --stemcell-tarball, -st            string             deprecated -- path to a stemcell tarball  (NOTE: mutually exclusive with --kilnfile)
   --stemcells-directory, -sd         string (variadic)  path to a directory containing stemcells  (NOTE: mutually exclusive with --kilnfile or --stemcell-tarball)
   --stub-releases, -sr               bool               skips importing release tarballs into the tile
@@ -122,10 +122,10 @@ stemcell_criteria:
 							RemotePath:   "https://bosh.io/d/github.com/cloudfoundry/capi-release?v=1.88.0",
 						},
 					},
-					Stemcell: cargo.Stemcell{
+					Stemcells: cargo.StemcellCriteria{{
 						OS:      "some-os",
 						Version: "4.5.6",
-					},
+					}},
 				}))
 		})
 
@@ -227,10 +227,10 @@ stemcell_criteria:
 							RemotePath:   "2.8/capi/capi-1.86.0-ubuntu-xenial-456.30.tgz",
 						},
 					},
-					Stemcell: cargo.Stemcell{
+					Stemcells: cargo.StemcellCriteria{{
 						OS:      "ubuntu-xenial",
 						Version: "456.30",
-					},
+					}},
 				}))
 		})
 	})
@@ -157,10 +157,10 @@ stemcell_criteria:
 				Releases: []cargo.ReleaseLock{
 					upgradeStemcell, notCompiledAgainstNewStemcell, remainsBuilt,
 				},
-				Stemcell: cargo.Stemcell{
+				Stemcells: cargo.StemcellCriteria{{
 					OS:      "ubuntu-xenial",
 					Version: "621.51",
-				},
+				}},
 			}))
 	})
 })
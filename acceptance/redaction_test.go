@@ -0,0 +1,57 @@
+package acceptance_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+)
+
+var _ = Context("Masking secrets in output", func() {
+	var tmpDir string
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "redaction")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(ioutil.WriteFile(filepath.Join(tmpDir, "Kilnfile"), []byte(`---
+release_sources:
+- type: s3
+  bucket: compiled-releases
+  path_template: "{{.Name}}-{{.Version}}.tgz"
+  publishable: $(variable "secret_access_key")
+`), 0644)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(tmpDir, "Kilnfile.lock"), []byte(`---
+releases: []
+stemcell_criteria:
+  os: some-os
+  version: "4.5.6"
+`), 0644)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(tmpDir)
+	})
+
+	It("masks the values of variables in errors", func() {
+		command := exec.Command(pathToMain, "fetch",
+			"--kilnfile", filepath.Join(tmpDir, "Kilnfile"),
+			"--releases-directory", tmpDir,
+			"--variable", "secret_access_key=hunter2222",
+		)
+
+		session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+
+		Eventually(session, 10*time.Second).Should(gexec.Exit(1))
+		Expect(session.Err).To(gbytes.Say(`\[redacted\]`))
+		Expect(string(session.Err.Contents())).NotTo(ContainSubstring("hunter2222"))
+	})
+})
@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"time"
 
+	"github.com/pivotal-cf/kiln/fetcher"
 	"github.com/pivotal-cf/kiln/internal/cargo"
 	"gopkg.in/yaml.v2"
 
@@ -21,11 +22,12 @@ import (
 
 var _ = Context("Syncing the Kilnfile.lock to releases on disk", func() {
 	var (
-		previousKilnfileLock string
-		kilnfileLockPath     string
-		kilnfilePath         string
-		releasesDirPath      string
-		expectedReleaseSHA   string
+		previousKilnfileLock  string
+		kilnfileLockPath      string
+		kilnfilePath          string
+		releasesDirPath       string
+		expectedReleaseSHA    string
+		expectedReleaseSHA256 string
 	)
 
 	const (
@@ -81,6 +83,9 @@ release_sources:
 			"5.3.6",
 			osfs.New(""))
 		Expect(err).NotTo(HaveOccurred())
+
+		_, expectedReleaseSHA256, err = fetcher.CalculateSums(fmt.Sprintf("%s/loggregator-agent-5.3.6.tgz", loggregatorReleaseDirPath), osfs.New(""))
+		Expect(err).NotTo(HaveOccurred())
 	})
 
 	It("updates the Kilnfile.lock", func() {
@@ -113,6 +118,7 @@ release_sources:
 						Name:         "loggregator-agent",
 						Version:      "5.3.6",
 						SHA1:         expectedReleaseSHA,
+						SHA256:       expectedReleaseSHA256,
 						RemoteSource: "compiled-releases",
 						RemotePath:   "2.8/loggregator-agent/loggregator-agent-5.3.6-some-os-4.5.6.tgz",
 					},
@@ -124,10 +130,10 @@ release_sources:
 						RemotePath:   "https://bosh.io/d/github.com/cloudfoundry/capi-release?v=1.87.0",
 					},
 				},
-				Stemcell: cargo.Stemcell{
+				Stemcells: cargo.StemcellCriteria{{
 					OS:      "some-os",
 					Version: "4.5.6",
-				},
+				}},
 			}))
 	})
 })
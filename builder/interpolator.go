@@ -28,6 +28,15 @@ type InterpolateInput struct {
 	PropertyBlueprints map[string]interface{}
 	RuntimeConfigs     map[string]interface{}
 	StubReleases       bool
+	Credentials        CredentialProvider
+}
+
+// CredentialProvider looks up the secrets a Kilnfile references with the env,
+// file and credential template functions.
+type CredentialProvider interface {
+	Env(name string) (string, error)
+	File(path string) (string, error)
+	Credential(path, key string) (string, error)
 }
 
 func NewInterpolator() Interpolator {
@@ -151,6 +160,42 @@ func (i Interpolator) interpolate(input InterpolateInput, templateYAML []byte) (
 			}
 			return i.interpolateValueIntoYAML(input, val)
 		},
+		"env": func(name string) (string, error) {
+			if input.Credentials == nil {
+				return "", errors.New("env can only be used in a Kilnfile")
+			}
+			val, err := input.Credentials.Env(name)
+			if err != nil {
+				return "", err
+			}
+			return i.interpolateValueIntoYAML(input, val)
+		},
+		"file": func(path string) (string, error) {
+			if input.Credentials == nil {
+				return "", errors.New("file can only be used in a Kilnfile")
+			}
+			val, err := input.Credentials.File(path)
+			if err != nil {
+				return "", err
+			}
+			return i.interpolateValueIntoYAML(input, val)
+		},
+		"credential": func(path string, key ...string) (string, error) {
+			if input.Credentials == nil {
+				return "", errors.New("credential can only be used in a Kilnfile")
+			}
+			if len(key) > 1 {
+				return "", fmt.Errorf("credential takes a path and at most one key, got %d keys", len(key))
+			}
+			if len(key) == 0 {
+				key = []string{"value"}
+			}
+			val, err := input.Credentials.Credential(path, key[0])
+			if err != nil {
+				return "", err
+			}
+			return i.interpolateValueIntoYAML(input, val)
+		},
 		"icon": func() (string, error) {
 			if input.IconImage == "" {
 				return "", fmt.Errorf("--icon must be specified")
@@ -1,6 +1,8 @@
 package builder_test
 
 import (
+	"fmt"
+
 	. "github.com/pivotal-cf/kiln/builder"
 	yaml "gopkg.in/yaml.v2"
 
@@ -391,6 +393,38 @@ some_runtime_configs:
 		})
 	})
 
+	Context("when a credential provider is given", func() {
+		BeforeEach(func() {
+			input.Credentials = stubCredentials{
+				"env:AWS_KEY":            "some-key",
+				"file:/run/secrets/x":    "some: secret",
+				"credential:s3#value":    "some-credential",
+				"credential:s3#password": "some-password",
+			}
+		})
+
+		It("interpolates env, file and credential lookups", func() {
+			interpolatedYAML, err := NewInterpolator().Interpolate(input, []byte(`
+key: $(env "AWS_KEY")
+secret: $(file "/run/secrets/x")
+credential: $(credential "s3")
+password: $(credential "s3" "password")
+`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(interpolatedYAML).To(HelpfullyMatchYAML(`
+key: some-key
+secret: "some: secret"
+credential: some-credential
+password: some-password
+`))
+		})
+
+		It("returns lookup errors", func() {
+			_, err := NewInterpolator().Interpolate(input, []byte(`key: $(env "MISSING")`))
+			Expect(err).To(MatchError(ContainSubstring(`"env:MISSING" not found`)))
+		})
+	})
+
 	Context("failure cases", func() {
 		Context("when the requested form name is not found", func() {
 			It("returns an error", func() {
@@ -649,6 +683,14 @@ stemcell_criteria: $( stemcell "windows" )
 			})
 		})
 
+		Context("when the env helper is used without a credential provider", func() {
+			It("returns an error", func() {
+				_, err := NewInterpolator().Interpolate(input, []byte(`key: $(env "AWS_KEY")`))
+
+				Expect(err).To(MatchError(ContainSubstring("env can only be used in a Kilnfile")))
+			})
+		})
+
 		Context("input to regexReplaceAll is not valid regex", func() {
 			It("returns an error", func() {
 				interpolator := NewInterpolator()
@@ -660,3 +702,21 @@ stemcell_criteria: $( stemcell "windows" )
 		})
 	})
 })
+
+type stubCredentials map[string]string
+
+func (c stubCredentials) lookup(key string) (string, error) {
+	val, ok := c[key]
+	if !ok {
+		return "", fmt.Errorf("%q not found", key)
+	}
+	return val, nil
+}
+
+func (c stubCredentials) Env(name string) (string, error) { return c.lookup("env:" + name) }
+
+func (c stubCredentials) File(path string) (string, error) { return c.lookup("file:" + path) }
+
+func (c stubCredentials) Credential(path, key string) (string, error) {
+	return c.lookup("credential:" + path + "#" + key)
+}
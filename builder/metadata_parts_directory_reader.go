@@ -18,7 +18,7 @@ type MetadataPartsDirectoryReader struct {
 }
 
 type Part struct {
-	File     string
+	File     string // relative to the directory that was read
 	Name     string
 	Metadata interface{}
 }
@@ -85,7 +85,12 @@ func (r MetadataPartsDirectoryReader) readMetadataRecursivelyFromDir(path string
 			}
 		}
 
-		parts, err = r.readMetadataIntoParts(pathpkg.Base(filePath), vars, parts)
+		relativePath, err := filepath.Rel(path, filePath)
+		if err != nil {
+			return err // untested
+		}
+
+		parts, err = r.readMetadataIntoParts(relativePath, vars, parts)
 		if err != nil {
 			return fmt.Errorf("file '%s' with top-level key '%s' has an invalid format: %s", filePath, r.topLevelKey, err)
 		}
@@ -125,7 +130,7 @@ func (r MetadataPartsDirectoryReader) readMetadataIntoParts(fileName string, var
 	return parts, nil
 }
 
-func (r MetadataPartsDirectoryReader) buildPartFromMetadata(metadata map[interface{}]interface{}, legacyFilename string) (Part, error) {
+func (r MetadataPartsDirectoryReader) buildPartFromMetadata(metadata map[interface{}]interface{}, fileName string) (Part, error) {
 	name, ok := metadata["alias"].(string)
 	if !ok {
 		name, ok = metadata["name"].(string)
@@ -135,7 +140,7 @@ func (r MetadataPartsDirectoryReader) buildPartFromMetadata(metadata map[interfa
 	}
 	delete(metadata, "alias")
 
-	return Part{File: legacyFilename, Name: name, Metadata: metadata}, nil
+	return Part{File: fileName, Name: name, Metadata: metadata}, nil
 }
 
 func (r MetadataPartsDirectoryReader) orderWithOrderFromFile(path string, parts []Part) ([]Part, error) {
@@ -8,7 +8,9 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
+	"time"
 )
 
 type TileWriter struct {
@@ -32,6 +34,7 @@ type zipper interface {
 	Add(path string, file io.Reader) error
 	AddWithMode(path string, file io.Reader, mode os.FileMode) error
 	CreateFolder(path string) error
+	SetModified(modified time.Time)
 	Close() error
 }
 
@@ -51,6 +54,18 @@ type WriteInput struct {
 	MigrationDirectories []string
 	ReleaseDirectories   []string
 	EmbedPaths           []string
+
+	// Reproducible makes the tile depend only on its contents: entries are
+	// sorted, modified at SourceDate, and have normalized file modes.
+	Reproducible bool
+	SourceDate   time.Time
+}
+
+// tileEntry is a file on disk to be added to the tile.
+type tileEntry struct {
+	path     string
+	filePath string
+	mode     os.FileMode
 }
 
 type tileMetadata struct {
@@ -71,6 +86,9 @@ func (w TileWriter) Write(generatedMetadataContents []byte, input WriteInput) er
 	defer f.Close()
 
 	w.zipper.SetWriter(f)
+	if input.Reproducible {
+		w.zipper.SetModified(input.SourceDate)
+	}
 
 	err = w.addToZipper(filepath.Join("metadata", "metadata.yml"), bytes.NewBuffer(generatedMetadataContents), input.OutputFile)
 	if err != nil {
@@ -78,7 +96,7 @@ func (w TileWriter) Write(generatedMetadataContents []byte, input WriteInput) er
 		return err
 	}
 
-	err = w.addMigrations(input.MigrationDirectories, input.OutputFile)
+	err = w.addMigrations(input.MigrationDirectories, input.OutputFile, input.Reproducible)
 	if err != nil {
 		w.removeOutputFile(input.OutputFile)
 		return err
@@ -87,14 +105,14 @@ func (w TileWriter) Write(generatedMetadataContents []byte, input WriteInput) er
 	if input.StubReleases {
 		err = w.addStubReleases(generatedMetadataContents, input.OutputFile)
 	} else {
-		err = w.addReleases(input.ReleaseDirectories, input.OutputFile)
+		err = w.addReleases(input.ReleaseDirectories, input.OutputFile, input.Reproducible)
 	}
 	if err != nil {
 		w.removeOutputFile(input.OutputFile)
 		return err
 	}
 
-	err = w.addEmbeddedPaths(input.EmbedPaths, input.OutputFile)
+	err = w.addEmbeddedPaths(input.EmbedPaths, input.OutputFile, input.Reproducible)
 	if err != nil {
 		w.removeOutputFile(input.OutputFile)
 		return err
@@ -109,15 +127,17 @@ func (w TileWriter) Write(generatedMetadataContents []byte, input WriteInput) er
 	return nil
 }
 
-func (w TileWriter) addReleases(releasesDirs []string, outputFile string) error {
+func (w TileWriter) addReleases(releasesDirs []string, outputFile string, reproducible bool) error {
+	var entries []tileEntry
 	for _, releasesDirectory := range releasesDirs {
-		err := w.addReleaseTarballs(releasesDirectory, outputFile)
+		releaseEntries, err := w.releaseTarballs(releasesDirectory)
 		if err != nil {
 			return err
 		}
+		entries = append(entries, releaseEntries...)
 	}
 
-	return nil
+	return w.addEntries(entries, outputFile, reproducible)
 }
 
 func (w TileWriter) addStubReleases(generatedMetadataContents []byte, outputFile string) error {
@@ -137,8 +157,9 @@ func (w TileWriter) addStubReleases(generatedMetadataContents []byte, outputFile
 	return nil
 }
 
-func (w TileWriter) addReleaseTarballs(releasesDir string, outputFile string) error {
-	return w.filesystem.Walk(releasesDir, func(filePath string, info os.FileInfo, err error) error {
+func (w TileWriter) releaseTarballs(releasesDir string) ([]tileEntry, error) {
+	var entries []tileEntry
+	err := w.filesystem.Walk(releasesDir, func(filePath string, info os.FileInfo, err error) error {
 		isTarball, _ := regexp.MatchString("tgz$|tar.gz$", filePath)
 		if !isTarball {
 			return nil
@@ -152,30 +173,34 @@ func (w TileWriter) addReleaseTarballs(releasesDir string, outputFile string) er
 			return nil
 		}
 
-		file := ioutil.NopCloser(strings.NewReader(""))
-		file, err = w.filesystem.Open(filePath)
-		if err != nil {
-			return err
-		}
-		defer file.Close()
-
-		return w.addToZipper(filepath.Join("releases", filepath.Base(filePath)), file, outputFile)
+		entries = append(entries, tileEntry{path: filepath.Join("releases", filepath.Base(filePath)), filePath: filePath})
+		return nil
 	})
+	return entries, err
 }
 
-func (w TileWriter) addEmbeddedPaths(embedPaths []string, outputFile string) error {
+func (w TileWriter) addEmbeddedPaths(embedPaths []string, outputFile string, reproducible bool) error {
+	var entries []tileEntry
 	for _, embedPath := range embedPaths {
-		err := w.addEmbeddedPath(embedPath, outputFile)
+		embedEntries, err := w.embeddedPath(embedPath)
 		if err != nil {
 			return err
 		}
+		entries = append(entries, embedEntries...)
 	}
 
-	return nil
+	if reproducible {
+		for i := range entries {
+			entries[i].mode = normalizedMode(entries[i].mode)
+		}
+	}
+
+	return w.addEntries(entries, outputFile, reproducible)
 }
 
-func (w TileWriter) addEmbeddedPath(pathToEmbed, outputFile string) error {
-	return w.filesystem.Walk(pathToEmbed, func(filePath string, info os.FileInfo, err error) error {
+func (w TileWriter) embeddedPath(pathToEmbed string) ([]tileEntry, error) {
+	var entries []tileEntry
+	err := w.filesystem.Walk(pathToEmbed, func(filePath string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -184,24 +209,20 @@ func (w TileWriter) addEmbeddedPath(pathToEmbed, outputFile string) error {
 			return nil
 		}
 
-		file, err := w.filesystem.Open(filePath)
-		if err != nil {
-			return err
-		}
-		defer file.Close()
-
 		relativePath, err := filepath.Rel(pathToEmbed, filePath)
 		if err != nil {
 			return err //not tested
 		}
 
 		entryPath := filepath.Join("embed", filepath.Join(filepath.Base(pathToEmbed), relativePath))
-		return w.addToZipperWithMode(entryPath, file, info.Mode(), outputFile)
+		entries = append(entries, tileEntry{path: entryPath, filePath: filePath, mode: info.Mode()})
+		return nil
 	})
+	return entries, err
 }
 
-func (w TileWriter) addMigrations(migrationsDir []string, outputFile string) error {
-	var found bool
+func (w TileWriter) addMigrations(migrationsDir []string, outputFile string, reproducible bool) error {
+	var entries []tileEntry
 
 	for _, migrationDir := range migrationsDir {
 		err := w.filesystem.Walk(migrationDir, func(filePath string, info os.FileInfo, err error) error {
@@ -221,15 +242,8 @@ func (w TileWriter) addMigrations(migrationsDir []string, outputFile string) err
 				return nil
 			}
 
-			found = true
-
-			file, err := w.filesystem.Open(filePath)
-			if err != nil {
-				return err
-			}
-			defer file.Close()
-
-			return w.addToZipper(filepath.Join("migrations", "v1", filepath.Base(filePath)), file, outputFile)
+			entries = append(entries, tileEntry{path: filepath.Join("migrations", "v1", filepath.Base(filePath)), filePath: filePath})
+			return nil
 		})
 
 		if err != nil {
@@ -237,13 +251,54 @@ func (w TileWriter) addMigrations(migrationsDir []string, outputFile string) err
 		}
 	}
 
-	if !found {
+	if len(entries) == 0 {
 		return w.addEmptyMigrationsDirectory(outputFile)
 	}
 
+	return w.addEntries(entries, outputFile, reproducible)
+}
+
+// addEntries adds files in the order they were found, or sorted by their path
+// in the tile for reproducible tiles.
+func (w TileWriter) addEntries(entries []tileEntry, outputFile string, reproducible bool) error {
+	if reproducible {
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].path < entries[j].path
+		})
+	}
+
+	for _, entry := range entries {
+		err := w.addEntry(entry, outputFile)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+func (w TileWriter) addEntry(entry tileEntry, outputFile string) error {
+	file, err := w.filesystem.Open(entry.filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if entry.mode == 0 {
+		return w.addToZipper(entry.path, file, outputFile)
+	}
+	return w.addToZipperWithMode(entry.path, file, entry.mode, outputFile)
+}
+
+// normalizedMode keeps only whether a file is executable, so the tile does not
+// depend on the umask of the machine it was baked on.
+func normalizedMode(mode os.FileMode) os.FileMode {
+	if mode&0111 != 0 {
+		return 0755
+	}
+	return 0644
+}
+
 func (w TileWriter) addToZipper(path string, contents io.Reader, outputFile string) error {
 	w.logger.Printf("Adding %s to %s...", path, outputFile)
 
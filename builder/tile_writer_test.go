@@ -7,6 +7,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"time"
 
 	. "github.com/pivotal-cf/kiln/builder"
 	"github.com/pivotal-cf/kiln/builder/fakes"
@@ -448,6 +449,63 @@ releases:
 			})
 		})
 
+		Context("when the tile is reproducible", func() {
+			BeforeEach(func() {
+				dirInfo := &fakes.FileInfo{}
+				dirInfo.IsDirReturns(true)
+
+				fileInfo := &fakes.FileInfo{}
+				fileInfo.IsDirReturns(false)
+				fileInfo.ModeReturns(0700)
+
+				filesystem.WalkStub = func(root string, walkFn filepath.WalkFunc) error {
+					switch root {
+					case "/some/path/releases":
+						walkFn(root, dirInfo, nil)
+						walkFn(filepath.Join(root, "zookeeper-1.tgz"), fileInfo, nil)
+					case "/some/other/path/releases":
+						walkFn(root, dirInfo, nil)
+						walkFn(filepath.Join(root, "bpm-1.tgz"), fileInfo, nil)
+					case "/some/path/to-embed":
+						walkFn(root, dirInfo, nil)
+						walkFn(filepath.Join(root, "my-file.txt"), fileInfo, nil)
+					}
+					return nil
+				}
+
+				filesystem.OpenStub = func(path string) (io.ReadCloser, error) {
+					return NewBuffer(bytes.NewBufferString("contents")), nil
+				}
+			})
+
+			It("sorts entries, fixes their modified time, and normalizes modes", func() {
+				sourceDate := time.Date(2020, time.March, 4, 5, 6, 7, 0, time.UTC)
+				input := WriteInput{
+					ReleaseDirectories: []string{"/some/path/releases", "/some/other/path/releases"},
+					EmbedPaths:         []string{"/some/path/to-embed"},
+					OutputFile:         outputFile,
+					Reproducible:       true,
+					SourceDate:         sourceDate,
+				}
+
+				err := tileWriter.Write([]byte("generated-metadata-contents"), input)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(zipper.SetModifiedCallCount()).To(Equal(1))
+				Expect(zipper.SetModifiedArgsForCall(0)).To(Equal(sourceDate))
+
+				Expect(zipper.AddCallCount()).To(Equal(3))
+				path, _ := zipper.AddArgsForCall(1)
+				Expect(path).To(Equal(filepath.Join("releases", "bpm-1.tgz")))
+				path, _ = zipper.AddArgsForCall(2)
+				Expect(path).To(Equal(filepath.Join("releases", "zookeeper-1.tgz")))
+
+				path, _, mode := zipper.AddWithModeArgsForCall(0)
+				Expect(path).To(Equal(filepath.Join("embed", "to-embed", "my-file.txt")))
+				Expect(mode).To(Equal(os.FileMode(0755)))
+			})
+		})
+
 		Context("failure cases", func() {
 			Context("when creating the zip file fails", func() {
 				BeforeEach(func() {
@@ -11,7 +11,8 @@ import (
 )
 
 type Zipper struct {
-	writer *zip.Writer
+	writer   *zip.Writer
+	modified time.Time
 }
 
 func NewZipper() Zipper {
@@ -22,6 +23,19 @@ func (z *Zipper) SetWriter(writer io.Writer) {
 	z.writer = zip.NewWriter(writer)
 }
 
+// SetModified fixes the modification time of every entry added afterwards,
+// instead of using the time each entry is added.
+func (z *Zipper) SetModified(modified time.Time) {
+	z.modified = modified
+}
+
+func (z Zipper) modifiedTime() time.Time {
+	if z.modified.IsZero() {
+		return time.Now()
+	}
+	return z.modified
+}
+
 func (z Zipper) Add(path string, file io.Reader) error {
 	if z.writer == nil {
 		return errors.New("zipper path must be set")
@@ -30,7 +44,7 @@ func (z Zipper) Add(path string, file io.Reader) error {
 	return z.add(&zip.FileHeader{
 		Name:     path,
 		Method:   zip.Store,
-		Modified: time.Now(),
+		Modified: z.modifiedTime(),
 	}, file)
 }
 
@@ -42,7 +56,7 @@ func (z Zipper) AddWithMode(path string, file io.Reader, mode os.FileMode) error
 	fh := &zip.FileHeader{
 		Name:     path,
 		Method:   zip.Store,
-		Modified: time.Now(),
+		Modified: z.modifiedTime(),
 	}
 	fh.SetMode(mode)
 
@@ -76,7 +90,7 @@ func (z Zipper) CreateFolder(path string) error {
 
 	fh := &zip.FileHeader{
 		Name:     path,
-		Modified: time.Now(),
+		Modified: z.modifiedTime(),
 	}
 	_, err := z.writer.CreateHeader(fh)
 	if err != nil {
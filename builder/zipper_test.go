@@ -169,6 +169,28 @@ var _ = Describe("Zipper", func() {
 			Expect(reader.File[0].FileHeader.Modified).To(BeTemporally("~", time.Now(), time.Minute))
 		})
 
+		Context("when the modified time is set", func() {
+			It("uses it for the file", func() {
+				modified := time.Date(2020, time.March, 4, 5, 6, 7, 0, time.UTC)
+
+				zipper := NewZipper()
+				zipper.SetWriter(tileFile)
+				zipper.SetModified(modified)
+
+				err := zipper.AddWithMode("some/path/to/file.txt", strings.NewReader("file contents"), 0755)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = zipper.Close()
+				Expect(err).NotTo(HaveOccurred())
+
+				reader, err := zip.OpenReader(pathToTile)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(reader.File[0].FileHeader.Modified.Equal(modified)).To(BeTrue())
+				Expect(reader.File[0].FileHeader.Mode()).To(Equal(os.FileMode(0755)))
+			})
+		})
+
 		Context("failure cases", func() {
 			Context("when the file cannot be copied", func() {
 				It("returns an error", func() {
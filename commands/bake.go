@@ -1,12 +1,24 @@
 package commands
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/pivotal-cf/jhanda"
+	"gopkg.in/src-d/go-billy.v4"
+
 	"github.com/pivotal-cf/kiln/builder"
+	"github.com/pivotal-cf/kiln/internal/baking"
+	"github.com/pivotal-cf/kiln/internal/cargo"
+	"github.com/pivotal-cf/kiln/proofing"
 )
 
 //go:generate counterfeiter -o ./fakes/interpolator.go --fake-name Interpolator . interpolator
@@ -32,7 +44,6 @@ type releasesService interface {
 //go:generate counterfeiter -o ./fakes/stemcell_service.go --fake-name StemcellService . stemcellService
 type stemcellService interface {
 	FromDirectories(directories []string) (stemcell map[string]interface{}, err error)
-	FromKilnfile(path string) (stemcell map[string]interface{}, err error)
 	FromTarball(path string) (stemcell interface{}, err error)
 }
 
@@ -81,9 +92,21 @@ type checksummer interface {
 	Sum(path string) error
 }
 
+//go:generate counterfeiter -o ./fakes/tile_manifest_writer.go --fake-name TileManifestWriter . tileManifestWriter
+type tileManifestWriter interface {
+	Write(formats []string, generatedMetadataContents []byte, interpolateInput builder.InterpolateInput, writeInput builder.WriteInput) error
+}
+
+//go:generate counterfeiter -o ./fakes/bake_config_loader.go --fake-name BakeConfigLoader . bakeConfigLoader
+type bakeConfigLoader interface {
+	LoadBakeConfig(fs billy.Filesystem, kilnfilePath string) (cargo.BakeConfig, error)
+	LoadStemcellCriteria(fs billy.Filesystem, kilnfilePath string) (cargo.StemcellCriteria, error)
+}
+
 type Bake struct {
 	interpolator      interpolator
 	checksummer       checksummer
+	tileManifest      tileManifestWriter
 	tileWriter        tileWriter
 	outLogger         *log.Logger
 	errLogger         *log.Logger
@@ -98,10 +121,12 @@ type Bake struct {
 	runtimeConfigs    runtimeConfigsService
 	icon              iconService
 	metadata          metadataService
+	fs                billy.Filesystem
+	bakeConfig        bakeConfigLoader
 
 	Options struct {
 		Kilnfile           string   `short:"kf"  long:"kilnfile"                        description:"path to Kilnfile  (NOTE: mutually exclusive with --stemcell-directory)"`
-		Metadata           string   `short:"m"  long:"metadata"           required:"true" description:"path to the metadata file"`
+		Metadata           string   `short:"m"  long:"metadata"                         description:"path to the metadata file (defaults to bake.metadata in the Kilnfile)"`
 		OutputFile         string   `short:"o"  long:"output-file"                        description:"path to where the tile will be output"`
 		ReleaseDirectories []string `short:"rd" long:"releases-directory"               description:"path to a directory containing release tarballs"`
 
@@ -111,11 +136,14 @@ type Bake struct {
 		IconPath                 string   `short:"i"   long:"icon"                      description:"path to icon file"`
 		InstanceGroupDirectories []string `short:"ig"  long:"instance-groups-directory" description:"path to a directory containing instance groups"`
 		JobDirectories           []string `short:"j"   long:"jobs-directory"            description:"path to a directory containing jobs"`
+		Manifests                []string `            long:"manifest"                  description:"writes a manifest of the tile contents next to the output file (kiln, cyclonedx or spdx)"`
 		MetadataOnly             bool     `short:"mo"  long:"metadata-only"             description:"don't build a tile, output the metadata to stdout"`
 		MigrationDirectories     []string `short:"md"  long:"migrations-directory"      description:"path to a directory containing migrations"`
 		PropertyDirectories      []string `short:"pd"  long:"properties-directory"      description:"path to a directory containing property blueprints"`
+		Reproducible             bool     `            long:"reproducible"              description:"bakes the same tile byte for byte from the same inputs, dated SOURCE_DATE_EPOCH when it is set"`
 		RuntimeConfigDirectories []string `short:"rcd" long:"runtime-configs-directory" description:"path to a directory containing runtime configs"`
 		Sha256                   bool     `            long:"sha256"                    description:"calculates a SHA256 checksum of the output file"`
+		SkipValidation           bool     `            long:"skip-validation"           description:"skips checking the metadata for mistakes Ops Manager would reject"`
 		StemcellTarball          string   `short:"st"  long:"stemcell-tarball"          description:"deprecated -- path to a stemcell tarball  (NOTE: mutually exclusive with --kilnfile)"`
 		StemcellsDirectories     []string `short:"sd"  long:"stemcells-directory"       description:"path to a directory containing stemcells  (NOTE: mutually exclusive with --kilnfile or --stemcell-tarball)"`
 		StubReleases             bool     `short:"sr"  long:"stub-releases"             description:"skips importing release tarballs into the tile"`
@@ -142,12 +170,16 @@ func NewBake(
 	iconService iconService,
 	metadataService metadataService,
 	checksummer checksummer,
+	tileManifestWriter tileManifestWriter,
+	fs billy.Filesystem,
+	bakeConfigLoader bakeConfigLoader,
 ) Bake {
 
 	return Bake{
 		interpolator:      interpolator,
 		tileWriter:        tileWriter,
 		checksummer:       checksummer,
+		tileManifest:      tileManifestWriter,
 		outLogger:         outLogger,
 		errLogger:         errLogger,
 		templateVariables: templateVariablesService,
@@ -161,6 +193,8 @@ func NewBake(
 		runtimeConfigs:    runtimeConfigsService,
 		icon:              iconService,
 		metadata:          metadataService,
+		fs:                fs,
+		bakeConfig:        bakeConfigLoader,
 	}
 }
 
@@ -170,6 +204,22 @@ func (b Bake) Execute(args []string) error {
 		return err
 	}
 
+	if b.Options.Kilnfile != "" {
+		config, err := b.bakeConfig.LoadBakeConfig(b.fs, b.Options.Kilnfile)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			// only the Kilnfile.lock is needed when every option is passed as a flag
+		case err != nil:
+			return fmt.Errorf("failed to read bake configuration from Kilnfile: %w", err)
+		default:
+			b.applyBakeConfig(config)
+		}
+	}
+
+	if b.Options.Metadata == "" {
+		return errors.New(`missing required flag "--metadata"`)
+	}
+
 	if len(b.Options.InstanceGroupDirectories) == 0 && len(b.Options.JobDirectories) > 0 {
 		return errors.New("--jobs-directory flag requires --instance-groups-directory to also be specified")
 	}
@@ -194,6 +244,12 @@ func (b Bake) Execute(args []string) error {
 		return errors.New("--output-file cannot be provided when using --metadata-only")
 	}
 
+	for _, format := range b.Options.Manifests {
+		if !isTileManifestFormat(format) {
+			return fmt.Errorf("--manifest must be one of %s, got %q", strings.Join(baking.TileManifestFormats, ", "), format)
+		}
+	}
+
 	// TODO: Remove check after deprecation of --stemcell-tarball
 	if b.Options.StemcellTarball != "" {
 		b.errLogger.Println("warning: --stemcell-tarball is being deprecated in favor of --stemcells-directory")
@@ -210,7 +266,7 @@ func (b Bake) Execute(args []string) error {
 		// TODO remove when stemcell tarball is deprecated
 		stemcellManifest, err = b.stemcell.FromTarball(b.Options.StemcellTarball)
 	} else if b.Options.Kilnfile != "" {
-		stemcellManifests, err = b.stemcell.FromKilnfile(b.Options.Kilnfile)
+		stemcellManifests, err = b.stemcellsFromKilnfile()
 	} else if len(b.Options.StemcellsDirectories) > 0 {
 		stemcellManifests, err = b.stemcell.FromDirectories(b.Options.StemcellsDirectories)
 	}
@@ -263,7 +319,7 @@ func (b Bake) Execute(args []string) error {
 		return fmt.Errorf("failed to read metadata: %s", err)
 	}
 
-	interpolatedMetadata, err := b.interpolator.Interpolate(builder.InterpolateInput{
+	interpolateInput := builder.InterpolateInput{
 		Version:            b.Options.Version,
 		Variables:          templateVariables,
 		BOSHVariables:      boshVariables,
@@ -277,7 +333,9 @@ func (b Bake) Execute(args []string) error {
 		PropertyBlueprints: propertyBlueprints,
 		RuntimeConfigs:     runtimeConfigs,
 		StubReleases:       b.Options.StubReleases,
-	}, metadata)
+	}
+
+	interpolatedMetadata, err := b.interpolator.Interpolate(interpolateInput, metadata)
 	if err != nil {
 		return err
 	}
@@ -287,17 +345,40 @@ func (b Bake) Execute(args []string) error {
 		return nil
 	}
 
-	err = b.tileWriter.Write(interpolatedMetadata, builder.WriteInput{
+	if !b.Options.SkipValidation {
+		err = b.validateMetadata(interpolatedMetadata)
+		if err != nil {
+			return err
+		}
+	}
+
+	writeInput := builder.WriteInput{
 		OutputFile:           b.Options.OutputFile,
 		StubReleases:         b.Options.StubReleases,
 		MigrationDirectories: b.Options.MigrationDirectories,
 		ReleaseDirectories:   b.Options.ReleaseDirectories,
 		EmbedPaths:           b.Options.EmbedPaths,
-	})
+	}
+	if b.Options.Reproducible {
+		writeInput.Reproducible = true
+		writeInput.SourceDate, err = sourceDate()
+		if err != nil {
+			return err
+		}
+	}
+
+	err = b.tileWriter.Write(interpolatedMetadata, writeInput)
 	if err != nil {
 		return err
 	}
 
+	if len(b.Options.Manifests) > 0 {
+		err = b.tileManifest.Write(b.Options.Manifests, interpolatedMetadata, interpolateInput, writeInput)
+		if err != nil {
+			return fmt.Errorf("failed to write tile manifest: %w", err)
+		}
+	}
+
 	if b.Options.Sha256 {
 		err = b.checksummer.Sum(b.Options.OutputFile)
 		if err != nil {
@@ -308,6 +389,52 @@ func (b Bake) Execute(args []string) error {
 	return nil
 }
 
+// applyBakeConfig uses the Kilnfile bake section for every option not set by
+// a flag.
+// stemcellsFromKilnfile reads a stemcell for each OS in the stemcell criteria
+// of the Kilnfile.lock.
+func (b Bake) stemcellsFromKilnfile() (map[string]interface{}, error) {
+	b.errLogger.Printf("Reading stemcell criteria from %s.lock", filepath.Base(b.Options.Kilnfile))
+
+	criteria, err := b.bakeConfig.LoadStemcellCriteria(b.fs, b.Options.Kilnfile)
+	if err != nil {
+		return nil, err
+	}
+
+	stemcellManifests := make(map[string]interface{})
+	for _, stemcell := range criteria {
+		stemcellManifests[stemcell.OS] = stemcell
+	}
+	return stemcellManifests, nil
+}
+
+func (b *Bake) applyBakeConfig(config cargo.BakeConfig) {
+	defaultString := func(option *string, value string) {
+		if *option == "" {
+			*option = value
+		}
+	}
+	defaultStrings := func(option *[]string, value []string) {
+		if len(*option) == 0 {
+			*option = value
+		}
+	}
+
+	defaultString(&b.Options.Metadata, config.Metadata)
+	defaultString(&b.Options.OutputFile, config.OutputFile)
+	defaultString(&b.Options.IconPath, config.Icon)
+	defaultStrings(&b.Options.ReleaseDirectories, config.ReleasesDirectories)
+	defaultStrings(&b.Options.BOSHVariableDirectories, config.BOSHVariablesDirectories)
+	defaultStrings(&b.Options.FormDirectories, config.FormsDirectories)
+	defaultStrings(&b.Options.InstanceGroupDirectories, config.InstanceGroupsDirectories)
+	defaultStrings(&b.Options.JobDirectories, config.JobsDirectories)
+	defaultStrings(&b.Options.PropertyDirectories, config.PropertiesDirectories)
+	defaultStrings(&b.Options.RuntimeConfigDirectories, config.RuntimeConfigsDirectories)
+	defaultStrings(&b.Options.MigrationDirectories, config.MigrationsDirectories)
+	defaultStrings(&b.Options.EmbedPaths, config.Embed)
+	defaultStrings(&b.Options.VariableFiles, config.VariablesFiles)
+}
+
 func (b Bake) Usage() jhanda.Usage {
 	return jhanda.Usage{
 		Description:      "Bakes tile metadata, stemcell, releases, and migrations into a format that can be consumed by OpsManager.",
@@ -315,3 +442,116 @@ func (b Bake) Usage() jhanda.Usage {
 		Flags:            b.Options,
 	}
 }
+
+func isTileManifestFormat(format string) bool {
+	for _, known := range baking.TileManifestFormats {
+		if format == known {
+			return true
+		}
+	}
+	return false
+}
+
+// sourceDate is the modification time of every entry in a reproducible tile:
+// SOURCE_DATE_EPOCH when it is set, otherwise the earliest time a zip holds.
+func sourceDate() (time.Time, error) {
+	epoch := os.Getenv("SOURCE_DATE_EPOCH")
+	if epoch == "" {
+		return time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC), nil
+	}
+
+	seconds, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("SOURCE_DATE_EPOCH must be a number of seconds since the Unix epoch: %w", err)
+	}
+
+	return time.Unix(seconds, 0).UTC(), nil
+}
+
+// MetadataPartError is a validation error found in the baked metadata along
+// with the metadata part file that most likely caused it.
+type MetadataPartError struct {
+	File string
+	Err  error
+}
+
+func (e MetadataPartError) Error() string {
+	return fmt.Sprintf("%s: %s", e.File, e.Err)
+}
+
+func (e MetadataPartError) Unwrap() error {
+	return e.Err
+}
+
+func (b Bake) validateMetadata(interpolatedMetadata []byte) error {
+	productTemplate, err := proofing.Parse(bytes.NewReader(interpolatedMetadata))
+	if err != nil {
+		return fmt.Errorf("failed to parse interpolated metadata: %w", err)
+	}
+
+	err = productTemplate.Validate()
+	if err == nil {
+		return nil
+	}
+
+	validationErrors := proofing.CompoundError{err}
+	if compoundError, ok := err.(*proofing.CompoundError); ok {
+		validationErrors = *compoundError
+	}
+
+	var partErrors proofing.CompoundError
+	for _, validationError := range validationErrors {
+		partErrors.Add(MetadataPartError{File: b.metadataPartFile(validationError), Err: validationError})
+	}
+
+	return fmt.Errorf("tile metadata is not valid (use --skip-validation to bake it anyway):\n%w", &partErrors)
+}
+
+// metadataPartFile finds the part file defining the object a validation error
+// is about, falling back to the metadata file for everything else.
+func (b Bake) metadataPartFile(err error) string {
+	validationError, ok := err.(proofing.ValidationError)
+	if !ok {
+		return b.Options.Metadata
+	}
+
+	var directories []string
+	switch validationError.Kind.(type) {
+	case proofing.JobType:
+		directories = b.Options.InstanceGroupDirectories
+	case proofing.Template:
+		directories = b.Options.JobDirectories
+	case proofing.FormType:
+		directories = b.Options.FormDirectories
+	case proofing.SimplePropertyBlueprint, proofing.SelectorPropertyBlueprint, proofing.CollectionPropertyBlueprint:
+		directories = b.Options.PropertyDirectories
+	case proofing.RuntimeConfigTemplate:
+		directories = b.Options.RuntimeConfigDirectories
+	}
+
+	kind := reflect.ValueOf(validationError.Kind)
+	if kind.Kind() != reflect.Struct {
+		return b.Options.Metadata
+	}
+	nameField := kind.FieldByName("Name")
+	if !nameField.IsValid() || nameField.String() == "" {
+		return b.Options.Metadata
+	}
+	name := nameField.String()
+
+	for _, directory := range directories {
+		parts, err := builder.NewMetadataPartsDirectoryReader().Read(directory)
+		if err != nil {
+			continue
+		}
+
+		for _, part := range parts {
+			metadata, _ := part.Metadata.(map[interface{}]interface{})
+			if part.Name == name || metadata["name"] == name {
+				return filepath.Join(directory, part.File)
+			}
+		}
+	}
+
+	return b.Options.Metadata
+}
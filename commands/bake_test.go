@@ -6,11 +6,15 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/pivotal-cf/jhanda"
 	"github.com/pivotal-cf/kiln/builder"
 	. "github.com/pivotal-cf/kiln/commands"
 	"github.com/pivotal-cf/kiln/commands/fakes"
+	"github.com/pivotal-cf/kiln/internal/cargo"
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/memfs"
 	"gopkg.in/yaml.v2"
 
 	. "github.com/onsi/ginkgo"
@@ -19,6 +23,13 @@ import (
 )
 
 var _ = Describe("Bake", func() {
+	const someInterpolatedMetadata = `---
+name: some-product
+label: Some Product
+product_version: 1.2.3
+metadata_version: "2.7"
+`
+
 	var (
 		fakeBOSHVariablesService     *fakes.BOSHVariablesService
 		fakeFormsService             *fakes.FormsService
@@ -35,6 +46,9 @@ var _ = Describe("Bake", func() {
 		fakeTemplateVariablesService *fakes.TemplateVariablesService
 		fakeTileWriter               *fakes.TileWriter
 		fakeChecksummer              *fakes.Checksummer
+		fakeTileManifestWriter       *fakes.TileManifestWriter
+		fakeBakeConfigLoader         *fakes.BakeConfigLoader
+		filesystem                   billy.Filesystem
 
 		otherReleasesDirectory string
 		someReleasesDirectory  string
@@ -73,6 +87,9 @@ var _ = Describe("Bake", func() {
 		fakeTemplateVariablesService = &fakes.TemplateVariablesService{}
 		fakeTileWriter = &fakes.TileWriter{}
 		fakeChecksummer = &fakes.Checksummer{}
+		fakeTileManifestWriter = &fakes.TileManifestWriter{}
+		fakeBakeConfigLoader = &fakes.BakeConfigLoader{}
+		filesystem = memfs.New()
 
 		fakeTemplateVariablesService.FromPathsAndPairsReturns(map[string]interface{}{
 			"some-variable-from-file": "some-variable-value-from-file",
@@ -148,7 +165,7 @@ var _ = Describe("Bake", func() {
 
 		fakeMetadataService.ReadReturns([]byte("some-metadata"), nil)
 
-		fakeInterpolator.InterpolateReturns([]byte("some-interpolated-metadata"), nil)
+		fakeInterpolator.InterpolateReturns([]byte(someInterpolatedMetadata), nil)
 
 		bake = NewBake(
 			fakeInterpolator,
@@ -167,6 +184,9 @@ var _ = Describe("Bake", func() {
 			fakeIconService,
 			fakeMetadataService,
 			fakeChecksummer,
+			fakeTileManifestWriter,
+			filesystem,
+			fakeBakeConfigLoader,
 		)
 	})
 
@@ -314,7 +334,7 @@ var _ = Describe("Bake", func() {
 
 			Expect(fakeTileWriter.WriteCallCount()).To(Equal(1))
 			metadata, writeInput := fakeTileWriter.WriteArgsForCall(0)
-			Expect(string(metadata)).To(Equal("some-interpolated-metadata"))
+			Expect(string(metadata)).To(Equal(someInterpolatedMetadata))
 			Expect(writeInput).To(Equal(builder.WriteInput{
 				OutputFile:           filepath.Join("some-output-dir", "some-product-file-1.2.3-build.4"),
 				StubReleases:         false,
@@ -328,6 +348,114 @@ var _ = Describe("Bake", func() {
 			Expect(outputFilePath).To(Equal(filepath.Join("some-output-dir", "some-product-file-1.2.3-build.4")))
 		})
 
+		Context("when the --reproducible flag is specified", func() {
+			var args []string
+
+			BeforeEach(func() {
+				args = []string{
+					"--metadata", "some-metadata",
+					"--output-file", "some-output-dir/some-product-file-1.2.3-build.4",
+					"--releases-directory", someReleasesDirectory,
+					"--version", "1.2.3",
+					"--reproducible",
+				}
+			})
+
+			AfterEach(func() {
+				Expect(os.Unsetenv("SOURCE_DATE_EPOCH")).To(Succeed())
+			})
+
+			It("writes a reproducible tile dated SOURCE_DATE_EPOCH", func() {
+				Expect(os.Setenv("SOURCE_DATE_EPOCH", "1583298367")).To(Succeed())
+
+				err := bake.Execute(args)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, input := fakeTileWriter.WriteArgsForCall(0)
+				Expect(input.Reproducible).To(BeTrue())
+				Expect(input.SourceDate).To(Equal(time.Date(2020, time.March, 4, 5, 6, 7, 0, time.UTC)))
+			})
+
+			It("dates the tile at the start of 1980 without SOURCE_DATE_EPOCH", func() {
+				Expect(os.Unsetenv("SOURCE_DATE_EPOCH")).To(Succeed())
+
+				err := bake.Execute(args)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, input := fakeTileWriter.WriteArgsForCall(0)
+				Expect(input.SourceDate).To(Equal(time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)))
+			})
+
+			It("returns an error when SOURCE_DATE_EPOCH is not a number", func() {
+				Expect(os.Setenv("SOURCE_DATE_EPOCH", "yesterday")).To(Succeed())
+
+				err := bake.Execute(args)
+				Expect(err).To(MatchError(ContainSubstring("SOURCE_DATE_EPOCH must be a number of seconds")))
+				Expect(fakeTileWriter.WriteCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the --manifest flag is specified", func() {
+			var args []string
+
+			BeforeEach(func() {
+				args = []string{
+					"--embed", "some-embed-path",
+					"--metadata", "some-metadata",
+					"--output-file", "some-output-dir/some-product-file-1.2.3-build.4",
+					"--releases-directory", someReleasesDirectory,
+					"--version", "1.2.3",
+					"--manifest", "kiln",
+					"--manifest", "spdx",
+				}
+			})
+
+			It("writes a manifest of the tile in each format", func() {
+				err := bake.Execute(args)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeTileManifestWriter.WriteCallCount()).To(Equal(1))
+				formats, metadata, interpolateInput, writeInput := fakeTileManifestWriter.WriteArgsForCall(0)
+				Expect(formats).To(Equal([]string{"kiln", "spdx"}))
+				Expect(metadata).To(Equal([]byte(someInterpolatedMetadata)))
+
+				expectedInterpolateInput, _ := fakeInterpolator.InterpolateArgsForCall(0)
+				Expect(interpolateInput).To(Equal(expectedInterpolateInput))
+
+				_, expectedWriteInput := fakeTileWriter.WriteArgsForCall(0)
+				Expect(writeInput).To(Equal(expectedWriteInput))
+			})
+
+			It("returns an error for an unknown format before baking", func() {
+				args = append(args, "--manifest", "swid")
+
+				err := bake.Execute(args)
+				Expect(err).To(MatchError(`--manifest must be one of kiln, cyclonedx, spdx, got "swid"`))
+				Expect(fakeTileWriter.WriteCallCount()).To(Equal(0))
+			})
+
+			It("returns an error when the manifest cannot be written", func() {
+				fakeTileManifestWriter.WriteReturns(errors.New("disk full"))
+
+				err := bake.Execute(args)
+				Expect(err).To(MatchError("failed to write tile manifest: disk full"))
+			})
+		})
+
+		Context("when the --manifest flag is not specified", func() {
+			It("does not write a manifest", func() {
+				err := bake.Execute([]string{
+					"--metadata", "some-metadata",
+					"--output-file", "some-output-dir/some-product-file-1.2.3-build.4",
+					"--releases-directory", someReleasesDirectory,
+					"--version", "1.2.3",
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeTileManifestWriter.WriteCallCount()).To(Equal(0))
+			})
+		})
+
 		Context("when the --sha256 flag is not specified", func() {
 			It("does not calculate a checksum", func() {
 				err := bake.Execute([]string{
@@ -416,7 +544,7 @@ var _ = Describe("Bake", func() {
 				Expect(err).NotTo(HaveOccurred())
 
 				generatedMetadataContents, _ := fakeTileWriter.WriteArgsForCall(0)
-				Expect(generatedMetadataContents).To(HelpfullyMatchYAML("some-interpolated-metadata"))
+				Expect(generatedMetadataContents).To(HelpfullyMatchYAML(someInterpolatedMetadata))
 			})
 		})
 
@@ -440,6 +568,13 @@ var _ = Describe("Bake", func() {
 		})
 
 		Context("when Kilnfile is specified", func() {
+			BeforeEach(func() {
+				fakeBakeConfigLoader.LoadStemcellCriteriaReturns(cargo.StemcellCriteria{
+					{OS: "ubuntu-xenial", Version: "621.55"},
+					{OS: "ubuntu-jammy", Version: "1.18"},
+				}, nil)
+			})
+
 			It("renders the stemcell criteria in tile metadata from that specified the Kilnfile.lock", func() {
 				outputFile := "some-output-dir/some-product-file-1.2.3-build.4"
 				err := bake.Execute([]string{
@@ -457,8 +592,93 @@ var _ = Describe("Bake", func() {
 					"--migrations-directory", "some-other-migrations-directory",
 				})
 				Expect(err).NotTo(HaveOccurred())
-				Expect(fakeStemcellService.FromKilnfileCallCount()).To(Equal(1))
-				Expect(fakeStemcellService.FromKilnfileArgsForCall(0)).To(Equal("Kilnfile"))
+
+				Expect(fakeBakeConfigLoader.LoadStemcellCriteriaCallCount()).To(Equal(1))
+				_, kilnfilePath := fakeBakeConfigLoader.LoadStemcellCriteriaArgsForCall(0)
+				Expect(kilnfilePath).To(Equal("Kilnfile"))
+
+				input, _ := fakeInterpolator.InterpolateArgsForCall(0)
+				Expect(input.StemcellManifests).To(Equal(map[string]interface{}{
+					"ubuntu-xenial": cargo.Stemcell{OS: "ubuntu-xenial", Version: "621.55"},
+					"ubuntu-jammy":  cargo.Stemcell{OS: "ubuntu-jammy", Version: "1.18"},
+				}))
+			})
+		})
+
+		Context("when the Kilnfile has a bake section", func() {
+			BeforeEach(func() {
+				fakeBakeConfigLoader.LoadBakeConfigReturns(cargo.BakeConfig{
+					Metadata:                  "tile/base.yml",
+					OutputFile:                "tile/tile.pivotal",
+					Icon:                      "tile/icon.png",
+					ReleasesDirectories:       []string{someReleasesDirectory},
+					FormsDirectories:          []string{"tile/forms"},
+					InstanceGroupsDirectories: []string{"tile/instance_groups"},
+					JobsDirectories:           []string{"tile/jobs"},
+					MigrationsDirectories:     []string{"tile/migrations"},
+					Embed:                     []string{"tile/embed"},
+				}, nil)
+			})
+
+			It("uses it in place of flags", func() {
+				err := bake.Execute([]string{
+					"--kilnfile", "Kilnfile",
+					"--version", "1.2.3",
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeBakeConfigLoader.LoadBakeConfigCallCount()).To(Equal(1))
+				fs, kilnfilePath := fakeBakeConfigLoader.LoadBakeConfigArgsForCall(0)
+				Expect(fs).To(Equal(filesystem))
+				Expect(kilnfilePath).To(Equal("Kilnfile"))
+
+				Expect(fakeMetadataService.ReadArgsForCall(0)).To(Equal("tile/base.yml"))
+				Expect(fakeIconService.EncodeArgsForCall(0)).To(Equal("tile/icon.png"))
+				Expect(fakeReleasesService.FromDirectoriesArgsForCall(0)).To(Equal([]string{someReleasesDirectory}))
+				Expect(fakeFormsService.FromDirectoriesArgsForCall(0)).To(Equal([]string{"tile/forms"}))
+				Expect(fakeInstanceGroupsService.FromDirectoriesArgsForCall(0)).To(Equal([]string{"tile/instance_groups"}))
+				Expect(fakeJobsService.FromDirectoriesArgsForCall(0)).To(Equal([]string{"tile/jobs"}))
+
+				_, input := fakeTileWriter.WriteArgsForCall(0)
+				Expect(input.OutputFile).To(Equal("tile/tile.pivotal"))
+				Expect(input.MigrationDirectories).To(Equal([]string{"tile/migrations"}))
+				Expect(input.EmbedPaths).To(Equal([]string{"tile/embed"}))
+			})
+
+			It("lets flags override individual fields", func() {
+				err := bake.Execute([]string{
+					"--kilnfile", "Kilnfile",
+					"--version", "1.2.3",
+					"--metadata", "other-metadata.yml",
+					"--forms-directory", "other-forms",
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeMetadataService.ReadArgsForCall(0)).To(Equal("other-metadata.yml"))
+				Expect(fakeFormsService.FromDirectoriesArgsForCall(0)).To(Equal([]string{"other-forms"}))
+				Expect(fakeInstanceGroupsService.FromDirectoriesArgsForCall(0)).To(Equal([]string{"tile/instance_groups"}))
+			})
+
+			When("the Kilnfile does not exist", func() {
+				BeforeEach(func() {
+					fakeBakeConfigLoader.LoadBakeConfigReturns(cargo.BakeConfig{}, &os.PathError{Op: "open", Path: "Kilnfile", Err: os.ErrNotExist})
+				})
+
+				It("only uses the flags", func() {
+					err := bake.Execute([]string{"--kilnfile", "Kilnfile", "--version", "1.2.3"})
+					Expect(err).To(MatchError(`missing required flag "--metadata"`))
+				})
+			})
+
+			When("the bake section can not be loaded", func() {
+				BeforeEach(func() {
+					fakeBakeConfigLoader.LoadBakeConfigReturns(cargo.BakeConfig{}, errors.New("boom"))
+				})
+
+				It("returns an error", func() {
+					err := bake.Execute([]string{"--kilnfile", "Kilnfile", "--version", "1.2.3"})
+					Expect(err).To(MatchError("failed to read bake configuration from Kilnfile: boom"))
+				})
 			})
 		})
 
@@ -671,6 +891,65 @@ var _ = Describe("Bake", func() {
 				})
 			})
 
+			Context("when the interpolated metadata is not valid", func() {
+				var instanceGroupsDirectory string
+
+				BeforeEach(func() {
+					instanceGroupsDirectory = filepath.Join(tmpDir, "instance_groups")
+					Expect(os.Mkdir(instanceGroupsDirectory, 0755)).To(Succeed())
+					Expect(ioutil.WriteFile(filepath.Join(instanceGroupsDirectory, "web.yml"), []byte(`name: web`), 0644)).To(Succeed())
+
+					fakeInterpolator.InterpolateReturns([]byte(someInterpolatedMetadata+`job_types:
+- name: web
+  resource_label: Web
+  max_in_flight: lots
+  templates:
+  - name: web-server
+    release: some-release
+`), nil)
+				})
+
+				It("returns errors pointing at the part files", func() {
+					err := bake.Execute([]string{
+						"--metadata", "some-metadata",
+						"--output-file", "some-output-dir/some-product-file-1.2.3-build.4.pivotal",
+						"--instance-groups-directory", instanceGroupsDirectory,
+					})
+
+					webPartFile := filepath.Join(instanceGroupsDirectory, "web.yml")
+					Expect(err).To(MatchError(`tile metadata is not valid (use --skip-validation to bake it anyway):
+- ` + webPartFile + `: job type "web" max in flight must be a positive integer or a percentage between 1% and 100%, got lots
+- ` + webPartFile + `: job type "web" template "web-server" references release "some-release", which is not in releases`))
+
+					var partError MetadataPartError
+					Expect(errors.As(err, &partError)).To(BeTrue())
+					Expect(partError.File).To(Equal(webPartFile))
+
+					Expect(fakeTileWriter.WriteCallCount()).To(Equal(0))
+				})
+
+				It("writes the tile when validation is skipped", func() {
+					err := bake.Execute([]string{
+						"--metadata", "some-metadata",
+						"--output-file", "some-output-dir/some-product-file-1.2.3-build.4.pivotal",
+						"--instance-groups-directory", instanceGroupsDirectory,
+						"--skip-validation",
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(fakeTileWriter.WriteCallCount()).To(Equal(1))
+				})
+
+				It("points at the metadata file when no part file defines the invalid object", func() {
+					fakeInterpolator.InterpolateReturns([]byte(`name: some-product`), nil)
+
+					err := bake.Execute([]string{
+						"--metadata", "some-metadata",
+						"--output-file", "some-output-dir/some-product-file-1.2.3-build.4.pivotal",
+					})
+					Expect(err).To(MatchError(ContainSubstring("- some-metadata: product template label must be present")))
+				})
+			})
+
 			Context("when the metadata flag is missing", func() {
 				It("returns an error", func() {
 					err := bake.Execute([]string{
@@ -0,0 +1,138 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/dustin/go-humanize"
+	"github.com/pivotal-cf/jhanda"
+
+	"github.com/pivotal-cf/kiln/fetcher"
+	"github.com/pivotal-cf/kiln/release"
+)
+
+type Cache struct {
+	Logger *log.Logger
+
+	PruneOptions struct {
+		ReleaseCache string `long:"release-cache" env:"KILN_RELEASE_CACHE" description:"path to the release cache directory"`
+		MaxSize      string `long:"max-size"      required:"true"           description:"size to shrink the cache to, removing the least recently used releases first (e.g. 20GB)"`
+	}
+}
+
+func NewCache(logger *log.Logger) Cache {
+	return Cache{Logger: logger}
+}
+
+func (c Cache) Execute(args []string) error {
+	if len(args) == 0 {
+		return errors.New("missing cache subcommand, expected one of: prune")
+	}
+
+	subcommand, args := args[0], args[1:]
+	switch subcommand {
+	case "prune":
+		return c.prune(args)
+	default:
+		return fmt.Errorf("unknown cache subcommand %q, expected one of: prune", subcommand)
+	}
+}
+
+func (c Cache) prune(args []string) error {
+	_, err := jhanda.Parse(&c.PruneOptions, args)
+	if err != nil {
+		return err
+	}
+
+	if c.PruneOptions.ReleaseCache == "" {
+		return errors.New("--release-cache (or KILN_RELEASE_CACHE) must be set")
+	}
+
+	maxSize, err := humanize.ParseBytes(c.PruneOptions.MaxSize)
+	if err != nil {
+		return fmt.Errorf("invalid --max-size %q: %w", c.PruneOptions.MaxSize, err)
+	}
+
+	cache := fetcher.NewReleaseCache(c.PruneOptions.ReleaseCache, c.Logger)
+	removed, err := cache.Prune(int64(maxSize))
+	for _, entry := range removed {
+		c.Logger.Printf("removed %s (%s)\n", entry.Path, humanize.Bytes(uint64(entry.Size)))
+	}
+	if err != nil {
+		return err
+	}
+
+	c.Logger.Printf("Removed %d releases from %s\n", len(removed), c.PruneOptions.ReleaseCache)
+	return nil
+}
+
+func (c Cache) Usage() jhanda.Usage {
+	return jhanda.Usage{
+		Description:      "Manages the release cache shared between tiles. Usage: kiln cache prune --max-size 20GB",
+		ShortDescription: "manages the shared release cache",
+		Flags:            c.PruneOptions,
+	}
+}
+
+// downloadReleaseUsingCache links a release from the cache when it has been
+// downloaded from the same remote before. Otherwise it downloads the release
+// and adds it to the cache for the next tile.
+func downloadReleaseUsingCache(releaseSource fetcher.MultiReleaseSource, cache fetcher.ReleaseCache, releasesDir string, remote release.Remote, logger *log.Logger) (release.Local, error) {
+	if cacheable(releaseSource, remote) {
+		local, found, err := cache.Get(releasesDir, remote, remote.SHA)
+		if err != nil {
+			logger.Printf("warning: release cache lookup for %s failed: %s", remote.Name, err)
+		}
+		if found {
+			return local, nil
+		}
+	}
+
+	local, err := releaseSource.DownloadRelease(releasesDir, remote, fetcher.DefaultDownloadThreadCount)
+	if err != nil {
+		return release.Local{}, err
+	}
+
+	err = cache.Add(local, remote)
+	if err != nil {
+		logger.Printf("warning: could not add %s to the release cache: %s", remote.Name, err)
+	}
+
+	return local, nil
+}
+
+// cacheable reports whether the release may be linked from the release cache.
+// The cache does not keep signatures, so releases from sources that verify
+// them are always downloaded.
+func cacheable(releaseSource fetcher.MultiReleaseSource, remote release.Remote) bool {
+	src, err := releaseSource.FindByID(remote.SourceID)
+	if err != nil {
+		return false
+	}
+	verifier, ok := src.(fetcher.SignatureVerifier)
+	return !ok || !verifier.VerifiesSignatures()
+}
+
+// releaseSHA1 returns the SHA1 the release source found. Sources that don't
+// publish checksums leave it empty, so the release is downloaded to a
+// temporary directory, or linked from the cache, to calculate it.
+func releaseSHA1(releaseSource fetcher.MultiReleaseSource, cache fetcher.ReleaseCache, remote release.Remote, logger *log.Logger) (string, error) {
+	if remote.SHA != "" {
+		return remote.SHA, nil
+	}
+
+	tmpDir, err := ioutil.TempDir("", "kiln-release")
+	if err != nil {
+		return "", err // untested
+	}
+	defer os.RemoveAll(tmpDir)
+
+	local, err := downloadReleaseUsingCache(releaseSource, cache, tmpDir, remote, logger)
+	if err != nil {
+		return "", err
+	}
+	return local.SHA1, nil
+}
@@ -0,0 +1,88 @@
+package commands_test
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/pivotal-cf/jhanda"
+	. "github.com/pivotal-cf/kiln/commands"
+	"github.com/pivotal-cf/kiln/fetcher"
+	"github.com/pivotal-cf/kiln/release"
+)
+
+var _ = Describe("Cache", func() {
+	var (
+		cacheDir string
+		command  Cache
+	)
+
+	BeforeEach(func() {
+		var err error
+		cacheDir, err = ioutil.TempDir("", "cache-command-test")
+		Expect(err).NotTo(HaveOccurred())
+
+		command = NewCache(log.New(GinkgoWriter, "", 0))
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(cacheDir)
+	})
+
+	Describe("prune", func() {
+		BeforeEach(func() {
+			cache := fetcher.NewReleaseCache(cacheDir, log.New(GinkgoWriter, "", 0))
+			for _, name := range []string{"a", "b"} {
+				tarball := filepath.Join(cacheDir, name+".tgz")
+				Expect(ioutil.WriteFile(tarball, make([]byte, 1000), 0644)).To(Succeed())
+				Expect(cache.Add(release.Local{LocalPath: tarball, SHA1: name + "-sha1"}, release.Remote{})).To(Succeed())
+				Expect(os.Remove(tarball)).To(Succeed())
+			}
+		})
+
+		It("shrinks the cache to the maximum size", func() {
+			err := command.Execute([]string{"prune", "--release-cache", cacheDir, "--max-size", "1.5kB"})
+			Expect(err).NotTo(HaveOccurred())
+
+			entries, err := fetcher.NewReleaseCache(cacheDir, nil).Entries()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(HaveLen(1))
+		})
+
+		When("the max size is not valid", func() {
+			It("returns an error", func() {
+				err := command.Execute([]string{"prune", "--release-cache", cacheDir, "--max-size", "lots"})
+				Expect(err).To(MatchError(ContainSubstring(`invalid --max-size "lots"`)))
+			})
+		})
+
+		When("the cache directory is not set", func() {
+			It("returns an error", func() {
+				Expect(os.Unsetenv("KILN_RELEASE_CACHE")).To(Succeed())
+				err := command.Execute([]string{"prune", "--max-size", "1GB"})
+				Expect(err).To(MatchError(ContainSubstring("--release-cache")))
+			})
+		})
+	})
+
+	When("the subcommand is unknown", func() {
+		It("returns an error", func() {
+			err := command.Execute([]string{"bake-bread"})
+			Expect(err).To(MatchError(`unknown cache subcommand "bake-bread", expected one of: prune`))
+		})
+	})
+
+	Describe("Usage", func() {
+		It("returns usage information for the command", func() {
+			Expect(command.Usage()).To(Equal(jhanda.Usage{
+				Description:      "Manages the release cache shared between tiles. Usage: kiln cache prune --max-size 20GB",
+				ShortDescription: "manages the shared release cache",
+				Flags:            command.PruneOptions,
+			}))
+		})
+	})
+})
@@ -2,11 +2,13 @@ package commands
 
 import (
 	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
 	boshsystem "github.com/cloudfoundry/bosh-utils/system"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
@@ -38,10 +40,12 @@ type CompileBuiltReleases struct {
 	BoshDirectorFactory        func() (BoshDirector, error)
 
 	Options struct {
-		ReleasesDir    string `short:"rd" long:"releases-directory" default:"releases" description:"path to a directory to download releases into"`
-		StemcellFile   string `short:"sf" long:"stemcell-file"      required:"true"    description:"path to the stemcell tarball on disk"`
-		UploadTargetID string `           long:"upload-target-id"   required:"true"    description:"the ID of the release source where the compiled release will be uploaded"`
-		Parallel       int64  `short:"p" long:"parallel" default:"1" description:"number of parallel compile release jobs"`
+		ReleasesDir    string   `short:"rd" long:"releases-directory" default:"releases" description:"path to a directory to download releases into"`
+		StemcellFiles  []string `short:"sf" long:"stemcell-file"      required:"true"    description:"path to a stemcell tarball on disk, one for each OS in the Kilnfile.lock stemcell_criteria"`
+		UploadTargetID string   `           long:"upload-target-id"   required:"true"    description:"the ID of the release source where the compiled release will be uploaded"`
+		Parallel       int64    `short:"p" long:"parallel" default:"1" description:"number of parallel compile release jobs"`
+		DryRun         bool     `long:"dry-run" description:"prints the planned downloads, compilations, uploads and Kilnfile.lock changes without making them"`
+		SigningKey     string   `long:"signing-key" description:"path to a private key used to sign the compiled releases; the signatures are uploaded next to them"`
 
 		Kilnfile       string   `short:"kf" long:"kilnfile"       default:"Kilnfile" description:"path to Kilnfile"`
 		VariablesFiles []string `short:"vf" long:"variables-file"                    description:"path to variables file"`
@@ -119,12 +123,22 @@ func (f CompileBuiltReleases) Execute(args []string) error {
 		return fmt.Errorf("couldn't load Kilnfiles: %w", err) // untested
 	}
 
-	publishableReleaseSources := f.MultiReleaseSourceProvider(kilnfile, true)
-	allReleaseSources := f.MultiReleaseSourceProvider(kilnfile, false)
+	publishableReleaseSources, err := f.MultiReleaseSourceProvider(kilnfile, true)
+	if err != nil {
+		return err
+	}
+	allReleaseSources, err := f.MultiReleaseSourceProvider(kilnfile, false)
+	if err != nil {
+		return err
+	}
 	releaseUploader, err := f.ReleaseUploaderFinder(kilnfile, f.Options.UploadTargetID)
 	if err != nil {
 		return fmt.Errorf("error loading release uploader: %w", err) // untested
 	}
+	signing, err := f.releaseSigning(releaseUploader)
+	if err != nil {
+		return err
+	}
 
 	builtReleases, err := findBuiltReleases(allReleaseSources, kilnfileLock)
 	if err != nil {
@@ -136,7 +150,9 @@ func (f CompileBuiltReleases) Execute(args []string) error {
 		return nil
 	}
 
-	updatedReleases, remainingBuiltReleases, err := f.downloadPreCompiledReleases(publishableReleaseSources, builtReleases, kilnfileLock.Stemcell)
+	plan := newDryRunPlan(kilnfileLock)
+
+	updatedReleases, remainingBuiltReleases, err := f.downloadPreCompiledReleases(publishableReleaseSources, builtReleases, kilnfileLock, plan)
 	if err != nil {
 		return err
 	}
@@ -144,16 +160,43 @@ func (f CompileBuiltReleases) Execute(args []string) error {
 	if len(remainingBuiltReleases) > 0 {
 		f.Logger.Printf("need to compile %d built releases\n", len(remainingBuiltReleases))
 
-		downloadedReleases, stemcell, err := f.compileAndDownloadReleases(allReleaseSources, remainingBuiltReleases)
+		stemcellFiles, err := f.stemcellFilesByOS(kilnfileLock)
 		if err != nil {
 			return err
 		}
 
-		uploadedReleases, err := f.uploadCompiledReleases(downloadedReleases, releaseUploader, stemcell)
+		stemcellOSes, releasesByStemcellOS, err := builtReleasesByStemcellOS(remainingBuiltReleases, kilnfileLock)
 		if err != nil {
 			return err
 		}
-		updatedReleases = append(updatedReleases, uploadedReleases...)
+
+		for _, stemcellOS := range stemcellOSes {
+			if _, found := stemcellFiles[stemcellOS]; !found {
+				return fmt.Errorf("no --stemcell-file was given for the %s stemcell", stemcellOS)
+			}
+		}
+
+		for _, stemcellOS := range stemcellOSes {
+			if f.Options.DryRun {
+				plannedReleases, err := f.planCompilation(plan, releaseUploader, releasesByStemcellOS[stemcellOS], stemcellFiles[stemcellOS], kilnfileLock)
+				if err != nil {
+					return err
+				}
+				updatedReleases = append(updatedReleases, plannedReleases...)
+				continue
+			}
+
+			downloadedReleases, stemcell, err := f.compileAndDownloadReleases(allReleaseSources, releasesByStemcellOS[stemcellOS], stemcellFiles[stemcellOS])
+			if err != nil {
+				return err
+			}
+
+			uploadedReleases, err := f.uploadCompiledReleases(downloadedReleases, releaseUploader, signing, stemcell)
+			if err != nil {
+				return err
+			}
+			updatedReleases = append(updatedReleases, uploadedReleases...)
+		}
 	} else {
 		f.Logger.Println("nothing left to compile")
 	}
@@ -163,6 +206,16 @@ func (f CompileBuiltReleases) Execute(args []string) error {
 		return err
 	}
 
+	if f.Options.DryRun {
+		plan.print(f.Logger, kilnfileLock)
+		return nil
+	}
+
+	err = f.KilnfileLoader.SaveKilnfileLock(osfs.New(""), f.Options.Kilnfile, kilnfileLock)
+	if err != nil {
+		return err
+	}
+
 	f.Logger.Println("Updated Kilnfile.lock. DONE")
 	return nil
 }
@@ -175,9 +228,10 @@ func (f CompileBuiltReleases) Usage() jhanda.Usage {
 	}
 }
 
-type remoteReleaseWithSHA1 struct {
+type remoteReleaseWithChecksums struct {
 	release.Remote
-	SHA1 string
+	SHA1   string
+	SHA256 string
 }
 
 func findBuiltReleases(allReleaseSources fetcher.MultiReleaseSource, kilnfileLock cargo.KilnfileLock) ([]release.Remote, error) {
@@ -199,15 +253,60 @@ func findBuiltReleases(allReleaseSources fetcher.MultiReleaseSource, kilnfileLoc
 	return builtReleases, nil
 }
 
-func (f CompileBuiltReleases) downloadPreCompiledReleases(publishableReleaseSources fetcher.MultiReleaseSource, builtReleases []release.Remote, stemcell cargo.Stemcell) ([]remoteReleaseWithSHA1, []release.Remote, error) {
+// builtReleasesByStemcellOS groups built releases by the OS of the stemcell
+// they are compiled against, returning the OSes in the order they are used.
+func builtReleasesByStemcellOS(builtReleases []release.Remote, kilnfileLock cargo.KilnfileLock) ([]string, map[string][]release.Remote, error) {
+	var stemcellOSes []string
+	releasesByStemcellOS := make(map[string][]release.Remote)
+	for _, builtRelease := range builtReleases {
+		stemcell, err := kilnfileLock.ReleaseStemcell(builtRelease.Name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, seen := releasesByStemcellOS[stemcell.OS]; !seen {
+			stemcellOSes = append(stemcellOSes, stemcell.OS)
+		}
+		releasesByStemcellOS[stemcell.OS] = append(releasesByStemcellOS[stemcell.OS], builtRelease)
+	}
+	return stemcellOSes, releasesByStemcellOS, nil
+}
+
+// stemcellFilesByOS maps the OS of each --stemcell-file to its path. A single
+// stemcell file is used for a Kilnfile.lock with a single stemcell, whatever
+// its OS.
+func (f CompileBuiltReleases) stemcellFilesByOS(kilnfileLock cargo.KilnfileLock) (map[string]string, error) {
+	stemcellManifestReader := builder.NewStemcellManifestReader(helper.NewFilesystem())
+	stemcellFiles := make(map[string]string)
+	for _, stemcellFile := range f.Options.StemcellFiles {
+		stemcellPart, err := stemcellManifestReader.Read(stemcellFile)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse manifest of stemcell %q: %w", stemcellFile, err)
+		}
+		stemcellManifest := stemcellPart.Metadata.(builder.StemcellManifest)
+		stemcellFiles[stemcellManifest.OperatingSystem] = stemcellFile
+	}
+
+	if len(f.Options.StemcellFiles) == 1 && len(kilnfileLock.Stemcells) <= 1 {
+		stemcellFiles[kilnfileLock.Stemcells.Default().OS] = f.Options.StemcellFiles[0]
+	}
+
+	return stemcellFiles, nil
+}
+
+func (f CompileBuiltReleases) downloadPreCompiledReleases(publishableReleaseSources fetcher.MultiReleaseSource, builtReleases []release.Remote, kilnfileLock cargo.KilnfileLock, plan *dryRunPlan) ([]remoteReleaseWithChecksums, []release.Remote, error) {
 	var (
 		remainingBuiltReleases []release.Remote
-		preCompiledReleases    []remoteReleaseWithSHA1
+		preCompiledReleases    []remoteReleaseWithChecksums
 	)
 
 	f.Logger.Println("searching for pre-compiled releases")
 
 	for _, builtRelease := range builtReleases {
+		stemcell, err := kilnfileLock.ReleaseStemcell(builtRelease.Name)
+		if err != nil {
+			return nil, nil, err
+		}
+
 		spec := release.Requirement{
 			Name:            builtRelease.Name,
 			Version:         builtRelease.Version,
@@ -223,12 +322,18 @@ func (f CompileBuiltReleases) downloadPreCompiledReleases(publishableReleaseSour
 			continue
 		}
 
+		if f.Options.DryRun {
+			plan.addStep("download pre-compiled %s %s from %s", remote.Name, remote.Version, remote.SourceID)
+			preCompiledReleases = append(preCompiledReleases, remoteReleaseWithChecksums{Remote: remote, SHA1: remote.SHA})
+			continue
+		}
+
 		local, err := publishableReleaseSources.DownloadRelease(f.Options.ReleasesDir, remote, fetcher.DefaultDownloadThreadCount)
 		if err != nil {
 			return nil, nil, fmt.Errorf("error downloading pre-compiled release for %q: %w", builtRelease.Name, err)
 		}
 
-		preCompiledReleases = append(preCompiledReleases, remoteReleaseWithSHA1{Remote: remote, SHA1: local.SHA1})
+		preCompiledReleases = append(preCompiledReleases, remoteReleaseWithChecksums{Remote: remote, SHA1: local.SHA1, SHA256: local.SHA256})
 	}
 
 	f.Logger.Printf("found %d pre-compiled releases\n", len(preCompiledReleases))
@@ -236,7 +341,37 @@ func (f CompileBuiltReleases) downloadPreCompiledReleases(publishableReleaseSour
 	return preCompiledReleases, remainingBuiltReleases, nil
 }
 
-func (f CompileBuiltReleases) compileAndDownloadReleases(releaseSource fetcher.MultiReleaseSource, builtReleases []release.Remote) ([]release.Local, builder.StemcellManifest, error) {
+// planCompilation adds the steps to compile builtReleases and upload them to
+// the plan, and returns where they would be uploaded without a SHA1.
+func (f CompileBuiltReleases) planCompilation(plan *dryRunPlan, releaseUploader fetcher.ReleaseUploader, builtReleases []release.Remote, stemcellFile string, kilnfileLock cargo.KilnfileLock) ([]remoteReleaseWithChecksums, error) {
+	var plannedReleases []remoteReleaseWithChecksums
+	for _, builtRelease := range builtReleases {
+		stemcell, err := kilnfileLock.ReleaseStemcell(builtRelease.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		plan.addStep("compile %s %s on the BOSH director with stemcell %s", builtRelease.Name, builtRelease.Version, stemcellFile)
+		plan.addStep("upload compiled %s %s to %s", builtRelease.Name, builtRelease.Version, f.Options.UploadTargetID)
+
+		planned := remoteReleaseWithChecksums{Remote: release.Remote{ID: builtRelease.ID, SourceID: f.Options.UploadTargetID}}
+		if remotePather, ok := releaseUploader.(fetcher.RemotePather); ok {
+			planned.RemotePath, err = remotePather.RemotePath(release.Requirement{
+				Name:            builtRelease.Name,
+				Version:         builtRelease.Version,
+				StemcellOS:      stemcell.OS,
+				StemcellVersion: stemcell.Version,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("couldn't generate a remote path for release %q: %w", builtRelease.Name, err)
+			}
+		}
+		plannedReleases = append(plannedReleases, planned)
+	}
+	return plannedReleases, nil
+}
+
+func (f CompileBuiltReleases) compileAndDownloadReleases(releaseSource fetcher.MultiReleaseSource, builtReleases []release.Remote, stemcellFile string) ([]release.Local, builder.StemcellManifest, error) {
 	f.Logger.Println("connecting to the bosh director")
 	boshDirector, err := f.BoshDirectorFactory()
 	if err != nil {
@@ -248,7 +383,7 @@ func (f CompileBuiltReleases) compileAndDownloadReleases(releaseSource fetcher.M
 		return nil, builder.StemcellManifest{}, err
 	}
 
-	stemcellManifest, err := f.uploadStemcellToDirector(boshDirector)
+	stemcellManifest, err := f.uploadStemcellToDirector(boshDirector, stemcellFile)
 	if err != nil {
 		return nil, builder.StemcellManifest{}, err
 	}
@@ -324,9 +459,9 @@ func (f CompileBuiltReleases) uploadReleasesToDirector(builtReleases []release.R
 	return releaseIDs, nil
 }
 
-func (f CompileBuiltReleases) uploadStemcellToDirector(boshDirector BoshDirector) (builder.StemcellManifest, error) {
-	f.Logger.Printf("uploading stemcell %q to director\n", f.Options.StemcellFile)
-	stemcellFile, err := os.Open(f.Options.StemcellFile)
+func (f CompileBuiltReleases) uploadStemcellToDirector(boshDirector BoshDirector, stemcellPath string) (builder.StemcellManifest, error) {
+	f.Logger.Printf("uploading stemcell %q to director\n", stemcellPath)
+	stemcellFile, err := os.Open(stemcellPath)
 	if err != nil {
 		return builder.StemcellManifest{}, fmt.Errorf("opening stemcell: %w", err) // untested
 	}
@@ -337,7 +472,7 @@ func (f CompileBuiltReleases) uploadStemcellToDirector(boshDirector BoshDirector
 	}
 
 	stemcellManifestReader := builder.NewStemcellManifestReader(helper.NewFilesystem())
-	stemcellPart, err := stemcellManifestReader.Read(f.Options.StemcellFile)
+	stemcellPart, err := stemcellManifestReader.Read(stemcellPath)
 	if err != nil {
 		return builder.StemcellManifest{}, fmt.Errorf("couldn't parse manifest of stemcell: %v", err) // untested
 	}
@@ -375,10 +510,10 @@ func (f CompileBuiltReleases) downloadCompiledReleases(stemcellManifest builder.
 			return nil, fmt.Errorf("failed reopening file %s: %w", rel.TarballPath, err) // untested
 		}
 
-		s := sha1.New()
-		_, err = io.Copy(s, fd)
+		sha1Hash, sha256Hash := sha1.New(), sha256.New()
+		_, err = io.Copy(io.MultiWriter(sha1Hash, sha256Hash), fd)
 		if err != nil {
-			return nil, fmt.Errorf("failed calculating SHA1 for file file %s: %w", rel.TarballPath, err) // untested
+			return nil, fmt.Errorf("failed calculating checksums for file file %s: %w", rel.TarballPath, err) // untested
 		}
 		err = fd.Close()
 		if err != nil {
@@ -388,7 +523,8 @@ func (f CompileBuiltReleases) downloadCompiledReleases(stemcellManifest builder.
 		downloadedReleases = append(downloadedReleases, release.Local{
 			ID:        release.ID{Name: rel.Name, Version: rel.Version},
 			LocalPath: rel.TarballPath,
-			SHA1:      hex.EncodeToString(s.Sum(nil)),
+			SHA1:      hex.EncodeToString(sha1Hash.Sum(nil)),
+			SHA256:    hex.EncodeToString(sha256Hash.Sum(nil)),
 		})
 
 		expectedMultipleDigest, err := boshcrypto.ParseMultipleDigest(rel.SHA1)
@@ -483,10 +619,27 @@ func (f CompileBuiltReleases) exportReleasesInParallel(stemcellManifest builder.
 	return exportedReleases, nil
 }
 
-func (f CompileBuiltReleases) uploadCompiledReleases(downloadedReleases []release.Local, releaseUploader fetcher.ReleaseUploader, stemcell builder.StemcellManifest) ([]remoteReleaseWithSHA1, error) {
-	var uploadedReleases []remoteReleaseWithSHA1
+func (f CompileBuiltReleases) releaseSigning(releaseUploader fetcher.ReleaseUploader) (*releaseSigning, error) {
+	if f.Options.SigningKey == "" {
+		return nil, nil
+	}
+
+	privateKey, err := ioutil.ReadFile(f.Options.SigningKey)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read the signing key: %w", err)
+	}
+	return newReleaseSigning(privateKey, releaseUploader, f.Options.UploadTargetID)
+}
+
+func (f CompileBuiltReleases) uploadCompiledReleases(downloadedReleases []release.Local, releaseUploader fetcher.ReleaseUploader, signing *releaseSigning, stemcell builder.StemcellManifest) ([]remoteReleaseWithChecksums, error) {
+	var uploadedReleases []remoteReleaseWithChecksums
 
 	for _, downloadedRelease := range downloadedReleases {
+		signature, err := signCompiledRelease(signing, downloadedRelease.LocalPath)
+		if err != nil {
+			return nil, err
+		}
+
 		releaseFile, err := os.Open(downloadedRelease.LocalPath)
 		if err != nil {
 			return nil, fmt.Errorf("opening compiled release %q for uploading: %w", downloadedRelease.LocalPath, err) // untested
@@ -502,12 +655,31 @@ func (f CompileBuiltReleases) uploadCompiledReleases(downloadedReleases []releas
 			return nil, fmt.Errorf("uploading compiled release %q failed: %w", downloadedRelease.LocalPath, err) // untested
 		}
 
-		uploadedReleases = append(uploadedReleases, remoteReleaseWithSHA1{Remote: remoteRelease, SHA1: downloadedRelease.SHA1})
+		err = signing.upload(remoteRelease, signature)
+		if err != nil {
+			return nil, err
+		}
+
+		uploadedReleases = append(uploadedReleases, remoteReleaseWithChecksums{Remote: remoteRelease, SHA1: downloadedRelease.SHA1, SHA256: downloadedRelease.SHA256})
 	}
 	return uploadedReleases, nil
 }
 
-func (f CompileBuiltReleases) updateLockfile(uploadedReleases []remoteReleaseWithSHA1, kilnfileLock cargo.KilnfileLock) error {
+func signCompiledRelease(signing *releaseSigning, path string) ([]byte, error) {
+	if signing == nil {
+		return nil, nil
+	}
+
+	releaseFile, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening compiled release %q for signing: %w", path, err) // untested
+	}
+	defer releaseFile.Close()
+
+	return signing.sign(releaseFile)
+}
+
+func (f CompileBuiltReleases) updateLockfile(uploadedReleases []remoteReleaseWithChecksums, kilnfileLock cargo.KilnfileLock) error {
 	for _, uploaded := range uploadedReleases {
 		var matchingRelease *cargo.ReleaseLock
 		for i := range kilnfileLock.Releases {
@@ -522,8 +694,8 @@ func (f CompileBuiltReleases) updateLockfile(uploadedReleases []remoteReleaseWit
 
 		matchingRelease.RemoteSource = uploaded.SourceID
 		matchingRelease.RemotePath = uploaded.RemotePath
-		matchingRelease.SHA1 = uploaded.SHA1
+		matchingRelease.SetChecksums(uploaded.SHA1, uploaded.SHA256)
 	}
 
-	return f.KilnfileLoader.SaveKilnfileLock(osfs.New(""), f.Options.Kilnfile, kilnfileLock)
+	return nil
 }
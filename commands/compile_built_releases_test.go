@@ -1,8 +1,15 @@
 package commands_test
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/hex"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
@@ -88,15 +95,15 @@ var _ = Describe("CompileBuiltReleases", func() {
 				{Name: "capi", Version: "2.3.4", RemoteSource: builtSourceID, RemotePath: "/remote/path/capi-2.3.4.tgz", SHA1: "original-sha"},
 				{Name: "bpm", Version: "1.6", RemoteSource: compiledSourceID, RemotePath: "not-used", SHA1: "original-sha"},
 			},
-			Stemcell: cargo.Stemcell{OS: stemcellOS, Version: stemcellVersion},
+			Stemcells: cargo.StemcellCriteria{{OS: stemcellOS, Version: stemcellVersion}},
 		}
 
 		multiReleaseSourceProvider = new(fakes.MultiReleaseSourceProvider)
-		multiReleaseSourceProvider.Calls(func(kilnfile cargo.Kilnfile, allowOnlyPublishable bool) fetcher.MultiReleaseSource {
+		multiReleaseSourceProvider.Calls(func(kilnfile cargo.Kilnfile, allowOnlyPublishable bool) (fetcher.MultiReleaseSource, error) {
 			if allowOnlyPublishable {
-				return fetcher.NewMultiReleaseSource(compiledReleaseSource)
+				return fetcher.NewMultiReleaseSource(compiledReleaseSource), nil
 			} else {
-				return fetcher.NewMultiReleaseSource(compiledReleaseSource, builtReleaseSource)
+				return fetcher.NewMultiReleaseSource(compiledReleaseSource, builtReleaseSource), nil
 			}
 		})
 
@@ -399,10 +406,12 @@ var _ = Describe("CompileBuiltReleases", func() {
 			s := sha1.New()
 			io.Copy(s, strings.NewReader(blobIDContents("uaa-1.2.3")))
 			expectedUaaSha := hex.EncodeToString(s.Sum(nil))
+			expectedUaaSHA256 := fmt.Sprintf("%x", sha256.Sum256([]byte(blobIDContents("uaa-1.2.3"))))
 
 			s = sha1.New()
 			io.Copy(s, strings.NewReader(blobIDContents("capi-2.3.4")))
 			expectedCapiSha := hex.EncodeToString(s.Sum(nil))
+			expectedCapiSHA256 := fmt.Sprintf("%x", sha256.Sum256([]byte(blobIDContents("capi-2.3.4"))))
 
 			Expect(updatedLockfile).To(Equal(cargo.KilnfileLock{
 				Releases: []cargo.ReleaseLock{
@@ -412,6 +421,7 @@ var _ = Describe("CompileBuiltReleases", func() {
 						RemoteSource: compiledSourceID,
 						RemotePath:   fmt.Sprintf("uaa/uaa-1.2.3-%s-%s.tgz", stemcellOS, stemcellVersion),
 						SHA1:         expectedUaaSha,
+						SHA256:       expectedUaaSHA256,
 					},
 					{
 						Name:         "capi",
@@ -419,6 +429,7 @@ var _ = Describe("CompileBuiltReleases", func() {
 						RemoteSource: compiledSourceID,
 						RemotePath:   fmt.Sprintf("capi/capi-2.3.4-%s-%s.tgz", stemcellOS, stemcellVersion),
 						SHA1:         expectedCapiSha,
+						SHA256:       expectedCapiSHA256,
 					},
 					{
 						Name:         "bpm",
@@ -428,10 +439,69 @@ var _ = Describe("CompileBuiltReleases", func() {
 						SHA1:         "original-sha",
 					},
 				},
-				Stemcell: cargo.Stemcell{OS: stemcellOS, Version: stemcellVersion},
+				Stemcells: cargo.StemcellCriteria{{OS: stemcellOS, Version: stemcellVersion}},
 			}))
 		})
 
+		When("a signing key is given", func() {
+			var (
+				signingKey        *ecdsa.PrivateKey
+				signingKeyPath    string
+				signatureUploader *fetcherFakes.SignatureUploader
+			)
+
+			BeforeEach(func() {
+				var err error
+				signingKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+				Expect(err).NotTo(HaveOccurred())
+				keyDER, err := x509.MarshalECPrivateKey(signingKey)
+				Expect(err).NotTo(HaveOccurred())
+				signingKeyPath = filepath.Join(filepath.Dir(kilnfilePath), "signing.key")
+				Expect(ioutil.WriteFile(signingKeyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)).To(Succeed())
+
+				signatureUploader = new(fetcherFakes.SignatureUploader)
+				releaseUploaderFinder.Returns(signingReleaseUploader{releaseUploader, signatureUploader}, nil)
+			})
+
+			It("uploads a signature next to each compiled release", func() {
+				err := command.Execute([]string{
+					"--kilnfile", kilnfilePath,
+					"--releases-directory", releasesPath,
+					"--stemcell-file", stemcellPath,
+					"--upload-target-id", compiledSourceID,
+					"--signing-key", signingKeyPath,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(signatureUploader.UploadSignatureCallCount()).To(Equal(2))
+				for i := 0; i < 2; i++ {
+					remote, signature := signatureUploader.UploadSignatureArgsForCall(i)
+					digest := sha256.Sum256([]byte(blobIDContents(remote.Name + "-" + remote.Version)))
+					decoded, err := base64.StdEncoding.DecodeString(string(signature))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(ecdsa.VerifyASN1(&signingKey.PublicKey, digest[:], decoded)).To(BeTrue())
+				}
+			})
+
+			When("the release source can't store signatures", func() {
+				BeforeEach(func() {
+					releaseUploaderFinder.Returns(releaseUploader, nil)
+				})
+
+				It("errors before compiling anything", func() {
+					err := command.Execute([]string{
+						"--kilnfile", kilnfilePath,
+						"--releases-directory", releasesPath,
+						"--stemcell-file", stemcellPath,
+						"--upload-target-id", compiledSourceID,
+						"--signing-key", signingKeyPath,
+					})
+					Expect(err).To(MatchError(`release source "compiled" can't store signatures`))
+					Expect(boshDirector.UploadReleaseFileCallCount()).To(Equal(0))
+				})
+			})
+		})
+
 		When("using parallel option", func() {
 			BeforeEach(func() {
 				kilnfileLock = cargo.KilnfileLock{
@@ -442,7 +512,7 @@ var _ = Describe("CompileBuiltReleases", func() {
 						{Name: "route-emitter", Version: "8.9.10", RemoteSource: builtSourceID, RemotePath: "/remote/path/route-emitter-8.9.10.tgz", SHA1: "original-sha"},
 						{Name: "bpm", Version: "1.6", RemoteSource: compiledSourceID, RemotePath: "not-used", SHA1: "original-sha"},
 					},
-					Stemcell: cargo.Stemcell{OS: stemcellOS, Version: stemcellVersion},
+					Stemcells: cargo.StemcellCriteria{{OS: stemcellOS, Version: stemcellVersion}},
 				}
 			})
 
@@ -606,6 +676,70 @@ var _ = Describe("CompileBuiltReleases", func() {
 		})
 	})
 
+	When("the Kilnfile.lock has a stemcell for each of several OSes", func() {
+		var otherStemcellPath string
+
+		BeforeEach(func() {
+			kilnfileLock.Stemcells = cargo.StemcellCriteria{
+				{OS: stemcellOS, Version: stemcellVersion},
+				{OS: "inferno", Version: "7"},
+			}
+			kilnfileLock.Releases[1].StemcellOS = "inferno"
+
+			otherStemcellPath = filepath.Join(filepath.Dir(stemcellPath), "other-stemcell.tgz")
+			_, err := test_helpers.WriteStemcellTarball(otherStemcellPath, "inferno", "7", osfs.New(""))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("compiles each release against the stemcell for its OS", func() {
+			err := command.Execute([]string{
+				"--kilnfile", kilnfilePath,
+				"--releases-directory", releasesPath,
+				"--stemcell-file", stemcellPath,
+				"--stemcell-file", otherStemcellPath,
+				"--upload-target-id", compiledSourceID,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(boshDirector.UploadStemcellFileCallCount()).To(Equal(2))
+
+			Expect(releaseUploader.UploadReleaseCallCount()).To(Equal(2))
+			requirement, _ := releaseUploader.UploadReleaseArgsForCall(0)
+			Expect(requirement).To(Equal(release.Requirement{Name: "uaa", Version: "1.2.3", StemcellOS: stemcellOS, StemcellVersion: stemcellVersion}))
+			requirement, _ = releaseUploader.UploadReleaseArgsForCall(1)
+			Expect(requirement).To(Equal(release.Requirement{Name: "capi", Version: "2.3.4", StemcellOS: "inferno", StemcellVersion: "7"}))
+
+			_, _, updatedLock := kilnfileLoader.SaveKilnfileLockArgsForCall(0)
+			Expect(updatedLock.Releases[1].RemotePath).To(Equal("capi/capi-2.3.4-inferno-7.tgz"))
+		})
+
+		It("searches for pre-compiled releases with the stemcell for each release", func() {
+			err := command.Execute([]string{
+				"--kilnfile", kilnfilePath,
+				"--releases-directory", releasesPath,
+				"--stemcell-file", stemcellPath,
+				"--stemcell-file", otherStemcellPath,
+				"--upload-target-id", compiledSourceID,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(compiledReleaseSource.GetMatchedReleaseCallCount()).To(Equal(2))
+			Expect(compiledReleaseSource.GetMatchedReleaseArgsForCall(1)).To(Equal(release.Requirement{Name: "capi", Version: "2.3.4", StemcellOS: "inferno", StemcellVersion: "7"}))
+		})
+
+		It("errors when a stemcell file is missing", func() {
+			err := command.Execute([]string{
+				"--kilnfile", kilnfilePath,
+				"--releases-directory", releasesPath,
+				"--stemcell-file", stemcellPath,
+				"--upload-target-id", compiledSourceID,
+			})
+			Expect(err).To(MatchError("no --stemcell-file was given for the inferno stemcell"))
+			Expect(boshDirector.UploadStemcellFileCallCount()).To(Equal(0))
+			Expect(kilnfileLoader.SaveKilnfileLockCallCount()).To(Equal(0))
+		})
+	})
+
 	When("all of the releases are already compiled in the Kilnfile.lock", func() {
 		BeforeEach(func() {
 			kilnfileLock = cargo.KilnfileLock{
@@ -614,7 +748,7 @@ var _ = Describe("CompileBuiltReleases", func() {
 					{Name: "capi", Version: "2.3.4", RemoteSource: compiledSourceID, RemotePath: "not-used", SHA1: "original-sha"},
 					{Name: "bpm", Version: "1.6", RemoteSource: compiledSourceID, RemotePath: "not-used", SHA1: "original-sha"},
 				},
-				Stemcell: cargo.Stemcell{OS: stemcellOS, Version: stemcellVersion},
+				Stemcells: cargo.StemcellCriteria{{OS: stemcellOS, Version: stemcellVersion}},
 			}
 		})
 		It("doesn't compile any releases", func() {
@@ -694,6 +828,36 @@ var _ = Describe("CompileBuiltReleases", func() {
 			}, nil)
 		})
 
+		When("--dry-run is given", func() {
+			It("prints the planned work and Kilnfile.lock changes without doing them", func() {
+				err := command.Execute([]string{
+					"--kilnfile", kilnfilePath,
+					"--releases-directory", releasesPath,
+					"--stemcell-file", stemcellPath,
+					"--upload-target-id", compiledSourceID,
+					"--dry-run",
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(compiledReleaseSource.DownloadReleaseCallCount()).To(Equal(0))
+				Expect(builtReleaseSource.DownloadReleaseCallCount()).To(Equal(0))
+				Expect(boshDirector.UploadStemcellFileCallCount()).To(Equal(0))
+				Expect(boshDirector.UploadReleaseFileCallCount()).To(Equal(0))
+				Expect(releaseUploader.UploadReleaseCallCount()).To(Equal(0))
+				Expect(kilnfileLoader.SaveKilnfileLockCallCount()).To(Equal(0))
+
+				Expect(string(logBuf.Contents())).To(ContainSubstring(fmt.Sprintf(`Steps:
+  - download pre-compiled uaa 1.2.3 from compiled
+  - compile capi 2.3.4 on the BOSH director with stemcell %s
+  - upload compiled capi 2.3.4 to compiled
+Kilnfile.lock changes:
+  Releases:
+    ~ capi 2.3.4 (in compiled)
+    ~ uaa 1.2.3 (compiled-uaa-remote-path in compiled)
+`, stemcellPath)))
+			})
+		})
+
 		It("doesn't compile that release", func() {
 			err := command.Execute([]string{
 				"--kilnfile", kilnfilePath,
@@ -769,6 +933,7 @@ var _ = Describe("CompileBuiltReleases", func() {
 			s := sha1.New()
 			io.Copy(s, strings.NewReader(blobIDContents("capi-2.3.4")))
 			expectedCapiSha := hex.EncodeToString(s.Sum(nil))
+			expectedCapiSHA256 := fmt.Sprintf("%x", sha256.Sum256([]byte(blobIDContents("capi-2.3.4"))))
 
 			Expect(kilnfileLoader.SaveKilnfileLockCallCount()).To(Equal(1))
 
@@ -789,6 +954,7 @@ var _ = Describe("CompileBuiltReleases", func() {
 						RemoteSource: compiledSourceID,
 						RemotePath:   fmt.Sprintf("capi/capi-2.3.4-%s-%s.tgz", stemcellOS, stemcellVersion),
 						SHA1:         expectedCapiSha,
+						SHA256:       expectedCapiSHA256,
 					},
 					{
 						Name:         "bpm",
@@ -798,7 +964,7 @@ var _ = Describe("CompileBuiltReleases", func() {
 						SHA1:         "original-sha",
 					},
 				},
-				Stemcell: cargo.Stemcell{OS: stemcellOS, Version: stemcellVersion},
+				Stemcells: cargo.StemcellCriteria{{OS: stemcellOS, Version: stemcellVersion}},
 			}))
 		})
 	})
@@ -944,7 +1110,7 @@ var _ = Describe("CompileBuiltReleases", func() {
 						SHA1:         "original-sha",
 					},
 				},
-				Stemcell: cargo.Stemcell{OS: stemcellOS, Version: stemcellVersion},
+				Stemcells: cargo.StemcellCriteria{{OS: stemcellOS, Version: stemcellVersion}},
 			}))
 		})
 	})
@@ -1050,7 +1216,7 @@ var _ = Describe("CompileBuiltReleases", func() {
 					{Name: "uaa", Version: "1.2.3", RemoteSource: "no-such-source", RemotePath: "/remote/path/uaa-1.2.3.tgz", SHA1: "not-used"},
 					{Name: "bpm", Version: "1.6", RemoteSource: compiledSourceID, RemotePath: "not-used", SHA1: "not-used"},
 				},
-				Stemcell: cargo.Stemcell{OS: stemcellOS, Version: stemcellVersion},
+				Stemcells: cargo.StemcellCriteria{{OS: stemcellOS, Version: stemcellVersion}},
 			}
 		})
 
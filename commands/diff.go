@@ -0,0 +1,480 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pivotal-cf/jhanda"
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/yaml.v2"
+
+	"github.com/pivotal-cf/kiln/internal/cargo"
+	"github.com/pivotal-cf/kiln/proofing"
+)
+
+const (
+	diffFormatText     = "text"
+	diffFormatJSON     = "json"
+	diffFormatMarkdown = "markdown"
+)
+
+type Diff struct {
+	outLogger *log.Logger
+	fs        billy.Filesystem
+
+	Options struct {
+		Format string `short:"f" long:"format" default:"text" description:"output format (text, json or markdown)"`
+	}
+}
+
+func NewDiff(outLogger *log.Logger, fs billy.Filesystem) Diff {
+	return Diff{outLogger: outLogger, fs: fs}
+}
+
+// tileDiff is everything that changed between two tiles or two
+// Kilnfile.lock files. Property blueprints and job types are only compared
+// for tiles.
+type tileDiff struct {
+	cargo.KilnfileLockDiff
+	PropertyBlueprints []propertyBlueprintChange `json:"property_blueprints,omitempty"`
+	JobTypes           []jobTypeChange           `json:"job_types,omitempty"`
+}
+
+type propertyBlueprintChange struct {
+	Name       string      `json:"name"`
+	Change     string      `json:"change"`
+	OldType    string      `json:"old_type,omitempty"`
+	NewType    string      `json:"new_type,omitempty"`
+	OldDefault interface{} `json:"old_default,omitempty"`
+	NewDefault interface{} `json:"new_default,omitempty"`
+}
+
+type jobTypeChange struct {
+	Name                string                     `json:"name"`
+	Change              string                     `json:"change"`
+	ResourceDefinitions []resourceDefinitionChange `json:"resource_definitions,omitempty"`
+}
+
+type resourceDefinitionChange struct {
+	Name       string `json:"name"`
+	Change     string `json:"change"`
+	OldDefault int    `json:"old_default"`
+	NewDefault int    `json:"new_default"`
+}
+
+// diffInput is a Kilnfile.lock, or the metadata of a tile along with the
+// Kilnfile.lock equivalent of its releases and stemcell criteria.
+type diffInput struct {
+	lock            cargo.KilnfileLock
+	productTemplate *proofing.ProductTemplate
+}
+
+func (d Diff) Execute(args []string) error {
+	args, err := jhanda.Parse(&d.Options, args)
+	if err != nil {
+		return err
+	}
+
+	if len(args) != 2 {
+		return errors.New("diff requires two tiles or two Kilnfile.lock files: kiln diff [--format text|json|markdown] <old> <new>")
+	}
+
+	switch d.Options.Format {
+	case diffFormatText, diffFormatJSON, diffFormatMarkdown:
+	default:
+		return fmt.Errorf("--format must be %q, %q or %q, got %q", diffFormatText, diffFormatJSON, diffFormatMarkdown, d.Options.Format)
+	}
+
+	from, err := d.readInput(args[0])
+	if err != nil {
+		return err
+	}
+	to, err := d.readInput(args[1])
+	if err != nil {
+		return err
+	}
+	if (from.productTemplate == nil) != (to.productTemplate == nil) {
+		return fmt.Errorf("cannot compare %s with %s: both must be tiles or both must be Kilnfile.lock files", args[0], args[1])
+	}
+
+	diff := tileDiff{KilnfileLockDiff: cargo.DiffKilnfileLocks(from.lock, to.lock)}
+	if from.productTemplate != nil {
+		diff.PropertyBlueprints = diffPropertyBlueprints(from.productTemplate.AllPropertyBlueprints(), to.productTemplate.AllPropertyBlueprints())
+		diff.JobTypes = diffJobTypes(from.productTemplate.JobTypes, to.productTemplate.JobTypes)
+	}
+
+	switch d.Options.Format {
+	case diffFormatJSON:
+		output, err := json.MarshalIndent(diff, "", "  ")
+		if err != nil {
+			return err // not tested
+		}
+		d.outLogger.Println(string(output))
+	case diffFormatMarkdown:
+		d.outLogger.Print(diff.Markdown())
+	default:
+		d.outLogger.Print(diff.String())
+	}
+
+	return nil
+}
+
+func (d Diff) readInput(filePath string) (diffInput, error) {
+	contents, err := readTileMetadata(d.fs, filePath)
+	if err != nil {
+		return diffInput{}, err
+	}
+
+	var kind struct {
+		ProductVersion string `yaml:"product_version"`
+	}
+	err = yaml.Unmarshal(contents, &kind)
+	if err != nil {
+		return diffInput{}, fmt.Errorf("failed to parse %s: %w", filePath, err)
+	}
+
+	if kind.ProductVersion == "" {
+		var lock cargo.KilnfileLock
+		err = yaml.Unmarshal(contents, &lock)
+		if err != nil {
+			return diffInput{}, fmt.Errorf("failed to parse %s: %w", filePath, err)
+		}
+		return diffInput{lock: lock}, nil
+	}
+
+	productTemplate, err := proofing.Parse(bytes.NewReader(contents))
+	if err != nil {
+		return diffInput{}, fmt.Errorf("failed to parse metadata in %s: %w", filePath, err)
+	}
+
+	input := diffInput{productTemplate: &productTemplate}
+	for _, rel := range productTemplate.Releases {
+		input.lock.Releases = append(input.lock.Releases, cargo.ReleaseLock{Name: rel.Name, Version: rel.Version, SHA1: rel.SHA1})
+	}
+	input.lock.Stemcells = cargo.StemcellCriteria{{OS: productTemplate.StemcellCriteria.OS, Version: productTemplate.StemcellCriteria.Version}}
+
+	return input, nil
+}
+
+func diffPropertyBlueprints(from, to []proofing.NormalizedPropertyBlueprint) []propertyBlueprintChange {
+	oldBlueprints := make(map[string]proofing.NormalizedPropertyBlueprint)
+	for _, pb := range from {
+		oldBlueprints[pb.Property] = pb
+	}
+	newBlueprints := make(map[string]proofing.NormalizedPropertyBlueprint)
+	for _, pb := range to {
+		newBlueprints[pb.Property] = pb
+	}
+
+	var changes []propertyBlueprintChange
+	for name, oldBlueprint := range oldBlueprints {
+		newBlueprint, found := newBlueprints[name]
+		switch {
+		case !found:
+			changes = append(changes, propertyBlueprintChange{Name: name, Change: cargo.ChangeRemoved, OldType: oldBlueprint.Type, OldDefault: jsonValue(oldBlueprint.Default)})
+		case oldBlueprint.Type != newBlueprint.Type || !reflect.DeepEqual(oldBlueprint.Default, newBlueprint.Default):
+			changes = append(changes, propertyBlueprintChange{
+				Name:       name,
+				Change:     cargo.ChangeChanged,
+				OldType:    oldBlueprint.Type,
+				NewType:    newBlueprint.Type,
+				OldDefault: jsonValue(oldBlueprint.Default),
+				NewDefault: jsonValue(newBlueprint.Default),
+			})
+		}
+	}
+	for name, newBlueprint := range newBlueprints {
+		if _, found := oldBlueprints[name]; !found {
+			changes = append(changes, propertyBlueprintChange{Name: name, Change: cargo.ChangeAdded, NewType: newBlueprint.Type, NewDefault: jsonValue(newBlueprint.Default)})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+
+	return changes
+}
+
+func diffJobTypes(from, to []proofing.JobType) []jobTypeChange {
+	oldJobTypes := make(map[string]proofing.JobType)
+	for _, jobType := range from {
+		oldJobTypes[jobType.Name] = jobType
+	}
+	newJobTypes := make(map[string]proofing.JobType)
+	for _, jobType := range to {
+		newJobTypes[jobType.Name] = jobType
+	}
+
+	var changes []jobTypeChange
+	for name, oldJobType := range oldJobTypes {
+		newJobType, found := newJobTypes[name]
+		if !found {
+			changes = append(changes, jobTypeChange{Name: name, Change: cargo.ChangeRemoved})
+			continue
+		}
+		resourceChanges := diffResourceDefinitions(oldJobType.ResourceDefinitions, newJobType.ResourceDefinitions)
+		if len(resourceChanges) > 0 {
+			changes = append(changes, jobTypeChange{Name: name, Change: cargo.ChangeChanged, ResourceDefinitions: resourceChanges})
+		}
+	}
+	for name := range newJobTypes {
+		if _, found := oldJobTypes[name]; !found {
+			changes = append(changes, jobTypeChange{Name: name, Change: cargo.ChangeAdded})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+
+	return changes
+}
+
+func diffResourceDefinitions(from, to []proofing.ResourceDefinition) []resourceDefinitionChange {
+	oldDefinitions := make(map[string]proofing.ResourceDefinition)
+	for _, definition := range from {
+		oldDefinitions[definition.Name] = definition
+	}
+
+	var changes []resourceDefinitionChange
+	for _, newDefinition := range to {
+		oldDefinition, found := oldDefinitions[newDefinition.Name]
+		switch {
+		case !found:
+			changes = append(changes, resourceDefinitionChange{Name: newDefinition.Name, Change: cargo.ChangeAdded, NewDefault: newDefinition.Default})
+		case oldDefinition.Default != newDefinition.Default:
+			changes = append(changes, resourceDefinitionChange{Name: newDefinition.Name, Change: cargo.ChangeChanged, OldDefault: oldDefinition.Default, NewDefault: newDefinition.Default})
+		}
+		delete(oldDefinitions, newDefinition.Name)
+	}
+	for name, oldDefinition := range oldDefinitions {
+		changes = append(changes, resourceDefinitionChange{Name: name, Change: cargo.ChangeRemoved, OldDefault: oldDefinition.Default})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+
+	return changes
+}
+
+// jsonValue converts the maps YAML decodes collection defaults into so they
+// can be written as JSON.
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			converted[fmt.Sprintf("%v", key)] = jsonValue(item)
+		}
+		return converted
+	case []interface{}:
+		converted := make([]interface{}, len(v))
+		for i, item := range v {
+			converted[i] = jsonValue(item)
+		}
+		return converted
+	default:
+		return value
+	}
+}
+
+func formatDefault(value interface{}) string {
+	if value == nil {
+		return "none"
+	}
+	if _, isString := value.(string); !isString {
+		if output, err := json.Marshal(value); err == nil {
+			return string(output)
+		}
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+func (diff tileDiff) empty() bool {
+	return len(diff.Releases) == 0 && len(diff.Stemcells) == 0 && len(diff.PropertyBlueprints) == 0 && len(diff.JobTypes) == 0
+}
+
+var changeSymbols = map[string]string{
+	cargo.ChangeAdded:   "+",
+	cargo.ChangeRemoved: "-",
+	cargo.ChangeBumped:  "~",
+	cargo.ChangeChanged: "~",
+}
+
+func (change propertyBlueprintChange) description() string {
+	switch change.Change {
+	case cargo.ChangeAdded:
+		return fmt.Sprintf("%s, default %s", change.NewType, formatDefault(change.NewDefault))
+	case cargo.ChangeRemoved:
+		return change.OldType
+	}
+
+	var parts []string
+	if change.OldType != change.NewType {
+		parts = append(parts, fmt.Sprintf("type %s -> %s", change.OldType, change.NewType))
+	}
+	if !reflect.DeepEqual(change.OldDefault, change.NewDefault) {
+		parts = append(parts, fmt.Sprintf("default %s -> %s", formatDefault(change.OldDefault), formatDefault(change.NewDefault)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (change jobTypeChange) description() string {
+	var parts []string
+	for _, resource := range change.ResourceDefinitions {
+		switch resource.Change {
+		case cargo.ChangeAdded:
+			parts = append(parts, fmt.Sprintf("%s added (default %d)", resource.Name, resource.NewDefault))
+		case cargo.ChangeRemoved:
+			parts = append(parts, fmt.Sprintf("%s removed", resource.Name))
+		default:
+			parts = append(parts, fmt.Sprintf("%s %d -> %d", resource.Name, resource.OldDefault, resource.NewDefault))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func releaseDescription(change cargo.ReleaseChange) string {
+	switch change.Change {
+	case cargo.ChangeAdded:
+		return change.NewVersion
+	case cargo.ChangeRemoved:
+		return change.OldVersion
+	}
+
+	description := change.NewVersion
+	if change.Change == cargo.ChangeBumped {
+		description = fmt.Sprintf("%s -> %s", change.OldVersion, change.NewVersion)
+	}
+
+	var notes []string
+	if change.Change == cargo.ChangeChanged && change.OldSHA1 != change.NewSHA1 {
+		notes = append(notes, fmt.Sprintf("sha1 %s -> %s", change.OldSHA1, change.NewSHA1))
+	}
+	if change.Moved() {
+		if change.NewRemotePath == "" {
+			notes = append(notes, fmt.Sprintf("in %s", change.NewRemoteSource))
+		} else {
+			notes = append(notes, fmt.Sprintf("%s in %s", change.NewRemotePath, change.NewRemoteSource))
+		}
+	}
+	if len(notes) > 0 {
+		description += fmt.Sprintf(" (%s)", strings.Join(notes, "; "))
+	}
+	return description
+}
+
+func stemcellDescription(change cargo.StemcellChange) string {
+	switch change.Change {
+	case cargo.ChangeAdded:
+		return change.NewVersion
+	case cargo.ChangeRemoved:
+		return change.OldVersion
+	}
+	return fmt.Sprintf("%s -> %s", change.OldVersion, change.NewVersion)
+}
+
+// String formats the diff with one line per change, marked + when added,
+// - when removed and ~ when changed.
+func (diff tileDiff) String() string {
+	if diff.empty() {
+		return "No differences.\n"
+	}
+
+	var out strings.Builder
+	section := func(title string, lines []string) {
+		if len(lines) == 0 {
+			return
+		}
+		if out.Len() > 0 {
+			out.WriteString("\n")
+		}
+		fmt.Fprintf(&out, "%s:\n", title)
+		for _, line := range lines {
+			fmt.Fprintf(&out, "  %s\n", line)
+		}
+	}
+
+	var lines []string
+	for _, change := range diff.Releases {
+		lines = append(lines, fmt.Sprintf("%s %s %s", changeSymbols[change.Change], change.Name, releaseDescription(change)))
+	}
+	section("Releases", lines)
+
+	lines = nil
+	for _, change := range diff.Stemcells {
+		lines = append(lines, fmt.Sprintf("%s %s %s", changeSymbols[change.Change], change.OS, stemcellDescription(change)))
+	}
+	section("Stemcells", lines)
+
+	lines = nil
+	for _, change := range diff.PropertyBlueprints {
+		lines = append(lines, fmt.Sprintf("%s %s %s", changeSymbols[change.Change], change.Name, change.description()))
+	}
+	section("Property blueprints", lines)
+
+	lines = nil
+	for _, change := range diff.JobTypes {
+		lines = append(lines, strings.TrimSpace(fmt.Sprintf("%s %s %s", changeSymbols[change.Change], change.Name, change.description())))
+	}
+	section("Job types", lines)
+
+	return out.String()
+}
+
+// Markdown formats the diff as a table per section, ready to paste into
+// release notes.
+func (diff tileDiff) Markdown() string {
+	if diff.empty() {
+		return "No differences.\n"
+	}
+
+	var out strings.Builder
+	table := func(title string, header []string, rows [][]string) {
+		if len(rows) == 0 {
+			return
+		}
+		if out.Len() > 0 {
+			out.WriteString("\n")
+		}
+		fmt.Fprintf(&out, "### %s\n\n", title)
+		fmt.Fprintf(&out, "| %s |\n", strings.Join(header, " | "))
+		fmt.Fprintf(&out, "|%s\n", strings.Repeat(" --- |", len(header)))
+		for _, row := range rows {
+			fmt.Fprintf(&out, "| %s |\n", strings.Join(row, " | "))
+		}
+	}
+
+	var rows [][]string
+	for _, change := range diff.Releases {
+		rows = append(rows, []string{change.Name, change.Change, change.OldVersion, change.NewVersion})
+	}
+	table("Releases", []string{"Release", "Change", "From", "To"}, rows)
+
+	rows = nil
+	for _, change := range diff.Stemcells {
+		rows = append(rows, []string{change.OS, change.Change, change.OldVersion, change.NewVersion})
+	}
+	table("Stemcells", []string{"OS", "Change", "From", "To"}, rows)
+
+	rows = nil
+	for _, change := range diff.PropertyBlueprints {
+		rows = append(rows, []string{"`" + change.Name + "`", change.Change, change.description()})
+	}
+	table("Property blueprints", []string{"Property", "Change", "Details"}, rows)
+
+	rows = nil
+	for _, change := range diff.JobTypes {
+		rows = append(rows, []string{change.Name, change.Change, change.description()})
+	}
+	table("Job types", []string{"Job type", "Change", "Details"}, rows)
+
+	return out.String()
+}
+
+func (d Diff) Usage() jhanda.Usage {
+	return jhanda.Usage{
+		Description:      "Compares two tiles or two Kilnfile.lock files, listing added, removed and bumped releases and stemcells and, for tiles, changed property blueprints and job types.",
+		ShortDescription: "compares two tiles or Kilnfile.lock files",
+		Flags:            d.Options,
+	}
+}
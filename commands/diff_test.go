@@ -0,0 +1,316 @@
+package commands_test
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"log"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/pivotal-cf/jhanda"
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+
+	. "github.com/pivotal-cf/kiln/commands"
+)
+
+var _ = Describe("Diff", func() {
+	const (
+		oldLock = `---
+releases:
+- name: uaa
+  version: 73.3.0
+  sha1: uaa-sha
+- name: nats
+  version: "34"
+  sha1: nats-sha
+stemcell_criteria:
+  os: ubuntu-xenial
+  version: "621.55"
+`
+		newLock = `---
+releases:
+- name: uaa
+  version: 74.0.0
+  sha1: new-uaa-sha
+- name: silk
+  version: 2.28.0
+  sha1: silk-sha
+stemcell_criteria:
+  os: ubuntu-xenial
+  version: "621.61"
+`
+		oldMetadata = `---
+name: example
+product_version: 1.0.0
+releases:
+- name: uaa
+  version: 73.3.0
+  file: uaa-73.3.0.tgz
+  sha1: uaa-sha
+stemcell_criteria:
+  os: ubuntu-xenial
+  version: "621.55"
+property_blueprints:
+- name: port
+  type: port
+  default: 80
+- name: legacy_flag
+  type: boolean
+  default: false
+job_types:
+- name: web
+  resource_label: Web
+  resource_definitions:
+  - name: ephemeral_disk
+    default: 1024
+  - name: persistent_disk
+    default: 0
+- name: clock
+  resource_label: Clock
+`
+		newMetadata = `---
+name: example
+product_version: 1.1.0
+releases:
+- name: uaa
+  version: 74.0.0
+  file: uaa-74.0.0.tgz
+  sha1: new-uaa-sha
+stemcell_criteria:
+  os: ubuntu-xenial
+  version: "621.55"
+property_blueprints:
+- name: port
+  type: port
+  default: 8080
+- name: log_level
+  type: string
+  default: info
+job_types:
+- name: web
+  resource_label: Web
+  resource_definitions:
+  - name: ephemeral_disk
+    default: 2048
+  - name: persistent_disk
+    default: 0
+- name: worker
+  resource_label: Worker
+`
+	)
+
+	var (
+		fs        billy.Filesystem
+		outBuffer *gbytes.Buffer
+		diff      Diff
+	)
+
+	writeTile := func(name, metadata string) {
+		tile, err := fs.Create(name)
+		Expect(err).NotTo(HaveOccurred())
+		zw := zip.NewWriter(tile)
+		w, err := zw.Create("metadata/example.yml")
+		Expect(err).NotTo(HaveOccurred())
+		_, err = w.Write([]byte(metadata))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(zw.Close()).To(Succeed())
+		Expect(tile.Close()).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		fs = memfs.New()
+		outBuffer = gbytes.NewBuffer()
+		diff = NewDiff(log.New(outBuffer, "", 0), fs)
+
+		Expect(util.WriteFile(fs, "old/Kilnfile.lock", []byte(oldLock), 0644)).To(Succeed())
+		Expect(util.WriteFile(fs, "new/Kilnfile.lock", []byte(newLock), 0644)).To(Succeed())
+		writeTile("example-1.0.0.pivotal", oldMetadata)
+		writeTile("example-1.1.0.pivotal", newMetadata)
+	})
+
+	When("given two Kilnfile.lock files", func() {
+		It("lists the release and stemcell changes", func() {
+			err := diff.Execute([]string{"old/Kilnfile.lock", "new/Kilnfile.lock"})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(string(outBuffer.Contents())).To(Equal(`Releases:
+  - nats 34
+  + silk 2.28.0
+  ~ uaa 73.3.0 -> 74.0.0
+
+Stemcells:
+  ~ ubuntu-xenial 621.55 -> 621.61
+`))
+		})
+
+		It("notes releases that were rebuilt or moved to another remote", func() {
+			Expect(util.WriteFile(fs, "old/Kilnfile.lock", []byte(`---
+releases:
+- name: uaa
+  version: 73.3.0
+  sha1: uaa-sha
+  remote_source: bosh.io
+  remote_path: uaa-path
+- name: nats
+  version: "34"
+  sha1: nats-sha
+  remote_source: bosh.io
+  remote_path: nats-path
+`), 0644)).To(Succeed())
+			Expect(util.WriteFile(fs, "new/Kilnfile.lock", []byte(`---
+releases:
+- name: uaa
+  version: 73.3.0
+  sha1: compiled-uaa-sha
+  remote_source: compiled
+  remote_path: compiled-uaa-path
+- name: nats
+  version: "34"
+  sha1: nats-sha
+  remote_source: mirror
+  remote_path: nats-path
+`), 0644)).To(Succeed())
+
+			err := diff.Execute([]string{"old/Kilnfile.lock", "new/Kilnfile.lock"})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(string(outBuffer.Contents())).To(Equal(`Releases:
+  ~ nats 34 (nats-path in mirror)
+  ~ uaa 73.3.0 (sha1 uaa-sha -> compiled-uaa-sha; compiled-uaa-path in compiled)
+`))
+		})
+
+		It("says when there are no differences", func() {
+			err := diff.Execute([]string{"old/Kilnfile.lock", "old/Kilnfile.lock"})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(string(outBuffer.Contents())).To(Equal("No differences.\n"))
+		})
+	})
+
+	When("given two tiles", func() {
+		It("also lists property blueprint and job type changes", func() {
+			err := diff.Execute([]string{"example-1.0.0.pivotal", "example-1.1.0.pivotal"})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(string(outBuffer.Contents())).To(Equal(`Releases:
+  ~ uaa 73.3.0 -> 74.0.0
+
+Property blueprints:
+  - .properties.legacy_flag boolean
+  + .properties.log_level string, default info
+  ~ .properties.port default 80 -> 8080
+
+Job types:
+  - clock
+  ~ web ephemeral_disk 1024 -> 2048
+  + worker
+`))
+		})
+
+		It("writes the changes as markdown tables", func() {
+			err := diff.Execute([]string{"--format", "markdown", "example-1.0.0.pivotal", "example-1.1.0.pivotal"})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(string(outBuffer.Contents())).To(Equal("### Releases\n" +
+				"\n" +
+				"| Release | Change | From | To |\n" +
+				"| --- | --- | --- | --- |\n" +
+				"| uaa | bumped | 73.3.0 | 74.0.0 |\n" +
+				"\n" +
+				"### Property blueprints\n" +
+				"\n" +
+				"| Property | Change | Details |\n" +
+				"| --- | --- | --- |\n" +
+				"| `.properties.legacy_flag` | removed | boolean |\n" +
+				"| `.properties.log_level` | added | string, default info |\n" +
+				"| `.properties.port` | changed | default 80 -> 8080 |\n" +
+				"\n" +
+				"### Job types\n" +
+				"\n" +
+				"| Job type | Change | Details |\n" +
+				"| --- | --- | --- |\n" +
+				"| clock | removed |  |\n" +
+				"| web | changed | ephemeral_disk 1024 -> 2048 |\n" +
+				"| worker | added |  |\n"))
+		})
+
+		It("writes the changes as JSON", func() {
+			err := diff.Execute([]string{"--format", "json", "example-1.0.0.pivotal", "example-1.1.0.pivotal"})
+			Expect(err).NotTo(HaveOccurred())
+
+			var result map[string]interface{}
+			Expect(json.Unmarshal(outBuffer.Contents(), &result)).To(Succeed())
+
+			Expect(result["releases"]).To(ConsistOf(map[string]interface{}{
+				"name":        "uaa",
+				"change":      "bumped",
+				"old_version": "73.3.0",
+				"new_version": "74.0.0",
+				"old_sha1":    "uaa-sha",
+				"new_sha1":    "new-uaa-sha",
+			}))
+			Expect(result["stemcells"]).To(BeEmpty())
+			Expect(result["property_blueprints"]).To(ContainElement(map[string]interface{}{
+				"name":        ".properties.port",
+				"change":      "changed",
+				"old_type":    "port",
+				"new_type":    "port",
+				"old_default": float64(80),
+				"new_default": float64(8080),
+			}))
+			Expect(result["job_types"]).To(ContainElement(map[string]interface{}{
+				"name":   "web",
+				"change": "changed",
+				"resource_definitions": []interface{}{map[string]interface{}{
+					"name":        "ephemeral_disk",
+					"change":      "changed",
+					"old_default": float64(1024),
+					"new_default": float64(2048),
+				}},
+			}))
+		})
+	})
+
+	Context("failure cases", func() {
+		It("requires two files", func() {
+			err := diff.Execute([]string{"old/Kilnfile.lock"})
+			Expect(err).To(MatchError(ContainSubstring("diff requires two tiles or two Kilnfile.lock files")))
+		})
+
+		It("rejects an unknown format", func() {
+			err := diff.Execute([]string{"--format", "html", "old/Kilnfile.lock", "new/Kilnfile.lock"})
+			Expect(err).To(MatchError(`--format must be "text", "json" or "markdown", got "html"`))
+		})
+
+		It("does not compare a tile with a Kilnfile.lock", func() {
+			err := diff.Execute([]string{"example-1.0.0.pivotal", "new/Kilnfile.lock"})
+			Expect(err).To(MatchError("cannot compare example-1.0.0.pivotal with new/Kilnfile.lock: both must be tiles or both must be Kilnfile.lock files"))
+		})
+
+		It("returns an error when a file does not exist", func() {
+			err := diff.Execute([]string{"old/Kilnfile.lock", "missing/Kilnfile.lock"})
+			Expect(err).To(MatchError(ContainSubstring("missing/Kilnfile.lock")))
+		})
+
+		It("returns an error when a file cannot be parsed", func() {
+			Expect(util.WriteFile(fs, "bad/Kilnfile.lock", []byte("%%%"), 0644)).To(Succeed())
+
+			err := diff.Execute([]string{"old/Kilnfile.lock", "bad/Kilnfile.lock"})
+			Expect(err).To(MatchError(ContainSubstring("failed to parse bad/Kilnfile.lock")))
+		})
+	})
+
+	Describe("Usage", func() {
+		It("returns usage information for the command", func() {
+			Expect(diff.Usage()).To(Equal(jhanda.Usage{
+				Description:      "Compares two tiles or two Kilnfile.lock files, listing added, removed and bumped releases and stemcells and, for tiles, changed property blueprints and job types.",
+				ShortDescription: "compares two tiles or Kilnfile.lock files",
+				Flags:            diff.Options,
+			}))
+		})
+	})
+})
@@ -0,0 +1,75 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/pivotal-cf/kiln/internal/cargo"
+)
+
+// dryRunPlan collects what a command that rewrites the Kilnfile.lock would
+// download, compile or upload, so --dry-run can print it along with the
+// Kilnfile.lock changes instead of doing it.
+type dryRunPlan struct {
+	from  cargo.KilnfileLock
+	steps []string
+}
+
+// newDryRunPlan copies lock so the changes made to it can be listed later.
+func newDryRunPlan(lock cargo.KilnfileLock) *dryRunPlan {
+	from := cargo.KilnfileLock{
+		Releases:  append([]cargo.ReleaseLock{}, lock.Releases...),
+		Stemcells: append(cargo.StemcellCriteria{}, lock.Stemcells...),
+	}
+	return &dryRunPlan{from: from}
+}
+
+func (plan *dryRunPlan) addStep(format string, a ...interface{}) {
+	plan.steps = append(plan.steps, fmt.Sprintf(format, a...))
+}
+
+// print logs the planned steps and the changes from the original
+// Kilnfile.lock to lock.
+func (plan *dryRunPlan) print(logger *log.Logger, lock cargo.KilnfileLock) {
+	logger.Println("Dry run; nothing was downloaded, compiled, uploaded or saved.")
+
+	if len(plan.steps) > 0 {
+		logger.Println("Steps:")
+		for _, step := range plan.steps {
+			logger.Printf("  - %s\n", step)
+		}
+	}
+
+	diff := tileDiff{KilnfileLockDiff: cargo.DiffKilnfileLocks(plan.from, withKnownSHA1s(plan.from, lock))}
+	logger.Println("Kilnfile.lock changes:")
+	if diff.empty() {
+		logger.Println("  none")
+		return
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(diff.String(), "\n"), "\n") {
+		if line == "" {
+			logger.Println()
+			continue
+		}
+		logger.Printf("  %s\n", line)
+	}
+}
+
+// withKnownSHA1s copies lock, filling in the SHA1 of each release whose new
+// SHA1 is not known yet from the original Kilnfile.lock, so it is not listed
+// as a change.
+func withKnownSHA1s(from, lock cargo.KilnfileLock) cargo.KilnfileLock {
+	oldSHA1s := make(map[string]string)
+	for _, rel := range from.Releases {
+		oldSHA1s[rel.Name] = rel.SHA1
+	}
+
+	releases := append([]cargo.ReleaseLock{}, lock.Releases...)
+	for i, rel := range releases {
+		if rel.SHA1 == "" {
+			releases[i].SHA1 = oldSHA1s[rel.Name]
+		}
+	}
+	return cargo.KilnfileLock{Releases: releases, Stemcells: lock.Stemcells}
+}
@@ -10,7 +10,7 @@ import (
 )
 
 type MultiReleaseSourceProvider struct {
-	Stub        func(cargo.Kilnfile, bool) fetcher.MultiReleaseSource
+	Stub        func(cargo.Kilnfile, bool) (fetcher.MultiReleaseSource, error)
 	mutex       sync.RWMutex
 	argsForCall []struct {
 		arg1 cargo.Kilnfile
@@ -18,15 +18,17 @@ type MultiReleaseSourceProvider struct {
 	}
 	returns struct {
 		result1 fetcher.MultiReleaseSource
+		result2 error
 	}
 	returnsOnCall map[int]struct {
 		result1 fetcher.MultiReleaseSource
+		result2 error
 	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *MultiReleaseSourceProvider) Spy(arg1 cargo.Kilnfile, arg2 bool) fetcher.MultiReleaseSource {
+func (fake *MultiReleaseSourceProvider) Spy(arg1 cargo.Kilnfile, arg2 bool) (fetcher.MultiReleaseSource, error) {
 	fake.mutex.Lock()
 	ret, specificReturn := fake.returnsOnCall[len(fake.argsForCall)]
 	fake.argsForCall = append(fake.argsForCall, struct {
@@ -39,9 +41,9 @@ func (fake *MultiReleaseSourceProvider) Spy(arg1 cargo.Kilnfile, arg2 bool) fetc
 		return fake.Stub(arg1, arg2)
 	}
 	if specificReturn {
-		return ret.result1
+		return ret.result1, ret.result2
 	}
-	return fake.returns.result1
+	return fake.returns.result1, fake.returns.result2
 }
 
 func (fake *MultiReleaseSourceProvider) CallCount() int {
@@ -50,7 +52,7 @@ func (fake *MultiReleaseSourceProvider) CallCount() int {
 	return len(fake.argsForCall)
 }
 
-func (fake *MultiReleaseSourceProvider) Calls(stub func(cargo.Kilnfile, bool) fetcher.MultiReleaseSource) {
+func (fake *MultiReleaseSourceProvider) Calls(stub func(cargo.Kilnfile, bool) (fetcher.MultiReleaseSource, error)) {
 	fake.mutex.Lock()
 	defer fake.mutex.Unlock()
 	fake.Stub = stub
@@ -62,27 +64,30 @@ func (fake *MultiReleaseSourceProvider) ArgsForCall(i int) (cargo.Kilnfile, bool
 	return fake.argsForCall[i].arg1, fake.argsForCall[i].arg2
 }
 
-func (fake *MultiReleaseSourceProvider) Returns(result1 fetcher.MultiReleaseSource) {
+func (fake *MultiReleaseSourceProvider) Returns(result1 fetcher.MultiReleaseSource, result2 error) {
 	fake.mutex.Lock()
 	defer fake.mutex.Unlock()
 	fake.Stub = nil
 	fake.returns = struct {
 		result1 fetcher.MultiReleaseSource
-	}{result1}
+		result2 error
+	}{result1, result2}
 }
 
-func (fake *MultiReleaseSourceProvider) ReturnsOnCall(i int, result1 fetcher.MultiReleaseSource) {
+func (fake *MultiReleaseSourceProvider) ReturnsOnCall(i int, result1 fetcher.MultiReleaseSource, result2 error) {
 	fake.mutex.Lock()
 	defer fake.mutex.Unlock()
 	fake.Stub = nil
 	if fake.returnsOnCall == nil {
 		fake.returnsOnCall = make(map[int]struct {
 			result1 fetcher.MultiReleaseSource
+			result2 error
 		})
 	}
 	fake.returnsOnCall[i] = struct {
 		result1 fetcher.MultiReleaseSource
-	}{result1}
+		result2 error
+	}{result1, result2}
 }
 
 func (fake *MultiReleaseSourceProvider) Invocations() map[string][][]interface{} {
@@ -19,19 +19,6 @@ type StemcellService struct {
 		result1 map[string]interface{}
 		result2 error
 	}
-	FromKilnfileStub        func(string) (map[string]interface{}, error)
-	fromKilnfileMutex       sync.RWMutex
-	fromKilnfileArgsForCall []struct {
-		arg1 string
-	}
-	fromKilnfileReturns struct {
-		result1 map[string]interface{}
-		result2 error
-	}
-	fromKilnfileReturnsOnCall map[int]struct {
-		result1 map[string]interface{}
-		result2 error
-	}
 	FromTarballStub        func(string) (interface{}, error)
 	fromTarballMutex       sync.RWMutex
 	fromTarballArgsForCall []struct {
@@ -117,69 +104,6 @@ func (fake *StemcellService) FromDirectoriesReturnsOnCall(i int, result1 map[str
 	}{result1, result2}
 }
 
-func (fake *StemcellService) FromKilnfile(arg1 string) (map[string]interface{}, error) {
-	fake.fromKilnfileMutex.Lock()
-	ret, specificReturn := fake.fromKilnfileReturnsOnCall[len(fake.fromKilnfileArgsForCall)]
-	fake.fromKilnfileArgsForCall = append(fake.fromKilnfileArgsForCall, struct {
-		arg1 string
-	}{arg1})
-	fake.recordInvocation("FromKilnfile", []interface{}{arg1})
-	fake.fromKilnfileMutex.Unlock()
-	if fake.FromKilnfileStub != nil {
-		return fake.FromKilnfileStub(arg1)
-	}
-	if specificReturn {
-		return ret.result1, ret.result2
-	}
-	fakeReturns := fake.fromKilnfileReturns
-	return fakeReturns.result1, fakeReturns.result2
-}
-
-func (fake *StemcellService) FromKilnfileCallCount() int {
-	fake.fromKilnfileMutex.RLock()
-	defer fake.fromKilnfileMutex.RUnlock()
-	return len(fake.fromKilnfileArgsForCall)
-}
-
-func (fake *StemcellService) FromKilnfileCalls(stub func(string) (map[string]interface{}, error)) {
-	fake.fromKilnfileMutex.Lock()
-	defer fake.fromKilnfileMutex.Unlock()
-	fake.FromKilnfileStub = stub
-}
-
-func (fake *StemcellService) FromKilnfileArgsForCall(i int) string {
-	fake.fromKilnfileMutex.RLock()
-	defer fake.fromKilnfileMutex.RUnlock()
-	argsForCall := fake.fromKilnfileArgsForCall[i]
-	return argsForCall.arg1
-}
-
-func (fake *StemcellService) FromKilnfileReturns(result1 map[string]interface{}, result2 error) {
-	fake.fromKilnfileMutex.Lock()
-	defer fake.fromKilnfileMutex.Unlock()
-	fake.FromKilnfileStub = nil
-	fake.fromKilnfileReturns = struct {
-		result1 map[string]interface{}
-		result2 error
-	}{result1, result2}
-}
-
-func (fake *StemcellService) FromKilnfileReturnsOnCall(i int, result1 map[string]interface{}, result2 error) {
-	fake.fromKilnfileMutex.Lock()
-	defer fake.fromKilnfileMutex.Unlock()
-	fake.FromKilnfileStub = nil
-	if fake.fromKilnfileReturnsOnCall == nil {
-		fake.fromKilnfileReturnsOnCall = make(map[int]struct {
-			result1 map[string]interface{}
-			result2 error
-		})
-	}
-	fake.fromKilnfileReturnsOnCall[i] = struct {
-		result1 map[string]interface{}
-		result2 error
-	}{result1, result2}
-}
-
 func (fake *StemcellService) FromTarball(arg1 string) (interface{}, error) {
 	fake.fromTarballMutex.Lock()
 	ret, specificReturn := fake.fromTarballReturnsOnCall[len(fake.fromTarballArgsForCall)]
@@ -248,8 +172,6 @@ func (fake *StemcellService) Invocations() map[string][][]interface{} {
 	defer fake.invocationsMutex.RUnlock()
 	fake.fromDirectoriesMutex.RLock()
 	defer fake.fromDirectoriesMutex.RUnlock()
-	fake.fromKilnfileMutex.RLock()
-	defer fake.fromKilnfileMutex.RUnlock()
 	fake.fromTarballMutex.RLock()
 	defer fake.fromTarballMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
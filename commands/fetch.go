@@ -1,11 +1,14 @@
 package commands
 
 import (
+	"errors"
 	"fmt"
 	"github.com/pivotal-cf/kiln/release"
 	"gopkg.in/src-d/go-billy.v4/osfs"
 	"log"
 	"os"
+	"strings"
+	"sync"
 
 	"github.com/pivotal-cf/kiln/fetcher"
 
@@ -26,13 +29,15 @@ type Fetch struct {
 		VariablesFiles               []string `short:"vf" long:"variables-file" description:"path to variables file"`
 		Variables                    []string `short:"vr" long:"variable" description:"variable in key=value format"`
 		DownloadThreads              int      `short:"dt" long:"download-threads" description:"number of parallel threads to download parts from S3"`
+		Parallel                     int      `short:"p" long:"parallel" default:"1" description:"number of releases to download at the same time"`
+		ReleaseCache                 string   `long:"release-cache" env:"KILN_RELEASE_CACHE" description:"path to a directory of release tarballs shared between tiles, checked before downloading"`
 		NoConfirm                    bool     `short:"n" long:"no-confirm" description:"non-interactive mode, will delete extra releases in releases dir without prompting"`
 		AllowOnlyPublishableReleases bool     `long:"allow-only-publishable-releases" description:"include releases that would not be shipped with the tile (development builds)"`
 	}
 }
 
 //go:generate counterfeiter -o ./fakes/multi_release_source_provider.go --fake-name MultiReleaseSourceProvider . MultiReleaseSourceProvider
-type MultiReleaseSourceProvider func(cargo.Kilnfile, bool) fetcher.MultiReleaseSource
+type MultiReleaseSourceProvider func(cargo.Kilnfile, bool) (fetcher.MultiReleaseSource, error)
 
 func NewFetch(logger *log.Logger, multiReleaseSourceProvider MultiReleaseSourceProvider, localReleaseDirectory LocalReleaseDirectory) Fetch {
 	return Fetch{
@@ -72,6 +77,37 @@ func (f Fetch) Execute(args []string) error {
 		localReleases = append(localReleases, downloadedReleases...)
 	}
 
+	return f.recordSHA256Sums(kilnfileLock, localReleases)
+}
+
+// recordSHA256Sums adds the SHA256 of each release to Kilnfile.lock entries
+// that only have a SHA1, so older Kilnfile.lock files gain SHA256 checksums
+// the first time their releases are fetched.
+func (f Fetch) recordSHA256Sums(kilnfileLock cargo.KilnfileLock, localReleases []release.Local) error {
+	recorded := 0
+	for i := range kilnfileLock.Releases {
+		lock := &kilnfileLock.Releases[i]
+		if lock.SHA256 != "" {
+			continue
+		}
+		for _, local := range localReleases {
+			if local.Name == lock.Name && local.Version == lock.Version && local.SHA1 == lock.SHA1 && local.SHA256 != "" {
+				lock.SHA256 = local.SHA256
+				recorded++
+				break
+			}
+		}
+	}
+	if recorded == 0 {
+		return nil
+	}
+
+	err := cargo.KilnfileLoader{}.SaveKilnfileLock(osfs.New(""), f.Options.Kilnfile, kilnfileLock)
+	if err != nil {
+		return fmt.Errorf("failed to record SHA256 checksums in the Kilnfile.lock: %w", err)
+	}
+	f.logger.Printf("Recorded SHA256 checksums for %d releases in the Kilnfile.lock", recorded)
+
 	return nil
 }
 
@@ -105,35 +141,169 @@ func (f *Fetch) setup(args []string) (cargo.Kilnfile, cargo.KilnfileLock, []rele
 }
 
 func (f Fetch) downloadMissingReleases(kilnfile cargo.Kilnfile, releaseLocks []cargo.ReleaseLock) ([]release.Local, error) {
-	releaseSource := f.multiReleaseSourceProvider(kilnfile, f.Options.AllowOnlyPublishableReleases)
+	releaseSource, err := f.multiReleaseSourceProvider(kilnfile, f.Options.AllowOnlyPublishableReleases)
+	if err != nil {
+		return nil, err
+	}
+	cache := fetcher.NewReleaseCache(f.Options.ReleaseCache, f.logger)
 
-	var downloaded []release.Local
+	workerCount := f.Options.Parallel
+	if workerCount < 1 {
+		workerCount = 1
+	}
 
-	for _, rl := range releaseLocks {
-		remoteRelease := release.Remote{
-			ID:         release.ID{Name: rl.Name, Version: rl.Version},
-			RemotePath: rl.RemotePath,
-			SourceID:   rl.RemoteSource,
+	type result struct {
+		local release.Local
+		err   error
+	}
+	results := make([]result, len(releaseLocks))
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workerCount; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i].local, results[i].err = f.downloadRelease(releaseSource, cache, releaseLocks[i])
+			}
+		}()
+	}
+	for i := range releaseLocks {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	var (
+		downloaded []release.Local
+		failures   ReleaseDownloadErrors
+	)
+	for i, r := range results {
+		if r.err != nil {
+			failures = append(failures, ReleaseDownloadError{Release: releaseLocks[i], Err: r.err})
+			continue
 		}
+		downloaded = append(downloaded, r.local)
+	}
+
+	if len(failures) > 0 {
+		f.logger.Printf("Downloaded %d of %d missing releases", len(downloaded), len(releaseLocks))
+		return downloaded, failures
+	}
+
+	return downloaded, nil
+}
+
+func (f Fetch) downloadRelease(releaseSource fetcher.MultiReleaseSource, cache fetcher.ReleaseCache, rl cargo.ReleaseLock) (release.Local, error) {
+	remoteRelease := release.Remote{
+		ID:         release.ID{Name: rl.Name, Version: rl.Version},
+		RemotePath: rl.RemotePath,
+		SourceID:   rl.RemoteSource,
+	}
 
-		local, err := releaseSource.DownloadRelease(f.Options.ReleasesDir, remoteRelease, f.Options.DownloadThreads)
+	if rl.SHA1 != "" && cacheable(releaseSource, remoteRelease) {
+		local, found, err := cache.Get(f.Options.ReleasesDir, remoteRelease, rl.SHA1)
 		if err != nil {
-			return nil, fmt.Errorf("download failed: %w", err)
+			f.logger.Printf("warning: release cache lookup for %s failed: %s", rl.Name, err)
 		}
-
-		if local.SHA1 != rl.SHA1 {
-			err = os.Remove(local.LocalPath)
-			if err != nil {
-				return nil, fmt.Errorf("error deleting bad release file %q: %w", local.LocalPath, err) // untested
+		if found {
+			err = checkReleaseChecksums(&local, rl)
+			if err == nil {
+				return local, nil
 			}
+			f.logger.Printf("warning: cached %s can not be used: %s; downloading it instead", rl.Name, err)
+		}
+	}
+
+	local, err := releaseSource.DownloadRelease(f.Options.ReleasesDir, remoteRelease, f.Options.DownloadThreads)
+	if err != nil {
+		return release.Local{}, fmt.Errorf("download failed: %w", err)
+	}
 
-			return nil, fmt.Errorf("downloaded release %q had an incorrect SHA1 - expected %q, got %q", local.LocalPath, rl.SHA1, local.SHA1)
+	err = checkReleaseChecksums(&local, rl)
+	if err != nil {
+		return release.Local{}, err
+	}
+
+	err = cache.Add(local, remoteRelease)
+	if err != nil {
+		f.logger.Printf("warning: could not add %s to the release cache: %s", rl.Name, err)
+	}
+
+	return local, nil
+}
+
+// checkReleaseChecksums deletes the release file when it does not match the
+// checksums in the Kilnfile.lock. The SHA256 is calculated when the release
+// source did not provide it.
+func checkReleaseChecksums(local *release.Local, rl cargo.ReleaseLock) error {
+	if local.SHA1 != rl.SHA1 {
+		err := os.Remove(local.LocalPath)
+		if err != nil {
+			return fmt.Errorf("error deleting bad release file %q: %w", local.LocalPath, err) // untested
 		}
 
-		downloaded = append(downloaded, local)
+		return fmt.Errorf("release %q had an incorrect SHA1 - expected %q, got %q", local.LocalPath, rl.SHA1, local.SHA1)
 	}
 
-	return downloaded, nil
+	if rl.SHA256 == "" {
+		return nil
+	}
+
+	if local.SHA256 == "" {
+		var err error
+		_, local.SHA256, err = fetcher.CalculateSums(local.LocalPath, osfs.New(""))
+		if err != nil {
+			return fmt.Errorf("couldn't calculate the SHA256 of %q: %w", local.LocalPath, err)
+		}
+	}
+	if local.SHA256 != rl.SHA256 {
+		err := os.Remove(local.LocalPath)
+		if err != nil {
+			return fmt.Errorf("error deleting bad release file %q: %w", local.LocalPath, err) // untested
+		}
+
+		return fmt.Errorf("release %q had an incorrect SHA256 - expected %q, got %q", local.LocalPath, rl.SHA256, local.SHA256)
+	}
+
+	return nil
+}
+
+// ReleaseDownloadError is the reason a single release in the Kilnfile.lock could not be fetched.
+type ReleaseDownloadError struct {
+	Release cargo.ReleaseLock
+	Err     error
+}
+
+func (err ReleaseDownloadError) Error() string {
+	return fmt.Sprintf("%s %s: %s", err.Release.Name, err.Release.Version, err.Err)
+}
+
+func (err ReleaseDownloadError) Unwrap() error {
+	return err.Err
+}
+
+// ReleaseDownloadErrors is returned by fetch when some releases could not be downloaded.
+// Releases that were downloaded successfully are left in the releases directory.
+type ReleaseDownloadErrors []ReleaseDownloadError
+
+func (errs ReleaseDownloadErrors) Error() string {
+	var b strings.Builder
+	_, _ = fmt.Fprintf(&b, "failed to fetch %d release(s):", len(errs))
+	for _, err := range errs {
+		_, _ = fmt.Fprintf(&b, "\n- %s", err)
+	}
+	return b.String()
+}
+
+func (errs ReleaseDownloadErrors) Is(target error) bool {
+	for _, err := range errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
 }
 
 func (f Fetch) Usage() jhanda.Usage {
@@ -151,7 +321,7 @@ func partition(releaseLocks []cargo.ReleaseLock, localReleases []release.Local)
 nextRelease:
 	for _, rel := range localReleases {
 		for j, lock := range missing {
-			if rel.Name == lock.Name && rel.Version == lock.Version && rel.SHA1 == lock.SHA1 {
+			if rel.Name == lock.Name && rel.Version == lock.Version && rel.SHA1 == lock.SHA1 && (lock.SHA256 == "" || rel.SHA256 == lock.SHA256) {
 				intersection = append(intersection, rel)
 				missing = append(missing[:j], missing[j+1:]...)
 				continue nextRelease
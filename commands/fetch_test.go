@@ -8,6 +8,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/pivotal-cf/kiln/release"
 
@@ -23,6 +24,11 @@ import (
 	fetcherFakes "github.com/pivotal-cf/kiln/fetcher/fakes"
 )
 
+type verifyingReleaseSource struct {
+	*fetcherFakes.ReleaseSource
+	*fetcherFakes.SignatureVerifier
+}
+
 var _ = Describe("Fetch", func() {
 	var (
 		fetch                       Fetch
@@ -35,6 +41,7 @@ var _ = Describe("Fetch", func() {
 		fakeS3CompiledReleaseSource *fetcherFakes.ReleaseSource
 		fakeBoshIOReleaseSource     *fetcherFakes.ReleaseSource
 		fakeS3BuiltReleaseSource    *fetcherFakes.ReleaseSource
+		s3CompiledReleaseSource     fetcher.ReleaseSource
 		fakeReleaseSources          fetcher.MultiReleaseSource
 		fakeLocalReleaseDirectory   *fakes.LocalReleaseDirectory
 		multiReleaseSourceProvider  MultiReleaseSourceProvider
@@ -47,6 +54,9 @@ var _ = Describe("Fetch", func() {
 		s3CompiledReleaseSourceID = "s3-compiled"
 		s3BuiltReleaseSourceID    = "s3-built"
 		boshIOReleaseSourceID     = fetcher.ReleaseSourceTypeBOSHIO
+
+		correctSHA256 = "15a596e3c98c407e043751ff3b21ff0358a1bdfdf3fe948b1523893a8e5de2e8"
+		wrongSHA256   = "8810ad581e59f2bc3928b261707a71308f7e139eb04820366dc4d5c18d980225"
 	)
 
 	Describe("Execute", func() {
@@ -80,6 +90,7 @@ stemcell_criteria:
 
 			fakeS3CompiledReleaseSource = new(fetcherFakes.ReleaseSource)
 			fakeS3CompiledReleaseSource.IDReturns(s3CompiledReleaseSourceID)
+			s3CompiledReleaseSource = fakeS3CompiledReleaseSource
 			fakeBoshIOReleaseSource = new(fetcherFakes.ReleaseSource)
 			fakeBoshIOReleaseSource.IDReturns(boshIOReleaseSourceID)
 			fakeS3BuiltReleaseSource = new(fetcherFakes.ReleaseSource)
@@ -96,9 +107,9 @@ stemcell_criteria:
 		})
 
 		JustBeforeEach(func() {
-			fakeReleaseSources = fetcher.NewMultiReleaseSource(fakeS3CompiledReleaseSource, fakeBoshIOReleaseSource, fakeS3BuiltReleaseSource)
-			multiReleaseSourceProvider = func(kilnfile cargo.Kilnfile, allowOnlyPublishable bool) fetcher.MultiReleaseSource {
-				return fakeReleaseSources
+			fakeReleaseSources = fetcher.NewMultiReleaseSource(s3CompiledReleaseSource, fakeBoshIOReleaseSource, fakeS3BuiltReleaseSource)
+			multiReleaseSourceProvider = func(kilnfile cargo.Kilnfile, allowOnlyPublishable bool) (fetcher.MultiReleaseSource, error) {
+				return fakeReleaseSources, nil
 			}
 
 			err := ioutil.WriteFile(someKilnfileLockPath, []byte(lockContents), 0644)
@@ -182,6 +193,33 @@ stemcell_criteria:
 					Expect(extras).To(HaveLen(0))
 				})
 			})
+
+			When("the release on disk has the correct SHA1 but the wrong SHA256", func() {
+				BeforeEach(func() {
+					lockContents = strings.Replace(lockContents, "sha1: correct-sha", "sha1: correct-sha\n  sha256: "+correctSHA256, 1)
+					releaseOnDisk = release.Local{
+						ID:        releaseID,
+						LocalPath: fmt.Sprintf("releases/%s-%s.tgz", releaseID.Name, releaseID.Version),
+						SHA1:      "correct-sha",
+						SHA256:    wrongSHA256,
+					}
+					fakeLocalReleaseDirectory.GetLocalReleasesReturns([]release.Local{releaseOnDisk}, nil)
+					fakeS3CompiledReleaseSource.DownloadReleaseReturns(release.Local{
+						ID:        releaseID,
+						LocalPath: fmt.Sprintf("releases/%s-%s.tgz", releaseID.Name, releaseID.Version),
+						SHA1:      "correct-sha",
+						SHA256:    correctSHA256,
+					}, nil)
+				})
+
+				It("downloads the release again", func() {
+					Expect(fetchExecuteErr).NotTo(HaveOccurred())
+					Expect(fakeS3CompiledReleaseSource.DownloadReleaseCallCount()).To(Equal(1))
+
+					extras, _ := fakeLocalReleaseDirectory.DeleteExtraReleasesArgsForCall(0)
+					Expect(extras).To(ConsistOf(releaseOnDisk))
+				})
+			})
 		})
 
 		Context("starting with no releases but all can be downloaded from their source (happy path)", func() {
@@ -261,6 +299,161 @@ stemcell_criteria:
 					release.Remote{ID: boshIOReleaseID, RemotePath: "some-bosh-io-url", SourceID: boshIOReleaseSourceID},
 				))
 			})
+
+			Context("when a release is in the release cache", func() {
+				const cachedSHA1 = "0c93713c1e43fccf897b7b4f02e822c65d557fdf"
+
+				var cacheDir string
+
+				BeforeEach(func() {
+					cacheDir = filepath.Join(tmpDir, "cache")
+
+					cachedTarball := filepath.Join(tmpDir, "lts-compiled-release-1.2.4.tgz")
+					Expect(ioutil.WriteFile(cachedTarball, []byte("cached"), 0644)).To(Succeed())
+					cache := fetcher.NewReleaseCache(cacheDir, logger)
+					Expect(cache.Add(release.Local{ID: s3CompiledReleaseID, LocalPath: cachedTarball, SHA1: cachedSHA1}, release.Remote{})).To(Succeed())
+
+					lockContents = strings.Replace(lockContents, "sha1: correct-sha", "sha1: "+cachedSHA1, -1)
+					fetchExecuteArgs = append(fetchExecuteArgs, "--release-cache", cacheDir)
+				})
+
+				It("links releases with a matching SHA1 instead of downloading them", func() {
+					Expect(fetchExecuteErr).NotTo(HaveOccurred())
+
+					// every release in the lock has the same SHA1 as the cached tarball
+					Expect(fakeS3CompiledReleaseSource.DownloadReleaseCallCount()).To(Equal(0))
+					Expect(fakeS3BuiltReleaseSource.DownloadReleaseCallCount()).To(Equal(0))
+					Expect(fakeBoshIOReleaseSource.DownloadReleaseCallCount()).To(Equal(0))
+					Expect(filepath.Join(someReleasesDirectory, "lts-compiled-release-1.2.4.tgz")).To(BeAnExistingFile())
+				})
+
+				Context("when the cached tarball is corrupted", func() {
+					BeforeEach(func() {
+						Expect(ioutil.WriteFile(filepath.Join(cacheDir, cachedSHA1, "lts-compiled-release-1.2.4.tgz"), []byte("cach"), 0644)).To(Succeed())
+
+						for _, source := range []*fetcherFakes.ReleaseSource{fakeS3CompiledReleaseSource, fakeS3BuiltReleaseSource, fakeBoshIOReleaseSource} {
+							source.DownloadReleaseCalls(func(releasesDir string, remote release.Remote, _ int) (release.Local, error) {
+								return release.Local{ID: remote.ID, LocalPath: filepath.Join(releasesDir, remote.Name+".tgz"), SHA1: cachedSHA1}, nil
+							})
+						}
+					})
+
+					It("downloads the releases instead", func() {
+						Expect(fetchExecuteErr).NotTo(HaveOccurred())
+
+						Expect(fakeS3CompiledReleaseSource.DownloadReleaseCallCount()).To(Equal(1))
+						Expect(fakeS3BuiltReleaseSource.DownloadReleaseCallCount()).To(Equal(1))
+						Expect(fakeBoshIOReleaseSource.DownloadReleaseCallCount()).To(Equal(1))
+						Expect(filepath.Join(cacheDir, cachedSHA1, "lts-compiled-release-1.2.4.tgz")).NotTo(BeAnExistingFile())
+					})
+				})
+
+				Context("when the release source verifies signatures", func() {
+					BeforeEach(func() {
+						signatureVerifier := new(fetcherFakes.SignatureVerifier)
+						signatureVerifier.VerifiesSignaturesReturns(true)
+						s3CompiledReleaseSource = verifyingReleaseSource{fakeS3CompiledReleaseSource, signatureVerifier}
+						fakeS3CompiledReleaseSource.DownloadReleaseReturns(release.Local{ID: s3CompiledReleaseID, LocalPath: "local-path", SHA1: cachedSHA1, SHA256: correctSHA256}, nil)
+					})
+
+					It("downloads the release instead of linking the unsigned cached tarball", func() {
+						Expect(fetchExecuteErr).NotTo(HaveOccurred())
+						Expect(fakeS3CompiledReleaseSource.DownloadReleaseCallCount()).To(Equal(1))
+						Expect(fakeS3BuiltReleaseSource.DownloadReleaseCallCount()).To(Equal(0))
+						Expect(fakeBoshIOReleaseSource.DownloadReleaseCallCount()).To(Equal(0))
+					})
+				})
+
+				Context("when the cached release has the wrong SHA256", func() {
+					BeforeEach(func() {
+						lockContents = strings.Replace(lockContents, "remote_path: some-s3-key\n", "remote_path: some-s3-key\n  sha256: "+correctSHA256+"\n", 1)
+						fakeS3CompiledReleaseSource.DownloadReleaseReturns(release.Local{ID: s3CompiledReleaseID, LocalPath: "local-path", SHA1: cachedSHA1, SHA256: correctSHA256}, nil)
+					})
+
+					It("downloads the release instead", func() {
+						Expect(fetchExecuteErr).NotTo(HaveOccurred())
+						Expect(fakeS3CompiledReleaseSource.DownloadReleaseCallCount()).To(Equal(1))
+						Expect(fakeS3BuiltReleaseSource.DownloadReleaseCallCount()).To(Equal(0))
+					})
+				})
+			})
+
+			Context("when the Kilnfile.lock only has SHA1 checksums", func() {
+				BeforeEach(func() {
+					fakeS3CompiledReleaseSource.DownloadReleaseReturns(
+						release.Local{ID: s3CompiledReleaseID, LocalPath: "local-path", SHA1: "correct-sha", SHA256: correctSHA256},
+						nil)
+					fakeBoshIOReleaseSource.DownloadReleaseReturns(
+						release.Local{ID: boshIOReleaseID, LocalPath: "local-path3", SHA1: "correct-sha", SHA256: correctSHA256},
+						nil)
+				})
+
+				It("records the SHA256 of the downloaded releases", func() {
+					Expect(fetchExecuteErr).NotTo(HaveOccurred())
+
+					var lock cargo.KilnfileLock
+					lockYAML, err := ioutil.ReadFile(someKilnfileLockPath)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(yaml.Unmarshal(lockYAML, &lock)).To(Succeed())
+
+					Expect(lock.Releases).To(HaveLen(3))
+					Expect(lock.Releases[0].SHA256).To(Equal(correctSHA256))
+					Expect(lock.Releases[1].SHA256).To(BeEmpty())
+					Expect(lock.Releases[2].SHA256).To(Equal(correctSHA256))
+				})
+			})
+
+			Context("when the downloaded release has the wrong SHA256", func() {
+				var badReleasePath string
+
+				BeforeEach(func() {
+					lockContents = strings.Replace(lockContents, "remote_path: some-s3-key\n", "remote_path: some-s3-key\n  sha256: "+correctSHA256+"\n", 1)
+
+					badReleasePath = filepath.Join(someReleasesDirectory, "local-path")
+					fakeS3CompiledReleaseSource.DownloadReleaseCalls(func(string, release.Remote, int) (release.Local, error) {
+						Expect(ioutil.WriteFile(badReleasePath, nil, 0644)).To(Succeed())
+						return release.Local{ID: s3CompiledReleaseID, LocalPath: badReleasePath, SHA1: "correct-sha", SHA256: wrongSHA256}, nil
+					})
+				})
+
+				It("errors and deletes the release file from disk", func() {
+					Expect(fetchExecuteErr).To(MatchError(ContainSubstring(fmt.Sprintf("incorrect SHA256 - expected %q, got %q", correctSHA256, wrongSHA256))))
+					Expect(badReleasePath).NotTo(BeAnExistingFile())
+				})
+			})
+		})
+
+		Context("when the Kilnfile.lock has a stemcell for each of several OSes", func() {
+			BeforeEach(func() {
+				lockContents = `---
+releases:
+- name: xenial-release
+  version: "1.2.4"
+  remote_source: ` + s3CompiledReleaseSourceID + `
+  remote_path: xenial-s3-key
+  sha1: correct-sha
+- name: jammy-release
+  version: "1.2.4"
+  remote_source: ` + s3CompiledReleaseSourceID + `
+  remote_path: jammy-s3-key
+  sha1: correct-sha
+  stemcell_os: ubuntu-jammy
+stemcell_criteria:
+- os: ubuntu-xenial
+  version: "621.55"
+- os: ubuntu-jammy
+  version: "1.18"
+`
+				fakeS3CompiledReleaseSource.DownloadReleaseReturns(
+					release.Local{ID: release.ID{Name: "some-release", Version: "1.2.4"}, LocalPath: "local-path", SHA1: "correct-sha"},
+					nil)
+				fakeLocalReleaseDirectory.GetLocalReleasesReturns(nil, nil)
+			})
+
+			It("fetches the releases compiled against each stemcell", func() {
+				Expect(fetchExecuteErr).NotTo(HaveOccurred())
+				Expect(fakeS3CompiledReleaseSource.DownloadReleaseCallCount()).To(Equal(2))
+			})
 		})
 
 		Context("when all releases are already present in releases directory", func() {
@@ -408,6 +601,41 @@ stemcell_criteria:
 					Expect(fetchExecuteErr).To(MatchError(ContainSubstring("download failed")))
 					Expect(errors.Is(fetchExecuteErr, wrappedErr)).To(BeTrue())
 				})
+
+				It("still downloads the other missing releases", func() {
+					Expect(fakeBoshIOReleaseSource.DownloadReleaseCallCount()).To(Equal(1))
+					Expect(fakeS3BuiltReleaseSource.DownloadReleaseCallCount()).To(Equal(1))
+				})
+
+				It("reports which release failed", func() {
+					var downloadErrs ReleaseDownloadErrors
+					Expect(errors.As(fetchExecuteErr, &downloadErrs)).To(BeTrue())
+					Expect(downloadErrs).To(HaveLen(1))
+					Expect(downloadErrs[0].Release.Name).To(Equal(missingReleaseS3CompiledID.Name))
+					Expect(fetchExecuteErr).To(MatchError(ContainSubstring("some-missing-release-on-s3-compiled 4.5.6")))
+				})
+			})
+
+			Context("when releases are downloaded in parallel", func() {
+				BeforeEach(func() {
+					fetchExecuteArgs = append(fetchExecuteArgs, "--parallel", "3")
+				})
+
+				It("downloads all the missing releases", func() {
+					Expect(fetchExecuteErr).NotTo(HaveOccurred())
+
+					Expect(fakeS3CompiledReleaseSource.DownloadReleaseCallCount()).To(Equal(1))
+					_, object, _ := fakeS3CompiledReleaseSource.DownloadReleaseArgsForCall(0)
+					Expect(object).To(Equal(missingReleaseS3Compiled))
+
+					Expect(fakeBoshIOReleaseSource.DownloadReleaseCallCount()).To(Equal(1))
+					_, object, _ = fakeBoshIOReleaseSource.DownloadReleaseArgsForCall(0)
+					Expect(object).To(Equal(missingReleaseBoshIO))
+
+					Expect(fakeS3BuiltReleaseSource.DownloadReleaseCallCount()).To(Equal(1))
+					_, object, _ = fakeS3BuiltReleaseSource.DownloadReleaseArgsForCall(0)
+					Expect(object).To(Equal(missingReleaseS3Built))
+				})
 			})
 
 			Context("when the downloaded release has the wrong sha1", func() {
@@ -502,7 +730,7 @@ stemcell_criteria:
 ---
 release_sources:
   - type: s3
-    compiled: true
+    publishable: true
     bucket: $( variable "bucket" )
     region: $( variable "region" )
     access_key_id: $( variable "access_key" )
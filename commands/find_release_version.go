@@ -40,7 +40,10 @@ func (cmd FindReleaseVersion) Execute(args []string) error {
 	if err != nil {
 		return err
 	}
-	releaseSource := cmd.mrsProvider(kilnfile, false)
+	releaseSource, err := cmd.mrsProvider(kilnfile, false)
+	if err != nil {
+		return err
+	}
 
 	var version string
 	for _, release := range kilnfile.Releases {
@@ -50,11 +53,16 @@ func (cmd FindReleaseVersion) Execute(args []string) error {
 		}
 	}
 
+	stemcell, err := kilnfileLock.ReleaseStemcell(cmd.Options.Release)
+	if err != nil {
+		return err
+	}
+
 	releaseRemote, _, err := releaseSource.FindReleaseVersion(release.Requirement{
 		Name:              cmd.Options.Release,
 		VersionConstraint: version,
-		StemcellVersion:   kilnfileLock.Stemcell.Version,
-		StemcellOS:        kilnfileLock.Stemcell.OS,
+		StemcellVersion:   stemcell.Version,
+		StemcellOS:        stemcell.OS,
 	})
 
 	releaseVersionJson, _ := json.Marshal(releaseVersionOutput{
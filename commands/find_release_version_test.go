@@ -52,9 +52,7 @@ stemcell_criteria:
 releases:
 - name: uaa
   version: ~74.16.0
-  source: bosh.io
-- name: uaac
-  source: bosh.io`
+- name: uaac`
 
 			someKilnfilePath = filepath.Join(tmpDir, "Kilnfile")
 			err = ioutil.WriteFile(someKilnfilePath, []byte(kilnContents), 0644)
@@ -65,8 +63,8 @@ releases:
 		})
 
 		JustBeforeEach(func() {
-			multiReleaseSourceProvider := func(kilnfile cargo.Kilnfile, allowOnlyPublishable bool) fetcher.MultiReleaseSource {
-				return fakeReleasesSource
+			multiReleaseSourceProvider := func(kilnfile cargo.Kilnfile, allowOnlyPublishable bool) (fetcher.MultiReleaseSource, error) {
+				return fakeReleasesSource, nil
 			}
 			findReleaseVersion = commands.NewFindReleaseVersion(logger, multiReleaseSourceProvider)
 
@@ -0,0 +1,298 @@
+package commands
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pivotal-cf/jhanda"
+	"gopkg.in/src-d/go-billy.v4"
+
+	"github.com/pivotal-cf/kiln/proofing"
+)
+
+const (
+	inspectFormatText = "text"
+	inspectFormatJSON = "json"
+)
+
+// SHA1 checks of the release tarballs in a tile.
+const (
+	releaseSHA1Match     = "ok"
+	releaseSHA1Mismatch  = "mismatch"
+	releaseSHA1Missing   = "missing"
+	releaseSHA1Unchecked = "unchecked"
+)
+
+type Inspect struct {
+	outLogger *log.Logger
+	fs        billy.Filesystem
+
+	Options struct {
+		Format string `short:"f" long:"format" default:"text" description:"output format (text or json)"`
+	}
+}
+
+func NewInspect(outLogger *log.Logger, fs billy.Filesystem) Inspect {
+	return Inspect{outLogger: outLogger, fs: fs}
+}
+
+// tileInspection describes what is inside a tile.
+type tileInspection struct {
+	Name               string                    `json:"name"`
+	ProductVersion     string                    `json:"product_version"`
+	MetadataVersion    string                    `json:"metadata_version"`
+	Releases           []inspectedRelease        `json:"releases"`
+	StemcellCriteria   inspectedStemcellCriteria `json:"stemcell_criteria"`
+	JobTypes           []inspectedJobType        `json:"job_types"`
+	PropertyBlueprints []inspectedProperty       `json:"property_blueprints"`
+	Migrations         []string                  `json:"migrations"`
+	EmbeddedFiles      []string                  `json:"embedded_files"`
+}
+
+// inspectedRelease is a release from the tile metadata. SHA1Check reports
+// whether the tarball in the tile has the SHA1 the metadata says it has.
+type inspectedRelease struct {
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	File        string `json:"file"`
+	SHA1        string `json:"sha1"`
+	TarballSHA1 string `json:"tarball_sha1,omitempty"`
+	SHA1Check   string `json:"sha1_check"`
+}
+
+type inspectedStemcellCriteria struct {
+	OS      string `json:"os"`
+	Version string `json:"version"`
+}
+
+type inspectedJobType struct {
+	Name          string `json:"name"`
+	ResourceLabel string `json:"resource_label"`
+	Errand        bool   `json:"errand"`
+}
+
+type inspectedProperty struct {
+	Name         string `json:"name"`
+	Type         string `json:"type"`
+	Configurable bool   `json:"configurable"`
+	Required     bool   `json:"required"`
+}
+
+func (i Inspect) Execute(args []string) error {
+	args, err := jhanda.Parse(&i.Options, args)
+	if err != nil {
+		return err
+	}
+
+	if len(args) != 1 {
+		return errors.New("inspect requires the path to a tile: kiln inspect [--format text|json] <tile.pivotal>")
+	}
+
+	if i.Options.Format != inspectFormatText && i.Options.Format != inspectFormatJSON {
+		return fmt.Errorf("--format must be %q or %q, got %q", inspectFormatText, inspectFormatJSON, i.Options.Format)
+	}
+
+	inspection, err := i.inspect(args[0])
+	if err != nil {
+		return err
+	}
+
+	if i.Options.Format == inspectFormatJSON {
+		output, err := json.MarshalIndent(inspection, "", "  ")
+		if err != nil {
+			return err // not tested
+		}
+		i.outLogger.Println(string(output))
+		return nil
+	}
+
+	i.outLogger.Print(inspection.String())
+	return nil
+}
+
+func (i Inspect) inspect(tilePath string) (tileInspection, error) {
+	f, err := i.fs.Open(tilePath)
+	if err != nil {
+		return tileInspection{}, fmt.Errorf("failed to open tile: %w", err)
+	}
+	defer f.Close()
+
+	info, err := i.fs.Stat(tilePath)
+	if err != nil {
+		return tileInspection{}, err // not tested
+	}
+
+	zr, err := zip.NewReader(f, info.Size())
+	if err != nil {
+		return tileInspection{}, fmt.Errorf("failed to read tile %s: %w", tilePath, err)
+	}
+
+	metadata, err := readZipMetadata(zr, tilePath)
+	if err != nil {
+		return tileInspection{}, err
+	}
+
+	productTemplate, err := proofing.Parse(bytes.NewReader(metadata))
+	if err != nil {
+		return tileInspection{}, fmt.Errorf("failed to parse metadata: %w", err)
+	}
+
+	inspection := tileInspection{
+		Name:            productTemplate.Name,
+		ProductVersion:  productTemplate.ProductVersion,
+		MetadataVersion: productTemplate.MetadataVersion,
+		StemcellCriteria: inspectedStemcellCriteria{
+			OS:      productTemplate.StemcellCriteria.OS,
+			Version: productTemplate.StemcellCriteria.Version,
+		},
+		Releases:           []inspectedRelease{},
+		JobTypes:           []inspectedJobType{},
+		PropertyBlueprints: []inspectedProperty{},
+		Migrations:         []string{},
+		EmbeddedFiles:      []string{},
+	}
+
+	files := make(map[string]*zip.File)
+	for _, file := range zr.File {
+		files[file.Name] = file
+
+		if strings.HasSuffix(file.Name, "/") {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(file.Name, "migrations/"):
+			inspection.Migrations = append(inspection.Migrations, file.Name)
+		case strings.HasPrefix(file.Name, "embed/"):
+			inspection.EmbeddedFiles = append(inspection.EmbeddedFiles, file.Name)
+		}
+	}
+	sort.Strings(inspection.Migrations)
+	sort.Strings(inspection.EmbeddedFiles)
+
+	for _, rel := range productTemplate.Releases {
+		inspected := inspectedRelease{
+			Name:    rel.Name,
+			Version: rel.Version,
+			File:    rel.File,
+			SHA1:    rel.SHA1,
+		}
+
+		tarball, found := files[path.Join("releases", rel.File)]
+		switch {
+		case !found:
+			inspected.SHA1Check = releaseSHA1Missing
+		case rel.SHA1 == "":
+			inspected.SHA1Check = releaseSHA1Unchecked
+		default:
+			inspected.TarballSHA1, err = zipFileSHA1(tarball)
+			if err != nil {
+				return tileInspection{}, fmt.Errorf("failed to read %s from tile: %w", tarball.Name, err)
+			}
+			inspected.SHA1Check = releaseSHA1Match
+			if inspected.TarballSHA1 != rel.SHA1 {
+				inspected.SHA1Check = releaseSHA1Mismatch
+			}
+		}
+
+		inspection.Releases = append(inspection.Releases, inspected)
+	}
+
+	for _, jobType := range productTemplate.JobTypes {
+		inspection.JobTypes = append(inspection.JobTypes, inspectedJobType{
+			Name:          jobType.Name,
+			ResourceLabel: jobType.ResourceLabel,
+			Errand:        jobType.Errand,
+		})
+	}
+
+	for _, pb := range productTemplate.AllPropertyBlueprints() {
+		inspection.PropertyBlueprints = append(inspection.PropertyBlueprints, inspectedProperty{
+			Name:         pb.Property,
+			Type:         pb.Type,
+			Configurable: pb.Configurable,
+			Required:     pb.Required,
+		})
+	}
+
+	return inspection, nil
+}
+
+func zipFileSHA1(file *zip.File) (string, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	hash := sha1.New()
+	_, err = io.Copy(hash, rc)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+// String formats the inspection as tables for people to read.
+func (inspection tileInspection) String() string {
+	var out strings.Builder
+	w := tabwriter.NewWriter(&out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(w, "Name:\t%s\n", inspection.Name)
+	fmt.Fprintf(w, "Product version:\t%s\n", inspection.ProductVersion)
+	fmt.Fprintf(w, "Metadata version:\t%s\n", inspection.MetadataVersion)
+	fmt.Fprintf(w, "Stemcell criteria:\t%s %s\n", inspection.StemcellCriteria.OS, inspection.StemcellCriteria.Version)
+
+	fmt.Fprintf(w, "\nReleases:\n")
+	fmt.Fprintf(w, "  NAME\tVERSION\tFILE\tSHA1\tCHECK\n")
+	for _, rel := range inspection.Releases {
+		check := rel.SHA1Check
+		if rel.SHA1Check == releaseSHA1Mismatch {
+			check = fmt.Sprintf("%s (tarball is %s)", rel.SHA1Check, rel.TarballSHA1)
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\n", rel.Name, rel.Version, rel.File, rel.SHA1, check)
+	}
+
+	fmt.Fprintf(w, "\nJob types:\n")
+	fmt.Fprintf(w, "  NAME\tLABEL\tERRAND\n")
+	for _, jobType := range inspection.JobTypes {
+		fmt.Fprintf(w, "  %s\t%s\t%t\n", jobType.Name, jobType.ResourceLabel, jobType.Errand)
+	}
+
+	fmt.Fprintf(w, "\nProperty blueprints:\n")
+	fmt.Fprintf(w, "  NAME\tTYPE\tCONFIGURABLE\tREQUIRED\n")
+	for _, pb := range inspection.PropertyBlueprints {
+		fmt.Fprintf(w, "  %s\t%s\t%t\t%t\n", pb.Name, pb.Type, pb.Configurable, pb.Required)
+	}
+
+	fmt.Fprintf(w, "\nMigrations:\n")
+	for _, migration := range inspection.Migrations {
+		fmt.Fprintf(w, "  %s\n", migration)
+	}
+
+	fmt.Fprintf(w, "\nEmbedded files:\n")
+	for _, file := range inspection.EmbeddedFiles {
+		fmt.Fprintf(w, "  %s\n", file)
+	}
+
+	_ = w.Flush()
+	return out.String()
+}
+
+func (i Inspect) Usage() jhanda.Usage {
+	return jhanda.Usage{
+		Description:      "Lists the releases, stemcell criteria, job types, property blueprints, migrations and embedded files in a tile, checking each release tarball against the SHA1 in the tile metadata.",
+		ShortDescription: "describes the contents of a tile",
+		Flags:            i.Options,
+	}
+}
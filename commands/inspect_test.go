@@ -0,0 +1,216 @@
+package commands_test
+
+import (
+	"archive/zip"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/pivotal-cf/jhanda"
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+
+	. "github.com/pivotal-cf/kiln/commands"
+)
+
+var _ = Describe("Inspect", func() {
+	const (
+		goodTarball = "good release contents"
+		badTarball  = "tampered release contents"
+	)
+
+	var (
+		fs        billy.Filesystem
+		outBuffer *gbytes.Buffer
+		inspect   Inspect
+	)
+
+	writeTile := func(files map[string]string) {
+		tile, err := fs.Create("example.pivotal")
+		Expect(err).NotTo(HaveOccurred())
+		zw := zip.NewWriter(tile)
+		for name, contents := range files {
+			w, err := zw.Create(name)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = w.Write([]byte(contents))
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(zw.Close()).To(Succeed())
+		Expect(tile.Close()).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		fs = memfs.New()
+		outBuffer = gbytes.NewBuffer()
+		inspect = NewInspect(log.New(outBuffer, "", 0), fs)
+
+		metadata := fmt.Sprintf(`---
+name: example
+label: Example Tile
+product_version: 1.2.3
+metadata_version: "2.7"
+stemcell_criteria:
+  os: ubuntu-xenial
+  version: "621.55"
+releases:
+- name: good-release
+  version: 0.1.0
+  file: good-release-0.1.0.tgz
+  sha1: %x
+- name: bad-release
+  version: 0.2.0
+  file: bad-release-0.2.0.tgz
+  sha1: %x
+- name: missing-release
+  version: 0.3.0
+  file: missing-release-0.3.0.tgz
+  sha1: abc123
+property_blueprints:
+- name: port
+  type: port
+  configurable: true
+job_types:
+- name: web
+  resource_label: Web
+  max_in_flight: 1
+  templates:
+  - name: web-server
+    release: good-release
+- name: smoke-tests
+  resource_label: Smoke Tests
+  errand: true
+  max_in_flight: 1
+  templates:
+  - name: smoke-tests
+    release: good-release
+`, sha1.Sum([]byte(goodTarball)), sha1.Sum([]byte(goodTarball)))
+
+		writeTile(map[string]string{
+			"metadata/example.yml":                            metadata,
+			"releases/good-release-0.1.0.tgz":                 goodTarball,
+			"releases/bad-release-0.2.0.tgz":                  badTarball,
+			"migrations/v1/201603041539_custom_buildpacks.js": "migration",
+			"embed/scripts/install.sh":                        "#!/bin/sh",
+		})
+	})
+
+	When("the format is json", func() {
+		It("describes the tile and checks the release SHA1s", func() {
+			err := inspect.Execute([]string{"--format", "json", "example.pivotal"})
+			Expect(err).NotTo(HaveOccurred())
+
+			var inspection struct {
+				Name             string `json:"name"`
+				ProductVersion   string `json:"product_version"`
+				StemcellCriteria struct {
+					OS      string `json:"os"`
+					Version string `json:"version"`
+				} `json:"stemcell_criteria"`
+				Releases []struct {
+					Name        string `json:"name"`
+					TarballSHA1 string `json:"tarball_sha1"`
+					SHA1Check   string `json:"sha1_check"`
+				} `json:"releases"`
+				JobTypes []struct {
+					Name   string `json:"name"`
+					Errand bool   `json:"errand"`
+				} `json:"job_types"`
+				PropertyBlueprints []struct {
+					Name string `json:"name"`
+					Type string `json:"type"`
+				} `json:"property_blueprints"`
+				Migrations    []string `json:"migrations"`
+				EmbeddedFiles []string `json:"embedded_files"`
+			}
+			Expect(json.Unmarshal(outBuffer.Contents(), &inspection)).To(Succeed())
+
+			Expect(inspection.Name).To(Equal("example"))
+			Expect(inspection.ProductVersion).To(Equal("1.2.3"))
+			Expect(inspection.StemcellCriteria.OS).To(Equal("ubuntu-xenial"))
+			Expect(inspection.StemcellCriteria.Version).To(Equal("621.55"))
+
+			Expect(inspection.Releases).To(HaveLen(3))
+			Expect(inspection.Releases[0].Name).To(Equal("good-release"))
+			Expect(inspection.Releases[0].SHA1Check).To(Equal("ok"))
+			Expect(inspection.Releases[1].Name).To(Equal("bad-release"))
+			Expect(inspection.Releases[1].SHA1Check).To(Equal("mismatch"))
+			Expect(inspection.Releases[1].TarballSHA1).To(Equal(fmt.Sprintf("%x", sha1.Sum([]byte(badTarball)))))
+			Expect(inspection.Releases[2].Name).To(Equal("missing-release"))
+			Expect(inspection.Releases[2].SHA1Check).To(Equal("missing"))
+
+			Expect(inspection.JobTypes).To(HaveLen(2))
+			Expect(inspection.JobTypes[1].Name).To(Equal("smoke-tests"))
+			Expect(inspection.JobTypes[1].Errand).To(BeTrue())
+
+			Expect(inspection.PropertyBlueprints).To(HaveLen(1))
+			Expect(inspection.PropertyBlueprints[0].Name).To(Equal(".properties.port"))
+			Expect(inspection.PropertyBlueprints[0].Type).To(Equal("port"))
+
+			Expect(inspection.Migrations).To(Equal([]string{"migrations/v1/201603041539_custom_buildpacks.js"}))
+			Expect(inspection.EmbeddedFiles).To(Equal([]string{"embed/scripts/install.sh"}))
+		})
+	})
+
+	When("the format is text", func() {
+		It("prints the tile contents as tables", func() {
+			err := inspect.Execute([]string{"example.pivotal"})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(outBuffer).To(gbytes.Say(`Name:\s+example`))
+			Expect(outBuffer).To(gbytes.Say(`Stemcell criteria:\s+ubuntu-xenial 621.55`))
+			Expect(outBuffer).To(gbytes.Say(`good-release\s+0.1.0\s+good-release-0.1.0.tgz\s+[0-9a-f]{40}\s+ok`))
+			Expect(outBuffer).To(gbytes.Say(`bad-release\s+0.2.0\s+bad-release-0.2.0.tgz\s+[0-9a-f]{40}\s+mismatch \(tarball is [0-9a-f]{40}\)`))
+			Expect(outBuffer).To(gbytes.Say(`missing-release\s+0.3.0\s+missing-release-0.3.0.tgz\s+abc123\s+missing`))
+			Expect(outBuffer).To(gbytes.Say(`smoke-tests\s+Smoke Tests\s+true`))
+			Expect(outBuffer).To(gbytes.Say(`\.properties\.port\s+port\s+true\s+true`))
+			Expect(outBuffer).To(gbytes.Say(`migrations/v1/201603041539_custom_buildpacks.js`))
+			Expect(outBuffer).To(gbytes.Say(`embed/scripts/install.sh`))
+		})
+	})
+
+	Context("failure cases", func() {
+		It("requires a tile", func() {
+			err := inspect.Execute([]string{})
+			Expect(err).To(MatchError(ContainSubstring("inspect requires the path to a tile")))
+		})
+
+		It("rejects an unknown format", func() {
+			err := inspect.Execute([]string{"--format", "yaml", "example.pivotal"})
+			Expect(err).To(MatchError(`--format must be "text" or "json", got "yaml"`))
+		})
+
+		It("returns an error when the tile does not exist", func() {
+			err := inspect.Execute([]string{"missing.pivotal"})
+			Expect(err).To(MatchError(ContainSubstring("failed to open tile")))
+		})
+
+		It("returns an error when the file is not a tile", func() {
+			Expect(util.WriteFile(fs, "metadata.yml", []byte("name: example"), 0644)).To(Succeed())
+
+			err := inspect.Execute([]string{"metadata.yml"})
+			Expect(err).To(MatchError(ContainSubstring("failed to read tile metadata.yml")))
+		})
+
+		It("returns an error when the tile has no metadata", func() {
+			writeTile(map[string]string{"releases/good-release-0.1.0.tgz": goodTarball})
+
+			err := inspect.Execute([]string{"example.pivotal"})
+			Expect(err).To(MatchError("tile example.pivotal does not contain metadata/*.yml"))
+		})
+	})
+
+	Describe("Usage", func() {
+		It("returns usage information for the command", func() {
+			Expect(inspect.Usage()).To(Equal(jhanda.Usage{
+				Description:      "Lists the releases, stemcell criteria, job types, property blueprints, migrations and embedded files in a tile, checking each release tarball against the SHA1 in the tile metadata.",
+				ShortDescription: "describes the contents of a tile",
+				Flags:            inspect.Options,
+			}))
+		})
+	})
+})
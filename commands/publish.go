@@ -5,13 +5,13 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
-	"os"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/pivotal-cf/kiln/internal/cargo"
+	"github.com/pivotal-cf/kiln/internal/redact"
 
 	"github.com/Masterminds/semver"
 	"github.com/pivotal-cf/go-pivnet/v2"
@@ -84,8 +84,8 @@ func NewPublish(outLogger, errLogger *log.Logger, fs billy.Filesystem) Publish {
 	}
 }
 
-func (p Publish) Execute(args []string) error {
-	defer p.recoverFromPanic()
+func (p Publish) Execute(args []string) (err error) {
+	defer p.recoverFromPanic(&err)
 
 	kilnfile, buildVersion, err := p.parseArgsAndSetup(args)
 	if err != nil {
@@ -101,12 +101,11 @@ func (p Publish) Execute(args []string) error {
 	return nil
 }
 
-func (p Publish) recoverFromPanic() func() {
-	return func() {
-		if r := recover(); r != nil {
-			p.ErrLogger.Println(r)
-			os.Exit(1)
-		}
+// recoverFromPanic turns a panic, such as one from the Pivnet client, into
+// an error with the Pivnet token and Kilnfile credentials masked.
+func (p Publish) recoverFromPanic(err *error) {
+	if r := recover(); r != nil {
+		*err = redact.Error(fmt.Errorf("publish failed unexpectedly: %v", r))
 	}
 }
 
@@ -115,6 +114,7 @@ func (p *Publish) parseArgsAndSetup(args []string) (cargo.Kilnfile, *semver.Vers
 	if err != nil {
 		return cargo.Kilnfile{}, nil, err
 	}
+	redact.Add(p.Options.PivnetToken)
 
 	if p.Now == nil {
 		p.Now = time.Now
@@ -179,6 +179,9 @@ func (p *Publish) parseArgsAndSetup(args []string) (cargo.Kilnfile, *semver.Vers
 	if err := yaml.NewDecoder(file).Decode(&kilnfile); err != nil {
 		return cargo.Kilnfile{}, nil, fmt.Errorf("could not parse Kilnfile: %s", err)
 	}
+	for _, source := range kilnfile.ReleaseSources {
+		redact.Add(source.Secrets()...)
+	}
 
 	window := p.Options.Window
 	if window != "ga" && window != "rc" && window != "beta" && window != "alpha" {
@@ -726,6 +726,20 @@ pre_ga_user_groups:
 				})
 			})
 
+			When("the Pivnet client panics", func() {
+				BeforeEach(func() {
+					executeArgs = []string{"--pivnet-token", "SOME_PANICKING_TOKEN", "--window", "ga"}
+					rs.ListStub = func(string) ([]pivnet.Release, error) {
+						panic("invalid token SOME_PANICKING_TOKEN")
+					}
+				})
+
+				It("returns an error with the token masked", func() {
+					err := publish.Execute(executeArgs)
+					Expect(err).To(MatchError("publish failed unexpectedly: invalid token [redacted]"))
+				})
+			})
+
 			When("the release to be updated is not found", func() {
 				BeforeEach(func() {
 					rs.ListReturns([]pivnet.Release{{Version: "1.2.3-build.1"}}, nil)
@@ -0,0 +1,294 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/pivotal-cf/jhanda"
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/yaml.v2"
+
+	"github.com/pivotal-cf/kiln/fetcher"
+	"github.com/pivotal-cf/kiln/internal/cargo"
+)
+
+const defaultReleaseNotesTemplate = `{{range .Releases -}}
+{{if eq .Change "added"}}- Added {{.Name}} {{.NewVersion}}
+{{else if eq .Change "removed"}}- Removed {{.Name}} {{.OldVersion}}
+{{else if eq .Change "bumped"}}- Bumped {{.Name}} from {{.OldVersion}} to {{.NewVersion}}
+{{else}}- Rebuilt {{.Name}} {{.NewVersion}}
+{{end -}}
+{{with .Changelog}}
+{{indent 2 .}}
+
+{{end -}}
+{{end -}}
+{{range .Stemcells -}}
+{{if eq .Change "added"}}- Added the {{.OS}} stemcell {{.NewVersion}}
+{{else if eq .Change "removed"}}- Removed the {{.OS}} stemcell {{.OldVersion}}
+{{else}}- Bumped the {{.OS}} stemcell from {{.OldVersion}} to {{.NewVersion}}
+{{end -}}
+{{end -}}
+`
+
+type ReleaseNotes struct {
+	Options struct {
+		Kilnfile         string   `short:"kf" long:"kilnfile"          default:"Kilnfile" description:"path to Kilnfile"`
+		From             string   `           long:"from"              required:"true"    description:"git ref or path of the Kilnfile.lock to compare from"`
+		To               string   `           long:"to"                                   description:"path of the Kilnfile.lock to compare to (defaults to the Kilnfile.lock next to --kilnfile)"`
+		Template         string   `short:"t"  long:"template"                             description:"path to a text/template for the release notes (defaults to a Markdown list)"`
+		OutputFile       string   `short:"o"  long:"output-file"                          description:"path to write the release notes to instead of stdout"`
+		GithubChangelogs bool     `           long:"github-changelogs"                    description:"includes the GitHub release notes of each new release version, found with the github release sources in the Kilnfile"`
+		Variables        []string `short:"vr" long:"variable"                             description:"variable in key=value format"`
+		VariablesFiles   []string `short:"vf" long:"variables-file"                       description:"path to variables file"`
+	}
+
+	FS             billy.Filesystem
+	KilnfileLoader KilnfileLoader
+	HTTPClient     *http.Client
+
+	// ReadGitFile reads a file as it was at a git ref in the repository
+	// containing directory.
+	ReadGitFile func(directory, ref, fileName string) ([]byte, error)
+
+	OutLogger *log.Logger
+}
+
+func NewReleaseNotes(outLogger *log.Logger, fs billy.Filesystem, loader KilnfileLoader) ReleaseNotes {
+	return ReleaseNotes{
+		FS:             fs,
+		KilnfileLoader: loader,
+		HTTPClient:     http.DefaultClient,
+		ReadGitFile:    gitShow,
+		OutLogger:      outLogger,
+	}
+}
+
+// releaseNotesData is passed to the release notes template.
+type releaseNotesData struct {
+	From, To  string
+	Releases  []releaseNote
+	Stemcells []cargo.StemcellChange
+}
+
+// releaseNote is a release change with the GitHub release notes of its new
+// version, when --github-changelogs finds them.
+type releaseNote struct {
+	cargo.ReleaseChange
+	Changelog string
+}
+
+func (r ReleaseNotes) Execute(args []string) error {
+	_, err := jhanda.Parse(&r.Options, args)
+	if err != nil {
+		return err
+	}
+
+	toPath := r.Options.To
+	if toPath == "" {
+		toPath = r.Options.Kilnfile + ".lock"
+	}
+
+	notesTemplate, err := r.parseTemplate()
+	if err != nil {
+		return err
+	}
+
+	toLock, err := r.readLock(toPath)
+	if err != nil {
+		return err
+	}
+
+	fromLock, err := r.readFromLock(toPath)
+	if err != nil {
+		return err
+	}
+
+	diff := cargo.DiffKilnfileLocks(fromLock, toLock)
+	data := releaseNotesData{
+		From:      r.Options.From,
+		To:        toPath,
+		Releases:  make([]releaseNote, 0, len(diff.Releases)),
+		Stemcells: diff.Stemcells,
+	}
+	for _, change := range diff.Releases {
+		if change.Change == cargo.ChangeChanged && change.OldSHA1 == change.NewSHA1 {
+			// only moved to another remote; nothing changed in the tile
+			continue
+		}
+		data.Releases = append(data.Releases, releaseNote{ReleaseChange: change})
+	}
+
+	if r.Options.GithubChangelogs {
+		err = r.addChangelogs(data.Releases)
+		if err != nil {
+			return err
+		}
+	}
+
+	var notes bytes.Buffer
+	err = notesTemplate.Execute(&notes, data)
+	if err != nil {
+		return fmt.Errorf("failed to render release notes: %w", err)
+	}
+
+	if r.Options.OutputFile == "" {
+		r.OutLogger.Print(notes.String())
+		return nil
+	}
+
+	f, err := r.FS.Create(r.Options.OutputFile)
+	if err != nil {
+		return fmt.Errorf("failed to write release notes: %w", err)
+	}
+	defer f.Close()
+
+	_, err = f.Write(notes.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to write release notes: %w", err) // not tested
+	}
+
+	return nil
+}
+
+func (r ReleaseNotes) parseTemplate() (*template.Template, error) {
+	templateText := defaultReleaseNotesTemplate
+	if r.Options.Template != "" {
+		f, err := r.FS.Open(r.Options.Template)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open release notes template: %w", err)
+		}
+		defer f.Close()
+
+		contents, err := ioutil.ReadAll(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read release notes template: %w", err) // not tested
+		}
+		templateText = string(contents)
+	}
+
+	notesTemplate, err := template.New("release-notes").Funcs(template.FuncMap{
+		"indent": indent,
+	}).Parse(templateText)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse release notes template: %w", err)
+	}
+
+	return notesTemplate, nil
+}
+
+func (r ReleaseNotes) readLock(lockPath string) (cargo.KilnfileLock, error) {
+	f, err := r.FS.Open(lockPath)
+	if err != nil {
+		return cargo.KilnfileLock{}, fmt.Errorf("failed to open %s: %w", lockPath, err)
+	}
+	defer f.Close()
+
+	contents, err := ioutil.ReadAll(f)
+	if err != nil {
+		return cargo.KilnfileLock{}, fmt.Errorf("failed to read %s: %w", lockPath, err) // not tested
+	}
+
+	return parseLock(lockPath, contents)
+}
+
+// readFromLock reads --from as a file when it exists, otherwise as the
+// Kilnfile.lock at toPath as of the git ref --from.
+func (r ReleaseNotes) readFromLock(toPath string) (cargo.KilnfileLock, error) {
+	if _, err := r.FS.Stat(r.Options.From); err == nil {
+		return r.readLock(r.Options.From)
+	}
+
+	contents, err := r.ReadGitFile(filepath.Dir(toPath), r.Options.From, filepath.Base(toPath))
+	if err != nil {
+		return cargo.KilnfileLock{}, fmt.Errorf("--from %q is not a file and could not be read from git: %w", r.Options.From, err)
+	}
+
+	return parseLock(r.Options.From+":"+toPath, contents)
+}
+
+func parseLock(name string, contents []byte) (cargo.KilnfileLock, error) {
+	var lock cargo.KilnfileLock
+	err := yaml.Unmarshal(contents, &lock)
+	if err != nil {
+		return cargo.KilnfileLock{}, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return lock, nil
+}
+
+func (r ReleaseNotes) addChangelogs(notes []releaseNote) error {
+	kilnfile, _, err := r.KilnfileLoader.LoadKilnfiles(r.FS, r.Options.Kilnfile, r.Options.VariablesFiles, r.Options.Variables)
+	if err != nil {
+		return err
+	}
+
+	var sources []fetcher.GithubReleaseSource
+	for _, config := range kilnfile.ReleaseSources {
+		if config.Type != fetcher.ReleaseSourceTypeGithub {
+			continue
+		}
+		source, err := fetcher.GithubReleaseSourceFromConfig(config, r.OutLogger)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, source.WithHTTPClient(r.HTTPClient))
+	}
+
+	for i, note := range notes {
+		if note.NewVersion == "" {
+			continue
+		}
+		for _, source := range sources {
+			changelog, found, err := source.ReleaseNotes(note.Name, note.NewVersion)
+			if err != nil {
+				return fmt.Errorf("failed to get GitHub release notes for %s %s: %w", note.Name, note.NewVersion, err)
+			}
+			if found {
+				notes[i].Changelog = strings.TrimSpace(changelog)
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+func indent(spaces int, text string) string {
+	padding := strings.Repeat(" ", spaces)
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = padding + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func gitShow(directory, ref, fileName string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", "show", ref+":./"+fileName)
+	cmd.Dir = directory
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git show failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return output, nil
+}
+
+func (r ReleaseNotes) Usage() jhanda.Usage {
+	return jhanda.Usage{
+		Description:      "Renders release notes from the release and stemcell changes between two Kilnfile.lock files, using a text/template.",
+		ShortDescription: "renders release notes from Kilnfile.lock changes",
+		Flags:            r.Options,
+	}
+}
@@ -0,0 +1,252 @@
+package commands_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/jhanda"
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+
+	. "github.com/pivotal-cf/kiln/commands"
+	"github.com/pivotal-cf/kiln/commands/fakes"
+	"github.com/pivotal-cf/kiln/internal/cargo"
+)
+
+var _ = Describe("ReleaseNotes", func() {
+	const (
+		oldLock = `---
+releases:
+- name: uaa
+  version: 73.3.0
+  sha1: uaa-sha
+- name: nats
+  version: "34"
+  sha1: nats-sha
+stemcell_criteria:
+  os: ubuntu-xenial
+  version: "621.55"
+`
+		newLock = `---
+releases:
+- name: uaa
+  version: 74.0.0
+  sha1: new-uaa-sha
+- name: silk
+  version: 2.28.0
+  sha1: silk-sha
+stemcell_criteria:
+  os: ubuntu-xenial
+  version: "621.61"
+`
+	)
+
+	var (
+		fs             billy.Filesystem
+		outBuffer      *gbytes.Buffer
+		kilnfileLoader *fakes.KilnfileLoader
+		gitDirectory   string
+		gitRef         string
+		gitFileName    string
+		releaseNotes   ReleaseNotes
+	)
+
+	BeforeEach(func() {
+		fs = memfs.New()
+		outBuffer = gbytes.NewBuffer()
+		kilnfileLoader = &fakes.KilnfileLoader{}
+		gitDirectory, gitRef, gitFileName = "", "", ""
+
+		Expect(util.WriteFile(fs, "tile/Kilnfile.lock", []byte(newLock), 0644)).To(Succeed())
+		Expect(util.WriteFile(fs, "old/Kilnfile.lock", []byte(oldLock), 0644)).To(Succeed())
+
+		releaseNotes = NewReleaseNotes(log.New(outBuffer, "", 0), fs, kilnfileLoader)
+		releaseNotes.ReadGitFile = func(directory, ref, fileName string) ([]byte, error) {
+			gitDirectory, gitRef, gitFileName = directory, ref, fileName
+			return []byte(oldLock), nil
+		}
+	})
+
+	It("lists the release and stemcell changes since a git ref", func() {
+		err := releaseNotes.Execute([]string{"--kilnfile", "tile/Kilnfile", "--from", "v1.0.0"})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(gitDirectory).To(Equal("tile"))
+		Expect(gitRef).To(Equal("v1.0.0"))
+		Expect(gitFileName).To(Equal("Kilnfile.lock"))
+
+		Expect(string(outBuffer.Contents())).To(Equal(`- Removed nats 34
+- Added silk 2.28.0
+- Bumped uaa from 73.3.0 to 74.0.0
+- Bumped the ubuntu-xenial stemcell from 621.55 to 621.61
+`))
+	})
+
+	It("compares two Kilnfile.lock files", func() {
+		err := releaseNotes.Execute([]string{"--from", "old/Kilnfile.lock", "--to", "tile/Kilnfile.lock"})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(gitRef).To(BeEmpty())
+		Expect(outBuffer).To(gbytes.Say("Bumped uaa from 73.3.0 to 74.0.0"))
+	})
+
+	It("leaves out releases that only moved to another remote", func() {
+		Expect(util.WriteFile(fs, "tile/Kilnfile.lock", []byte(`---
+releases:
+- name: uaa
+  version: 73.3.0
+  sha1: uaa-sha
+  remote_source: compiled
+  remote_path: compiled-uaa-path
+- name: nats
+  version: "34"
+  sha1: nats-sha
+`), 0644)).To(Succeed())
+
+		err := releaseNotes.Execute([]string{"--from", "old/Kilnfile.lock", "--to", "tile/Kilnfile.lock"})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(string(outBuffer.Contents())).To(Equal(`- Removed the ubuntu-xenial stemcell 621.55
+`))
+	})
+
+	It("renders a user supplied template", func() {
+		Expect(util.WriteFile(fs, "notes.md.tmpl", []byte(`## Changes from {{.From}}
+{{range .Releases}}{{if eq .Change "bumped"}}* {{.Name}} {{.NewVersion}}
+{{end}}{{end}}`), 0644)).To(Succeed())
+
+		err := releaseNotes.Execute([]string{"--kilnfile", "tile/Kilnfile", "--from", "v1.0.0", "--template", "notes.md.tmpl", "--output-file", "notes.md"})
+		Expect(err).NotTo(HaveOccurred())
+
+		f, err := fs.Open("notes.md")
+		Expect(err).NotTo(HaveOccurred())
+		defer f.Close()
+		notes, err := ioutil.ReadAll(f)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(notes)).To(Equal("## Changes from v1.0.0\n* uaa 74.0.0\n"))
+	})
+
+	When("--github-changelogs is set", func() {
+		var server *ghttp.Server
+
+		BeforeEach(func() {
+			server = ghttp.NewServer()
+			server.AllowUnhandledRequests = true
+			server.UnhandledRequestStatusCode = http.StatusNotFound
+			server.RouteToHandler("GET", "/repos/cloudfoundry/uaa-release/releases/tags/v74.0.0", ghttp.RespondWith(http.StatusOK, `{
+				"tag_name": "v74.0.0",
+				"body": "Features:\n- SAML logout\n"
+			}`))
+
+			kilnfileLoader.LoadKilnfilesReturns(cargo.Kilnfile{
+				ReleaseSources: []cargo.ReleaseSourceConfig{
+					{Type: "bosh.io"},
+					{Type: "github", Org: "cloudfoundry", Endpoint: server.URL()},
+				},
+			}, cargo.KilnfileLock{}, nil)
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("includes the GitHub release notes of each new version", func() {
+			err := releaseNotes.Execute([]string{"--kilnfile", "tile/Kilnfile", "--from", "v1.0.0", "--github-changelogs", "--variable", "github_token=secret"})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, kilnfilePath, _, variables := kilnfileLoader.LoadKilnfilesArgsForCall(0)
+			Expect(kilnfilePath).To(Equal("tile/Kilnfile"))
+			Expect(variables).To(Equal([]string{"github_token=secret"}))
+
+			Expect(string(outBuffer.Contents())).To(Equal(`- Removed nats 34
+- Added silk 2.28.0
+- Bumped uaa from 73.3.0 to 74.0.0
+
+  Features:
+  - SAML logout
+
+- Bumped the ubuntu-xenial stemcell from 621.55 to 621.61
+`))
+		})
+
+		It("uses the injected HTTP client", func() {
+			releaseNotes.HTTPClient = &http.Client{Transport: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+				return nil, errors.New("no network")
+			})}
+
+			err := releaseNotes.Execute([]string{"--kilnfile", "tile/Kilnfile", "--from", "v1.0.0", "--github-changelogs"})
+			Expect(err).To(MatchError(ContainSubstring("failed to get GitHub release notes for silk 2.28.0")))
+			Expect(err).To(MatchError(ContainSubstring("no network")))
+		})
+
+		It("returns an error when the Kilnfile cannot be loaded", func() {
+			kilnfileLoader.LoadKilnfilesReturns(cargo.Kilnfile{}, cargo.KilnfileLock{}, errors.New("no Kilnfile"))
+
+			err := releaseNotes.Execute([]string{"--kilnfile", "tile/Kilnfile", "--from", "v1.0.0", "--github-changelogs"})
+			Expect(err).To(MatchError("no Kilnfile"))
+		})
+	})
+
+	Context("failure cases", func() {
+		It("returns an error when the git ref cannot be read", func() {
+			releaseNotes.ReadGitFile = func(string, string, string) ([]byte, error) {
+				return nil, errors.New("unknown revision")
+			}
+
+			err := releaseNotes.Execute([]string{"--kilnfile", "tile/Kilnfile", "--from", "v0.0.0"})
+			Expect(err).To(MatchError(`--from "v0.0.0" is not a file and could not be read from git: unknown revision`))
+		})
+
+		It("returns an error when the Kilnfile.lock does not exist", func() {
+			err := releaseNotes.Execute([]string{"--from", "v1.0.0"})
+			Expect(err).To(MatchError(ContainSubstring("failed to open Kilnfile.lock")))
+		})
+
+		It("returns an error when a Kilnfile.lock cannot be parsed", func() {
+			Expect(util.WriteFile(fs, "bad/Kilnfile.lock", []byte("%%%"), 0644)).To(Succeed())
+
+			err := releaseNotes.Execute([]string{"--from", "bad/Kilnfile.lock", "--to", "tile/Kilnfile.lock"})
+			Expect(err).To(MatchError(ContainSubstring("failed to parse bad/Kilnfile.lock")))
+		})
+
+		It("returns an error when the template cannot be parsed", func() {
+			Expect(util.WriteFile(fs, "notes.md.tmpl", []byte("{{range}}"), 0644)).To(Succeed())
+
+			err := releaseNotes.Execute([]string{"--kilnfile", "tile/Kilnfile", "--from", "v1.0.0", "--template", "notes.md.tmpl"})
+			Expect(err).To(MatchError(ContainSubstring("failed to parse release notes template")))
+		})
+
+		It("returns an error when the template cannot be rendered", func() {
+			Expect(util.WriteFile(fs, "notes.md.tmpl", []byte("{{.Missing}}"), 0644)).To(Succeed())
+
+			err := releaseNotes.Execute([]string{"--kilnfile", "tile/Kilnfile", "--from", "v1.0.0", "--template", "notes.md.tmpl"})
+			Expect(err).To(MatchError(ContainSubstring("failed to render release notes")))
+		})
+
+		It("requires --from", func() {
+			err := releaseNotes.Execute([]string{"--kilnfile", "tile/Kilnfile"})
+			Expect(err).To(MatchError(ContainSubstring("missing required flag \"--from\"")))
+		})
+	})
+
+	Describe("Usage", func() {
+		It("returns usage information for the command", func() {
+			Expect(releaseNotes.Usage()).To(Equal(jhanda.Usage{
+				Description:      "Renders release notes from the release and stemcell changes between two Kilnfile.lock files, using a text/template.",
+				ShortDescription: "renders release notes from Kilnfile.lock changes",
+				Flags:            releaseNotes.Options,
+			}))
+		})
+	})
+})
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
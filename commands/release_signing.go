@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/pivotal-cf/kiln/fetcher"
+	"github.com/pivotal-cf/kiln/release"
+)
+
+// releaseSigning signs the releases a command uploads and stores the
+// signatures on the upload target. A nil *releaseSigning signs nothing.
+type releaseSigning struct {
+	signer   fetcher.ReleaseSigner
+	uploader fetcher.SignatureUploader
+}
+
+func newReleaseSigning(privateKey []byte, releaseUploader fetcher.ReleaseUploader, uploadTargetID string) (*releaseSigning, error) {
+	signer, err := fetcher.NewReleaseSigner(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't load the signing key: %w", err)
+	}
+
+	signatureUploader, ok := releaseUploader.(fetcher.SignatureUploader)
+	if !ok {
+		return nil, fmt.Errorf("release source %q can't store signatures", uploadTargetID)
+	}
+
+	return &releaseSigning{signer: signer, uploader: signatureUploader}, nil
+}
+
+func (signing *releaseSigning) sign(tarball io.Reader) ([]byte, error) {
+	if signing == nil {
+		return nil, nil
+	}
+
+	signature, err := signing.signer.Sign(tarball)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't sign the release: %w", err)
+	}
+	return signature, nil
+}
+
+func (signing *releaseSigning) upload(remote release.Remote, signature []byte) error {
+	if signing == nil {
+		return nil
+	}
+
+	err := signing.uploader.UploadSignature(remote, signature)
+	if err != nil {
+		return fmt.Errorf("couldn't upload the signature of %s %s: %w", remote.Name, remote.Version, err)
+	}
+	return nil
+}
@@ -18,6 +18,7 @@ type SyncWithLocal struct {
 		SkipSameVersion bool     `           long:"skip-same-version"                      description:"only update the Kilnfile.lock when the release version has changed'"`
 		Variables       []string `short:"vr" long:"variable"                               description:"variable in key=value format"`
 		VariablesFiles  []string `short:"vf" long:"variables-file"                         description:"path to variables file"`
+		DryRun          bool     `           long:"dry-run"                                description:"prints the planned Kilnfile.lock changes without making them"`
 	}
 	fs                    billy.Filesystem
 	kilnfileLoader        KilnfileLoader
@@ -68,12 +69,19 @@ func (command SyncWithLocal) Execute(args []string) error {
 
 	command.logger.Printf("Found %d releases on disk\n", len(releases))
 
+	plan := newDryRunPlan(kilnfileLock)
+
 	for _, rel := range releases {
+		stemcell, err := kilnfileLock.ReleaseStemcell(rel.Name)
+		if err != nil {
+			return err
+		}
+
 		remotePath, err := remotePather.RemotePath(release.Requirement{
 			Name:            rel.Name,
 			Version:         rel.Version,
-			StemcellOS:      kilnfileLock.Stemcell.OS,
-			StemcellVersion: kilnfileLock.Stemcell.Version,
+			StemcellOS:      stemcell.OS,
+			StemcellVersion: stemcell.Version,
 		})
 		if err != nil {
 			return fmt.Errorf("couldn't generate a remote path for release %q: %w", rel.Name, err)
@@ -96,13 +104,18 @@ func (command SyncWithLocal) Execute(args []string) error {
 		}
 
 		matchingRelease.Version = rel.Version
-		matchingRelease.SHA1 = rel.SHA1
+		matchingRelease.SetChecksums(rel.SHA1, rel.SHA256)
 		matchingRelease.RemoteSource = command.Options.ReleaseSourceID
 		matchingRelease.RemotePath = remotePath
 
 		command.logger.Printf("Updated %s to %s\n", rel.Name, rel.Version)
 	}
 
+	if command.Options.DryRun {
+		plan.print(command.logger, kilnfileLock)
+		return nil
+	}
+
 	err = command.kilnfileLoader.SaveKilnfileLock(command.fs, command.Options.Kilnfile, kilnfileLock)
 	if err != nil {
 		return err
@@ -4,6 +4,7 @@ import (
 	"errors"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
 	. "github.com/pivotal-cf/kiln/commands"
 	"github.com/pivotal-cf/kiln/commands/fakes"
 	fetcherFakes "github.com/pivotal-cf/kiln/fetcher/fakes"
@@ -69,7 +70,7 @@ var _ = Describe("sync-with-local", func() {
 						SHA1:         release2OldSha,
 					},
 				},
-				Stemcell: cargo.Stemcell{OS: stemcellOS, Version: stemcellVersion},
+				Stemcells: cargo.StemcellCriteria{{OS: stemcellOS, Version: stemcellVersion}},
 			}
 
 			localReleaseDirectory = new(fakes.LocalReleaseDirectory)
@@ -141,6 +142,63 @@ var _ = Describe("sync-with-local", func() {
 			}))
 		})
 
+		When("the local releases have SHA256 checksums", func() {
+			const (
+				release1NewSHA256 = "5b2f9c7d8ad4d3e5e7e5b2c8d9b27c6d2e45f0c0f23b1f5e0a1a6f0c3bfe1a02"
+				release2OldSHA256 = "0d6ee8bd7c3a1e4ccfa2be2c4b8e1b7d0fc55b7e0c6f5c7a3b2d1e0f9a8b7c6d"
+			)
+
+			BeforeEach(func() {
+				kilnfileLock.Releases[1].SHA256 = release2OldSHA256
+				localReleaseDirectory.GetLocalReleasesReturns([]release.Local{
+					{
+						ID:        release.ID{Name: release1Name, Version: release1NewVersion},
+						LocalPath: "local-path",
+						SHA1:      release1NewSha,
+						SHA256:    release1NewSHA256,
+					},
+					{
+						ID:        release.ID{Name: release2Name, Version: release2NewVersion},
+						LocalPath: "local-path-2",
+						SHA1:      release2NewSha,
+					},
+				}, nil)
+			})
+
+			It("records them and drops SHA256 checksums of replaced tarballs", func() {
+				err := syncWithLocal.Execute([]string{
+					"--kilnfile", kilnfilePath,
+					"--assume-release-source", releaseSourceID,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				_, _, updatedLockfile := kilnfileLoader.SaveKilnfileLockArgsForCall(0)
+				Expect(updatedLockfile.Releases[0].SHA256).To(Equal(release1NewSHA256))
+				Expect(updatedLockfile.Releases[1].SHA256).To(BeEmpty())
+			})
+		})
+
+		When("--dry-run is given", func() {
+			It("prints the planned Kilnfile.lock changes without saving them", func() {
+				outBuffer := gbytes.NewBuffer()
+				syncWithLocal = NewSyncWithLocal(kilnfileLoader, fs, localReleaseDirectory, remotePatherFinder.Spy, log.New(outBuffer, "", 0))
+
+				err := syncWithLocal.Execute([]string{
+					"--kilnfile", kilnfilePath,
+					"--assume-release-source", releaseSourceID,
+					"--dry-run",
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(kilnfileLoader.SaveKilnfileLockCallCount()).To(Equal(0))
+				Expect(string(outBuffer.Contents())).To(ContainSubstring(`Kilnfile.lock changes:
+  Releases:
+    ~ some-release 1 -> 2 (new-path in some-source)
+    ~ some-release-2 42 -> 43 (new-path-2 in some-source)
+`))
+			})
+		})
+
 		When("one of the releases on disk is the same version as in the Kilnfile.lock", func() {
 			BeforeEach(func() {
 				localReleaseDirectory.GetLocalReleasesReturns([]release.Local{
@@ -233,7 +291,7 @@ var _ = Describe("sync-with-local", func() {
 							SHA1:         release1OldSha,
 						},
 					},
-					Stemcell: cargo.Stemcell{},
+					Stemcells: cargo.StemcellCriteria{{}},
 				}
 			})
 
@@ -0,0 +1,307 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/Masterminds/semver"
+	"github.com/pivotal-cf/jhanda"
+	"gopkg.in/src-d/go-billy.v4"
+
+	"github.com/pivotal-cf/kiln/fetcher"
+	"github.com/pivotal-cf/kiln/internal/cargo"
+	"github.com/pivotal-cf/kiln/release"
+)
+
+type UpdateAll struct {
+	Options struct {
+		Kilnfile                     string   `short:"kf" long:"kilnfile" default:"Kilnfile" description:"path to Kilnfile"`
+		ReleasesDir                  string   `short:"rd" long:"releases-directory" default:"releases" description:"path to a directory to download releases into"`
+		Variables                    []string `short:"vr" long:"variable" description:"variable in key=value format"`
+		VariablesFiles               []string `short:"vf" long:"variables-file" description:"path to variables file"`
+		Only                         []string `long:"only" description:"name of a release to update, instead of every release (may be repeated)"`
+		Exclude                      []string `long:"exclude" description:"name of a release to leave as it is (may be repeated)"`
+		PatchOnly                    bool     `long:"patch-only" description:"only bump releases to newer patch versions of their current major and minor version"`
+		Parallel                     int      `short:"p" long:"parallel" default:"4" description:"number of releases to look up and download at once"`
+		AllowOnlyPublishableReleases bool     `long:"allow-only-publishable-releases" description:"include releases that would not be shipped with the tile (development builds)"`
+		WithoutDownload              bool     `long:"without-download" description:"updates releases without downloading them"`
+		ReleaseCache                 string   `long:"release-cache" env:"KILN_RELEASE_CACHE" description:"path to a directory of release tarballs shared between tiles, checked before downloading"`
+		DryRun                       bool     `long:"dry-run" description:"prints the planned downloads and Kilnfile.lock changes without making them"`
+	}
+	multiReleaseSourceProvider MultiReleaseSourceProvider
+	filesystem                 billy.Filesystem
+	logger                     *log.Logger
+	loader                     KilnfileLoader
+}
+
+func NewUpdateAll(logger *log.Logger, filesystem billy.Filesystem, multiReleaseSourceProvider MultiReleaseSourceProvider, loader KilnfileLoader) UpdateAll {
+	return UpdateAll{
+		logger:                     logger,
+		multiReleaseSourceProvider: multiReleaseSourceProvider,
+		filesystem:                 filesystem,
+		loader:                     loader,
+	}
+}
+
+// releaseBump is the newest version found for a release in the Kilnfile.lock.
+type releaseBump struct {
+	lock     *cargo.ReleaseLock
+	latest   release.Remote
+	found    bool
+	note     string
+	findErr  error
+	local    release.Local
+	fetchErr error
+}
+
+func (bump releaseBump) isUpdate() bool {
+	return bump.found && bump.note == ""
+}
+
+// apply updates the Kilnfile.lock entry to the latest version.
+func (bump releaseBump) apply(sha1, sha256 string) {
+	bump.lock.Version = bump.latest.Version
+	bump.lock.SetChecksums(sha1, sha256)
+	bump.lock.RemoteSource = bump.latest.SourceID
+	bump.lock.RemotePath = bump.latest.RemotePath
+}
+
+func (u UpdateAll) Execute(args []string) error {
+	_, err := jhanda.Parse(&u.Options, args)
+	if err != nil {
+		return err
+	}
+
+	kilnfile, kilnfileLock, err := u.loader.LoadKilnfiles(u.filesystem, u.Options.Kilnfile, u.Options.VariablesFiles, u.Options.Variables)
+	if err != nil {
+		return err
+	}
+
+	bumps, err := u.selectReleases(kilnfileLock)
+	if err != nil {
+		return err
+	}
+
+	releaseSource, err := u.multiReleaseSourceProvider(kilnfile, u.Options.AllowOnlyPublishableReleases)
+	if err != nil {
+		return err
+	}
+
+	u.logger.Printf("Searching for the latest version of %d releases...\n", len(bumps))
+	inParallel(len(bumps), u.Options.Parallel, func(i int) {
+		u.findLatest(releaseSource, kilnfile, kilnfileLock, &bumps[i])
+	})
+
+	var failures ReleaseDownloadErrors
+	for _, bump := range bumps {
+		if bump.findErr != nil {
+			failures = append(failures, ReleaseDownloadError{Release: *bump.lock, Err: bump.findErr})
+		}
+	}
+	if len(failures) > 0 {
+		return failures
+	}
+
+	u.logger.Print(releaseBumpPlan(bumps))
+
+	var updates []*releaseBump
+	for i := range bumps {
+		if bumps[i].isUpdate() {
+			updates = append(updates, &bumps[i])
+		}
+	}
+	if len(updates) == 0 {
+		u.logger.Println("All releases are up to date. No changes made.")
+		return nil
+	}
+
+	if u.Options.DryRun {
+		plan := newDryRunPlan(kilnfileLock)
+		for _, bump := range updates {
+			if !u.Options.WithoutDownload {
+				plan.addStep("download %s %s from %s", bump.lock.Name, bump.latest.Version, bump.latest.SourceID)
+			}
+			bump.apply(bump.latest.SHA, "")
+		}
+		plan.print(u.logger, kilnfileLock)
+		return nil
+	}
+
+	cache := fetcher.NewReleaseCache(u.Options.ReleaseCache, u.logger)
+	inParallel(len(updates), u.Options.Parallel, func(i int) {
+		if u.Options.WithoutDownload {
+			updates[i].latest.SHA, updates[i].fetchErr = releaseSHA1(releaseSource, cache, updates[i].latest, u.logger)
+			return
+		}
+		updates[i].local, updates[i].fetchErr = downloadReleaseUsingCache(releaseSource, cache, u.Options.ReleasesDir, updates[i].latest, u.logger)
+	})
+
+	updated := 0
+	for _, bump := range updates {
+		if bump.fetchErr != nil {
+			failures = append(failures, ReleaseDownloadError{
+				Release: cargo.ReleaseLock{Name: bump.lock.Name, Version: bump.latest.Version},
+				Err:     bump.fetchErr,
+			})
+			continue
+		}
+
+		sha1, sha256 := bump.latest.SHA, ""
+		if !u.Options.WithoutDownload {
+			sha1, sha256 = bump.local.SHA1, bump.local.SHA256
+		}
+		bump.apply(sha1, sha256)
+		updated++
+	}
+
+	if updated > 0 {
+		err = u.loader.SaveKilnfileLock(u.filesystem, u.Options.Kilnfile, kilnfileLock)
+		if err != nil {
+			return err
+		}
+		u.logger.Printf("Updated %d releases. DON'T FORGET TO MAKE A COMMIT AND PR\n", updated)
+	}
+
+	if len(failures) > 0 {
+		return failures
+	}
+	return nil
+}
+
+// selectReleases returns a releaseBump for each release in the Kilnfile.lock
+// allowed by --only and --exclude.
+func (u UpdateAll) selectReleases(kilnfileLock cargo.KilnfileLock) ([]releaseBump, error) {
+	locked := make(map[string]bool)
+	for _, rel := range kilnfileLock.Releases {
+		locked[rel.Name] = true
+	}
+	for _, name := range append(append([]string{}, u.Options.Only...), u.Options.Exclude...) {
+		if !locked[name] {
+			return nil, fmt.Errorf("no release named %q exists in your Kilnfile.lock", name)
+		}
+	}
+
+	var bumps []releaseBump
+	for i, rel := range kilnfileLock.Releases {
+		if len(u.Options.Only) > 0 && !containsString(u.Options.Only, rel.Name) {
+			continue
+		}
+		if containsString(u.Options.Exclude, rel.Name) {
+			continue
+		}
+		bumps = append(bumps, releaseBump{lock: &kilnfileLock.Releases[i]})
+	}
+	return bumps, nil
+}
+
+func (u UpdateAll) findLatest(releaseSource fetcher.MultiReleaseSource, kilnfile cargo.Kilnfile, kilnfileLock cargo.KilnfileLock, bump *releaseBump) {
+	var constraints []string
+	for _, rel := range kilnfile.Releases {
+		if rel.Name == bump.lock.Name && rel.Version != "" {
+			constraints = append(constraints, rel.Version)
+		}
+	}
+
+	current, err := semver.NewVersion(bump.lock.Version)
+	if u.Options.PatchOnly {
+		if err != nil {
+			bump.note = "skipped, the current version is not semantic"
+			return
+		}
+		constraints = append(constraints, fmt.Sprintf("~%d.%d.%d", current.Major(), current.Minor(), current.Patch()))
+	}
+
+	stemcell, err := kilnfileLock.ReleaseStemcell(bump.lock.Name)
+	if err != nil {
+		bump.findErr = err
+		return
+	}
+
+	bump.latest, bump.found, bump.findErr = releaseSource.FindReleaseVersion(release.Requirement{
+		Name:              bump.lock.Name,
+		VersionConstraint: strings.Join(constraints, ", "),
+		StemcellOS:        stemcell.OS,
+		StemcellVersion:   stemcell.Version,
+	})
+	switch {
+	case bump.findErr != nil:
+	case !bump.found:
+		bump.note = "not found"
+	case !isNewerVersion(bump.lock.Version, bump.latest.Version):
+		bump.note = "up to date"
+	}
+}
+
+// isNewerVersion compares semantic versions, and treats any other change of
+// version as newer.
+func isNewerVersion(current, latest string) bool {
+	currentVersion, err := semver.NewVersion(current)
+	if err != nil {
+		return current != latest
+	}
+	latestVersion, err := semver.NewVersion(latest)
+	if err != nil {
+		return current != latest
+	}
+	return latestVersion.GreaterThan(currentVersion)
+}
+
+func releaseBumpPlan(bumps []releaseBump) string {
+	var out bytes.Buffer
+	w := tabwriter.NewWriter(&out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RELEASE\tCURRENT\tLATEST")
+	for _, bump := range bumps {
+		latest := bump.latest.Version
+		if !bump.isUpdate() {
+			latest = "(" + bump.note + ")"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", bump.lock.Name, bump.lock.Version, latest)
+	}
+	_ = w.Flush()
+	return out.String()
+}
+
+// inParallel calls fn for each index below n using up to workers goroutines.
+func inParallel(n, workers int, fn func(i int)) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (u UpdateAll) Usage() jhanda.Usage {
+	return jhanda.Usage{
+		Description:      "Bumps every release in Kilnfile.lock to the newest version allowed by its Kilnfile version constraint",
+		ShortDescription: "bumps all releases to their newest versions",
+		Flags:            u.Options,
+	}
+}
@@ -0,0 +1,234 @@
+package commands_test
+
+import (
+	"errors"
+	"log"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/pivotal-cf/jhanda"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+
+	. "github.com/pivotal-cf/kiln/commands"
+	"github.com/pivotal-cf/kiln/commands/fakes"
+	fetcherFakes "github.com/pivotal-cf/kiln/fetcher/fakes"
+	"github.com/pivotal-cf/kiln/internal/cargo"
+	"github.com/pivotal-cf/kiln/release"
+)
+
+var _ = Describe("UpdateAll", func() {
+	var (
+		updateAll                  UpdateAll
+		outBuffer                  *gbytes.Buffer
+		kilnfileLoader             *fakes.KilnfileLoader
+		multiReleaseSourceProvider *fakes.MultiReleaseSourceProvider
+		releaseSource              *fetcherFakes.MultiReleaseSource
+		latestVersions             map[string]string
+	)
+
+	BeforeEach(func() {
+		outBuffer = gbytes.NewBuffer()
+		kilnfileLoader = new(fakes.KilnfileLoader)
+		releaseSource = new(fetcherFakes.MultiReleaseSource)
+		multiReleaseSourceProvider = new(fakes.MultiReleaseSourceProvider)
+		multiReleaseSourceProvider.Returns(releaseSource, nil)
+
+		kilnfileLoader.LoadKilnfilesReturns(cargo.Kilnfile{
+			Releases: []cargo.ReleaseKiln{
+				{Name: "capi", Version: "~1.8"},
+				{Name: "uaa"},
+			},
+		}, cargo.KilnfileLock{
+			Releases: []cargo.ReleaseLock{
+				{Name: "capi", Version: "1.8.0", SHA1: "old-capi-sha", RemoteSource: "bosh.io", RemotePath: "old-capi-path"},
+				{Name: "uaa", Version: "73.3.0", SHA1: "old-uaa-sha", RemoteSource: "bosh.io", RemotePath: "old-uaa-path"},
+				{Name: "nats", Version: "34", SHA1: "nats-sha", RemoteSource: "bosh.io", RemotePath: "nats-path"},
+			},
+			Stemcells: cargo.StemcellCriteria{{OS: "ubuntu-xenial", Version: "621.61"}},
+		}, nil)
+
+		latestVersions = map[string]string{"capi": "1.8.7", "uaa": "74.1.0", "nats": "34"}
+		releaseSource.FindReleaseVersionStub = func(requirement release.Requirement) (release.Remote, bool, error) {
+			version, ok := latestVersions[requirement.Name]
+			return release.Remote{
+				ID:         release.ID{Name: requirement.Name, Version: version},
+				RemotePath: "new-" + requirement.Name + "-path",
+				SourceID:   "final-pcf-bosh-releases",
+				SHA:        "remote-" + requirement.Name + "-sha",
+			}, ok, nil
+		}
+		releaseSource.DownloadReleaseStub = func(releasesDir string, remote release.Remote, _ int) (release.Local, error) {
+			return release.Local{ID: remote.ID, LocalPath: releasesDir + "/" + remote.Name + ".tgz", SHA1: "new-" + remote.Name + "-sha"}, nil
+		}
+
+		updateAll = NewUpdateAll(log.New(outBuffer, "", 0), memfs.New(), multiReleaseSourceProvider.Spy, kilnfileLoader)
+	})
+
+	savedLock := func() cargo.KilnfileLock {
+		Expect(kilnfileLoader.SaveKilnfileLockCallCount()).To(Equal(1))
+		_, _, lock := kilnfileLoader.SaveKilnfileLockArgsForCall(0)
+		return lock
+	}
+
+	It("bumps every release to the newest version matching its constraint", func() {
+		err := updateAll.Execute([]string{"--kilnfile", "Kilnfile", "--releases-directory", "releases"})
+		Expect(err).NotTo(HaveOccurred())
+
+		requirements := make(map[string]release.Requirement)
+		for i := 0; i < releaseSource.FindReleaseVersionCallCount(); i++ {
+			requirement := releaseSource.FindReleaseVersionArgsForCall(i)
+			requirements[requirement.Name] = requirement
+		}
+		Expect(requirements).To(Equal(map[string]release.Requirement{
+			"capi": {Name: "capi", VersionConstraint: "~1.8", StemcellOS: "ubuntu-xenial", StemcellVersion: "621.61"},
+			"uaa":  {Name: "uaa", StemcellOS: "ubuntu-xenial", StemcellVersion: "621.61"},
+			"nats": {Name: "nats", StemcellOS: "ubuntu-xenial", StemcellVersion: "621.61"},
+		}))
+
+		Expect(releaseSource.DownloadReleaseCallCount()).To(Equal(2))
+
+		Expect(savedLock().Releases).To(Equal([]cargo.ReleaseLock{
+			{Name: "capi", Version: "1.8.7", SHA1: "new-capi-sha", RemoteSource: "final-pcf-bosh-releases", RemotePath: "new-capi-path"},
+			{Name: "uaa", Version: "74.1.0", SHA1: "new-uaa-sha", RemoteSource: "final-pcf-bosh-releases", RemotePath: "new-uaa-path"},
+			{Name: "nats", Version: "34", SHA1: "nats-sha", RemoteSource: "bosh.io", RemotePath: "nats-path"},
+		}))
+	})
+
+	It("prints a plan of the current and latest versions", func() {
+		err := updateAll.Execute(nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(string(outBuffer.Contents())).To(ContainSubstring(`RELEASE  CURRENT  LATEST
+capi     1.8.0    1.8.7
+uaa      73.3.0   74.1.0
+nats     34       (up to date)
+`))
+		Expect(outBuffer).To(gbytes.Say("Updated 2 releases"))
+	})
+
+	It("does not save when every release is up to date", func() {
+		latestVersions = map[string]string{"capi": "1.8.0", "uaa": "73.3.0"}
+
+		err := updateAll.Execute(nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(outBuffer).To(gbytes.Say(`nats\s+34\s+\(not found\)`))
+		Expect(outBuffer).To(gbytes.Say("All releases are up to date"))
+		Expect(kilnfileLoader.SaveKilnfileLockCallCount()).To(Equal(0))
+	})
+
+	It("uses the remote SHA1 with --without-download", func() {
+		err := updateAll.Execute([]string{"--without-download", "--only", "uaa"})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(releaseSource.DownloadReleaseCallCount()).To(Equal(0))
+		Expect(savedLock().Releases[1].SHA1).To(Equal("remote-uaa-sha"))
+	})
+
+	It("downloads releases without a remote SHA1 to calculate it with --without-download", func() {
+		findReleaseVersion := releaseSource.FindReleaseVersionStub
+		releaseSource.FindReleaseVersionStub = func(requirement release.Requirement) (release.Remote, bool, error) {
+			remote, found, err := findReleaseVersion(requirement)
+			remote.SHA = ""
+			return remote, found, err
+		}
+
+		err := updateAll.Execute([]string{"--without-download", "--only", "uaa", "--releases-directory", "releases"})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(releaseSource.DownloadReleaseCallCount()).To(Equal(1))
+		releasesDir, _, _ := releaseSource.DownloadReleaseArgsForCall(0)
+		Expect(releasesDir).NotTo(Equal("releases"))
+		Expect(savedLock().Releases[1].SHA1).To(Equal("new-uaa-sha"))
+	})
+
+	It("prints the planned downloads and Kilnfile.lock changes with --dry-run", func() {
+		err := updateAll.Execute([]string{"--dry-run", "--only", "uaa"})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(releaseSource.DownloadReleaseCallCount()).To(Equal(0))
+		Expect(kilnfileLoader.SaveKilnfileLockCallCount()).To(Equal(0))
+		Expect(string(outBuffer.Contents())).To(ContainSubstring(`Steps:
+  - download uaa 74.1.0 from final-pcf-bosh-releases
+Kilnfile.lock changes:
+  Releases:
+    ~ uaa 73.3.0 -> 74.1.0 (new-uaa-path in final-pcf-bosh-releases)
+`))
+	})
+
+	It("only looks up the releases given with --only", func() {
+		err := updateAll.Execute([]string{"--only", "uaa", "--only", "nats"})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(releaseSource.FindReleaseVersionCallCount()).To(Equal(2))
+		Expect(savedLock().Releases[0].Version).To(Equal("1.8.0"))
+	})
+
+	It("skips the releases given with --exclude", func() {
+		err := updateAll.Execute([]string{"--exclude", "capi"})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(releaseSource.FindReleaseVersionCallCount()).To(Equal(2))
+		Expect(savedLock().Releases[0].Version).To(Equal("1.8.0"))
+		Expect(savedLock().Releases[1].Version).To(Equal("74.1.0"))
+	})
+
+	It("constrains releases to patch versions with --patch-only", func() {
+		err := updateAll.Execute([]string{"--patch-only", "--only", "capi"})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(releaseSource.FindReleaseVersionCallCount()).To(Equal(1))
+		Expect(releaseSource.FindReleaseVersionArgsForCall(0).VersionConstraint).To(Equal("~1.8, ~1.8.0"))
+	})
+
+	Context("failure cases", func() {
+		It("returns an error for an unknown release name", func() {
+			err := updateAll.Execute([]string{"--exclude", "garden"})
+			Expect(err).To(MatchError(`no release named "garden" exists in your Kilnfile.lock`))
+		})
+
+		It("returns an error when the Kilnfiles cannot be loaded", func() {
+			kilnfileLoader.LoadKilnfilesReturns(cargo.Kilnfile{}, cargo.KilnfileLock{}, errors.New("no Kilnfile"))
+
+			err := updateAll.Execute(nil)
+			Expect(err).To(MatchError("no Kilnfile"))
+		})
+
+		It("returns an error and saves nothing when a release cannot be looked up", func() {
+			releaseSource.FindReleaseVersionStub = nil
+			releaseSource.FindReleaseVersionReturns(release.Remote{}, false, errors.New("bosh.io is down"))
+
+			err := updateAll.Execute([]string{"--only", "uaa"})
+			Expect(err).To(MatchError(ContainSubstring("bosh.io is down")))
+			Expect(kilnfileLoader.SaveKilnfileLockCallCount()).To(Equal(0))
+		})
+
+		It("saves the other releases when a download fails", func() {
+			releaseSource.DownloadReleaseStub = func(releasesDir string, remote release.Remote, _ int) (release.Local, error) {
+				if remote.Name == "capi" {
+					return release.Local{}, errors.New("connection reset")
+				}
+				return release.Local{ID: remote.ID, SHA1: "new-" + remote.Name + "-sha"}, nil
+			}
+
+			err := updateAll.Execute(nil)
+			Expect(err).To(MatchError(ContainSubstring("connection reset")))
+			Expect(err).To(MatchError(ContainSubstring("capi")))
+
+			lock := savedLock()
+			Expect(lock.Releases[0].Version).To(Equal("1.8.0"))
+			Expect(lock.Releases[1].Version).To(Equal("74.1.0"))
+		})
+	})
+
+	Describe("Usage", func() {
+		It("returns usage information for the command", func() {
+			Expect(updateAll.Usage()).To(Equal(jhanda.Usage{
+				Description:      "Bumps every release in Kilnfile.lock to the newest version allowed by its Kilnfile version constraint",
+				ShortDescription: "bumps all releases to their newest versions",
+				Flags:            updateAll.Options,
+			}))
+		})
+	})
+})
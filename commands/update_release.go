@@ -23,6 +23,8 @@ type UpdateRelease struct {
 		VariablesFiles               []string `short:"vf" long:"variables-file" description:"path to variables file"`
 		AllowOnlyPublishableReleases bool     `long:"allow-only-publishable-releases" description:"include releases that would not be shipped with the tile (development builds)"`
 		WithoutDownload              bool     `long:"without-download" description:"updates releases without downloading them"`
+		ReleaseCache                 string   `long:"release-cache" env:"KILN_RELEASE_CACHE" description:"path to a directory of release tarballs shared between tiles, checked before downloading"`
+		DryRun                       bool     `long:"dry-run" description:"prints the planned downloads and Kilnfile.lock changes without making them"`
 	}
 	multiReleaseSourceProvider MultiReleaseSourceProvider
 	filesystem                 billy.Filesystem
@@ -77,20 +79,30 @@ func (u UpdateRelease) Execute(args []string) error {
 		)
 	}
 
-	releaseSource := u.multiReleaseSourceProvider(kilnfile, u.Options.AllowOnlyPublishableReleases)
+	stemcell, err := kilnfileLock.ReleaseStemcell(u.Options.Name)
+	if err != nil {
+		return err
+	}
+
+	releaseSource, err := u.multiReleaseSourceProvider(kilnfile, u.Options.AllowOnlyPublishableReleases)
+	if err != nil {
+		return err
+	}
+
+	plan := newDryRunPlan(kilnfileLock)
 
 	u.logger.Println("Searching for the release...")
 
 	var localRelease release.Local
 	var remoteRelease release.Remote
 	var found bool
-	var newVersion, newSHA1, newSourceID, newRemotePath string
+	var newVersion, newSHA1, newSHA256, newSourceID, newRemotePath string
 	if u.Options.WithoutDownload {
 		remoteRelease, found, err = releaseSource.FindReleaseVersion(release.Requirement{
 			Name:              u.Options.Name,
 			VersionConstraint: releaseVersionConstraint,
-			StemcellVersion:   kilnfileLock.Stemcell.Version,
-			StemcellOS:        kilnfileLock.Stemcell.OS,
+			StemcellVersion:   stemcell.Version,
+			StemcellOS:        stemcell.OS,
 		})
 
 		if err != nil {
@@ -105,12 +117,20 @@ func (u UpdateRelease) Execute(args []string) error {
 		newSourceID = remoteRelease.SourceID
 		newRemotePath = remoteRelease.RemotePath
 
+		if !u.Options.DryRun {
+			cache := fetcher.NewReleaseCache(u.Options.ReleaseCache, u.logger)
+			newSHA1, err = releaseSHA1(releaseSource, cache, remoteRelease, u.logger)
+			if err != nil {
+				return fmt.Errorf("error downloading the release: %w", err)
+			}
+		}
+
 	} else {
 		remoteRelease, found, err = releaseSource.GetMatchedRelease(release.Requirement{
 			Name:            u.Options.Name,
 			Version:         u.Options.Version,
-			StemcellOS:      kilnfileLock.Stemcell.OS,
-			StemcellVersion: kilnfileLock.Stemcell.Version,
+			StemcellOS:      stemcell.OS,
+			StemcellVersion: stemcell.Version,
 		})
 
 		if err != nil {
@@ -120,26 +140,38 @@ func (u UpdateRelease) Execute(args []string) error {
 			return fmt.Errorf("couldn't find %q %s in any release source", u.Options.Name, u.Options.Version)
 		}
 
-		localRelease, err = releaseSource.DownloadRelease(u.Options.ReleasesDir, remoteRelease, fetcher.DefaultDownloadThreadCount)
-		if err != nil {
-			return fmt.Errorf("error downloading the release: %w", err)
+		if u.Options.DryRun {
+			plan.addStep("download %s %s from %s", remoteRelease.Name, remoteRelease.Version, remoteRelease.SourceID)
+			localRelease = release.Local{ID: remoteRelease.ID, SHA1: remoteRelease.SHA}
+		} else {
+			cache := fetcher.NewReleaseCache(u.Options.ReleaseCache, u.logger)
+			localRelease, err = downloadReleaseUsingCache(releaseSource, cache, u.Options.ReleasesDir, remoteRelease, u.logger)
+			if err != nil {
+				return fmt.Errorf("error downloading the release: %w", err)
+			}
 		}
 		newVersion = localRelease.Version
 		newSHA1 = localRelease.SHA1
+		newSHA256 = localRelease.SHA256
 		newSourceID = remoteRelease.SourceID
 		newRemotePath = remoteRelease.RemotePath
 	}
 
-	if releaseLock.Version == newVersion && releaseLock.SHA1 == newSHA1 && releaseLock.RemoteSource == newSourceID && releaseLock.RemotePath == newRemotePath {
+	if releaseLock.Version == newVersion && releaseLock.SHA1 == newSHA1 && (newSHA256 == "" || releaseLock.SHA256 == newSHA256) && releaseLock.RemoteSource == newSourceID && releaseLock.RemotePath == newRemotePath {
 		u.logger.Println("Neither the version nor remote location of the release changed. No changes made.")
 		return nil
 	}
 
 	releaseLock.Version = newVersion
-	releaseLock.SHA1 = newSHA1
+	releaseLock.SetChecksums(newSHA1, newSHA256)
 	releaseLock.RemoteSource = newSourceID
 	releaseLock.RemotePath = newRemotePath
 
+	if u.Options.DryRun {
+		plan.print(u.logger, kilnfileLock)
+		return nil
+	}
+
 	err = u.loader.SaveKilnfileLock(u.filesystem, u.Options.Kilnfile, kilnfileLock)
 	if err != nil {
 		return err
@@ -55,7 +55,7 @@ var _ = Describe("UpdateRelease", func() {
 			kilnFileLoader = new(fakes.KilnfileLoader)
 			releaseSource = new(fetcherFakes.MultiReleaseSource)
 			multiReleaseSourceProvider = new(fakes.MultiReleaseSourceProvider)
-			multiReleaseSourceProvider.Returns(releaseSource)
+			multiReleaseSourceProvider.Returns(releaseSource, nil)
 
 			filesystem = osfs.New("/tmp/")
 
@@ -78,10 +78,10 @@ var _ = Describe("UpdateRelease", func() {
 						RemotePath:   oldRemotePath,
 					},
 				},
-				Stemcell: cargo.Stemcell{
+				Stemcells: cargo.StemcellCriteria{{
 					OS:      "some-os",
 					Version: "4.5.6",
-				},
+				}},
 			}
 
 			kilnFileLoader.LoadKilnfilesReturns(kilnfile, kilnFileLock, nil)
@@ -274,6 +274,30 @@ var _ = Describe("UpdateRelease", func() {
 				Expect(string(logBuf.Contents())).NotTo(ContainSubstring("Updated"))
 				Expect(string(logBuf.Contents())).NotTo(ContainSubstring("COMMIT"))
 			})
+
+			When("the Kilnfile.lock does not have the release's SHA256 yet", func() {
+				const sha256 = "48d2ea5b9f2fb7bd1a3e0a3a0c6b0fb2ea2b7e8f5e7b77b48a1a0e6e4bf3dbfa"
+
+				BeforeEach(func() {
+					expectedDownloadedRelease.SHA256 = sha256
+					releaseSource.DownloadReleaseReturns(expectedDownloadedRelease, nil)
+				})
+
+				It("records it", func() {
+					err := updateReleaseCommand.Execute([]string{
+						"--kilnfile", "Kilnfile",
+						"--name", releaseName,
+						"--version", oldReleaseVersion,
+						"--releases-directory", releasesDir,
+					})
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(kilnFileLoader.SaveKilnfileLockCallCount()).To(Equal(1))
+					_, _, updatedLockfile := kilnFileLoader.SaveKilnfileLockArgsForCall(0)
+					Expect(updatedLockfile.Releases[1].SHA1).To(Equal(oldReleaseSha1))
+					Expect(updatedLockfile.Releases[1].SHA256).To(Equal(sha256))
+				})
+			})
 		})
 
 		When("the named release isn't in Kilnfile.lock", func() {
@@ -328,6 +352,22 @@ var _ = Describe("UpdateRelease", func() {
 			})
 		})
 
+		When("the release sources can't be constructed", func() {
+			BeforeEach(func() {
+				multiReleaseSourceProvider.Returns(nil, errors.New("release_sources[0]: unknown release source type"))
+			})
+
+			It("errors", func() {
+				err := updateReleaseCommand.Execute([]string{
+					"--kilnfile", "Kilnfile",
+					"--name", releaseName,
+					"--version", newReleaseVersion,
+					"--releases-directory", releasesDir,
+				})
+				Expect(err).To(MatchError(ContainSubstring("unknown release source type")))
+			})
+		})
+
 		When("the release can't be found", func() {
 			BeforeEach(func() {
 				releaseSource.GetMatchedReleaseReturns(release.Remote{}, false, errors.New("bad stuff"))
@@ -401,6 +441,35 @@ var _ = Describe("UpdateRelease", func() {
 			})
 		})
 
+		When("--dry-run is given", func() {
+			var outBuffer *gbytes.Buffer
+
+			BeforeEach(func() {
+				outBuffer = gbytes.NewBuffer()
+				logger = log.New(outBuffer, "", 0)
+			})
+
+			It("prints the planned download and Kilnfile.lock change without making them", func() {
+				err := updateReleaseCommand.Execute([]string{
+					"--kilnfile", "Kilnfile",
+					"--name", releaseName,
+					"--version", newReleaseVersion,
+					"--dry-run",
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(releaseSource.DownloadReleaseCallCount()).To(Equal(0))
+				Expect(kilnFileLoader.SaveKilnfileLockCallCount()).To(Equal(0))
+
+				Expect(string(outBuffer.Contents())).To(ContainSubstring(`Steps:
+  - download capi 1.8.7 from final-pcf-bosh-releases
+Kilnfile.lock changes:
+  Releases:
+    ~ capi 1.8.0 -> 1.8.7 (some/s3/path in final-pcf-bosh-releases)
+`))
+			})
+		})
+
 		When("invalid arguments are given", func() {
 			It("errors", func() {
 				err := updateReleaseCommand.Execute([]string{"--no-such-flag"})
@@ -1,11 +1,13 @@
 package commands
 
 import (
+	"errors"
 	"fmt"
 	"github.com/pivotal-cf/jhanda"
 	"github.com/pivotal-cf/kiln/builder"
 	"github.com/pivotal-cf/kiln/fetcher"
 	"github.com/pivotal-cf/kiln/helper"
+	"github.com/pivotal-cf/kiln/internal/cargo"
 	"github.com/pivotal-cf/kiln/release"
 	"gopkg.in/src-d/go-billy.v4/osfs"
 	"log"
@@ -16,11 +18,17 @@ type UpdateStemcell struct {
 		Kilnfile       string   `short:"kf" long:"kilnfile"           default:"Kilnfile" description:"path to Kilnfile"`
 		VariablesFiles []string `short:"vf" long:"variables-file"                        description:"path to variables file"`
 		Variables      []string `short:"vr" long:"variable"                              description:"variable in key=value format"`
-		StemcellFile   string   `short:"sf" long:"stemcell-file"                         description:"path to the stemcell tarball on disk"`
+		StemcellFile   string   `short:"sf" long:"stemcell-file"                         description:"path to the stemcell tarball on disk (NOTE: mutually exclusive with --os)"`
+		OS             string   `           long:"os"                                    description:"stemcell OS to update to the newest version in the stemcell index"`
+		Version        string   `           long:"version"                               description:"version constraint for the --os stemcell, like ~621 (defaults to the newest version)"`
+		StemcellIndex  string   `           long:"stemcell-index"     default:"https://bosh.io" description:"URL of the bosh.io stemcell API, or path to a YAML file listing the versions of each OS, used with --os"`
 		ReleasesDir    string   `short:"rd" long:"releases-directory" default:"releases" description:"path to a directory to download releases into"`
+		ReleaseCache   string   `           long:"release-cache"      env:"KILN_RELEASE_CACHE" description:"path to a directory of release tarballs shared between tiles, checked before downloading"`
+		DryRun         bool     `           long:"dry-run"                               description:"prints the planned downloads and Kilnfile.lock changes without making them"`
 	}
 	KilnfileLoader             KilnfileLoader
 	MultiReleaseSourceProvider MultiReleaseSourceProvider
+	StemcellIndexFinder        func(location string) fetcher.StemcellIndex
 	Logger                     *log.Logger
 }
 
@@ -30,17 +38,11 @@ func (update UpdateStemcell) Execute(args []string) error {
 		return err
 	}
 
-	update.Logger.Println("Parsing stemcell manifest...")
-	fs := helper.NewFilesystem()
-	part, err := builder.NewStemcellManifestReader(fs).Read(update.Options.StemcellFile)
+	newStemcellOS, newStemcellVersion, err := update.newStemcell()
 	if err != nil {
-		return fmt.Errorf("unable to read stemcell file: %w", err) // untested
+		return err
 	}
 
-	stemcellManifest := part.Metadata.(builder.StemcellManifest)
-	newStemcellOS := stemcellManifest.OperatingSystem
-	newStemcellVersion := stemcellManifest.Version
-
 	kilnfile, kilnfileLock, err := update.KilnfileLoader.LoadKilnfiles(
 		osfs.New(""),
 		update.Options.Kilnfile,
@@ -51,15 +53,35 @@ func (update UpdateStemcell) Execute(args []string) error {
 		return fmt.Errorf("couldn't load kilnfiles: %w", err) // untested
 	}
 
-	if kilnfileLock.Stemcell.OS == newStemcellOS &&
-		kilnfileLock.Stemcell.Version == newStemcellVersion {
+	// A stemcell for an OS that is not in stemcell_criteria replaces the only
+	// stemcell; with several, it is ambiguous which one it should replace.
+	currentStemcell, sameOS := kilnfileLock.Stemcells.Find(newStemcellOS)
+	if sameOS && currentStemcell.Version == newStemcellVersion {
 		update.Logger.Println("Nothing to update for product")
 		return nil
 	}
+	if !sameOS && len(kilnfileLock.Stemcells) > 1 {
+		return fmt.Errorf("the stemcell OS %q is not in the Kilnfile.lock stemcell_criteria", newStemcellOS)
+	}
 
-	releaseSource := update.MultiReleaseSourceProvider(kilnfile, false)
+	releaseSource, err := update.MultiReleaseSourceProvider(kilnfile, false)
+	if err != nil {
+		return err
+	}
+	cache := fetcher.NewReleaseCache(update.Options.ReleaseCache, update.Logger)
+
+	plan := newDryRunPlan(kilnfileLock)
 
 	for i, rel := range kilnfileLock.Releases {
+		stemcell, err := kilnfileLock.ReleaseStemcell(rel.Name)
+		if err != nil {
+			return err
+		}
+		if sameOS && stemcell.OS != newStemcellOS {
+			update.Logger.Printf("Skipping release %q, which uses the %s stemcell\n", rel.Name, stemcell.OS)
+			continue
+		}
+
 		update.Logger.Printf("Updating release %q with stemcell %s %s...", rel.Name, newStemcellOS, newStemcellVersion)
 
 		remote, found, err := releaseSource.GetMatchedRelease(release.Requirement{
@@ -80,19 +102,39 @@ func (update UpdateStemcell) Execute(args []string) error {
 			continue
 		}
 
-		local, err := releaseSource.DownloadRelease(update.Options.ReleasesDir, remote, fetcher.DefaultDownloadThreadCount)
-		if err != nil {
-			return fmt.Errorf("while downloading release %q, encountered error: %w", rel.Name, err)
+		sha1, sha256 := remote.SHA, ""
+		if update.Options.DryRun {
+			plan.addStep("download %s %s from %s", rel.Name, rel.Version, remote.SourceID)
+		} else {
+			local, err := downloadReleaseUsingCache(releaseSource, cache, update.Options.ReleasesDir, remote, update.Logger)
+			if err != nil {
+				return fmt.Errorf("while downloading release %q, encountered error: %w", rel.Name, err)
+			}
+			sha1, sha256 = local.SHA1, local.SHA256
 		}
 
 		lock := &kilnfileLock.Releases[i]
-		lock.SHA1 = local.SHA1
+		lock.SetChecksums(sha1, sha256)
 		lock.RemotePath = remote.RemotePath
 		lock.RemoteSource = remote.SourceID
 	}
 
-	kilnfileLock.Stemcell.OS = newStemcellOS
-	kilnfileLock.Stemcell.Version = newStemcellVersion
+	newStemcell := cargo.Stemcell{OS: newStemcellOS, Version: newStemcellVersion}
+	if sameOS {
+		kilnfileLock.Stemcells.Set(newStemcell)
+	} else {
+		kilnfileLock.Stemcells = cargo.StemcellCriteria{newStemcell}
+		for i := range kilnfileLock.Releases {
+			if kilnfileLock.Releases[i].StemcellOS != "" {
+				kilnfileLock.Releases[i].StemcellOS = newStemcellOS
+			}
+		}
+	}
+
+	if update.Options.DryRun {
+		plan.print(update.Logger, kilnfileLock)
+		return nil
+	}
 
 	err = update.KilnfileLoader.SaveKilnfileLock(osfs.New(""), update.Options.Kilnfile, kilnfileLock)
 	if err != nil {
@@ -103,6 +145,48 @@ func (update UpdateStemcell) Execute(args []string) error {
 	return nil
 }
 
+// newStemcell reads the OS and version of --stemcell-file, or finds the
+// newest --os stemcell matching --version in the stemcell index.
+func (update UpdateStemcell) newStemcell() (string, string, error) {
+	switch {
+	case update.Options.StemcellFile != "" && update.Options.OS != "":
+		return "", "", errors.New("--stemcell-file cannot be provided when using --os")
+	case update.Options.StemcellFile == "" && update.Options.OS == "":
+		return "", "", errors.New("--stemcell-file or --os must be provided")
+	case update.Options.Version != "" && update.Options.OS == "":
+		return "", "", errors.New("--version can only be used with --os")
+	}
+
+	if update.Options.StemcellFile != "" {
+		update.Logger.Println("Parsing stemcell manifest...")
+		fs := helper.NewFilesystem()
+		part, err := builder.NewStemcellManifestReader(fs).Read(update.Options.StemcellFile)
+		if err != nil {
+			return "", "", fmt.Errorf("unable to read stemcell file: %w", err) // untested
+		}
+
+		stemcellManifest := part.Metadata.(builder.StemcellManifest)
+		return stemcellManifest.OperatingSystem, stemcellManifest.Version, nil
+	}
+
+	update.Logger.Printf("Finding the newest %s stemcell in %s...\n", update.Options.OS, update.Options.StemcellIndex)
+	versions, err := update.StemcellIndexFinder(update.Options.StemcellIndex).StemcellVersions(update.Options.OS)
+	if err != nil {
+		return "", "", fmt.Errorf("couldn't list %s stemcells: %w", update.Options.OS, err)
+	}
+
+	version, found, err := fetcher.LatestStemcellVersion(versions, update.Options.Version)
+	if err != nil {
+		return "", "", err
+	}
+	if !found {
+		return "", "", fmt.Errorf("no %s stemcell matching %q was found in %s", update.Options.OS, update.Options.Version, update.Options.StemcellIndex)
+	}
+
+	update.Logger.Printf("Found %s stemcell %s\n", update.Options.OS, version)
+	return update.Options.OS, version, nil
+}
+
 func (update UpdateStemcell) Usage() jhanda.Usage {
 	return jhanda.Usage{
 		Description:      "updates stemcell_criteria and release information in Kilnfile.lock",
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"github.com/onsi/gomega/gbytes"
 	"github.com/pivotal-cf/kiln/commands/fakes"
+	"github.com/pivotal-cf/kiln/fetcher"
 	fetcherFakes "github.com/pivotal-cf/kiln/fetcher/fakes"
 	"github.com/pivotal-cf/kiln/internal/cargo"
 	test_helpers "github.com/pivotal-cf/kiln/internal/test-helpers"
@@ -76,10 +77,10 @@ var _ = Describe("UpdateStemcell", func() {
 						RemotePath:   "old-remote-path-2",
 					},
 				},
-				Stemcell: cargo.Stemcell{
+				Stemcells: cargo.StemcellCriteria{{
 					OS:      "old-os",
 					Version: "0.1",
-				},
+				}},
 			}
 
 			releaseSource = new(fetcherFakes.MultiReleaseSource)
@@ -126,7 +127,7 @@ var _ = Describe("UpdateStemcell", func() {
 			})
 
 			multiReleaseSourceProvider := new(fakes.MultiReleaseSourceProvider)
-			multiReleaseSourceProvider.Returns(releaseSource)
+			multiReleaseSourceProvider.Returns(releaseSource, nil)
 
 			tmpDir, err = ioutil.TempDir("", "fetch-test")
 			Expect(err).NotTo(HaveOccurred())
@@ -165,10 +166,10 @@ var _ = Describe("UpdateStemcell", func() {
 			_, path, updatedLockfile := kilnfileLoader.SaveKilnfileLockArgsForCall(0)
 			Expect(path).To(Equal(kilnfilePath))
 
-			Expect(updatedLockfile.Stemcell).To(Equal(cargo.Stemcell{
+			Expect(updatedLockfile.Stemcells).To(Equal(cargo.StemcellCriteria{{
 				OS:      newStemcellOS,
 				Version: newStemcellVersion,
-			}))
+			}}))
 
 			Expect(updatedLockfile.Releases).To(Equal([]cargo.ReleaseLock{
 				{
@@ -242,10 +243,10 @@ var _ = Describe("UpdateStemcell", func() {
 
 		When("the stemcell didn't change", func() {
 			BeforeEach(func() {
-				kilnfileLock.Stemcell = cargo.Stemcell{
+				kilnfileLock.Stemcells = cargo.StemcellCriteria{{
 					OS:      newStemcellOS,
 					Version: newStemcellVersion,
-				}
+				}}
 			})
 
 			It("no-ops", func() {
@@ -260,6 +261,137 @@ var _ = Describe("UpdateStemcell", func() {
 			})
 		})
 
+		When("--os is given instead of a stemcell file", func() {
+			var (
+				stemcellIndex         *fetcherFakes.StemcellIndex
+				stemcellIndexLocation string
+			)
+
+			BeforeEach(func() {
+				stemcellIndex = new(fetcherFakes.StemcellIndex)
+				stemcellIndex.StemcellVersionsReturns([]string{"1.2.3", "1.3.0", "2.0.0"}, nil)
+				update.StemcellIndexFinder = func(location string) fetcher.StemcellIndex {
+					stemcellIndexLocation = location
+					return stemcellIndex
+				}
+			})
+
+			It("updates to the newest stemcell in the index matching the version constraint", func() {
+				err := update.Execute([]string{"--kilnfile", kilnfilePath, "--os", newStemcellOS, "--version", "~1", "--stemcell-index", "stemcells.yml"})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(stemcellIndexLocation).To(Equal("stemcells.yml"))
+				Expect(stemcellIndex.StemcellVersionsArgsForCall(0)).To(Equal(newStemcellOS))
+
+				Expect(releaseSource.GetMatchedReleaseArgsForCall(0)).To(Equal(release.Requirement{
+					Name: release1Name, Version: release1Version,
+					StemcellOS: newStemcellOS, StemcellVersion: "1.3.0",
+				}))
+
+				_, _, updatedLockfile := kilnfileLoader.SaveKilnfileLockArgsForCall(0)
+				Expect(updatedLockfile.Stemcells).To(Equal(cargo.StemcellCriteria{{OS: newStemcellOS, Version: "1.3.0"}}))
+			})
+
+			It("uses bosh.io by default", func() {
+				err := update.Execute([]string{"--kilnfile", kilnfilePath, "--os", newStemcellOS})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(stemcellIndexLocation).To(Equal("https://bosh.io"))
+				_, _, updatedLockfile := kilnfileLoader.SaveKilnfileLockArgsForCall(0)
+				Expect(updatedLockfile.Stemcells).To(Equal(cargo.StemcellCriteria{{OS: newStemcellOS, Version: "2.0.0"}}))
+			})
+
+			It("errors when no stemcell matches", func() {
+				err := update.Execute([]string{"--kilnfile", kilnfilePath, "--os", newStemcellOS, "--version", "~3", "--stemcell-index", "stemcells.yml"})
+				Expect(err).To(MatchError(`no some-os stemcell matching "~3" was found in stemcells.yml`))
+				Expect(kilnfileLoader.SaveKilnfileLockCallCount()).To(Equal(0))
+			})
+
+			It("errors when the stemcell index fails", func() {
+				stemcellIndex.StemcellVersionsReturns(nil, errors.New("bosh.io is down"))
+
+				err := update.Execute([]string{"--kilnfile", kilnfilePath, "--os", newStemcellOS})
+				Expect(err).To(MatchError("couldn't list some-os stemcells: bosh.io is down"))
+			})
+
+			It("errors when --stemcell-file is also given", func() {
+				err := update.Execute([]string{"--kilnfile", kilnfilePath, "--os", newStemcellOS, "--stemcell-file", stemcellPath})
+				Expect(err).To(MatchError("--stemcell-file cannot be provided when using --os"))
+			})
+		})
+
+		It("requires a stemcell file or --os", func() {
+			err := update.Execute([]string{"--kilnfile", kilnfilePath})
+			Expect(err).To(MatchError("--stemcell-file or --os must be provided"))
+		})
+
+		It("requires --os for a --version constraint", func() {
+			err := update.Execute([]string{"--kilnfile", kilnfilePath, "--stemcell-file", stemcellPath, "--version", "~1"})
+			Expect(err).To(MatchError("--version can only be used with --os"))
+		})
+
+		When("--dry-run is given", func() {
+			It("prints the planned downloads and changes without downloading releases or saving the Kilnfile.lock", func() {
+				err := update.Execute([]string{"--kilnfile", kilnfilePath, "--stemcell-file", stemcellPath, "--dry-run"})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(releaseSource.GetMatchedReleaseCallCount()).To(Equal(2))
+				Expect(releaseSource.DownloadReleaseCallCount()).To(Equal(0))
+				Expect(kilnfileLoader.SaveKilnfileLockCallCount()).To(Equal(0))
+
+				Expect(string(outputBuffer.Contents())).To(ContainSubstring(`Dry run; nothing was downloaded, compiled, uploaded or saved.
+Steps:
+  - download release1 1 from publishable
+  - download release2 2 from test-only
+Kilnfile.lock changes:
+  Releases:
+    ~ release1 1 (new-remote-path-1 in publishable)
+    ~ release2 2 (new-remote-path-2 in test-only)
+
+  Stemcells:
+    - old-os 0.1
+    + some-os 1.2.3
+`))
+			})
+		})
+
+		When("the Kilnfile.lock has a stemcell for each of several OSes", func() {
+			BeforeEach(func() {
+				kilnfileLock.Stemcells = cargo.StemcellCriteria{
+					{OS: "old-os", Version: "0.1"},
+					{OS: newStemcellOS, Version: "1.0.0"},
+				}
+				kilnfileLock.Releases[1].StemcellOS = newStemcellOS
+			})
+
+			It("updates only the stemcell and releases for the OS of the stemcell file", func() {
+				err := update.Execute([]string{"--kilnfile", kilnfilePath, "--stemcell-file", stemcellPath})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(releaseSource.GetMatchedReleaseCallCount()).To(Equal(1))
+				Expect(releaseSource.GetMatchedReleaseArgsForCall(0).Name).To(Equal(release2Name))
+
+				_, _, updatedLockfile := kilnfileLoader.SaveKilnfileLockArgsForCall(0)
+				Expect(updatedLockfile.Stemcells).To(Equal(cargo.StemcellCriteria{
+					{OS: "old-os", Version: "0.1"},
+					{OS: newStemcellOS, Version: newStemcellVersion},
+				}))
+				Expect(updatedLockfile.Releases[0].RemotePath).To(Equal("old-remote-path-1"))
+				Expect(updatedLockfile.Releases[1].SHA1).To(Equal(newRelease2SHA))
+				Expect(updatedLockfile.Releases[1].StemcellOS).To(Equal(newStemcellOS))
+			})
+
+			It("errors when none of the stemcells have the OS of the stemcell file", func() {
+				kilnfileLock.Stemcells[1].OS = "other-os"
+				kilnfileLock.Releases[1].StemcellOS = "other-os"
+				kilnfileLoader.LoadKilnfilesReturns(kilnfile, kilnfileLock, nil)
+
+				err := update.Execute([]string{"--kilnfile", kilnfilePath, "--stemcell-file", stemcellPath})
+				Expect(err).To(MatchError(`the stemcell OS "some-os" is not in the Kilnfile.lock stemcell_criteria`))
+				Expect(kilnfileLoader.SaveKilnfileLockCallCount()).To(Equal(0))
+			})
+		})
+
 		When("the remote information for a release doesn't change", func() {
 			BeforeEach(func() {
 				kilnfileLock.Releases[1].RemoteSource = unpublishableReleaseSourceID
@@ -2,6 +2,7 @@ package commands
 
 import (
 	"fmt"
+	"io/ioutil"
 	"log"
 
 	"github.com/Masterminds/semver"
@@ -29,6 +30,8 @@ type UploadRelease struct {
 		Kilnfile       string   `short:"kf" long:"kilnfile" default:"Kilnfile" description:"path to Kilnfile"`
 		Variables      []string `short:"vr" long:"variable" description:"variable in key=value format"`
 		VariablesFiles []string `short:"vf" long:"variables-file" description:"path to variables file"`
+
+		SigningKey string `long:"signing-key" description:"path to a private key used to sign the release; the signature is uploaded next to it"`
 	}
 }
 
@@ -41,7 +44,7 @@ func (command UploadRelease) Execute(args []string) error {
 		return err
 	}
 
-	kilnfile, _, err := command.KilnfileLoader.LoadKilnfiles(
+	kilnfile, kilnfileLock, err := command.KilnfileLoader.LoadKilnfiles(
 		command.FS,
 		command.Options.Kilnfile,
 		command.Options.VariablesFiles,
@@ -56,6 +59,11 @@ func (command UploadRelease) Execute(args []string) error {
 		return fmt.Errorf("error finding release source: %w", err)
 	}
 
+	signing, err := command.releaseSigning(releaseUploader)
+	if err != nil {
+		return err
+	}
+
 	file, err := command.FS.Open(command.Options.LocalPath)
 	if err != nil {
 		return fmt.Errorf("could not open release: %w", err)
@@ -80,6 +88,16 @@ func (command UploadRelease) Execute(args []string) error {
 		return fmt.Errorf("cannot upload development release %q - only finalized releases are allowed", manifest.Version)
 	}
 
+	sha1, sha256, err := fetcher.CalculateSums(command.Options.LocalPath, command.FS)
+	if err != nil {
+		return fmt.Errorf("couldn't calculate the checksums of the release: %w", err) // untested
+	}
+	for _, lock := range kilnfileLock.Releases {
+		if lock.Name == manifest.Name && lock.Version == manifest.Version && lock.SHA256 != "" && lock.SHA256 != sha256 {
+			return fmt.Errorf("the SHA256 of %q is %q, but the Kilnfile.lock has %q for %s %s", command.Options.LocalPath, sha256, lock.SHA256, manifest.Name, manifest.Version)
+		}
+	}
+
 	requirement := release.Requirement{Name: manifest.Name, Version: manifest.Version}
 	_, found, err := releaseUploader.GetMatchedRelease(requirement)
 	if err != nil {
@@ -91,7 +109,12 @@ func (command UploadRelease) Execute(args []string) error {
 			manifest.Name, manifest.Version, command.Options.UploadTargetID)
 	}
 
-	_, err = releaseUploader.UploadRelease(release.Requirement{
+	signature, err := command.signRelease(signing)
+	if err != nil {
+		return err
+	}
+
+	remote, err := releaseUploader.UploadRelease(release.Requirement{
 		Name:    manifest.Name,
 		Version: manifest.Version,
 	}, file)
@@ -99,15 +122,53 @@ func (command UploadRelease) Execute(args []string) error {
 		return fmt.Errorf("error uploading the release: %w", err)
 	}
 
+	err = signing.upload(remote, signature)
+	if err != nil {
+		return err
+	}
+
 	command.Logger.Println("Upload succeeded")
+	command.Logger.Printf("sha1: %s\nsha256: %s\n", sha1, sha256)
 
 	return nil
 }
 
+func (command UploadRelease) releaseSigning(releaseUploader fetcher.ReleaseUploader) (*releaseSigning, error) {
+	if command.Options.SigningKey == "" {
+		return nil, nil
+	}
+
+	keyFile, err := command.FS.Open(command.Options.SigningKey)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read the signing key: %w", err)
+	}
+	defer keyFile.Close()
+
+	privateKey, err := ioutil.ReadAll(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read the signing key: %w", err) // untested
+	}
+	return newReleaseSigning(privateKey, releaseUploader, command.Options.UploadTargetID)
+}
+
+func (command UploadRelease) signRelease(signing *releaseSigning) ([]byte, error) {
+	if signing == nil {
+		return nil, nil
+	}
+
+	file, err := command.FS.Open(command.Options.LocalPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not open release: %w", err) // untested
+	}
+	defer file.Close()
+
+	return signing.sign(file)
+}
+
 func (command UploadRelease) Usage() jhanda.Usage {
 	return jhanda.Usage{
-		Description:      "Uploads a BOSH Release to an S3 release source for use in kiln fetch",
-		ShortDescription: "uploads a BOSH release to an s3 release_source",
+		Description:      "Uploads a BOSH Release to an s3, directory or http release source for use in kiln fetch",
+		ShortDescription: "uploads a BOSH release to a release_source",
 		Flags:            command.Options,
 	}
 }
@@ -1,7 +1,14 @@
 package commands_test
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
@@ -9,15 +16,24 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
 	"github.com/pivotal-cf/kiln/commands"
 	"github.com/pivotal-cf/kiln/commands/fakes"
+	"github.com/pivotal-cf/kiln/fetcher"
 	fetcherFakes "github.com/pivotal-cf/kiln/fetcher/fakes"
+	"github.com/pivotal-cf/kiln/internal/cargo"
 	test_helpers "github.com/pivotal-cf/kiln/internal/test-helpers"
 	"github.com/pivotal-cf/kiln/release"
 	"gopkg.in/src-d/go-billy.v4"
 	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
 )
 
+type signingReleaseUploader struct {
+	*fetcherFakes.ReleaseUploader
+	*fetcherFakes.SignatureUploader
+}
+
 var _ = Describe("UploadRelease", func() {
 	Context("Execute", func() {
 		var (
@@ -99,6 +115,119 @@ var _ = Describe("UploadRelease", func() {
 				})
 			})
 
+			It("logs the checksums of the uploaded release", func() {
+				logBuffer := gbytes.NewBuffer()
+				uploadRelease.Logger = log.New(logBuffer, "", 0)
+
+				err := uploadRelease.Execute([]string{
+					"--local-path", "banana-release.tgz",
+					"--upload-target-id", "orange-bucket",
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				_, expectedSHA256, err := fetcher.CalculateSums("banana-release.tgz", fs)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(logBuffer).To(gbytes.Say("sha1: " + expectedReleaseSHA))
+				Expect(logBuffer).To(gbytes.Say("sha256: " + expectedSHA256))
+			})
+
+			When("the Kilnfile.lock has a different SHA256 for the release", func() {
+				BeforeEach(func() {
+					loader.LoadKilnfilesReturns(cargo.Kilnfile{}, cargo.KilnfileLock{
+						Releases: []cargo.ReleaseLock{{
+							Name:    "banana",
+							Version: "1.2.3",
+							SHA256:  "4a0c5c5b3d9c1d2e9f8a7b6c5d4e3f2a1b0c9d8e7f6a5b4c3d2e1f0a9b8c7d6e",
+						}},
+					}, nil)
+				})
+
+				It("errors and does not upload", func() {
+					err := uploadRelease.Execute([]string{
+						"--local-path", "banana-release.tgz",
+						"--upload-target-id", "orange-bucket",
+					})
+					Expect(err).To(MatchError(ContainSubstring(`but the Kilnfile.lock has "4a0c5c5b3d9c1d2e9f8a7b6c5d4e3f2a1b0c9d8e7f6a5b4c3d2e1f0a9b8c7d6e" for banana 1.2.3`)))
+					Expect(releaseUploader.UploadReleaseCallCount()).To(Equal(0))
+				})
+			})
+
+			When("a signing key is given", func() {
+				var (
+					signingKey        *ecdsa.PrivateKey
+					signatureUploader *fetcherFakes.SignatureUploader
+				)
+
+				BeforeEach(func() {
+					var err error
+					signingKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+					Expect(err).NotTo(HaveOccurred())
+					keyDER, err := x509.MarshalECPrivateKey(signingKey)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(util.WriteFile(fs, "signing.key", pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)).To(Succeed())
+
+					signatureUploader = new(fetcherFakes.SignatureUploader)
+					releaseUploaderFinder.Returns(signingReleaseUploader{releaseUploader, signatureUploader}, nil)
+					releaseUploader.UploadReleaseReturns(release.Remote{
+						ID:         release.ID{Name: "banana", Version: "1.2.3"},
+						RemotePath: "banana/banana-1.2.3.tgz",
+					}, nil)
+				})
+
+				It("uploads a signature of the release next to it", func() {
+					err := uploadRelease.Execute([]string{
+						"--local-path", "banana-release.tgz",
+						"--upload-target-id", "orange-bucket",
+						"--signing-key", "signing.key",
+					})
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(signatureUploader.UploadSignatureCallCount()).To(Equal(1))
+					remote, signature := signatureUploader.UploadSignatureArgsForCall(0)
+					Expect(remote.RemotePath).To(Equal("banana/banana-1.2.3.tgz"))
+
+					decoded, err := base64.StdEncoding.DecodeString(string(signature))
+					Expect(err).NotTo(HaveOccurred())
+					_, expectedSHA256, err := fetcher.CalculateSums("banana-release.tgz", fs)
+					Expect(err).NotTo(HaveOccurred())
+					digest, err := hex.DecodeString(expectedSHA256)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(ecdsa.VerifyASN1(&signingKey.PublicKey, digest, decoded)).To(BeTrue())
+				})
+
+				When("the release source can't store signatures", func() {
+					BeforeEach(func() {
+						releaseUploaderFinder.Returns(releaseUploader, nil)
+					})
+
+					It("errors and does not upload", func() {
+						err := uploadRelease.Execute([]string{
+							"--local-path", "banana-release.tgz",
+							"--upload-target-id", "orange-bucket",
+							"--signing-key", "signing.key",
+						})
+						Expect(err).To(MatchError(`release source "orange-bucket" can't store signatures`))
+						Expect(releaseUploader.UploadReleaseCallCount()).To(Equal(0))
+					})
+				})
+
+				When("the signing key is not a key", func() {
+					BeforeEach(func() {
+						Expect(util.WriteFile(fs, "signing.key", []byte("not a key"), 0600)).To(Succeed())
+					})
+
+					It("errors and does not upload", func() {
+						err := uploadRelease.Execute([]string{
+							"--local-path", "banana-release.tgz",
+							"--upload-target-id", "orange-bucket",
+							"--signing-key", "signing.key",
+						})
+						Expect(err).To(MatchError(ContainSubstring("couldn't load the signing key")))
+						Expect(releaseUploader.UploadReleaseCallCount()).To(Equal(0))
+					})
+				})
+			})
+
 			When("the release tarball is compiled", func() {
 				BeforeEach(func() {
 					_, err := test_helpers.WriteTarballWithFile("banana-release.tgz", "release.MF", `
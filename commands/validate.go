@@ -0,0 +1,110 @@
+package commands
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path"
+
+	"github.com/pivotal-cf/jhanda"
+	"gopkg.in/src-d/go-billy.v4"
+
+	"github.com/pivotal-cf/kiln/proofing"
+)
+
+type Validate struct {
+	outLogger *log.Logger
+	fs        billy.Filesystem
+
+	Options struct {
+		Metadata string `short:"m" long:"metadata" required:"true" description:"path to baked metadata (e.g. from bake --metadata-only) or to a tile"`
+	}
+}
+
+func NewValidate(outLogger *log.Logger, fs billy.Filesystem) Validate {
+	return Validate{outLogger: outLogger, fs: fs}
+}
+
+func (v Validate) Execute(args []string) error {
+	_, err := jhanda.Parse(&v.Options, args)
+	if err != nil {
+		return err
+	}
+
+	metadata, err := readTileMetadata(v.fs, v.Options.Metadata)
+	if err != nil {
+		return err
+	}
+
+	productTemplate, err := proofing.Parse(bytes.NewReader(metadata))
+	if err != nil {
+		return fmt.Errorf("failed to parse metadata: %w", err)
+	}
+
+	err = productTemplate.Validate()
+	if err != nil {
+		return fmt.Errorf("metadata is not valid:\n%w", err)
+	}
+
+	v.outLogger.Printf("%s is valid\n", v.Options.Metadata)
+	return nil
+}
+
+func (v Validate) Usage() jhanda.Usage {
+	return jhanda.Usage{
+		Description:      "Validates baked tile metadata against the rules Ops Manager applies when a tile is imported.",
+		ShortDescription: "validates tile metadata",
+		Flags:            v.Options,
+	}
+}
+
+var zipMagic = []byte("PK\x03\x04")
+
+// readTileMetadata reads a metadata file, or the metadata inside a tile when
+// the file is a zip archive.
+func readTileMetadata(fs billy.Filesystem, filePath string) ([]byte, error) {
+	f, err := fs.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", filePath, err)
+	}
+	defer f.Close()
+
+	magic := make([]byte, len(zipMagic))
+	n, _ := f.ReadAt(magic, 0)
+	if !bytes.Equal(magic[:n], zipMagic) {
+		return ioutil.ReadAll(f)
+	}
+
+	info, err := fs.Stat(filePath)
+	if err != nil {
+		return nil, err // untested
+	}
+
+	zr, err := zip.NewReader(f, info.Size())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tile %s: %w", filePath, err)
+	}
+
+	return readZipMetadata(zr, filePath)
+}
+
+// readZipMetadata reads the metadata/*.yml file from an open tile.
+func readZipMetadata(zr *zip.Reader, filePath string) ([]byte, error) {
+	for _, file := range zr.File {
+		if path.Dir(file.Name) != "metadata" || path.Ext(file.Name) != ".yml" {
+			continue
+		}
+
+		rc, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s from tile: %w", file.Name, err)
+		}
+		defer rc.Close()
+
+		return ioutil.ReadAll(rc)
+	}
+
+	return nil, fmt.Errorf("tile %s does not contain metadata/*.yml", filePath)
+}
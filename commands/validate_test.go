@@ -0,0 +1,118 @@
+package commands_test
+
+import (
+	"archive/zip"
+	"errors"
+	"log"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/pivotal-cf/jhanda"
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+
+	. "github.com/pivotal-cf/kiln/commands"
+	"github.com/pivotal-cf/kiln/proofing"
+)
+
+var _ = Describe("Validate", func() {
+	const validMetadata = `---
+name: example
+label: Example Tile
+product_version: 1.2.3
+metadata_version: "2.7"
+releases:
+- name: example-release
+  version: 0.1.0
+  file: example-release-0.1.0.tgz
+job_types:
+- name: web
+  resource_label: Web
+  max_in_flight: 1
+  templates:
+  - name: web-server
+    release: example-release
+`
+
+	var (
+		fs        billy.Filesystem
+		outBuffer *gbytes.Buffer
+		validate  Validate
+	)
+
+	BeforeEach(func() {
+		fs = memfs.New()
+		outBuffer = gbytes.NewBuffer()
+		validate = NewValidate(log.New(outBuffer, "", 0), fs)
+	})
+
+	When("the metadata is valid", func() {
+		It("succeeds", func() {
+			Expect(util.WriteFile(fs, "metadata.yml", []byte(validMetadata), 0644)).To(Succeed())
+
+			err := validate.Execute([]string{"--metadata", "metadata.yml"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(outBuffer).To(gbytes.Say("metadata.yml is valid"))
+		})
+	})
+
+	When("the metadata is not valid", func() {
+		It("returns the validation errors", func() {
+			invalidMetadata := validMetadata + `- name: worker
+  resource_label: Worker
+  max_in_flight: lots
+  templates:
+  - name: worker
+    release: missing-release
+`
+			Expect(util.WriteFile(fs, "metadata.yml", []byte(invalidMetadata), 0644)).To(Succeed())
+
+			err := validate.Execute([]string{"--metadata", "metadata.yml"})
+			Expect(err).To(MatchError(`metadata is not valid:
+- job type "worker" max in flight must be a positive integer or a percentage between 1% and 100%, got lots
+- job type "worker" template "worker" references release "missing-release", which is not in releases`))
+
+			var compoundError *proofing.CompoundError
+			Expect(errors.As(err, &compoundError)).To(BeTrue())
+			Expect(*compoundError).To(HaveLen(2))
+		})
+	})
+
+	When("given a tile", func() {
+		It("validates the metadata in the tile", func() {
+			tile, err := fs.Create("example.pivotal")
+			Expect(err).NotTo(HaveOccurred())
+			zw := zip.NewWriter(tile)
+			w, err := zw.Create("metadata/example.yml")
+			Expect(err).NotTo(HaveOccurred())
+			_, err = w.Write([]byte(validMetadata))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(zw.Close()).To(Succeed())
+			Expect(tile.Close()).To(Succeed())
+
+			err = validate.Execute([]string{"--metadata", "example.pivotal"})
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	When("the metadata cannot be parsed", func() {
+		It("returns an error", func() {
+			Expect(util.WriteFile(fs, "metadata.yml", []byte("%%%"), 0644)).To(Succeed())
+
+			err := validate.Execute([]string{"--metadata", "metadata.yml"})
+			Expect(err).To(MatchError(ContainSubstring("failed to parse metadata")))
+		})
+	})
+
+	Describe("Usage", func() {
+		It("returns usage information for the command", func() {
+			Expect(validate.Usage()).To(Equal(jhanda.Usage{
+				Description:      "Validates baked tile metadata against the rules Ops Manager applies when a tile is imported.",
+				ShortDescription: "validates tile metadata",
+				Flags:            validate.Options,
+			}))
+		})
+	})
+})
@@ -0,0 +1,144 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/Masterminds/semver"
+	"github.com/pivotal-cf/jhanda"
+	"gopkg.in/src-d/go-billy.v4"
+
+	"github.com/pivotal-cf/kiln/fetcher"
+	"github.com/pivotal-cf/kiln/internal/cargo"
+	"github.com/pivotal-cf/kiln/release"
+)
+
+type VerifyLock struct {
+	Options struct {
+		Kilnfile       string   `short:"kf" long:"kilnfile"       default:"Kilnfile" description:"path to Kilnfile"`
+		Variables      []string `short:"vr" long:"variable"                          description:"variable in key=value format"`
+		VariablesFiles []string `short:"vf" long:"variables-file"                    description:"path to variables file"`
+		Publishable    bool     `           long:"publishable"                       description:"fails when a release comes from a release source that is not publishable"`
+		Parallel       int      `short:"p"  long:"parallel"       default:"4"        description:"number of releases to look up at once"`
+	}
+	multiReleaseSourceProvider MultiReleaseSourceProvider
+	filesystem                 billy.Filesystem
+	logger                     *log.Logger
+	loader                     KilnfileLoader
+}
+
+func NewVerifyLock(logger *log.Logger, filesystem billy.Filesystem, multiReleaseSourceProvider MultiReleaseSourceProvider, loader KilnfileLoader) VerifyLock {
+	return VerifyLock{
+		logger:                     logger,
+		multiReleaseSourceProvider: multiReleaseSourceProvider,
+		filesystem:                 filesystem,
+		loader:                     loader,
+	}
+}
+
+func (v VerifyLock) Execute(args []string) error {
+	_, err := jhanda.Parse(&v.Options, args)
+	if err != nil {
+		return err
+	}
+
+	kilnfile, kilnfileLock, err := v.loader.LoadKilnfiles(v.filesystem, v.Options.Kilnfile, v.Options.VariablesFiles, v.Options.Variables)
+	if err != nil {
+		return err
+	}
+
+	releaseSource, err := v.multiReleaseSourceProvider(kilnfile, false)
+	if err != nil {
+		return err
+	}
+
+	constraints := make(map[string]string)
+	for _, rel := range kilnfile.Releases {
+		constraints[rel.Name] = rel.Version
+	}
+
+	v.logger.Printf("Verifying %d releases in the Kilnfile.lock...\n", len(kilnfileLock.Releases))
+	problems := make([][]string, len(kilnfileLock.Releases))
+	inParallel(len(kilnfileLock.Releases), v.Options.Parallel, func(i int) {
+		problems[i] = v.verifyRelease(releaseSource, kilnfileLock, kilnfileLock.Releases[i], constraints[kilnfileLock.Releases[i].Name])
+	})
+
+	var count int
+	for i, releaseProblems := range problems {
+		for _, problem := range releaseProblems {
+			v.logger.Printf("- %s %s: %s\n", kilnfileLock.Releases[i].Name, kilnfileLock.Releases[i].Version, problem)
+			count++
+		}
+	}
+	if count > 0 {
+		return fmt.Errorf("found %d problems in the Kilnfile.lock", count)
+	}
+
+	v.logger.Println("The Kilnfile.lock is valid.")
+	return nil
+}
+
+// verifyRelease checks that a release lock satisfies its Kilnfile version
+// constraint and still exists where the Kilnfile.lock says it is.
+func (v VerifyLock) verifyRelease(releaseSource fetcher.MultiReleaseSource, kilnfileLock cargo.KilnfileLock, lock cargo.ReleaseLock, constraint string) []string {
+	var problems []string
+
+	if constraint != "" {
+		if problem := checkVersionConstraint(lock.Version, constraint); problem != "" {
+			problems = append(problems, problem)
+		}
+	}
+
+	src, err := releaseSource.FindByID(lock.RemoteSource)
+	if err != nil {
+		return append(problems, err.Error())
+	}
+	if v.Options.Publishable && !src.Publishable() {
+		problems = append(problems, fmt.Sprintf("release source %q is not publishable", lock.RemoteSource))
+	}
+
+	stemcell, err := kilnfileLock.ReleaseStemcell(lock.Name)
+	if err != nil {
+		return append(problems, err.Error())
+	}
+
+	remote, found, err := src.GetMatchedRelease(release.Requirement{
+		Name:            lock.Name,
+		Version:         lock.Version,
+		StemcellOS:      stemcell.OS,
+		StemcellVersion: stemcell.Version,
+	})
+	switch {
+	case err != nil:
+		problems = append(problems, fmt.Sprintf("couldn't look it up in %s: %s", lock.RemoteSource, err))
+	case !found:
+		problems = append(problems, fmt.Sprintf("not found in %s", lock.RemoteSource))
+	case remote.RemotePath != lock.RemotePath:
+		problems = append(problems, fmt.Sprintf("found at %s in %s, but the Kilnfile.lock has %s", remote.RemotePath, lock.RemoteSource, lock.RemotePath))
+	}
+
+	return problems
+}
+
+func checkVersionConstraint(version, constraint string) string {
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return fmt.Sprintf("invalid version constraint %q in the Kilnfile: %s", constraint, err)
+	}
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return fmt.Sprintf("version is not semantic, so it can't be checked against %q", constraint)
+	}
+	if !c.Check(v) {
+		return fmt.Sprintf("version does not match the Kilnfile constraint %q", constraint)
+	}
+	return ""
+}
+
+func (v VerifyLock) Usage() jhanda.Usage {
+	return jhanda.Usage{
+		Description:      "Checks that each release in the Kilnfile.lock matches its Kilnfile version constraint and exists in its release source",
+		ShortDescription: "verifies the releases in Kilnfile.lock",
+		Flags:            v.Options,
+	}
+}
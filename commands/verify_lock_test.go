@@ -0,0 +1,161 @@
+package commands_test
+
+import (
+	"errors"
+	"log"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/pivotal-cf/jhanda"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+
+	. "github.com/pivotal-cf/kiln/commands"
+	"github.com/pivotal-cf/kiln/commands/fakes"
+	"github.com/pivotal-cf/kiln/fetcher"
+	fetcherFakes "github.com/pivotal-cf/kiln/fetcher/fakes"
+	"github.com/pivotal-cf/kiln/internal/cargo"
+	"github.com/pivotal-cf/kiln/release"
+)
+
+var _ = Describe("VerifyLock", func() {
+	var (
+		verifyLock                 VerifyLock
+		outBuffer                  *gbytes.Buffer
+		kilnfileLoader             *fakes.KilnfileLoader
+		multiReleaseSourceProvider *fakes.MultiReleaseSourceProvider
+		boshIOSource, builtSource  *fetcherFakes.ReleaseSource
+		kilnfile                   cargo.Kilnfile
+		kilnfileLock               cargo.KilnfileLock
+	)
+
+	BeforeEach(func() {
+		outBuffer = gbytes.NewBuffer()
+		kilnfileLoader = new(fakes.KilnfileLoader)
+
+		matchLockedPath := func(requirement release.Requirement) (release.Remote, bool, error) {
+			for _, lock := range kilnfileLock.Releases {
+				if lock.Name == requirement.Name {
+					return release.Remote{ID: release.ID{Name: lock.Name, Version: requirement.Version}, RemotePath: lock.RemotePath}, true, nil
+				}
+			}
+			return release.Remote{}, false, nil
+		}
+		boshIOSource = new(fetcherFakes.ReleaseSource)
+		boshIOSource.IDReturns("bosh.io")
+		boshIOSource.PublishableReturns(true)
+		boshIOSource.GetMatchedReleaseStub = matchLockedPath
+		builtSource = new(fetcherFakes.ReleaseSource)
+		builtSource.IDReturns("built")
+		builtSource.GetMatchedReleaseStub = matchLockedPath
+
+		multiReleaseSourceProvider = new(fakes.MultiReleaseSourceProvider)
+		multiReleaseSourceProvider.Returns(fetcher.NewMultiReleaseSource(boshIOSource, builtSource), nil)
+
+		kilnfile = cargo.Kilnfile{
+			Releases: []cargo.ReleaseKiln{
+				{Name: "uaa", Version: "~74.1"},
+				{Name: "capi"},
+			},
+		}
+		kilnfileLock = cargo.KilnfileLock{
+			Releases: []cargo.ReleaseLock{
+				{Name: "uaa", Version: "74.1.2", RemoteSource: "bosh.io", RemotePath: "https://bosh.io/uaa-74.1.2"},
+				{Name: "capi", Version: "1.8.0", RemoteSource: "built", RemotePath: "capi/capi-1.8.0.tgz", StemcellOS: "ubuntu-jammy"},
+			},
+			Stemcells: cargo.StemcellCriteria{{OS: "ubuntu-xenial", Version: "621.61"}, {OS: "ubuntu-jammy", Version: "1.18"}},
+		}
+
+		verifyLock = NewVerifyLock(log.New(outBuffer, "", 0), memfs.New(), multiReleaseSourceProvider.Spy, kilnfileLoader)
+	})
+
+	JustBeforeEach(func() {
+		kilnfileLoader.LoadKilnfilesReturns(kilnfile, kilnfileLock, nil)
+	})
+
+	It("passes when every release is found where the Kilnfile.lock says", func() {
+		err := verifyLock.Execute([]string{"--kilnfile", "Kilnfile", "--variable", "key=value"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(outBuffer).To(gbytes.Say("The Kilnfile.lock is valid."))
+
+		_, kilnfilePath, _, variables := kilnfileLoader.LoadKilnfilesArgsForCall(0)
+		Expect(kilnfilePath).To(Equal("Kilnfile"))
+		Expect(variables).To(Equal([]string{"key=value"}))
+
+		Expect(builtSource.GetMatchedReleaseCallCount()).To(Equal(1))
+		Expect(builtSource.GetMatchedReleaseArgsForCall(0)).To(Equal(release.Requirement{
+			Name:            "capi",
+			Version:         "1.8.0",
+			StemcellOS:      "ubuntu-jammy",
+			StemcellVersion: "1.18",
+		}))
+	})
+
+	It("reports a release that is no longer in its release source", func() {
+		builtSource.GetMatchedReleaseStub = nil
+		builtSource.GetMatchedReleaseReturns(release.Remote{}, false, nil)
+
+		err := verifyLock.Execute(nil)
+		Expect(err).To(MatchError("found 1 problems in the Kilnfile.lock"))
+		Expect(outBuffer).To(gbytes.Say("- capi 1.8.0: not found in built"))
+	})
+
+	It("reports a release found at another path", func() {
+		builtSource.GetMatchedReleaseStub = nil
+		builtSource.GetMatchedReleaseReturns(release.Remote{RemotePath: "capi/capi-1.8.0-ubuntu-jammy-1.18.tgz"}, true, nil)
+
+		err := verifyLock.Execute(nil)
+		Expect(err).To(HaveOccurred())
+		Expect(outBuffer).To(gbytes.Say("- capi 1.8.0: found at capi/capi-1.8.0-ubuntu-jammy-1.18.tgz in built, but the Kilnfile.lock has capi/capi-1.8.0.tgz"))
+	})
+
+	It("reports a release source that can't be searched", func() {
+		boshIOSource.GetMatchedReleaseStub = nil
+		boshIOSource.GetMatchedReleaseReturns(release.Remote{}, false, errors.New("bosh.io is down"))
+
+		err := verifyLock.Execute(nil)
+		Expect(err).To(HaveOccurred())
+		Expect(outBuffer).To(gbytes.Say("- uaa 74.1.2: couldn't look it up in bosh.io: bosh.io is down"))
+	})
+
+	It("reports a version that doesn't match the Kilnfile constraint", func() {
+		kilnfile.Releases[0].Version = "~74.2"
+
+		err := verifyLock.Execute(nil)
+		Expect(err).To(HaveOccurred())
+		Expect(outBuffer).To(gbytes.Say(`- uaa 74.1.2: version does not match the Kilnfile constraint "~74.2"`))
+	})
+
+	It("reports a release source that isn't in the Kilnfile", func() {
+		kilnfileLock.Releases[0].RemoteSource = "retired"
+
+		err := verifyLock.Execute(nil)
+		Expect(err).To(HaveOccurred())
+		Expect(outBuffer).To(gbytes.Say(`- uaa 74.1.2: couldn't find a release source with ID "retired"`))
+	})
+
+	When("--publishable is given", func() {
+		It("reports releases from release sources that aren't publishable", func() {
+			err := verifyLock.Execute([]string{"--publishable"})
+			Expect(err).To(MatchError("found 1 problems in the Kilnfile.lock"))
+			Expect(outBuffer).To(gbytes.Say(`- capi 1.8.0: release source "built" is not publishable`))
+		})
+	})
+
+	It("returns an error when the Kilnfiles cannot be loaded", func() {
+		kilnfileLoader.LoadKilnfilesReturns(cargo.Kilnfile{}, cargo.KilnfileLock{}, errors.New("no Kilnfile"))
+
+		err := verifyLock.Execute(nil)
+		Expect(err).To(MatchError("no Kilnfile"))
+	})
+
+	Describe("Usage", func() {
+		It("returns usage information for the command", func() {
+			Expect(verifyLock.Usage()).To(Equal(jhanda.Usage{
+				Description:      "Checks that each release in the Kilnfile.lock matches its Kilnfile version constraint and exists in its release source",
+				ShortDescription: "verifies the releases in Kilnfile.lock",
+				Flags:            verifyLock.Options,
+			}))
+		})
+	})
+})
@@ -1,16 +1,12 @@
 package fetcher
 
 import (
-	"crypto/sha1"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"github.com/Masterminds/semver"
-	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
-	"os"
 	"path/filepath"
 
 	"github.com/pivotal-cf/kiln/internal/cargo"
@@ -138,41 +134,34 @@ func (src BOSHIOReleaseSource) FindReleaseVersion(requirement release.Requiremen
 func (src BOSHIOReleaseSource) DownloadRelease(releaseDir string, remoteRelease release.Remote, downloadThreads int) (release.Local, error) {
 	src.logger.Printf("downloading %s %s from %s", remoteRelease.Name, remoteRelease.Version, src.ID())
 
-	downloadURL := remoteRelease.RemotePath
+	filePath := filepath.Join(releaseDir, fmt.Sprintf("%s-%s.tgz", remoteRelease.Name, remoteRelease.Version))
 
-	resp, err := http.Get(downloadURL)
+	download, err := openPartialDownload(filePath)
 	if err != nil {
 		return release.Local{}, err
 	}
+	defer download.Close()
 
-	filePath := filepath.Join(releaseDir, fmt.Sprintf("%s-%s.tgz", remoteRelease.Name, remoteRelease.Version))
-
-	out, err := os.Create(filePath)
-	if err != nil {
-		return release.Local{}, err
+	if download.Offset() > 0 {
+		src.logger.Printf("resuming download of %s %s after %d bytes", remoteRelease.Name, remoteRelease.Version, download.Offset())
 	}
-	defer out.Close()
 
-	_, err = io.Copy(out, resp.Body)
-	resp.Body.Close()
+	req, err := http.NewRequest(http.MethodGet, remoteRelease.RemotePath, nil)
 	if err != nil {
 		return release.Local{}, err
 	}
 
-	_, err = out.Seek(0, 0)
+	err = download.Get(http.DefaultClient, req)
 	if err != nil {
-		return release.Local{}, fmt.Errorf("error reseting file cursor: %w", err) // untested
+		return release.Local{}, err
 	}
 
-	hash := sha1.New()
-	_, err = io.Copy(hash, out)
+	sha1, sha256, err := download.Complete()
 	if err != nil {
-		return release.Local{}, fmt.Errorf("error hashing file contents: %w", err) // untested
+		return release.Local{}, err
 	}
 
-	sha1 := hex.EncodeToString(hash.Sum(nil))
-
-	return release.Local{ID: remoteRelease.ID, LocalPath: filePath, SHA1: sha1}, nil
+	return release.Local{ID: remoteRelease.ID, LocalPath: filePath, SHA1: sha1, SHA256: sha256}, nil
 }
 
 type ResponseStatusCodeError http.Response
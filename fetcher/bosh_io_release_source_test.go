@@ -216,6 +216,7 @@ var _ = Describe("BOSHIOReleaseSource", func() {
 			release1Filename           = "some-1.2.3.tgz"
 			release1ServerPath         = "/some-release"
 			release1ServerFileContents = "totes-a-real-release"
+			release1Sha256             = "9534c5aa3805b0aa8f295a2e483d0f88837f6e139d0dffb582dcd22987e8acf2"
 		)
 		var (
 			releaseDir    string
@@ -270,7 +271,62 @@ var _ = Describe("BOSHIOReleaseSource", func() {
 			Expect(err).NotTo(HaveOccurred())
 			Expect(release1DiskContents).To(BeEquivalentTo(release1ServerFileContents))
 
-			Expect(localRelease).To(Equal(release.Local{ID: release1ID, LocalPath: fullRelease1Path, SHA1: release1Sha1}))
+			Expect(localRelease).To(Equal(release.Local{ID: release1ID, LocalPath: fullRelease1Path, SHA1: release1Sha1, SHA256: release1Sha256}))
+		})
+
+		When("a previous download was interrupted", func() {
+			const alreadyDownloaded = "totes-a-"
+
+			BeforeEach(func() {
+				partialPath := filepath.Join(releaseDir, release1Filename+".partial")
+				Expect(ioutil.WriteFile(partialPath, []byte(alreadyDownloaded), 0644)).To(Succeed())
+			})
+
+			When("the server supports range requests", func() {
+				BeforeEach(func() {
+					testServer.RouteToHandler("GET", release1ServerPath, ghttp.CombineHandlers(
+						ghttp.VerifyHeaderKV("Range", fmt.Sprintf("bytes=%d-", len(alreadyDownloaded))),
+						ghttp.RespondWith(http.StatusPartialContent, strings.TrimPrefix(release1ServerFileContents, alreadyDownloaded)),
+					))
+				})
+
+				It("resumes the download", func() {
+					localRelease, err := releaseSource.DownloadRelease(releaseDir, release1, 1)
+					Expect(err).NotTo(HaveOccurred())
+
+					fullRelease1Path := filepath.Join(releaseDir, release1Filename)
+					release1DiskContents, err := ioutil.ReadFile(fullRelease1Path)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(release1DiskContents).To(BeEquivalentTo(release1ServerFileContents))
+					Expect(fullRelease1Path + ".partial").NotTo(BeAnExistingFile())
+
+					Expect(localRelease).To(Equal(release.Local{ID: release1ID, LocalPath: fullRelease1Path, SHA1: release1Sha1, SHA256: release1Sha256}))
+				})
+			})
+
+			When("the server ignores the range request", func() {
+				It("downloads the whole release again", func() {
+					localRelease, err := releaseSource.DownloadRelease(releaseDir, release1, 1)
+					Expect(err).NotTo(HaveOccurred())
+
+					release1DiskContents, err := ioutil.ReadFile(localRelease.LocalPath)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(release1DiskContents).To(BeEquivalentTo(release1ServerFileContents))
+					Expect(localRelease.SHA1).To(Equal(release1Sha1))
+				})
+			})
+		})
+
+		When("the server responds with an error", func() {
+			BeforeEach(func() {
+				testServer.RouteToHandler("GET", release1ServerPath, ghttp.RespondWith(http.StatusNotFound, "not here"))
+			})
+
+			It("returns an error and does not create the release file", func() {
+				_, err := releaseSource.DownloadRelease(releaseDir, release1, 1)
+				Expect(err).To(MatchError(ContainSubstring("got status 404")))
+				Expect(filepath.Join(releaseDir, release1Filename)).NotTo(BeAnExistingFile())
+			})
 		})
 	})
 
@@ -0,0 +1,309 @@
+package fetcher
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/semver"
+	"gopkg.in/src-d/go-billy.v4/osfs"
+
+	"github.com/pivotal-cf/kiln/internal/cargo"
+	"github.com/pivotal-cf/kiln/release"
+)
+
+// DirectoryReleaseSource finds release tarballs in a local (or mounted)
+// directory. Releases are laid out using a path_template, just like in an S3
+// bucket, so a bucket can be mirrored to disk for air-gapped environments.
+type DirectoryReleaseSource struct {
+	id              string
+	directory       string
+	pathTemplate    *template.Template
+	pathTemplateErr error
+	publishable     bool
+
+	signatures *ReleaseSignatures
+
+	logger *log.Logger
+}
+
+func NewDirectoryReleaseSource(id, directory, pathTemplate string, publishable bool, logger *log.Logger) DirectoryReleaseSource {
+	src := DirectoryReleaseSource{
+		id:          id,
+		directory:   directory,
+		publishable: publishable,
+		logger:      logger,
+	}
+	src.pathTemplate, src.pathTemplateErr = parsePathTemplate(pathTemplate)
+	return src
+}
+
+func DirectoryReleaseSourceFromConfig(config cargo.ReleaseSourceConfig, logger *log.Logger) (DirectoryReleaseSource, error) {
+	if config.PathTemplate == "" {
+		return DirectoryReleaseSource{}, MissingFieldError{SourceType: ReleaseSourceTypeDirectory, Field: "path_template"}
+	}
+	if config.Path == "" {
+		return DirectoryReleaseSource{}, MissingFieldError{SourceType: ReleaseSourceTypeDirectory, Field: "path"}
+	}
+
+	src := NewDirectoryReleaseSource(config.ID, config.Path, config.PathTemplate, config.Publishable, logger)
+	if src.pathTemplateErr != nil {
+		return DirectoryReleaseSource{}, PathTemplateError{SourceID: config.ID, Err: src.pathTemplateErr}
+	}
+
+	signatures, err := signaturesFromConfig(config)
+	if err != nil {
+		return DirectoryReleaseSource{}, err
+	}
+
+	return src.WithSignatures(signatures), nil
+}
+
+// WithSignatures makes DownloadRelease refuse releases without a valid
+// signature.
+func (src DirectoryReleaseSource) WithSignatures(signatures *ReleaseSignatures) DirectoryReleaseSource {
+	src.signatures = signatures
+	return src
+}
+
+// VerifiesSignatures reports whether DownloadRelease refuses releases without
+// a valid signature.
+func (src DirectoryReleaseSource) VerifiesSignatures() bool {
+	return src.signatures != nil
+}
+
+func (src DirectoryReleaseSource) ID() string {
+	return src.id
+}
+
+func (src DirectoryReleaseSource) Publishable() bool {
+	return src.publishable
+}
+
+func (src DirectoryReleaseSource) GetMatchedRelease(requirement release.Requirement) (release.Remote, bool, error) {
+	remotePath, err := src.RemotePath(requirement)
+	if err != nil {
+		return release.Remote{}, false, err
+	}
+
+	info, err := os.Stat(src.fullPath(remotePath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return release.Remote{}, false, nil
+		}
+		return release.Remote{}, false, err
+	}
+	if info.IsDir() {
+		return release.Remote{}, false, nil
+	}
+
+	return release.Remote{
+		ID:         release.ID{Name: requirement.Name, Version: requirement.Version},
+		RemotePath: remotePath,
+		SourceID:   src.ID(),
+	}, true, nil
+}
+
+// versionPlaceholder stands in for the release version when the path template
+// is turned into a pattern that matches every version of a release.
+const versionPlaceholder = "\x00version\x00"
+
+func (src DirectoryReleaseSource) FindReleaseVersion(requirement release.Requirement) (release.Remote, bool, error) {
+	constraintString := requirement.VersionConstraint
+	if constraintString == "" {
+		constraintString = ">0"
+	}
+	constraint, err := semver.NewConstraint(constraintString)
+	if err != nil {
+		return release.Remote{}, false, fmt.Errorf("invalid version constraint %q: %w", constraintString, err)
+	}
+
+	pathPattern, err := pathTemplateVersionPattern(src.RemotePath, requirement)
+	if err != nil {
+		return release.Remote{}, false, err
+	}
+
+	var (
+		latestVersion *semver.Version
+		latestPath    string
+	)
+	err = filepath.Walk(src.directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		remotePath, err := filepath.Rel(src.directory, path)
+		if err != nil {
+			return err // untested
+		}
+		remotePath = filepath.ToSlash(remotePath)
+
+		version, found := matchVersion(pathPattern, remotePath)
+		if !found || !constraint.Check(version) {
+			return nil
+		}
+
+		if latestVersion == nil || version.GreaterThan(latestVersion) {
+			latestVersion, latestPath = version, remotePath
+		}
+		return nil
+	})
+	if err != nil {
+		return release.Remote{}, false, fmt.Errorf("failed to search %q for releases: %w", src.directory, err)
+	}
+
+	if latestVersion == nil {
+		return release.Remote{}, false, nil
+	}
+
+	sum, err := CalculateSum(src.fullPath(latestPath), osfs.New(""))
+	if err != nil {
+		return release.Remote{}, false, err
+	}
+
+	return release.Remote{
+		ID:         release.ID{Name: requirement.Name, Version: latestVersion.Original()},
+		RemotePath: latestPath,
+		SourceID:   src.ID(),
+		SHA:        sum,
+	}, true, nil
+}
+
+func (src DirectoryReleaseSource) DownloadRelease(releaseDir string, remoteRelease release.Remote, downloadThreads int) (release.Local, error) {
+	src.logger.Printf("copying %s %s from %s", remoteRelease.Name, remoteRelease.Version, src.directory)
+
+	inputFile, err := os.Open(src.fullPath(remoteRelease.RemotePath))
+	if err != nil {
+		return release.Local{}, fmt.Errorf("failed to open release: %w", err)
+	}
+	defer inputFile.Close()
+
+	outputFile := filepath.Join(releaseDir, filepath.Base(remoteRelease.RemotePath))
+
+	download, err := openPartialDownload(outputFile)
+	if err != nil {
+		return release.Local{}, err
+	}
+	defer download.Close()
+
+	err = download.Restart()
+	if err != nil {
+		return release.Local{}, err // untested
+	}
+
+	_, err = download.ReadFrom(inputFile)
+	if err != nil {
+		return release.Local{}, fmt.Errorf("failed to copy release: %w", err)
+	}
+
+	sha1, sha256, err := download.Complete()
+	if err != nil {
+		return release.Local{}, err
+	}
+
+	local := release.Local{ID: remoteRelease.ID, LocalPath: outputFile, SHA1: sha1, SHA256: sha256}
+	if src.signatures != nil {
+		signature, err := ioutil.ReadFile(src.fullPath(src.signatures.signaturePath(remoteRelease.RemotePath)))
+		err = src.signatures.verifyDownload(local, signature, err)
+		if err != nil {
+			return release.Local{}, err
+		}
+	}
+
+	return local, nil
+}
+
+func (src DirectoryReleaseSource) UploadRelease(spec release.Requirement, file io.Reader) (release.Remote, error) {
+	remotePath, err := src.RemotePath(spec)
+	if err != nil {
+		return release.Remote{}, err
+	}
+
+	src.logger.Printf("uploading release %q to %s at %q...\n", spec.Name, src.ID(), remotePath)
+
+	fullPath := src.fullPath(remotePath)
+	err = os.MkdirAll(filepath.Dir(fullPath), 0755)
+	if err != nil {
+		return release.Remote{}, err
+	}
+
+	tmpFile, err := ioutil.TempFile(filepath.Dir(fullPath), filepath.Base(fullPath)+".upload*")
+	if err != nil {
+		return release.Remote{}, err // untested
+	}
+	defer os.Remove(tmpFile.Name())
+
+	_, err = io.Copy(tmpFile, file)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return release.Remote{}, fmt.Errorf("failed to write release: %w", err)
+	}
+
+	err = os.Rename(tmpFile.Name(), fullPath)
+	if err != nil {
+		return release.Remote{}, err // untested
+	}
+
+	return release.Remote{
+		ID:         release.ID{Name: spec.Name, Version: spec.Version},
+		RemotePath: remotePath,
+		SourceID:   src.ID(),
+	}, nil
+}
+
+func (src DirectoryReleaseSource) UploadSignature(remote release.Remote, signature []byte) error {
+	return ioutil.WriteFile(src.fullPath(src.signatures.signaturePath(remote.RemotePath)), signature, 0644)
+}
+
+func (src DirectoryReleaseSource) RemotePath(requirement release.Requirement) (string, error) {
+	if src.pathTemplateErr != nil {
+		return "", PathTemplateError{SourceID: src.id, Err: src.pathTemplateErr}
+	}
+	return evaluatePathTemplate(src.pathTemplate, requirement)
+}
+
+func (src DirectoryReleaseSource) fullPath(remotePath string) string {
+	return filepath.Join(src.directory, filepath.FromSlash(remotePath))
+}
+
+// pathTemplateVersionPattern matches the remote paths of every version of the
+// required release.
+func pathTemplateVersionPattern(remotePathFor func(release.Requirement) (string, error), requirement release.Requirement) (*regexp.Regexp, error) {
+	requirement.Version = versionPlaceholder
+	remotePath, err := remotePathFor(requirement)
+	if err != nil {
+		return nil, err
+	}
+
+	pattern := strings.Replace(regexp.QuoteMeta(remotePath), versionPlaceholder, `([^/]+)`, -1)
+	return regexp.Compile("^" + pattern + "$")
+}
+
+func matchVersion(pathPattern *regexp.Regexp, remotePath string) (*semver.Version, bool) {
+	matches := pathPattern.FindStringSubmatch(remotePath)
+	if len(matches) < 2 {
+		return nil, false
+	}
+	for _, match := range matches[2:] {
+		if match != matches[1] {
+			return nil, false
+		}
+	}
+
+	version, err := semver.NewVersion(matches[1])
+	if err != nil {
+		return nil, false
+	}
+	return version, true
+}
@@ -0,0 +1,153 @@
+package fetcher_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/pivotal-cf/kiln/fetcher"
+	"github.com/pivotal-cf/kiln/internal/cargo"
+	"github.com/pivotal-cf/kiln/release"
+)
+
+var _ = Describe("DirectoryReleaseSource", func() {
+	const pathTemplate = `2.10/{{trimSuffix .Name "-release"}}/{{.Name}}-{{.Version}}-{{.StemcellOS}}-{{.StemcellVersion}}.tgz`
+
+	var (
+		tmpDir, directory, releasesDir string
+		releaseSource                  DirectoryReleaseSource
+	)
+
+	writeRelease := func(remotePath, contents string) {
+		fullPath := filepath.Join(directory, remotePath)
+		Expect(os.MkdirAll(filepath.Dir(fullPath), 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(fullPath, []byte(contents), 0644)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "directory-release-source")
+		Expect(err).NotTo(HaveOccurred())
+
+		directory = filepath.Join(tmpDir, "releases-mirror")
+		releasesDir = filepath.Join(tmpDir, "releases")
+		Expect(os.MkdirAll(releasesDir, 0755)).To(Succeed())
+
+		writeRelease("2.10/uaa/uaa-73.3.0-ubuntu-xenial-621.55.tgz", "uaa-73.3.0")
+		writeRelease("2.10/uaa/uaa-73.4.0-ubuntu-xenial-621.55.tgz", "uaa-73.4.0")
+		writeRelease("2.10/uaa/uaa-74.0.0-ubuntu-xenial-621.55.tgz", "uaa-74.0.0")
+		writeRelease("2.10/uaa/uaa-73.9.0-ubuntu-xenial-456.0.tgz", "other-stemcell")
+
+		releaseSource = NewDirectoryReleaseSource("some-directory", directory, pathTemplate, true, log.New(GinkgoWriter, "", 0))
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(tmpDir)
+	})
+
+	Describe("DirectoryReleaseSourceFromConfig", func() {
+		It("returns an error for a path_template it can't parse", func() {
+			_, err := DirectoryReleaseSourceFromConfig(cargo.ReleaseSourceConfig{
+				ID:           "some-directory",
+				Path:         directory,
+				PathTemplate: "{{.Name",
+			}, log.New(GinkgoWriter, "", 0))
+			Expect(err).To(MatchError(ContainSubstring(`release source "some-directory" has an invalid path_template`)))
+			Expect(errors.As(err, new(PathTemplateError))).To(BeTrue())
+		})
+	})
+
+	Describe("GetMatchedRelease", func() {
+		It("finds releases at the path given by the template", func() {
+			remote, found, err := releaseSource.GetMatchedRelease(release.Requirement{Name: "uaa", Version: "73.3.0", StemcellOS: "ubuntu-xenial", StemcellVersion: "621.55"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(remote).To(Equal(release.Remote{
+				ID:         release.ID{Name: "uaa", Version: "73.3.0"},
+				RemotePath: "2.10/uaa/uaa-73.3.0-ubuntu-xenial-621.55.tgz",
+				SourceID:   "some-directory",
+			}))
+		})
+
+		It("does not find releases that are not in the directory", func() {
+			_, found, err := releaseSource.GetMatchedRelease(release.Requirement{Name: "uaa", Version: "1.0.0", StemcellOS: "ubuntu-xenial", StemcellVersion: "621.55"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+	})
+
+	Describe("FindReleaseVersion", func() {
+		It("finds the latest release matching the constraint and stemcell", func() {
+			remote, found, err := releaseSource.FindReleaseVersion(release.Requirement{Name: "uaa", VersionConstraint: "~73", StemcellOS: "ubuntu-xenial", StemcellVersion: "621.55"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(remote).To(Equal(release.Remote{
+				ID:         release.ID{Name: "uaa", Version: "73.4.0"},
+				RemotePath: "2.10/uaa/uaa-73.4.0-ubuntu-xenial-621.55.tgz",
+				SourceID:   "some-directory",
+				SHA:        "8212448b2661c27c28209ee8b832bb6ac69c4e82",
+			}))
+		})
+
+		It("finds the latest release when there is no constraint", func() {
+			remote, found, err := releaseSource.FindReleaseVersion(release.Requirement{Name: "uaa", StemcellOS: "ubuntu-xenial", StemcellVersion: "621.55"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(remote.Version).To(Equal("74.0.0"))
+		})
+
+		It("does not find releases that are not in the directory", func() {
+			_, found, err := releaseSource.FindReleaseVersion(release.Requirement{Name: "metrics", StemcellOS: "ubuntu-xenial", StemcellVersion: "621.55"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+	})
+
+	Describe("DownloadRelease", func() {
+		It("copies the release into the releases directory", func() {
+			remote := release.Remote{ID: release.ID{Name: "uaa", Version: "73.3.0"}, RemotePath: "2.10/uaa/uaa-73.3.0-ubuntu-xenial-621.55.tgz"}
+
+			local, err := releaseSource.DownloadRelease(releasesDir, remote, 0)
+			Expect(err).NotTo(HaveOccurred())
+
+			expectedPath := filepath.Join(releasesDir, "uaa-73.3.0-ubuntu-xenial-621.55.tgz")
+			Expect(local).To(Equal(release.Local{ID: remote.ID, LocalPath: expectedPath, SHA1: "ef24433d73098eca5b995d760b3dc6e114222fba", SHA256: "280520a1dc5d72a2ab6ed18479f0a0071778786b4b0e4648aae7d65bbe580257"}))
+			Expect(ioutil.ReadFile(expectedPath)).To(BeEquivalentTo("uaa-73.3.0"))
+		})
+
+		When("the release does not exist", func() {
+			It("returns an error", func() {
+				remote := release.Remote{ID: release.ID{Name: "uaa", Version: "1.0.0"}, RemotePath: "2.10/uaa/missing.tgz"}
+
+				_, err := releaseSource.DownloadRelease(releasesDir, remote, 0)
+				Expect(err).To(MatchError(ContainSubstring("failed to open release")))
+			})
+		})
+	})
+
+	Describe("UploadRelease", func() {
+		It("writes the release to the path given by the template", func() {
+			spec := release.Requirement{Name: "bpm", Version: "1.1.0", StemcellOS: "ubuntu-xenial", StemcellVersion: "621.55"}
+
+			remote, err := releaseSource.UploadRelease(spec, strings.NewReader("bpm-tarball"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(remote).To(Equal(release.Remote{
+				ID:         release.ID{Name: "bpm", Version: "1.1.0"},
+				RemotePath: "2.10/bpm/bpm-1.1.0-ubuntu-xenial-621.55.tgz",
+				SourceID:   "some-directory",
+			}))
+
+			Expect(ioutil.ReadFile(filepath.Join(directory, "2.10/bpm/bpm-1.1.0-ubuntu-xenial-621.55.tgz"))).To(BeEquivalentTo("bpm-tarball"))
+
+			_, found, err := releaseSource.GetMatchedRelease(spec)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeTrue())
+		})
+	})
+})
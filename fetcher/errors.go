@@ -1,5 +1,69 @@
 package fetcher
 
+import "fmt"
+
 type stringError string
 
 func (str stringError) Error() string { return string(str) }
+
+// MissingFieldError is returned when a release source config lacks a field
+// its type requires.
+type MissingFieldError struct {
+	SourceType string
+	Field      string
+}
+
+func (err MissingFieldError) Error() string {
+	return fmt.Sprintf("missing required field %q in %s release source config; is your Kilnfile out of date?", err.Field, err.SourceType)
+}
+
+// UnknownSourceTypeError is returned for a release source config with a type
+// kiln does not support.
+type UnknownSourceTypeError struct {
+	Type string
+}
+
+func (err UnknownSourceTypeError) Error() string {
+	return fmt.Sprintf("unknown release source type %q", err.Type)
+}
+
+// DuplicateIDError is returned when two release sources end up with the same
+// ID, so one could not be told apart from the other.
+type DuplicateIDError struct {
+	ID                    string
+	FirstIndex, NextIndex int
+}
+
+func (err DuplicateIDError) Error() string {
+	return fmt.Sprintf("release_sources must have unique IDs; items at index %d and %d both have ID %q", err.FirstIndex, err.NextIndex, err.ID)
+}
+
+// PathTemplateError is returned for a release source whose path_template can
+// not be parsed.
+type PathTemplateError struct {
+	SourceID string
+	Err      error
+}
+
+func (err PathTemplateError) Error() string {
+	return fmt.Sprintf("release source %q has an invalid path_template: %s", err.SourceID, err.Err)
+}
+
+func (err PathTemplateError) Unwrap() error {
+	return err.Err
+}
+
+// ReleaseSourceConfigError identifies the release source in the Kilnfile that
+// could not be constructed.
+type ReleaseSourceConfigError struct {
+	Index int
+	Err   error
+}
+
+func (err ReleaseSourceConfigError) Error() string {
+	return fmt.Sprintf("release_sources[%d]: %s", err.Index, err.Err)
+}
+
+func (err ReleaseSourceConfigError) Unwrap() error {
+	return err.Err
+}
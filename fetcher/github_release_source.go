@@ -0,0 +1,382 @@
+package fetcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver"
+
+	"github.com/pivotal-cf/kiln/internal/cargo"
+	"github.com/pivotal-cf/kiln/release"
+)
+
+const defaultGithubAPIURL = "https://api.github.com"
+
+// GithubReleaseSource finds BOSH release tarballs attached as assets to
+// GitHub releases. The repository is looked up in org using the release name
+// and the same suffixes bosh.io uses (uaa, uaa-release, ...).
+type GithubReleaseSource struct {
+	id          string
+	org         string
+	token       string
+	apiURL      string
+	publishable bool
+	client      *http.Client
+	logger      *log.Logger
+}
+
+func NewGithubReleaseSource(id, org, token, customAPIURL string, publishable bool, logger *log.Logger) GithubReleaseSource {
+	if customAPIURL == "" {
+		customAPIURL = defaultGithubAPIURL
+	}
+
+	return GithubReleaseSource{
+		id:          id,
+		org:         org,
+		token:       token,
+		apiURL:      strings.TrimSuffix(customAPIURL, "/"),
+		publishable: publishable,
+		client:      http.DefaultClient,
+		logger:      logger,
+	}
+}
+
+func GithubReleaseSourceFromConfig(config cargo.ReleaseSourceConfig, logger *log.Logger) (GithubReleaseSource, error) {
+	if config.Org == "" {
+		return GithubReleaseSource{}, MissingFieldError{SourceType: ReleaseSourceTypeGithub, Field: "org"}
+	}
+
+	return NewGithubReleaseSource(config.ID, config.Org, config.GithubToken, config.Endpoint, config.Publishable, logger), nil
+}
+
+// WithHTTPClient returns a copy of the release source using client for every
+// request to GitHub.
+func (src GithubReleaseSource) WithHTTPClient(client *http.Client) GithubReleaseSource {
+	src.client = client
+	return src
+}
+
+func (src GithubReleaseSource) ID() string {
+	return src.id
+}
+
+func (src GithubReleaseSource) Publishable() bool {
+	return src.publishable
+}
+
+func (src GithubReleaseSource) GetMatchedRelease(requirement release.Requirement) (release.Remote, bool, error) {
+	for _, repo := range src.repositoryNames(requirement.Name) {
+		for _, tag := range []string{"v" + requirement.Version, requirement.Version} {
+			var rel githubRelease
+			found, err := src.getJSON(fmt.Sprintf("/repos/%s/%s/releases/tags/%s", src.org, repo, tag), &rel)
+			if err != nil {
+				return release.Remote{}, false, err
+			}
+			if !found {
+				continue
+			}
+
+			asset, found := rel.tarball()
+			if !found {
+				continue
+			}
+
+			return src.remote(requirement.Name, requirement.Version, asset), true, nil
+		}
+	}
+
+	return release.Remote{}, false, nil
+}
+
+func (src GithubReleaseSource) FindReleaseVersion(requirement release.Requirement) (release.Remote, bool, error) {
+	constraintString := requirement.VersionConstraint
+	if constraintString == "" {
+		constraintString = ">0"
+	}
+	constraint, err := semver.NewConstraint(constraintString)
+	if err != nil {
+		return release.Remote{}, false, fmt.Errorf("invalid version constraint %q: %w", constraintString, err)
+	}
+
+	for _, repo := range src.repositoryNames(requirement.Name) {
+		releases, found, err := src.listReleases(repo)
+		if err != nil {
+			return release.Remote{}, false, err
+		}
+		if !found {
+			continue
+		}
+
+		var (
+			latestVersion *semver.Version
+			latestRelease githubRelease
+			latestAsset   githubAsset
+		)
+		for _, rel := range releases {
+			if rel.Draft || rel.Prerelease {
+				continue
+			}
+
+			version, err := semver.NewVersion(strings.TrimPrefix(rel.TagName, "v"))
+			if err != nil || !constraint.Check(version) {
+				continue
+			}
+
+			asset, found := rel.tarball()
+			if !found {
+				continue
+			}
+
+			if latestVersion == nil || version.GreaterThan(latestVersion) {
+				latestVersion, latestRelease, latestAsset = version, rel, asset
+			}
+		}
+
+		if latestVersion == nil {
+			continue
+		}
+
+		remote := src.remote(requirement.Name, latestVersion.Original(), latestAsset)
+
+		// the SHA is left empty, for the download that follows to provide,
+		// unless the release publishes one
+		if checksumAsset, found := latestRelease.asset(latestAsset.Name + ".sha1"); found {
+			remote.SHA, err = src.getSHA1Asset(checksumAsset)
+			if err != nil {
+				return release.Remote{}, false, err
+			}
+		}
+
+		return remote, true, nil
+	}
+
+	return release.Remote{}, false, nil
+}
+
+// ReleaseNotes returns the body of the GitHub release for a version of a BOSH
+// release, if there is one.
+func (src GithubReleaseSource) ReleaseNotes(name, version string) (string, bool, error) {
+	for _, repo := range src.repositoryNames(name) {
+		for _, tag := range []string{"v" + version, version} {
+			var rel githubRelease
+			found, err := src.getJSON(fmt.Sprintf("/repos/%s/%s/releases/tags/%s", src.org, repo, tag), &rel)
+			if err != nil {
+				return "", false, err
+			}
+			if found {
+				return rel.Body, true, nil
+			}
+		}
+	}
+
+	return "", false, nil
+}
+
+func (src GithubReleaseSource) DownloadRelease(releaseDir string, remoteRelease release.Remote, downloadThreads int) (release.Local, error) {
+	src.logger.Printf("downloading %s %s from %s", remoteRelease.Name, remoteRelease.Version, src.ID())
+
+	filePath := filepath.Join(releaseDir, fmt.Sprintf("%s-%s.tgz", remoteRelease.Name, remoteRelease.Version))
+
+	download, err := openPartialDownload(filePath)
+	if err != nil {
+		return release.Local{}, err
+	}
+	defer download.Close()
+
+	if download.Offset() > 0 {
+		src.logger.Printf("resuming download of %s %s after %d bytes", remoteRelease.Name, remoteRelease.Version, download.Offset())
+	}
+
+	req, err := src.newRequest(remoteRelease.RemotePath)
+	if err != nil {
+		return release.Local{}, err
+	}
+	req.Header.Set("Accept", "application/octet-stream")
+
+	err = download.Get(src.client, req)
+	if err != nil {
+		return release.Local{}, err
+	}
+
+	sha1, sha256, err := download.Complete()
+	if err != nil {
+		return release.Local{}, err
+	}
+
+	return release.Local{ID: remoteRelease.ID, LocalPath: filePath, SHA1: sha1, SHA256: sha256}, nil
+}
+
+func (src GithubReleaseSource) getSHA1Asset(asset githubAsset) (string, error) {
+	req, err := src.newRequest(asset.URL)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/octet-stream")
+
+	resp, err := src.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", asset.Name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download %s: %w", asset.Name, (*ResponseStatusCodeError)(resp))
+	}
+
+	contents, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", asset.Name, err)
+	}
+
+	sha1, found := parsePublishedSHA1(contents)
+	if !found {
+		return "", fmt.Errorf("%s does not contain a SHA1 checksum", asset.Name)
+	}
+	return sha1, nil
+}
+
+func (src GithubReleaseSource) repositoryNames(releaseName string) []string {
+	names := make([]string, 0, len(suffixes))
+	for _, suffix := range suffixes {
+		names = append(names, releaseName+suffix)
+	}
+	return names
+}
+
+func (src GithubReleaseSource) remote(name, version string, asset githubAsset) release.Remote {
+	return release.Remote{
+		ID:         release.ID{Name: name, Version: version},
+		RemotePath: asset.URL,
+		SourceID:   src.ID(),
+	}
+}
+
+func (src GithubReleaseSource) newRequest(url string) (*http.Request, error) {
+	if strings.HasPrefix(url, "/") {
+		url = src.apiURL + url
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if src.token != "" {
+		req.Header.Set("Authorization", "token "+src.token)
+	}
+
+	return req, nil
+}
+
+// listReleases follows the pages of a repository's releases, so versions
+// older than the first hundred releases can be found.
+func (src GithubReleaseSource) listReleases(repo string) ([]githubRelease, bool, error) {
+	var releases []githubRelease
+
+	next := fmt.Sprintf("/repos/%s/%s/releases?per_page=100", src.org, repo)
+	for next != "" {
+		var page []githubRelease
+		found, nextPage, err := src.getJSONPage(next, &page)
+		if err != nil || !found {
+			return nil, found, err
+		}
+		releases = append(releases, page...)
+		next = nextPage
+	}
+
+	return releases, true, nil
+}
+
+func (src GithubReleaseSource) getJSON(path string, v interface{}) (bool, error) {
+	found, _, err := src.getJSONPage(path, v)
+	return found, err
+}
+
+// getJSONPage also returns the URL of the next page from the Link header, if
+// there is one.
+func (src GithubReleaseSource) getJSONPage(path string, v interface{}) (bool, string, error) {
+	req, err := src.newRequest(path)
+	if err != nil {
+		return false, "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+
+	resp, err := src.client.Do(req)
+	if err != nil {
+		return false, "", fmt.Errorf("GitHub API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return false, "", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, "", (*ResponseStatusCodeError)(resp)
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(v)
+	if err != nil {
+		return false, "", fmt.Errorf("failed to parse GitHub API response from %s: %w", req.URL, err)
+	}
+
+	return true, nextPageURL(resp.Header.Get("Link")), nil
+}
+
+var linkNextPattern = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+func nextPageURL(link string) string {
+	matches := linkNextPattern.FindStringSubmatch(link)
+	if len(matches) < 2 {
+		return ""
+	}
+	return matches[1]
+}
+
+type githubRelease struct {
+	TagName    string        `json:"tag_name"`
+	Body       string        `json:"body"`
+	Draft      bool          `json:"draft"`
+	Prerelease bool          `json:"prerelease"`
+	Assets     []githubAsset `json:"assets"`
+}
+
+type githubAsset struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+func (rel githubRelease) asset(name string) (githubAsset, bool) {
+	for _, asset := range rel.Assets {
+		if asset.Name == name {
+			return asset, true
+		}
+	}
+	return githubAsset{}, false
+}
+
+func (rel githubRelease) tarball() (githubAsset, bool) {
+	for _, asset := range rel.Assets {
+		if strings.HasSuffix(asset.Name, ".tgz") || strings.HasSuffix(asset.Name, ".tar.gz") {
+			return asset, true
+		}
+	}
+	return githubAsset{}, false
+}
+
+var sha1Pattern = regexp.MustCompile(`^[0-9a-fA-F]{40}$`)
+
+// parsePublishedSHA1 reads a checksum file as written by sha1sum, which may
+// name the file after the checksum.
+func parsePublishedSHA1(contents []byte) (string, bool) {
+	fields := strings.Fields(string(contents))
+	if len(fields) == 0 || !sha1Pattern.MatchString(fields[0]) {
+		return "", false
+	}
+	return strings.ToLower(fields[0]), true
+}
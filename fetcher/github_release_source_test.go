@@ -0,0 +1,250 @@
+package fetcher_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+
+	. "github.com/pivotal-cf/kiln/fetcher"
+	"github.com/pivotal-cf/kiln/release"
+)
+
+var _ = Describe("GithubReleaseSource", func() {
+	var (
+		releaseSource GithubReleaseSource
+		testServer    *ghttp.Server
+		assetURL      string
+	)
+
+	BeforeEach(func() {
+		testServer = ghttp.NewServer()
+		testServer.AllowUnhandledRequests = true
+		testServer.UnhandledRequestStatusCode = http.StatusNotFound
+
+		assetURL = testServer.URL() + "/repos/cloudfoundry/uaa-release/releases/assets/42"
+
+		releaseSource = NewGithubReleaseSource("some-github-source", "cloudfoundry", "some-token", testServer.URL(), false, log.New(GinkgoWriter, "", 0))
+	})
+
+	AfterEach(func() {
+		testServer.Close()
+	})
+
+	Describe("GetMatchedRelease", func() {
+		BeforeEach(func() {
+			testServer.RouteToHandler("GET", "/repos/cloudfoundry/uaa-release/releases/tags/v73.3.0", ghttp.CombineHandlers(
+				ghttp.VerifyHeaderKV("Authorization", "token some-token"),
+				ghttp.RespondWith(http.StatusOK, fmt.Sprintf(`{
+					"tag_name": "v73.3.0",
+					"assets": [
+						{"name": "uaa-73.3.0.tgz.sha256", "url": "some-other-url"},
+						{"name": "uaa-73.3.0.tgz", "url": %q}
+					]
+				}`, assetURL)),
+			))
+			testServer.RouteToHandler("GET", "/repos/cloudfoundry/metrics/releases/tags/2.3.0", ghttp.RespondWith(http.StatusOK, `{
+				"tag_name": "2.3.0",
+				"assets": [{"name": "metrics-2.3.0.tar.gz", "url": "some-metrics-url"}]
+			}`))
+		})
+
+		It("finds releases tagged with a v prefix in a -release repository", func() {
+			remote, found, err := releaseSource.GetMatchedRelease(release.Requirement{Name: "uaa", Version: "73.3.0"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(remote).To(Equal(release.Remote{
+				ID:         release.ID{Name: "uaa", Version: "73.3.0"},
+				RemotePath: assetURL,
+				SourceID:   "some-github-source",
+			}))
+		})
+
+		It("finds releases tagged without a v prefix", func() {
+			remote, found, err := releaseSource.GetMatchedRelease(release.Requirement{Name: "metrics", Version: "2.3.0"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(remote.RemotePath).To(Equal("some-metrics-url"))
+		})
+
+		It("does not find releases that do not exist", func() {
+			_, found, err := releaseSource.GetMatchedRelease(release.Requirement{Name: "uaa", Version: "1.0.0"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+
+		When("the GitHub API returns an error", func() {
+			BeforeEach(func() {
+				testServer.RouteToHandler("GET", "/repos/cloudfoundry/broken/releases/tags/v1.0.0", ghttp.RespondWith(http.StatusUnauthorized, `{"message": "Bad credentials"}`))
+			})
+
+			It("returns the error", func() {
+				_, _, err := releaseSource.GetMatchedRelease(release.Requirement{Name: "broken", Version: "1.0.0"})
+				Expect(err).To(MatchError(ContainSubstring("401")))
+			})
+		})
+	})
+
+	Describe("ReleaseNotes", func() {
+		BeforeEach(func() {
+			testServer.RouteToHandler("GET", "/repos/cloudfoundry/uaa-release/releases/tags/v74.0.0", ghttp.RespondWith(http.StatusOK, `{
+				"tag_name": "v74.0.0",
+				"body": "- Fixes login with SAML"
+			}`))
+		})
+
+		It("returns the body of the GitHub release", func() {
+			notes, found, err := releaseSource.ReleaseNotes("uaa", "74.0.0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(notes).To(Equal("- Fixes login with SAML"))
+		})
+
+		It("does not find notes for releases that do not exist", func() {
+			_, found, err := releaseSource.ReleaseNotes("uaa", "1.0.0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+
+		It("uses the HTTP client it is given", func() {
+			client := &http.Client{Transport: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+				return nil, errors.New("no network")
+			})}
+
+			_, _, err := releaseSource.WithHTTPClient(client).ReleaseNotes("uaa", "74.0.0")
+			Expect(err).To(MatchError(ContainSubstring("no network")))
+		})
+	})
+
+	Describe("FindReleaseVersion", func() {
+		BeforeEach(func() {
+			testServer.RouteToHandler("GET", "/repos/cloudfoundry/uaa-release/releases", ghttp.RespondWith(http.StatusOK, fmt.Sprintf(`[
+				{"tag_name": "v74.0.0", "assets": [{"name": "uaa-74.0.0.tgz", "url": "newer-major-url"}]},
+				{"tag_name": "v73.5.0-rc.1", "prerelease": true, "assets": [{"name": "uaa.tgz", "url": "prerelease-url"}]},
+				{"tag_name": "v73.4.0", "draft": true, "assets": [{"name": "uaa.tgz", "url": "draft-url"}]},
+				{"tag_name": "v73.3.0", "assets": [{"name": "uaa-73.3.0.tgz", "url": %q}]},
+				{"tag_name": "v73.2.0", "assets": [{"name": "uaa-73.2.0.tgz", "url": "older-url"}]}
+			]`, assetURL)))
+		})
+
+		It("finds the latest published release matching the constraint", func() {
+			remote, found, err := releaseSource.FindReleaseVersion(release.Requirement{Name: "uaa", VersionConstraint: "~73"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(remote).To(Equal(release.Remote{
+				ID:         release.ID{Name: "uaa", Version: "73.3.0"},
+				RemotePath: assetURL,
+				SourceID:   "some-github-source",
+			}))
+
+			for _, req := range testServer.ReceivedRequests() {
+				Expect(req.URL.Path).NotTo(Equal("/repos/cloudfoundry/uaa-release/releases/assets/42"), "the release should not be downloaded")
+			}
+		})
+
+		When("the release publishes a SHA1 checksum", func() {
+			BeforeEach(func() {
+				testServer.RouteToHandler("GET", "/repos/cloudfoundry/uaa-release/releases", ghttp.RespondWith(http.StatusOK, fmt.Sprintf(`[
+					{"tag_name": "v73.3.0", "assets": [
+						{"name": "uaa-73.3.0.tgz", "url": %q},
+						{"name": "uaa-73.3.0.tgz.sha1", "url": %q}
+					]}
+				]`, assetURL, testServer.URL()+"/repos/cloudfoundry/uaa-release/releases/assets/43")))
+				testServer.RouteToHandler("GET", "/repos/cloudfoundry/uaa-release/releases/assets/43", ghttp.CombineHandlers(
+					ghttp.VerifyHeaderKV("Accept", "application/octet-stream"),
+					ghttp.RespondWith(http.StatusOK, "0EE4ECEF8E88A257B4951FF6B3D65A25462D043A  uaa-73.3.0.tgz\n"),
+				))
+			})
+
+			It("uses the published checksum", func() {
+				remote, found, err := releaseSource.FindReleaseVersion(release.Requirement{Name: "uaa", VersionConstraint: "~73"})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(found).To(BeTrue())
+				Expect(remote.SHA).To(Equal("0ee4ecef8e88a257b4951ff6b3d65a25462d043a"))
+			})
+		})
+
+		It("does not find releases in repositories that do not exist", func() {
+			_, found, err := releaseSource.FindReleaseVersion(release.Requirement{Name: "missing"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+
+		When("the matching release is not on the first page", func() {
+			BeforeEach(func() {
+				testServer.RouteToHandler("GET", "/repos/cloudfoundry/uaa-release/releases", func(w http.ResponseWriter, req *http.Request) {
+					if req.URL.Query().Get("page") == "2" {
+						fmt.Fprintf(w, `[{"tag_name": "v73.3.0", "assets": [{"name": "uaa-73.3.0.tgz", "url": %q}]}]`, assetURL)
+						return
+					}
+					w.Header().Set("Link", fmt.Sprintf(`<%s/repos/cloudfoundry/uaa-release/releases?per_page=100&page=2>; rel="next", <%[1]s/repos/cloudfoundry/uaa-release/releases?per_page=100&page=2>; rel="last"`, testServer.URL()))
+					fmt.Fprint(w, `[{"tag_name": "v74.0.0", "assets": [{"name": "uaa-74.0.0.tgz", "url": "newer-major-url"}]}]`)
+				})
+			})
+
+			It("follows the next page links", func() {
+				remote, found, err := releaseSource.FindReleaseVersion(release.Requirement{Name: "uaa", VersionConstraint: "~73"})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(found).To(BeTrue())
+				Expect(remote.Version).To(Equal("73.3.0"))
+				Expect(remote.RemotePath).To(Equal(assetURL))
+			})
+		})
+	})
+
+	Describe("DownloadRelease", func() {
+		var releaseDir string
+
+		BeforeEach(func() {
+			var err error
+			releaseDir, err = ioutil.TempDir("", "github-release-source")
+			Expect(err).NotTo(HaveOccurred())
+
+			testServer.RouteToHandler("GET", "/repos/cloudfoundry/uaa-release/releases/assets/42", ghttp.CombineHandlers(
+				ghttp.VerifyHeaderKV("Authorization", "token some-token"),
+				ghttp.VerifyHeaderKV("Accept", "application/octet-stream"),
+				ghttp.RespondWith(http.StatusOK, "uaa-tarball"),
+			))
+		})
+
+		AfterEach(func() {
+			_ = os.RemoveAll(releaseDir)
+		})
+
+		It("downloads the release asset", func() {
+			remote := release.Remote{ID: release.ID{Name: "uaa", Version: "73.3.0"}, RemotePath: assetURL, SourceID: "some-github-source"}
+
+			local, err := releaseSource.DownloadRelease(releaseDir, remote, 0)
+			Expect(err).NotTo(HaveOccurred())
+
+			expectedPath := filepath.Join(releaseDir, "uaa-73.3.0.tgz")
+			Expect(local).To(Equal(release.Local{
+				ID:        remote.ID,
+				LocalPath: expectedPath,
+				SHA1:      "0ee4ecef8e88a257b4951ff6b3d65a25462d043a",
+				SHA256:    "a05930aef3d64c5e78e53aef9e9e23a8a14bfa08ed46b1069b98cd2aac95aa0f",
+			}))
+			Expect(ioutil.ReadFile(expectedPath)).To(BeEquivalentTo("uaa-tarball"))
+		})
+
+		When("the asset does not exist", func() {
+			It("returns an error", func() {
+				remote := release.Remote{ID: release.ID{Name: "uaa", Version: "1.0.0"}, RemotePath: testServer.URL() + "/missing"}
+
+				_, err := releaseSource.DownloadRelease(releaseDir, remote, 0)
+				Expect(err).To(MatchError(ContainSubstring("404")))
+			})
+		})
+	})
+})
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
@@ -0,0 +1,471 @@
+package fetcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/semver"
+
+	"github.com/pivotal-cf/kiln/internal/cargo"
+	"github.com/pivotal-cf/kiln/release"
+)
+
+// HTTPReleaseSource finds release tarballs on a plain HTTP file server or an
+// Artifactory-like repository. Releases are laid out using a path_template,
+// just like in an S3 bucket. Versions are discovered by listing the directory
+// containing the release tarballs, either as an HTML index or as JSON.
+type HTTPReleaseSource struct {
+	id                 string
+	baseURL            string
+	pathTemplateString string
+	pathTemplate       *template.Template
+	pathTemplateErr    error
+	publishable        bool
+
+	username, password string
+	bearerToken        string
+
+	signatures *ReleaseSignatures
+
+	client *http.Client
+	logger *log.Logger
+}
+
+func NewHTTPReleaseSource(id, baseURL, pathTemplate string, publishable bool, logger *log.Logger) HTTPReleaseSource {
+	src := HTTPReleaseSource{
+		id:                 id,
+		baseURL:            strings.TrimSuffix(baseURL, "/"),
+		pathTemplateString: pathTemplate,
+		publishable:        publishable,
+		client:             http.DefaultClient,
+		logger:             logger,
+	}
+	src.pathTemplate, src.pathTemplateErr = parsePathTemplate(pathTemplate)
+	return src
+}
+
+func HTTPReleaseSourceFromConfig(config cargo.ReleaseSourceConfig, logger *log.Logger) (HTTPReleaseSource, error) {
+	if config.PathTemplate == "" {
+		return HTTPReleaseSource{}, MissingFieldError{SourceType: ReleaseSourceTypeHTTP, Field: "path_template"}
+	}
+	if config.URL == "" {
+		return HTTPReleaseSource{}, MissingFieldError{SourceType: ReleaseSourceTypeHTTP, Field: "url"}
+	}
+
+	src := NewHTTPReleaseSource(config.ID, config.URL, config.PathTemplate, config.Publishable, logger)
+	if src.pathTemplateErr != nil {
+		return HTTPReleaseSource{}, PathTemplateError{SourceID: config.ID, Err: src.pathTemplateErr}
+	}
+	src = src.WithBasicAuth(config.Username, config.Password)
+	src = src.WithBearerToken(config.BearerToken)
+
+	signatures, err := signaturesFromConfig(config)
+	if err != nil {
+		return HTTPReleaseSource{}, err
+	}
+	return src.WithSignatures(signatures), nil
+}
+
+func (src HTTPReleaseSource) WithBasicAuth(username, password string) HTTPReleaseSource {
+	src.username, src.password = username, password
+	return src
+}
+
+func (src HTTPReleaseSource) WithBearerToken(token string) HTTPReleaseSource {
+	src.bearerToken = token
+	return src
+}
+
+// WithSignatures makes DownloadRelease refuse releases without a valid
+// signature.
+func (src HTTPReleaseSource) WithSignatures(signatures *ReleaseSignatures) HTTPReleaseSource {
+	src.signatures = signatures
+	return src
+}
+
+// VerifiesSignatures reports whether DownloadRelease refuses releases without
+// a valid signature.
+func (src HTTPReleaseSource) VerifiesSignatures() bool {
+	return src.signatures != nil
+}
+
+func (src HTTPReleaseSource) ID() string {
+	return src.id
+}
+
+func (src HTTPReleaseSource) Publishable() bool {
+	return src.publishable
+}
+
+func (src HTTPReleaseSource) GetMatchedRelease(requirement release.Requirement) (release.Remote, bool, error) {
+	remotePath, err := src.RemotePath(requirement)
+	if err != nil {
+		return release.Remote{}, false, err
+	}
+
+	req, err := src.newRequest(http.MethodHead, src.url(remotePath), nil)
+	if err != nil {
+		return release.Remote{}, false, err
+	}
+
+	resp, err := src.client.Do(req)
+	if err != nil {
+		return release.Remote{}, false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return release.Remote{}, false, nil
+	default:
+		return release.Remote{}, false, (*ResponseStatusCodeError)(resp)
+	}
+
+	return release.Remote{
+		ID:         release.ID{Name: requirement.Name, Version: requirement.Version},
+		RemotePath: remotePath,
+		SourceID:   src.ID(),
+	}, true, nil
+}
+
+func (src HTTPReleaseSource) FindReleaseVersion(requirement release.Requirement) (release.Remote, bool, error) {
+	constraintString := requirement.VersionConstraint
+	if constraintString == "" {
+		constraintString = ">0"
+	}
+	constraint, err := semver.NewConstraint(constraintString)
+	if err != nil {
+		return release.Remote{}, false, fmt.Errorf("invalid version constraint %q: %w", constraintString, err)
+	}
+
+	pathPattern, err := pathTemplateVersionPattern(src.RemotePath, requirement)
+	if err != nil {
+		return release.Remote{}, false, err
+	}
+
+	indexPath, err := src.indexPath(requirement)
+	if err != nil {
+		return release.Remote{}, false, err
+	}
+
+	names, found, err := src.listIndex(indexPath)
+	if err != nil || !found {
+		return release.Remote{}, false, err
+	}
+
+	var (
+		latestVersion *semver.Version
+		latestPath    string
+		listed        = make(map[string]bool)
+	)
+	for _, name := range names {
+		remotePath := path.Join(indexPath, path.Base(name))
+		listed[remotePath] = true
+
+		version, found := matchVersion(pathPattern, remotePath)
+		if !found || !constraint.Check(version) {
+			continue
+		}
+
+		if latestVersion == nil || version.GreaterThan(latestVersion) {
+			latestVersion, latestPath = version, remotePath
+		}
+	}
+
+	if latestVersion == nil {
+		return release.Remote{}, false, nil
+	}
+
+	sha1, err := src.publishedSHA1(latestPath, listed[latestPath+".sha1"])
+	if err != nil {
+		return release.Remote{}, false, err
+	}
+
+	return release.Remote{
+		ID:         release.ID{Name: requirement.Name, Version: latestVersion.Original()},
+		RemotePath: latestPath,
+		SourceID:   src.ID(),
+		SHA:        sha1,
+	}, true, nil
+}
+
+// publishedSHA1 reads the SHA1 of a release from a .sha1 file next to it, or
+// from the X-Checksum-Sha1 header Artifactory responds with. It is empty when
+// the server publishes neither, for the download that follows to provide.
+func (src HTTPReleaseSource) publishedSHA1(remotePath string, hasChecksumFile bool) (string, error) {
+	if hasChecksumFile {
+		req, err := src.newRequest(http.MethodGet, src.url(remotePath+".sha1"), nil)
+		if err != nil {
+			return "", err
+		}
+
+		resp, err := src.client.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("failed to download %s.sha1: %w", path.Base(remotePath), (*ResponseStatusCodeError)(resp))
+		}
+
+		contents, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return "", fmt.Errorf("failed to download %s.sha1: %w", path.Base(remotePath), err)
+		}
+
+		sha1, found := parsePublishedSHA1(contents)
+		if !found {
+			return "", fmt.Errorf("%s.sha1 does not contain a SHA1 checksum", path.Base(remotePath))
+		}
+		return sha1, nil
+	}
+
+	req, err := src.newRequest(http.MethodHead, src.url(remotePath), nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := src.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", nil
+	}
+	sha1, _ := parsePublishedSHA1([]byte(resp.Header.Get("X-Checksum-Sha1")))
+	return sha1, nil
+}
+
+func (src HTTPReleaseSource) DownloadRelease(releaseDir string, remoteRelease release.Remote, downloadThreads int) (release.Local, error) {
+	src.logger.Printf("downloading %s %s from %s", remoteRelease.Name, remoteRelease.Version, src.ID())
+
+	outputFile := filepath.Join(releaseDir, path.Base(remoteRelease.RemotePath))
+
+	download, err := openPartialDownload(outputFile)
+	if err != nil {
+		return release.Local{}, err
+	}
+	defer download.Close()
+
+	if download.Offset() > 0 {
+		src.logger.Printf("resuming download of %s %s after %d bytes", remoteRelease.Name, remoteRelease.Version, download.Offset())
+	}
+
+	req, err := src.newRequest(http.MethodGet, src.url(remoteRelease.RemotePath), nil)
+	if err != nil {
+		return release.Local{}, err
+	}
+
+	err = download.Get(src.client, req)
+	if err != nil {
+		return release.Local{}, fmt.Errorf("failed to download file: %w", err)
+	}
+
+	sha1, sha256, err := download.Complete()
+	if err != nil {
+		return release.Local{}, err
+	}
+
+	local := release.Local{ID: remoteRelease.ID, LocalPath: outputFile, SHA1: sha1, SHA256: sha256}
+	if src.signatures != nil {
+		signature, err := src.getSignature(remoteRelease)
+		err = src.signatures.verifyDownload(local, signature, err)
+		if err != nil {
+			return release.Local{}, err
+		}
+	}
+
+	return local, nil
+}
+
+func (src HTTPReleaseSource) getSignature(remote release.Remote) ([]byte, error) {
+	req, err := src.newRequest(http.MethodGet, src.url(src.signatures.signaturePath(remote.RemotePath)), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := src.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, (*ResponseStatusCodeError)(resp)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+func (src HTTPReleaseSource) UploadSignature(remote release.Remote, signature []byte) error {
+	req, err := src.newRequest(http.MethodPut, src.url(src.signatures.signaturePath(remote.RemotePath)), bytes.NewReader(signature))
+	if err != nil {
+		return err
+	}
+
+	resp, err := src.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+		return nil
+	default:
+		return fmt.Errorf("failed to upload signature: %w", (*ResponseStatusCodeError)(resp))
+	}
+}
+
+func (src HTTPReleaseSource) UploadRelease(spec release.Requirement, file io.Reader) (release.Remote, error) {
+	remotePath, err := src.RemotePath(spec)
+	if err != nil {
+		return release.Remote{}, err
+	}
+
+	src.logger.Printf("uploading release %q to %s at %q...\n", spec.Name, src.ID(), remotePath)
+
+	req, err := src.newRequest(http.MethodPut, src.url(remotePath), file)
+	if err != nil {
+		return release.Remote{}, err
+	}
+	req.Header.Set("Content-Type", "application/gzip")
+
+	resp, err := src.client.Do(req)
+	if err != nil {
+		return release.Remote{}, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+	default:
+		return release.Remote{}, fmt.Errorf("failed to upload release: %w", (*ResponseStatusCodeError)(resp))
+	}
+
+	return release.Remote{
+		ID:         release.ID{Name: spec.Name, Version: spec.Version},
+		RemotePath: remotePath,
+		SourceID:   src.ID(),
+	}, nil
+}
+
+func (src HTTPReleaseSource) RemotePath(requirement release.Requirement) (string, error) {
+	if src.pathTemplateErr != nil {
+		return "", PathTemplateError{SourceID: src.id, Err: src.pathTemplateErr}
+	}
+	return evaluatePathTemplate(src.pathTemplate, requirement)
+}
+
+func (src HTTPReleaseSource) url(remotePath string) string {
+	return src.baseURL + "/" + strings.TrimPrefix(remotePath, "/")
+}
+
+func (src HTTPReleaseSource) newRequest(method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case src.bearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+src.bearerToken)
+	case src.username != "":
+		req.SetBasicAuth(src.username, src.password)
+	}
+
+	return req, nil
+}
+
+// indexPath is the directory holding every version of the required release.
+func (src HTTPReleaseSource) indexPath(requirement release.Requirement) (string, error) {
+	requirement.Version = versionPlaceholder
+	remotePath, err := src.RemotePath(requirement)
+	if err != nil {
+		return "", err
+	}
+
+	indexPath := path.Dir(remotePath)
+	if strings.Contains(indexPath, versionPlaceholder) {
+		return "", fmt.Errorf("path_template %q must only use the version in the file name to find release versions", src.pathTemplateString)
+	}
+
+	return indexPath, nil
+}
+
+var indexLinkPattern = regexp.MustCompile(`(?i)href\s*=\s*["']([^"'?#]+)["']`)
+
+// listIndex returns the names of the files in a directory. Servers responding
+// with JSON may return a list of names or an Artifactory storage listing;
+// anything else is treated as an HTML index page.
+func (src HTTPReleaseSource) listIndex(indexPath string) ([]string, bool, error) {
+	req, err := src.newRequest(http.MethodGet, src.url(indexPath)+"/", nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Accept", "application/json, text/html;q=0.9")
+
+	resp, err := src.client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, (*ResponseStatusCodeError)(resp)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read index of %s: %w", req.URL, err)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if !strings.HasSuffix(mediaType, "json") {
+		var names []string
+		for _, match := range indexLinkPattern.FindAllStringSubmatch(string(body), -1) {
+			names = append(names, match[1])
+		}
+		return names, true, nil
+	}
+
+	var names []string
+	if err := json.Unmarshal(body, &names); err == nil {
+		return names, true, nil
+	}
+
+	var storage struct {
+		Children []struct {
+			URI    string `json:"uri"`
+			Folder bool   `json:"folder"`
+		} `json:"children"`
+	}
+	err = json.Unmarshal(body, &storage)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to parse index of %s: %w", req.URL, err)
+	}
+	for _, child := range storage.Children {
+		if !child.Folder {
+			names = append(names, child.URI)
+		}
+	}
+
+	return names, true, nil
+}
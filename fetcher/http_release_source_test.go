@@ -0,0 +1,299 @@
+package fetcher_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+
+	. "github.com/pivotal-cf/kiln/fetcher"
+	"github.com/pivotal-cf/kiln/internal/cargo"
+	"github.com/pivotal-cf/kiln/release"
+)
+
+var _ = Describe("HTTPReleaseSource", func() {
+	const pathTemplate = `bosh-releases/{{.Name}}/{{.Name}}-{{.Version}}-{{.StemcellOS}}-{{.StemcellVersion}}.tgz`
+
+	var (
+		releaseSource HTTPReleaseSource
+		testServer    *ghttp.Server
+		requirement   release.Requirement
+	)
+
+	BeforeEach(func() {
+		testServer = ghttp.NewServer()
+		testServer.AllowUnhandledRequests = true
+		testServer.UnhandledRequestStatusCode = http.StatusNotFound
+
+		releaseSource = NewHTTPReleaseSource("some-http-source", testServer.URL()+"/artifactory/", pathTemplate, true, log.New(GinkgoWriter, "", 0))
+
+		requirement = release.Requirement{Name: "uaa", Version: "73.3.0", StemcellOS: "ubuntu-xenial", StemcellVersion: "621.55"}
+	})
+
+	AfterEach(func() {
+		testServer.Close()
+	})
+
+	Describe("HTTPReleaseSourceFromConfig", func() {
+		It("returns an error for a path_template it can't parse", func() {
+			_, err := HTTPReleaseSourceFromConfig(cargo.ReleaseSourceConfig{
+				ID:           "some-http-source",
+				URL:          testServer.URL(),
+				PathTemplate: "{{.Name",
+			}, log.New(GinkgoWriter, "", 0))
+			Expect(err).To(MatchError(ContainSubstring(`release source "some-http-source" has an invalid path_template`)))
+			Expect(errors.As(err, new(PathTemplateError))).To(BeTrue())
+		})
+	})
+
+	Describe("GetMatchedRelease", func() {
+		BeforeEach(func() {
+			releaseSource = releaseSource.WithBasicAuth("some-user", "some-password")
+			testServer.RouteToHandler("HEAD", "/artifactory/bosh-releases/uaa/uaa-73.3.0-ubuntu-xenial-621.55.tgz", ghttp.CombineHandlers(
+				ghttp.VerifyBasicAuth("some-user", "some-password"),
+				ghttp.RespondWith(http.StatusOK, nil),
+			))
+		})
+
+		It("finds releases at the URL given by the template", func() {
+			remote, found, err := releaseSource.GetMatchedRelease(requirement)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(remote).To(Equal(release.Remote{
+				ID:         release.ID{Name: "uaa", Version: "73.3.0"},
+				RemotePath: "bosh-releases/uaa/uaa-73.3.0-ubuntu-xenial-621.55.tgz",
+				SourceID:   "some-http-source",
+			}))
+		})
+
+		It("does not find releases that do not exist", func() {
+			requirement.Version = "1.0.0"
+			_, found, err := releaseSource.GetMatchedRelease(requirement)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+
+		When("the server rejects the credentials", func() {
+			BeforeEach(func() {
+				testServer.RouteToHandler("HEAD", "/artifactory/bosh-releases/uaa/uaa-73.3.0-ubuntu-xenial-621.55.tgz", ghttp.RespondWith(http.StatusForbidden, nil))
+			})
+
+			It("returns an error", func() {
+				_, _, err := releaseSource.GetMatchedRelease(requirement)
+				Expect(err).To(MatchError(ContainSubstring("403")))
+			})
+		})
+	})
+
+	Describe("FindReleaseVersion", func() {
+		BeforeEach(func() {
+			requirement = release.Requirement{Name: "uaa", VersionConstraint: "~73", StemcellOS: "ubuntu-xenial", StemcellVersion: "621.55"}
+
+			testServer.RouteToHandler("HEAD", "/artifactory/bosh-releases/uaa/uaa-73.4.0-ubuntu-xenial-621.55.tgz", ghttp.RespondWith(http.StatusOK, nil))
+		})
+
+		When("the server responds with an HTML directory index", func() {
+			const index = `<html><body>
+<a href="../">../</a>
+<a href="uaa-73.3.0-ubuntu-xenial-621.55.tgz">uaa-73.3.0-ubuntu-xenial-621.55.tgz</a>
+<a href="uaa-73.4.0-ubuntu-xenial-621.55.tgz">uaa-73.4.0-ubuntu-xenial-621.55.tgz</a>
+<a href="uaa-73.9.0-ubuntu-xenial-456.0.tgz">uaa-73.9.0-ubuntu-xenial-456.0.tgz</a>
+<a href="uaa-74.0.0-ubuntu-xenial-621.55.tgz">uaa-74.0.0-ubuntu-xenial-621.55.tgz</a>
+</body></html>`
+
+			BeforeEach(func() {
+				testServer.RouteToHandler("GET", "/artifactory/bosh-releases/uaa/", ghttp.RespondWith(http.StatusOK, index, http.Header{"Content-Type": {"text/html"}}))
+			})
+
+			It("finds the latest release matching the constraint and stemcell without downloading it", func() {
+				remote, found, err := releaseSource.FindReleaseVersion(requirement)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(found).To(BeTrue())
+				Expect(remote).To(Equal(release.Remote{
+					ID:         release.ID{Name: "uaa", Version: "73.4.0"},
+					RemotePath: "bosh-releases/uaa/uaa-73.4.0-ubuntu-xenial-621.55.tgz",
+					SourceID:   "some-http-source",
+				}))
+
+				for _, req := range testServer.ReceivedRequests() {
+					Expect(req.Method == http.MethodGet && strings.HasSuffix(req.URL.Path, ".tgz")).To(BeFalse(), "the release should not be downloaded")
+				}
+			})
+
+			When("the index lists a SHA1 checksum file for the release", func() {
+				BeforeEach(func() {
+					testServer.RouteToHandler("GET", "/artifactory/bosh-releases/uaa/", ghttp.RespondWith(http.StatusOK,
+						strings.Replace(index, "</body>", `<a href="uaa-73.4.0-ubuntu-xenial-621.55.tgz.sha1">uaa-73.4.0-ubuntu-xenial-621.55.tgz.sha1</a>
+</body>`, 1),
+						http.Header{"Content-Type": {"text/html"}},
+					))
+					testServer.RouteToHandler("GET", "/artifactory/bosh-releases/uaa/uaa-73.4.0-ubuntu-xenial-621.55.tgz.sha1", ghttp.RespondWith(http.StatusOK,
+						"8212448b2661c27c28209ee8b832bb6ac69c4e82  uaa-73.4.0-ubuntu-xenial-621.55.tgz\n",
+					))
+				})
+
+				It("uses the published checksum", func() {
+					remote, found, err := releaseSource.FindReleaseVersion(requirement)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(found).To(BeTrue())
+					Expect(remote.SHA).To(Equal("8212448b2661c27c28209ee8b832bb6ac69c4e82"))
+				})
+			})
+		})
+
+		When("the server responds with an Artifactory storage listing", func() {
+			BeforeEach(func() {
+				releaseSource = releaseSource.WithBearerToken("some-token")
+				testServer.RouteToHandler("GET", "/artifactory/bosh-releases/uaa/", ghttp.CombineHandlers(
+					ghttp.VerifyHeaderKV("Authorization", "Bearer some-token"),
+					ghttp.RespondWith(http.StatusOK, `{"children": [
+						{"uri": "/uaa-73.3.0-ubuntu-xenial-621.55.tgz", "folder": false},
+						{"uri": "/uaa-73.4.0-ubuntu-xenial-621.55.tgz", "folder": false},
+						{"uri": "/uaa-73.5.0-ubuntu-xenial-621.55.tgz", "folder": true}
+					]}`, http.Header{"Content-Type": {"application/json"}}),
+				))
+			})
+
+			It("finds the latest release matching the constraint", func() {
+				remote, found, err := releaseSource.FindReleaseVersion(requirement)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(found).To(BeTrue())
+				Expect(remote.Version).To(Equal("73.4.0"))
+			})
+
+			It("uses the checksum Artifactory responds with", func() {
+				testServer.RouteToHandler("HEAD", "/artifactory/bosh-releases/uaa/uaa-73.4.0-ubuntu-xenial-621.55.tgz", ghttp.RespondWith(http.StatusOK, nil,
+					http.Header{"X-Checksum-Sha1": {"8212448b2661c27c28209ee8b832bb6ac69c4e82"}},
+				))
+
+				remote, _, err := releaseSource.FindReleaseVersion(requirement)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(remote.SHA).To(Equal("8212448b2661c27c28209ee8b832bb6ac69c4e82"))
+			})
+		})
+
+		When("the server responds with a JSON list of names", func() {
+			BeforeEach(func() {
+				testServer.RouteToHandler("GET", "/artifactory/bosh-releases/uaa/", ghttp.RespondWith(http.StatusOK,
+					`["uaa-73.3.0-ubuntu-xenial-621.55.tgz", "uaa-73.4.0-ubuntu-xenial-621.55.tgz"]`,
+					http.Header{"Content-Type": {"application/json; charset=utf-8"}},
+				))
+			})
+
+			It("finds the latest release matching the constraint", func() {
+				remote, found, err := releaseSource.FindReleaseVersion(requirement)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(found).To(BeTrue())
+				Expect(remote.Version).To(Equal("73.4.0"))
+			})
+		})
+
+		When("there is no index for the release", func() {
+			It("does not find the release", func() {
+				requirement.Name = "metrics"
+				_, found, err := releaseSource.FindReleaseVersion(requirement)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(found).To(BeFalse())
+			})
+		})
+
+		When("the version is not part of the file name", func() {
+			It("returns an error", func() {
+				releaseSource = NewHTTPReleaseSource("some-http-source", testServer.URL(), "{{.Name}}/{{.Version}}/release.tgz", true, log.New(GinkgoWriter, "", 0))
+				_, _, err := releaseSource.FindReleaseVersion(requirement)
+				Expect(err).To(MatchError(ContainSubstring("must only use the version in the file name")))
+			})
+		})
+	})
+
+	Describe("DownloadRelease", func() {
+		var releaseDir string
+
+		BeforeEach(func() {
+			var err error
+			releaseDir, err = ioutil.TempDir("", "http-release-source")
+			Expect(err).NotTo(HaveOccurred())
+
+			testServer.RouteToHandler("GET", "/artifactory/bosh-releases/uaa/uaa-73.3.0-ubuntu-xenial-621.55.tgz", ghttp.RespondWith(http.StatusOK, "uaa-73.3.0"))
+		})
+
+		AfterEach(func() {
+			_ = os.RemoveAll(releaseDir)
+		})
+
+		It("downloads the release", func() {
+			remote := release.Remote{ID: release.ID{Name: "uaa", Version: "73.3.0"}, RemotePath: "bosh-releases/uaa/uaa-73.3.0-ubuntu-xenial-621.55.tgz"}
+
+			local, err := releaseSource.DownloadRelease(releaseDir, remote, 0)
+			Expect(err).NotTo(HaveOccurred())
+
+			expectedPath := filepath.Join(releaseDir, "uaa-73.3.0-ubuntu-xenial-621.55.tgz")
+			Expect(local).To(Equal(release.Local{ID: remote.ID, LocalPath: expectedPath, SHA1: "ef24433d73098eca5b995d760b3dc6e114222fba", SHA256: "280520a1dc5d72a2ab6ed18479f0a0071778786b4b0e4648aae7d65bbe580257"}))
+			Expect(ioutil.ReadFile(expectedPath)).To(BeEquivalentTo("uaa-73.3.0"))
+		})
+
+		When("the release does not exist", func() {
+			It("returns an error", func() {
+				remote := release.Remote{ID: release.ID{Name: "uaa", Version: "1.0.0"}, RemotePath: "bosh-releases/uaa/missing.tgz"}
+
+				_, err := releaseSource.DownloadRelease(releaseDir, remote, 0)
+				Expect(err).To(MatchError(ContainSubstring("404")))
+			})
+		})
+	})
+
+	Describe("UploadRelease", func() {
+		BeforeEach(func() {
+			releaseSource = releaseSource.WithBearerToken("some-token")
+		})
+
+		It("puts the release at the URL given by the template", func() {
+			testServer.RouteToHandler("PUT", "/artifactory/bosh-releases/uaa/uaa-73.3.0-ubuntu-xenial-621.55.tgz", ghttp.CombineHandlers(
+				ghttp.VerifyHeaderKV("Authorization", "Bearer some-token"),
+				ghttp.VerifyBody([]byte("uaa-tarball")),
+				ghttp.RespondWith(http.StatusCreated, nil),
+			))
+
+			remote, err := releaseSource.UploadRelease(requirement, strings.NewReader("uaa-tarball"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(remote).To(Equal(release.Remote{
+				ID:         release.ID{Name: "uaa", Version: "73.3.0"},
+				RemotePath: "bosh-releases/uaa/uaa-73.3.0-ubuntu-xenial-621.55.tgz",
+				SourceID:   "some-http-source",
+			}))
+			Expect(testServer.ReceivedRequests()).To(HaveLen(1))
+		})
+
+		When("the server rejects the upload", func() {
+			It("returns an error", func() {
+				testServer.RouteToHandler("PUT", "/artifactory/bosh-releases/uaa/uaa-73.3.0-ubuntu-xenial-621.55.tgz", ghttp.RespondWith(http.StatusUnauthorized, nil))
+
+				_, err := releaseSource.UploadRelease(requirement, strings.NewReader("uaa-tarball"))
+				Expect(err).To(MatchError(ContainSubstring("failed to upload release")))
+			})
+		})
+	})
+
+	Describe("UploadSignature", func() {
+		It("puts the signature next to the release", func() {
+			testServer.RouteToHandler("PUT", "/artifactory/bosh-releases/uaa/uaa-73.3.0-ubuntu-xenial-621.55.tgz.sig", ghttp.CombineHandlers(
+				ghttp.VerifyBody([]byte("uaa-signature")),
+				ghttp.RespondWith(http.StatusCreated, nil),
+			))
+
+			err := releaseSource.UploadSignature(release.Remote{
+				ID:         release.ID{Name: "uaa", Version: "73.3.0"},
+				RemotePath: "bosh-releases/uaa/uaa-73.3.0-ubuntu-xenial-621.55.tgz",
+			}, []byte("uaa-signature"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(testServer.ReceivedRequests()).To(HaveLen(1))
+		})
+	})
+})
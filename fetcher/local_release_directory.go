@@ -2,6 +2,7 @@ package fetcher
 
 import (
 	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"github.com/pivotal-cf/kiln/builder"
@@ -39,13 +40,13 @@ func (l LocalReleaseDirectory) GetLocalReleases(releasesDir string) ([]release.L
 		releaseManifest := rel.Metadata.(builder.ReleaseManifest)
 		id := release.ID{Name: releaseManifest.Name, Version: releaseManifest.Version}
 		localPath := rel.File
-		sha1, err := CalculateSum(localPath, osfs.New(""))
+		sha1, sha256, err := CalculateSums(localPath, osfs.New(""))
 
 		if err != nil {
-			return nil, fmt.Errorf("couldn't calculate SHA1 sum of %q: %w", localPath, err) // untested
+			return nil, fmt.Errorf("couldn't calculate the checksums of %q: %w", localPath, err) // untested
 		}
 
-		outputReleases = append(outputReleases, release.Local{ID: id, LocalPath: localPath, SHA1: sha1})
+		outputReleases = append(outputReleases, release.Local{ID: id, LocalPath: localPath, SHA1: sha1, SHA256: sha256})
 	}
 	return outputReleases, nil
 }
@@ -100,17 +101,24 @@ func (l LocalReleaseDirectory) deleteReleases(releasesToDelete []release.Local)
 }
 
 func CalculateSum(releasePath string, fs billy.Filesystem) (string, error) {
+	sha1Sum, _, err := CalculateSums(releasePath, fs)
+	return sha1Sum, err
+}
+
+// CalculateSums returns the SHA1 and SHA256 checksums of a release tarball,
+// reading it only once.
+func CalculateSums(releasePath string, fs billy.Filesystem) (string, string, error) {
 	f, err := fs.Open(releasePath)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 	defer f.Close()
 
-	h := sha1.New()
-	_, err = io.Copy(h, f)
+	sha1Hash, sha256Hash := sha1.New(), sha256.New()
+	_, err = io.Copy(io.MultiWriter(sha1Hash, sha256Hash), f)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
-	return hex.EncodeToString(h.Sum(nil)), nil
+	return hex.EncodeToString(sha1Hash.Sum(nil)), hex.EncodeToString(sha256Hash.Sum(nil)), nil
 }
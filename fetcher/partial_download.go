@@ -0,0 +1,231 @@
+package fetcher
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	partialDownloadSuffix = ".partial"
+
+	// partialDownloadOffsetSuffix names the file recording how much of a
+	// .partial file was written without gaps when it is written out of order.
+	partialDownloadOffsetSuffix = ".partial.offset"
+)
+
+// partialDownload writes a release tarball next to its final path so a download
+// interrupted by a crash can be resumed from where it stopped. The tarball is
+// only moved into place once it has been completely written.
+//
+// Parts written with WriteAt, like those of a concurrent S3 download, may
+// arrive out of order, so the end of the parts written without gaps is
+// recorded next to the file and a resumed download starts from there.
+type partialDownload struct {
+	file      *os.File
+	finalPath string
+	offset    int64
+
+	mu         sync.Mutex
+	contiguous int64
+	pending    map[int64]int64
+	recording  bool
+}
+
+func openPartialDownload(finalPath string) (*partialDownload, error) {
+	partialPath := finalPath + partialDownloadSuffix
+
+	file, err := os.OpenFile(partialPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file %q: %w", partialPath, err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to stat file %q: %w", partialPath, err) // untested
+	}
+
+	offset := info.Size()
+	if recorded, found := readPartialDownloadOffset(finalPath); found && recorded < offset {
+		err = file.Truncate(recorded)
+		if err != nil {
+			_ = file.Close()
+			return nil, fmt.Errorf("failed to truncate file %q: %w", partialPath, err) // untested
+		}
+		offset = recorded
+	}
+
+	return &partialDownload{
+		file:       file,
+		finalPath:  finalPath,
+		offset:     offset,
+		contiguous: offset,
+		pending:    make(map[int64]int64),
+	}, nil
+}
+
+// readPartialDownloadOffset treats an unreadable offset file as if nothing
+// was written.
+func readPartialDownloadOffset(finalPath string) (int64, bool) {
+	contents, err := ioutil.ReadFile(finalPath + partialDownloadOffsetSuffix)
+	if err != nil {
+		return 0, !os.IsNotExist(err)
+	}
+	offset, err := strconv.ParseInt(strings.TrimSpace(string(contents)), 10, 64)
+	if err != nil || offset < 0 {
+		return 0, true
+	}
+	return offset, true
+}
+
+// Offset is the number of bytes written by a previous attempt.
+func (d *partialDownload) Offset() int64 {
+	return d.offset
+}
+
+// Restart discards anything written by a previous attempt.
+func (d *partialDownload) Restart() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.offset, d.contiguous = 0, 0
+	d.pending = make(map[int64]int64)
+	err := os.Remove(d.finalPath + partialDownloadOffsetSuffix)
+	if err != nil && !os.IsNotExist(err) {
+		return err // untested
+	}
+	d.recording = false
+	return d.file.Truncate(0)
+}
+
+// WriteAt writes relative to the end of the previously downloaded bytes and
+// records how far the file has been written without gaps.
+func (d *partialDownload) WriteAt(p []byte, off int64) (int, error) {
+	d.mu.Lock()
+	if !d.recording {
+		// the offset is recorded before any part is written, so parts
+		// written out of order by an interrupted attempt are never trusted
+		err := d.recordOffset()
+		if err != nil {
+			d.mu.Unlock()
+			return 0, err
+		}
+		d.recording = true
+	}
+	d.mu.Unlock()
+
+	start := d.offset + off
+	n, err := d.file.WriteAt(p, start)
+	if err != nil {
+		return n, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.pending[start] = start + int64(n)
+	advanced := false
+	for end, found := d.pending[d.contiguous]; found; end, found = d.pending[d.contiguous] {
+		delete(d.pending, d.contiguous)
+		d.contiguous = end
+		advanced = true
+	}
+	if advanced {
+		err = d.recordOffset()
+	}
+	return n, err
+}
+
+func (d *partialDownload) recordOffset() error {
+	offsetPath := d.finalPath + partialDownloadOffsetSuffix
+	err := ioutil.WriteFile(offsetPath, []byte(strconv.FormatInt(d.contiguous, 10)), 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write file %q: %w", offsetPath, err) // untested
+	}
+	return nil
+}
+
+// ReadFrom appends everything in r after the previously downloaded bytes.
+func (d *partialDownload) ReadFrom(r io.Reader) (int64, error) {
+	_, err := d.file.Seek(d.offset, io.SeekStart)
+	if err != nil {
+		return 0, err // untested
+	}
+	return io.Copy(d.file, r)
+}
+
+// Get resumes the download with a range request. Servers that ignore the
+// range are handled by starting over.
+func (d *partialDownload) Get(client *http.Client, req *http.Request) error {
+	if d.offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", d.offset))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		err = d.Restart()
+		if err != nil {
+			return err // untested
+		}
+		fallthrough
+	case http.StatusPartialContent:
+		_, err = d.ReadFrom(resp.Body)
+		return err
+	case http.StatusRequestedRangeNotSatisfiable:
+		if d.offset == 0 {
+			return (*ResponseStatusCodeError)(resp)
+		}
+		return nil // the previous attempt already wrote the whole file
+	default:
+		return (*ResponseStatusCodeError)(resp)
+	}
+}
+
+// Complete moves the tarball into its final location and returns its SHA1
+// and SHA256 checksums.
+func (d *partialDownload) Complete() (string, string, error) {
+	_, err := d.file.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", "", fmt.Errorf("error reseting file cursor: %w", err) // untested
+	}
+
+	sha1Hash, sha256Hash := sha1.New(), sha256.New()
+	_, err = io.Copy(io.MultiWriter(sha1Hash, sha256Hash), d.file)
+	if err != nil {
+		return "", "", fmt.Errorf("error hashing file contents: %w", err) // untested
+	}
+
+	err = d.file.Close()
+	if err != nil {
+		return "", "", fmt.Errorf("error closing file %q: %w", d.file.Name(), err) // untested
+	}
+
+	err = os.Rename(d.file.Name(), d.finalPath)
+	if err != nil {
+		return "", "", fmt.Errorf("error moving %q into place: %w", d.finalPath, err) // untested
+	}
+	_ = os.Remove(d.finalPath + partialDownloadOffsetSuffix)
+
+	return hex.EncodeToString(sha1Hash.Sum(nil)), hex.EncodeToString(sha256Hash.Sum(nil)), nil
+}
+
+// Close releases the file without moving it into place, leaving whatever was
+// written to be resumed later. It is safe to call after Complete.
+func (d *partialDownload) Close() {
+	_ = d.file.Close()
+}
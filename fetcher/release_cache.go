@@ -0,0 +1,355 @@
+package fetcher
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/src-d/go-billy.v4/osfs"
+
+	"github.com/pivotal-cf/kiln/release"
+)
+
+const releaseCacheRemotesDirectory = "remotes"
+
+// ReleaseCache is a directory of release tarballs that can be shared between
+// tiles. Tarballs are stored by their SHA1 so any tile that locks the same
+// release can link it into its releases directory instead of downloading it.
+//
+// The zero value is a disabled cache; lookups never find anything and
+// additions are ignored.
+type ReleaseCache struct {
+	directory string
+	logger    *log.Logger
+}
+
+func NewReleaseCache(directory string, logger *log.Logger) ReleaseCache {
+	return ReleaseCache{
+		directory: directory,
+		logger:    logger,
+	}
+}
+
+func (cache ReleaseCache) Enabled() bool {
+	return cache.directory != ""
+}
+
+// Get links the cached release into releasesDir. When sha1 is empty the
+// release is looked up by where it was originally downloaded from. An entry
+// whose tarball does not match its SHA1 is removed and not found.
+func (cache ReleaseCache) Get(releasesDir string, remote release.Remote, sha1 string) (release.Local, bool, error) {
+	if !cache.Enabled() {
+		return release.Local{}, false, nil
+	}
+
+	if sha1 == "" {
+		var found bool
+		sha1, found = cache.lookupRemote(remote)
+		if !found {
+			return release.Local{}, false, nil
+		}
+	}
+
+	cachedPath, found, err := cache.tarballPath(sha1)
+	if err != nil || !found {
+		return release.Local{}, false, err
+	}
+
+	cachedSHA1, sha256Sum, err := CalculateSums(cachedPath, osfs.New(""))
+	if err != nil {
+		return release.Local{}, false, fmt.Errorf("failed to calculate the checksums of cached release %q: %w", cachedPath, err) // untested
+	}
+
+	// a truncated or modified tarball must not be used in place of the release it is stored as
+	if cachedSHA1 != sha1 {
+		cache.logger.Printf("warning: cached release %q has an incorrect SHA1 - expected %q, got %q; removing it from the cache", cachedPath, sha1, cachedSHA1)
+		err = os.RemoveAll(cache.entryPath(sha1))
+		if err != nil {
+			return release.Local{}, false, fmt.Errorf("failed to remove bad cached release %q: %w", cachedPath, err) // untested
+		}
+		return release.Local{}, false, nil
+	}
+
+	localPath := filepath.Join(releasesDir, filepath.Base(cachedPath))
+	err = linkOrCopy(cachedPath, localPath)
+	if err != nil {
+		return release.Local{}, false, fmt.Errorf("failed to copy cached release %q: %w", cachedPath, err)
+	}
+
+	now := time.Now()
+	_ = os.Chtimes(filepath.Dir(cachedPath), now, now) // used by Prune to find the least recently used entries
+
+	cache.logger.Printf("using cached %s %s from %s", remote.Name, remote.Version, cache.directory)
+
+	return release.Local{ID: remote.ID, LocalPath: localPath, SHA1: sha1, SHA256: sha256Sum}, true, nil
+}
+
+// Add stores a downloaded release in the cache and remembers where it came from.
+func (cache ReleaseCache) Add(local release.Local, remote release.Remote) error {
+	if !cache.Enabled() || local.SHA1 == "" {
+		return nil
+	}
+
+	_, found, err := cache.tarballPath(local.SHA1)
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		err = os.MkdirAll(cache.directory, 0755)
+		if err != nil {
+			return fmt.Errorf("failed to create release cache: %w", err)
+		}
+
+		tmpDir, err := ioutil.TempDir(cache.directory, local.SHA1+".tmp")
+		if err != nil {
+			return fmt.Errorf("failed to create release cache entry: %w", err)
+		}
+		defer os.RemoveAll(tmpDir)
+
+		err = linkOrCopy(local.LocalPath, filepath.Join(tmpDir, filepath.Base(local.LocalPath)))
+		if err != nil {
+			return fmt.Errorf("failed to add %q to release cache: %w", local.LocalPath, err)
+		}
+
+		err = os.Rename(tmpDir, cache.entryPath(local.SHA1))
+		if err != nil {
+			// another kiln process may have cached the same release in the meantime
+			if _, found, _ := cache.tarballPath(local.SHA1); !found {
+				return fmt.Errorf("failed to add %q to release cache: %w", local.LocalPath, err) // untested
+			}
+		}
+	}
+
+	if remote.SourceID == "" || remote.RemotePath == "" {
+		return nil
+	}
+
+	return cache.rememberRemote(remote, local.SHA1)
+}
+
+// ReleaseCacheEntry is a single cached release tarball.
+type ReleaseCacheEntry struct {
+	SHA1     string
+	Path     string
+	Size     int64
+	LastUsed time.Time
+}
+
+// Entries lists the cached releases from least to most recently used.
+func (cache ReleaseCache) Entries() ([]ReleaseCacheEntry, error) {
+	infos, err := ioutil.ReadDir(cache.directory)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var entries []ReleaseCacheEntry
+	for _, info := range infos {
+		if !info.IsDir() || info.Name() == releaseCacheRemotesDirectory || strings.Contains(info.Name(), ".tmp") {
+			continue
+		}
+
+		tarballPath, found, err := cache.tarballPath(info.Name())
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			continue
+		}
+
+		tarballInfo, err := os.Stat(tarballPath)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, ReleaseCacheEntry{
+			SHA1:     info.Name(),
+			Path:     tarballPath,
+			Size:     tarballInfo.Size(),
+			LastUsed: info.ModTime(),
+		})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].LastUsed.Before(entries[j].LastUsed)
+	})
+
+	return entries, nil
+}
+
+// Prune removes the least recently used releases until the cache is no larger than maxSize bytes.
+func (cache ReleaseCache) Prune(maxSize int64) ([]ReleaseCacheEntry, error) {
+	entries, err := cache.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	for _, entry := range entries {
+		total += entry.Size
+	}
+
+	var removed []ReleaseCacheEntry
+	for _, entry := range entries {
+		if total <= maxSize {
+			break
+		}
+
+		err := os.RemoveAll(cache.entryPath(entry.SHA1))
+		if err != nil {
+			return removed, fmt.Errorf("failed to remove cached release %q: %w", entry.Path, err)
+		}
+
+		total -= entry.Size
+		removed = append(removed, entry)
+	}
+
+	if len(removed) > 0 {
+		err = cache.forgetRemotes(removed)
+		if err != nil {
+			return removed, err
+		}
+	}
+
+	return removed, nil
+}
+
+func (cache ReleaseCache) entryPath(sha1 string) string {
+	return filepath.Join(cache.directory, sha1)
+}
+
+func (cache ReleaseCache) tarballPath(sha1 string) (string, bool, error) {
+	infos, err := ioutil.ReadDir(cache.entryPath(sha1))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+
+	for _, info := range infos {
+		if !info.IsDir() {
+			return filepath.Join(cache.entryPath(sha1), info.Name()), true, nil
+		}
+	}
+
+	return "", false, nil
+}
+
+func (cache ReleaseCache) remoteKeyPath(remote release.Remote) string {
+	sum := sha256.Sum256([]byte(remote.SourceID + "\x00" + remote.RemotePath))
+	return filepath.Join(cache.directory, releaseCacheRemotesDirectory, hex.EncodeToString(sum[:]))
+}
+
+func (cache ReleaseCache) lookupRemote(remote release.Remote) (string, bool) {
+	if remote.SourceID == "" || remote.RemotePath == "" {
+		return "", false
+	}
+
+	contents, err := ioutil.ReadFile(cache.remoteKeyPath(remote))
+	if err != nil {
+		return "", false
+	}
+
+	return strings.TrimSpace(string(contents)), true
+}
+
+func (cache ReleaseCache) rememberRemote(remote release.Remote, sha1 string) error {
+	keyPath := cache.remoteKeyPath(remote)
+
+	err := os.MkdirAll(filepath.Dir(keyPath), 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create release cache: %w", err)
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(keyPath), ".tmp")
+	if err != nil {
+		return fmt.Errorf("failed to record release cache entry: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.WriteString(sha1)
+	closeErr := tmp.Close()
+	if err != nil || closeErr != nil {
+		return fmt.Errorf("failed to record release cache entry: %v %v", err, closeErr) // untested
+	}
+
+	return os.Rename(tmp.Name(), keyPath)
+}
+
+func (cache ReleaseCache) forgetRemotes(removed []ReleaseCacheEntry) error {
+	removedSHAs := make(map[string]bool, len(removed))
+	for _, entry := range removed {
+		removedSHAs[entry.SHA1] = true
+	}
+
+	remotesDirectory := filepath.Join(cache.directory, releaseCacheRemotesDirectory)
+	infos, err := ioutil.ReadDir(remotesDirectory)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, info := range infos {
+		keyPath := filepath.Join(remotesDirectory, info.Name())
+		contents, err := ioutil.ReadFile(keyPath)
+		if err != nil {
+			continue
+		}
+		if removedSHAs[strings.TrimSpace(string(contents))] {
+			_ = os.Remove(keyPath)
+		}
+	}
+
+	return nil
+}
+
+// linkOrCopy hard links src to dst, falling back to a copy when the
+// two paths are on different filesystems. An existing dst is replaced.
+func linkOrCopy(src, dst string) error {
+	err := os.Remove(dst)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if os.Link(src, dst) == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmpPath := dst + ".tmp"
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, in)
+	closeErr := out.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+
+	return os.Rename(tmpPath, dst)
+}
@@ -0,0 +1,174 @@
+package fetcher_test
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/pivotal-cf/kiln/fetcher"
+	"github.com/pivotal-cf/kiln/release"
+)
+
+var _ = Describe("ReleaseCache", func() {
+	const uaaSHA1 = "b066f8b25ba123b75badfd96a51dbec19223c92d"
+
+	var (
+		tmpDir, cacheDir, releasesDir string
+		cache                         ReleaseCache
+		local                         release.Local
+		remote                        release.Remote
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "release-cache-test")
+		Expect(err).NotTo(HaveOccurred())
+
+		cacheDir = filepath.Join(tmpDir, "cache")
+		releasesDir = filepath.Join(tmpDir, "releases")
+		Expect(os.Mkdir(releasesDir, 0755)).To(Succeed())
+
+		cache = NewReleaseCache(cacheDir, log.New(GinkgoWriter, "", 0))
+
+		downloadedPath := filepath.Join(tmpDir, "uaa-1.2.3-ubuntu-xenial-621.tgz")
+		Expect(ioutil.WriteFile(downloadedPath, []byte("uaa-contents"), 0644)).To(Succeed())
+
+		local = release.Local{
+			ID:        release.ID{Name: "uaa", Version: "1.2.3"},
+			LocalPath: downloadedPath,
+			SHA1:      uaaSHA1,
+		}
+		remote = release.Remote{
+			ID:         local.ID,
+			SourceID:   "some-bucket",
+			RemotePath: "2.10/uaa/uaa-1.2.3-ubuntu-xenial-621.tgz",
+		}
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(tmpDir)
+	})
+
+	It("links added releases into a releases directory by SHA1", func() {
+		Expect(cache.Add(local, remote)).To(Succeed())
+
+		found, ok, err := cache.Get(releasesDir, remote, uaaSHA1)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+
+		expectedPath := filepath.Join(releasesDir, "uaa-1.2.3-ubuntu-xenial-621.tgz")
+		Expect(found).To(Equal(release.Local{
+			ID:        local.ID,
+			LocalPath: expectedPath,
+			SHA1:      uaaSHA1,
+			SHA256:    "76db5f4355e5e2f84382d19eeb86f8344cd49ee824d3859a5195f475906e33d8",
+		}))
+		Expect(ioutil.ReadFile(expectedPath)).To(BeEquivalentTo("uaa-contents"))
+	})
+
+	It("finds added releases by their remote when the SHA1 is not known", func() {
+		Expect(cache.Add(local, remote)).To(Succeed())
+
+		found, ok, err := cache.Get(releasesDir, remote, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(found.SHA1).To(Equal(uaaSHA1))
+
+		otherRemote := remote
+		otherRemote.RemotePath = "2.10/uaa/uaa-1.2.3-ubuntu-xenial-456.tgz"
+		_, ok, err = cache.Get(releasesDir, otherRemote, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeFalse())
+	})
+
+	It("removes entries whose tarball does not match their SHA1", func() {
+		Expect(cache.Add(local, remote)).To(Succeed())
+		cachedPath := filepath.Join(cacheDir, uaaSHA1, "uaa-1.2.3-ubuntu-xenial-621.tgz")
+		Expect(ioutil.WriteFile(cachedPath, []byte("uaa-con"), 0644)).To(Succeed())
+
+		_, ok, err := cache.Get(releasesDir, remote, uaaSHA1)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeFalse())
+		Expect(filepath.Join(releasesDir, "uaa-1.2.3-ubuntu-xenial-621.tgz")).NotTo(BeAnExistingFile())
+		Expect(filepath.Join(cacheDir, uaaSHA1)).NotTo(BeADirectory())
+	})
+
+	It("does not find releases that were never added", func() {
+		_, ok, err := cache.Get(releasesDir, remote, "some-other-sha1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeFalse())
+	})
+
+	When("the cache is disabled", func() {
+		BeforeEach(func() {
+			cache = ReleaseCache{}
+		})
+
+		It("never finds anything", func() {
+			Expect(cache.Add(local, remote)).To(Succeed())
+
+			_, ok, err := cache.Get(releasesDir, remote, uaaSHA1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Describe("Prune", func() {
+		var oldLocal, newLocal release.Local
+
+		BeforeEach(func() {
+			oldContents, newContents := bytes.Repeat([]byte("o"), 100), bytes.Repeat([]byte("n"), 100)
+			oldLocal = release.Local{ID: release.ID{Name: "old", Version: "1.0.0"}, LocalPath: filepath.Join(tmpDir, "old.tgz"), SHA1: fmt.Sprintf("%x", sha1.Sum(oldContents))}
+			newLocal = release.Local{ID: release.ID{Name: "new", Version: "1.0.0"}, LocalPath: filepath.Join(tmpDir, "new.tgz"), SHA1: fmt.Sprintf("%x", sha1.Sum(newContents))}
+			Expect(ioutil.WriteFile(oldLocal.LocalPath, oldContents, 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(newLocal.LocalPath, newContents, 0644)).To(Succeed())
+
+			oldRemote := release.Remote{ID: oldLocal.ID, SourceID: "some-bucket", RemotePath: "old.tgz"}
+			Expect(cache.Add(oldLocal, oldRemote)).To(Succeed())
+			Expect(cache.Add(newLocal, release.Remote{ID: newLocal.ID})).To(Succeed())
+
+			lastWeek := time.Now().Add(-7 * 24 * time.Hour)
+			Expect(os.Chtimes(filepath.Join(cacheDir, oldLocal.SHA1), lastWeek, lastWeek)).To(Succeed())
+		})
+
+		It("removes the least recently used releases until the cache fits", func() {
+			removed, err := cache.Prune(150)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(removed).To(HaveLen(1))
+			Expect(removed[0].SHA1).To(Equal(oldLocal.SHA1))
+
+			entries, err := cache.Entries()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(HaveLen(1))
+			Expect(entries[0].SHA1).To(Equal(newLocal.SHA1))
+
+			_, ok, err := cache.Get(releasesDir, release.Remote{ID: oldLocal.ID, SourceID: "some-bucket", RemotePath: "old.tgz"}, "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+
+		It("keeps recently used releases", func() {
+			_, _, err := cache.Get(releasesDir, release.Remote{ID: oldLocal.ID}, oldLocal.SHA1)
+			Expect(err).NotTo(HaveOccurred())
+
+			removed, err := cache.Prune(150)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(removed).To(HaveLen(1))
+			Expect(removed[0].SHA1).To(Equal(newLocal.SHA1))
+		})
+
+		It("does nothing when the cache is small enough", func() {
+			removed, err := cache.Prune(200)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(removed).To(BeEmpty())
+		})
+	})
+})
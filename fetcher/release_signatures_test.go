@@ -0,0 +1,171 @@
+package fetcher_test
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/pivotal-cf/kiln/fetcher"
+	"github.com/pivotal-cf/kiln/internal/cargo"
+	"github.com/pivotal-cf/kiln/release"
+)
+
+var _ = Describe("ReleaseSignatures", func() {
+	const (
+		pathTemplate = "{{.Name}}/{{.Name}}-{{.Version}}.tgz"
+		remotePath   = "uaa/uaa-1.2.3.tgz"
+	)
+
+	var (
+		tmpDir, directory, releasesDir string
+		remote                         release.Remote
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "release-signatures")
+		Expect(err).NotTo(HaveOccurred())
+
+		directory = filepath.Join(tmpDir, "bucket")
+		releasesDir = filepath.Join(tmpDir, "releases")
+		Expect(os.MkdirAll(filepath.Join(directory, "uaa"), 0755)).To(Succeed())
+		Expect(os.Mkdir(releasesDir, 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(directory, remotePath), []byte("uaa-tarball"), 0644)).To(Succeed())
+
+		remote = release.Remote{ID: release.ID{Name: "uaa", Version: "1.2.3"}, RemotePath: remotePath}
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(tmpDir)
+	})
+
+	signAndUpload := func(src DirectoryReleaseSource, privateKey []byte) {
+		signer, err := NewReleaseSigner(privateKey)
+		Expect(err).NotTo(HaveOccurred())
+		signature, err := signer.Sign(strings.NewReader("uaa-tarball"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(src.UploadSignature(remote, signature)).To(Succeed())
+	}
+
+	itVerifiesDownloads := func(signatureType string, keys func() (privateKey, publicKey []byte)) {
+		var (
+			privateKey, publicKey []byte
+			releaseSource         DirectoryReleaseSource
+		)
+
+		BeforeEach(func() {
+			privateKey, publicKey = keys()
+
+			var err error
+			releaseSource, err = DirectoryReleaseSourceFromConfig(cargo.ReleaseSourceConfig{
+				ID:           "signed",
+				Path:         directory,
+				PathTemplate: pathTemplate,
+				Verification: &cargo.VerificationConfig{Type: signatureType, PublicKey: string(publicKey)},
+			}, log.New(GinkgoWriter, "", 0))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("reports that it verifies signatures", func() {
+			Expect(releaseSource.VerifiesSignatures()).To(BeTrue())
+			Expect(releaseSource.WithSignatures(nil).VerifiesSignatures()).To(BeFalse())
+		})
+
+		It("downloads releases signed by the key", func() {
+			signAndUpload(releaseSource, privateKey)
+			Expect(filepath.Join(directory, remotePath+".sig")).To(BeAnExistingFile())
+
+			local, err := releaseSource.DownloadRelease(releasesDir, remote, 0)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(local.LocalPath).To(BeAnExistingFile())
+		})
+
+		It("refuses unsigned releases", func() {
+			_, err := releaseSource.DownloadRelease(releasesDir, remote, 0)
+			Expect(err).To(MatchError(ContainSubstring("couldn't get the signature of uaa 1.2.3")))
+			Expect(filepath.Join(releasesDir, "uaa-1.2.3.tgz")).NotTo(BeAnExistingFile())
+		})
+
+		It("refuses releases signed by another key", func() {
+			otherPrivateKey, _ := keys()
+			signAndUpload(releaseSource, otherPrivateKey)
+
+			_, err := releaseSource.DownloadRelease(releasesDir, remote, 0)
+			Expect(err).To(MatchError(ContainSubstring("uaa 1.2.3 is not signed by the verification key")))
+			Expect(filepath.Join(releasesDir, "uaa-1.2.3.tgz")).NotTo(BeAnExistingFile())
+		})
+
+		It("refuses releases that changed after they were signed", func() {
+			signAndUpload(releaseSource, privateKey)
+			Expect(ioutil.WriteFile(filepath.Join(directory, remotePath), []byte("tampered-tarball"), 0644)).To(Succeed())
+
+			_, err := releaseSource.DownloadRelease(releasesDir, remote, 0)
+			Expect(err).To(MatchError(ContainSubstring("uaa 1.2.3 is not signed by the verification key")))
+		})
+	}
+
+	Context("with cosign signatures", func() {
+		itVerifiesDownloads(SignatureTypeCosign, func() ([]byte, []byte) {
+			key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			Expect(err).NotTo(HaveOccurred())
+
+			privateDER, err := x509.MarshalPKCS8PrivateKey(key)
+			Expect(err).NotTo(HaveOccurred())
+			publicDER, err := x509.MarshalPKIXPublicKey(key.Public())
+			Expect(err).NotTo(HaveOccurred())
+
+			return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}),
+				pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+		})
+	})
+
+	Context("with gpg signatures", func() {
+		itVerifiesDownloads(SignatureTypeGPG, func() ([]byte, []byte) {
+			entity, err := openpgp.NewEntity("Release Engineering", "", "releng@example.com", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			var privateKey, publicKey bytes.Buffer
+			w, err := armor.Encode(&privateKey, openpgp.PrivateKeyType, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entity.SerializePrivate(w, nil)).To(Succeed())
+			Expect(w.Close()).To(Succeed())
+
+			w, err = armor.Encode(&publicKey, openpgp.PublicKeyType, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entity.Serialize(w)).To(Succeed())
+			Expect(w.Close()).To(Succeed())
+
+			return privateKey.Bytes(), publicKey.Bytes()
+		})
+	})
+
+	It("reads the public key from a file", func() {
+		Expect(ioutil.WriteFile(filepath.Join(tmpDir, "key.pub"), []byte("not a key"), 0644)).To(Succeed())
+
+		_, err := ReleaseSignaturesFromConfig(cargo.VerificationConfig{Type: SignatureTypeCosign, PublicKey: filepath.Join(tmpDir, "key.pub")})
+		Expect(err).To(MatchError("couldn't parse the verification public key: the key is not PEM encoded"))
+	})
+
+	It("rejects unknown signature types", func() {
+		_, err := ReleaseSignaturesFromConfig(cargo.VerificationConfig{Type: "notary", PublicKey: "-----BEGIN PUBLIC KEY-----"})
+		Expect(err).To(MatchError(`unknown verification type "notary"; use "cosign" or "gpg"`))
+	})
+
+	It("rejects signing keys it can't use", func() {
+		_, err := NewReleaseSigner([]byte("not a key"))
+		Expect(err).To(MatchError("the signing key is neither an armored PGP private key nor PEM encoded"))
+	})
+})
@@ -14,6 +14,9 @@ import (
 const (
 	ReleaseSourceTypeBOSHIO    = "bosh.io"
 	ReleaseSourceTypeS3        = "s3"
+	ReleaseSourceTypeGithub    = "github"
+	ReleaseSourceTypeDirectory = "directory"
+	ReleaseSourceTypeHTTP      = "http"
 	DefaultDownloadThreadCount = 0
 )
 
@@ -40,6 +43,16 @@ type ReleaseUploader interface {
 	UploadRelease(spec release.Requirement, file io.Reader) (release.Remote, error)
 }
 
+//go:generate counterfeiter -o ./fakes/signature_uploader.go --fake-name SignatureUploader . SignatureUploader
+type SignatureUploader interface {
+	UploadSignature(remote release.Remote, signature []byte) error
+}
+
+//go:generate counterfeiter -o ./fakes/signature_verifier.go --fake-name SignatureVerifier . SignatureVerifier
+type SignatureVerifier interface {
+	VerifiesSignatures() bool
+}
+
 //go:generate counterfeiter -o ./fakes/remote_pather.go --fake-name RemotePather . RemotePather
 type RemotePather interface {
 	RemotePath(release.Requirement) (string, error)
@@ -49,16 +62,23 @@ type ReleaseSourceRepo struct {
 	ReleaseSources []ReleaseSource
 }
 
-func NewReleaseSourceRepo(kilnfile cargo.Kilnfile, logger *log.Logger) ReleaseSourceRepo {
+func NewReleaseSourceRepo(kilnfile cargo.Kilnfile, logger *log.Logger) (ReleaseSourceRepo, error) {
 	var releaseSources multiReleaseSource
 
-	for _, releaseConfig := range kilnfile.ReleaseSources {
-		releaseSources = append(releaseSources, releaseSourceFor(releaseConfig, logger))
+	for index, releaseConfig := range kilnfile.ReleaseSources {
+		releaseSource, err := releaseSourceFor(releaseConfig, logger)
+		if err != nil {
+			return ReleaseSourceRepo{}, ReleaseSourceConfigError{Index: index, Err: err}
+		}
+		releaseSources = append(releaseSources, releaseSource)
 	}
 
-	panicIfDuplicateIDs(releaseSources)
+	err := checkForDuplicateIDs(releaseSources)
+	if err != nil {
+		return ReleaseSourceRepo{}, err
+	}
 
-	return ReleaseSourceRepo{ReleaseSources: releaseSources}
+	return ReleaseSourceRepo{ReleaseSources: releaseSources}, nil
 }
 
 func (repo ReleaseSourceRepo) MultiReleaseSource(allowOnlyPublishable bool) multiReleaseSource {
@@ -135,32 +155,52 @@ func (repo ReleaseSourceRepo) FindRemotePather(sourceID string) (RemotePather, e
 	return pather, nil
 }
 
-func releaseSourceFor(releaseConfig cargo.ReleaseSourceConfig, outLogger *log.Logger) ReleaseSource {
+func releaseSourceFor(releaseConfig cargo.ReleaseSourceConfig, outLogger *log.Logger) (ReleaseSource, error) {
+	if releaseConfig.Verification != nil && (releaseConfig.Type == ReleaseSourceTypeBOSHIO || releaseConfig.Type == ReleaseSourceTypeGithub) {
+		return nil, fmt.Errorf("%s release sources do not support verification", releaseConfig.Type)
+	}
+
 	switch releaseConfig.Type {
 	case ReleaseSourceTypeBOSHIO:
 		id := releaseConfig.ID
 		if id == "" {
 			id = ReleaseSourceTypeBOSHIO
 		}
-		return NewBOSHIOReleaseSource(id, releaseConfig.Publishable, "", outLogger)
+		return NewBOSHIOReleaseSource(id, releaseConfig.Publishable, "", outLogger), nil
 	case ReleaseSourceTypeS3:
 		if releaseConfig.ID == "" {
 			releaseConfig.ID = releaseConfig.Bucket
 		}
 		return S3ReleaseSourceFromConfig(releaseConfig, outLogger)
+	case ReleaseSourceTypeGithub:
+		if releaseConfig.ID == "" {
+			releaseConfig.ID = releaseConfig.Org
+		}
+		return GithubReleaseSourceFromConfig(releaseConfig, outLogger)
+	case ReleaseSourceTypeDirectory:
+		if releaseConfig.ID == "" {
+			releaseConfig.ID = releaseConfig.Path
+		}
+		return DirectoryReleaseSourceFromConfig(releaseConfig, outLogger)
+	case ReleaseSourceTypeHTTP:
+		if releaseConfig.ID == "" {
+			releaseConfig.ID = releaseConfig.URL
+		}
+		return HTTPReleaseSourceFromConfig(releaseConfig, outLogger)
 	default:
-		panic(fmt.Sprintf("unknown release config: %v", releaseConfig))
+		return nil, UnknownSourceTypeError{Type: releaseConfig.Type}
 	}
 }
 
-func panicIfDuplicateIDs(releaseSources []ReleaseSource) {
+func checkForDuplicateIDs(releaseSources []ReleaseSource) error {
 	indexOfID := make(map[string]int)
 	for index, rs := range releaseSources {
 		id := rs.ID()
 		previousIndex, seen := indexOfID[id]
 		if seen {
-			panic(fmt.Sprintf(`release_sources must have unique IDs; items at index %d and %d both have ID %q`, previousIndex, index, id))
+			return DuplicateIDError{ID: id, FirstIndex: previousIndex, NextIndex: index}
 		}
 		indexOfID[id] = index
 	}
+	return nil
 }
@@ -1,6 +1,7 @@
 package fetcher_test
 
 import (
+	"errors"
 	"log"
 
 	. "github.com/onsi/ginkgo"
@@ -31,7 +32,8 @@ var _ = Describe("ReleaseSourceRepo", func() {
 			})
 
 			It("constructs the ReleaseSources properly", func() {
-				repo := NewReleaseSourceRepo(kilnfile, logger)
+				repo, err := NewReleaseSourceRepo(kilnfile, logger)
+				Expect(err).NotTo(HaveOccurred())
 				releaseSources := repo.ReleaseSources
 
 				Expect(releaseSources).To(HaveLen(3))
@@ -64,7 +66,8 @@ var _ = Describe("ReleaseSourceRepo", func() {
 			})
 
 			It("marks it correctly", func() {
-				repo := NewReleaseSourceRepo(kilnfile, logger)
+				repo, err := NewReleaseSourceRepo(kilnfile, logger)
+				Expect(err).NotTo(HaveOccurred())
 				releaseSources := repo.ReleaseSources
 
 				Expect(releaseSources).To(HaveLen(1))
@@ -89,7 +92,8 @@ var _ = Describe("ReleaseSourceRepo", func() {
 			})
 
 			It("gives the correct IDs to the release sources", func() {
-				repo := NewReleaseSourceRepo(kilnfile, logger)
+				repo, err := NewReleaseSourceRepo(kilnfile, logger)
+				Expect(err).NotTo(HaveOccurred())
 				releaseSources := repo.ReleaseSources
 
 				Expect(releaseSources).To(HaveLen(3))
@@ -99,6 +103,79 @@ var _ = Describe("ReleaseSourceRepo", func() {
 			})
 		})
 
+		Context("when the Kilnfile has a github release source", func() {
+			BeforeEach(func() {
+				kilnfile = cargo.Kilnfile{
+					ReleaseSources: []cargo.ReleaseSourceConfig{
+						{Type: "github", Org: "cloudfoundry", GithubToken: "some-token"},
+					},
+				}
+			})
+
+			It("uses the org as the ID", func() {
+				repo, err := NewReleaseSourceRepo(kilnfile, logger)
+				Expect(err).NotTo(HaveOccurred())
+				releaseSources := repo.ReleaseSources
+
+				Expect(releaseSources).To(HaveLen(1))
+				Expect(releaseSources[0]).To(BeAssignableToTypeOf(GithubReleaseSource{}))
+				Expect(releaseSources[0].ID()).To(Equal("cloudfoundry"))
+			})
+		})
+
+		Context("when the Kilnfile has a directory release source", func() {
+			BeforeEach(func() {
+				kilnfile = cargo.Kilnfile{
+					ReleaseSources: []cargo.ReleaseSourceConfig{
+						{Type: "directory", Path: "/mnt/releases", PathTemplate: "template"},
+					},
+				}
+			})
+
+			It("uses the path as the ID", func() {
+				repo, err := NewReleaseSourceRepo(kilnfile, logger)
+				Expect(err).NotTo(HaveOccurred())
+				releaseSources := repo.ReleaseSources
+
+				Expect(releaseSources).To(HaveLen(1))
+				Expect(releaseSources[0]).To(BeAssignableToTypeOf(DirectoryReleaseSource{}))
+				Expect(releaseSources[0].ID()).To(Equal("/mnt/releases"))
+			})
+
+			It("can upload releases and build remote paths", func() {
+				repo, err := NewReleaseSourceRepo(kilnfile, logger)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = repo.FindReleaseUploader("/mnt/releases")
+				Expect(err).NotTo(HaveOccurred())
+				_, err = repo.FindRemotePather("/mnt/releases")
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
+		Context("when the Kilnfile has an http release source", func() {
+			BeforeEach(func() {
+				kilnfile = cargo.Kilnfile{
+					ReleaseSources: []cargo.ReleaseSourceConfig{
+						{Type: "http", URL: "https://artifactory.example.com/bosh", PathTemplate: "template", Username: "some-user", Password: "some-password"},
+					},
+				}
+			})
+
+			It("uses the URL as the ID", func() {
+				repo, err := NewReleaseSourceRepo(kilnfile, logger)
+				Expect(err).NotTo(HaveOccurred())
+				releaseSources := repo.ReleaseSources
+
+				Expect(releaseSources).To(HaveLen(1))
+				Expect(releaseSources[0]).To(BeAssignableToTypeOf(HTTPReleaseSource{}))
+				Expect(releaseSources[0].ID()).To(Equal("https://artifactory.example.com/bosh"))
+
+				_, err = repo.FindReleaseUploader("https://artifactory.example.com/bosh")
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
 		Context("when there are duplicate release source identifiers", func() {
 			BeforeEach(func() {
 				kilnfile = cargo.Kilnfile{
@@ -109,16 +186,49 @@ var _ = Describe("ReleaseSourceRepo", func() {
 				}
 			})
 
-			It("panics with a helpful message", func() {
-				var r interface{}
-				func() {
-					defer func() {
-						r = recover()
-					}()
-					NewReleaseSourceRepo(kilnfile, logger)
-				}()
-				Expect(r).To(ContainSubstring("unique"))
-				Expect(r).To(ContainSubstring(`"some-bucket"`))
+			It("returns a helpful error", func() {
+				_, err := NewReleaseSourceRepo(kilnfile, logger)
+				Expect(err).To(MatchError(ContainSubstring("unique")))
+				Expect(err).To(MatchError(ContainSubstring(`"some-bucket"`)))
+
+				var duplicateErr DuplicateIDError
+				Expect(errors.As(err, &duplicateErr)).To(BeTrue())
+				Expect(duplicateErr).To(Equal(DuplicateIDError{ID: "some-bucket", FirstIndex: 0, NextIndex: 1}))
+			})
+		})
+
+		Context("when a release source has an unknown type", func() {
+			BeforeEach(func() {
+				kilnfile = cargo.Kilnfile{
+					ReleaseSources: []cargo.ReleaseSourceConfig{
+						{Type: "bosh.io"},
+						{Type: "s4", Bucket: "some-bucket"},
+					},
+				}
+			})
+
+			It("returns an error identifying the release source", func() {
+				_, err := NewReleaseSourceRepo(kilnfile, logger)
+				Expect(err).To(MatchError(`release_sources[1]: unknown release source type "s4"`))
+				Expect(errors.As(err, new(UnknownSourceTypeError))).To(BeTrue())
+			})
+		})
+
+		Context("when a release source is missing a required field", func() {
+			BeforeEach(func() {
+				kilnfile = cargo.Kilnfile{
+					ReleaseSources: []cargo.ReleaseSourceConfig{
+						{Type: "github"},
+					},
+				}
+			})
+
+			It("returns an error naming the field", func() {
+				_, err := NewReleaseSourceRepo(kilnfile, logger)
+
+				var missingErr MissingFieldError
+				Expect(errors.As(err, &missingErr)).To(BeTrue())
+				Expect(missingErr).To(Equal(MissingFieldError{SourceType: "github", Field: "org"}))
 			})
 		})
 	})
@@ -130,7 +240,9 @@ var _ = Describe("ReleaseSourceRepo", func() {
 		)
 
 		JustBeforeEach(func() {
-			repo = NewReleaseSourceRepo(kilnfile, logger)
+			var err error
+			repo, err = NewReleaseSourceRepo(kilnfile, logger)
+			Expect(err).NotTo(HaveOccurred())
 		})
 
 		Context("when allow-only-publishable-releases is false", func() {
@@ -204,7 +316,9 @@ var _ = Describe("ReleaseSourceRepo", func() {
 		)
 
 		JustBeforeEach(func() {
-			repo = NewReleaseSourceRepo(kilnfile, logger)
+			var err error
+			repo, err = NewReleaseSourceRepo(kilnfile, logger)
+			Expect(err).NotTo(HaveOccurred())
 		})
 
 		BeforeEach(func() {
@@ -272,7 +386,9 @@ var _ = Describe("ReleaseSourceRepo", func() {
 		)
 
 		JustBeforeEach(func() {
-			repo = NewReleaseSourceRepo(kilnfile, logger)
+			var err error
+			repo, err = NewReleaseSourceRepo(kilnfile, logger)
+			Expect(err).NotTo(HaveOccurred())
 		})
 
 		BeforeEach(func() {
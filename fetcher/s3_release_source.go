@@ -2,13 +2,11 @@ package fetcher
 
 import (
 	"bytes"
-	"crypto/sha1"
-	"encoding/hex"
 	"fmt"
 	"github.com/Masterminds/semver"
 	"io"
 	"log"
-	"os"
+	"net/http"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -44,17 +42,21 @@ type S3ReleaseSource struct {
 	id                 string
 	bucket             string
 	pathTemplateString string
+	pathTemplate       *template.Template
+	pathTemplateErr    error
 	publishable        bool
 
 	s3Client     S3Client
 	s3Downloader S3Downloader
 	s3Uploader   S3Uploader
 
+	signatures *ReleaseSignatures
+
 	logger *log.Logger
 }
 
 func NewS3ReleaseSource(id, bucket, pathTemplate string, publishable bool, client S3Client, downloader S3Downloader, uploader S3Uploader, logger *log.Logger) S3ReleaseSource {
-	return S3ReleaseSource{
+	src := S3ReleaseSource{
 		id:                 id,
 		bucket:             bucket,
 		pathTemplateString: pathTemplate,
@@ -64,25 +66,46 @@ func NewS3ReleaseSource(id, bucket, pathTemplate string, publishable bool, clien
 		s3Uploader:         uploader,
 		logger:             logger,
 	}
+	src.pathTemplate, src.pathTemplateErr = parsePathTemplate(pathTemplate)
+	return src
 }
 
-func S3ReleaseSourceFromConfig(config cargo.ReleaseSourceConfig, logger *log.Logger) S3ReleaseSource {
-	validateConfig(config)
+func S3ReleaseSourceFromConfig(config cargo.ReleaseSourceConfig, logger *log.Logger) (S3ReleaseSource, error) {
+	err := validateConfig(config)
+	if err != nil {
+		return S3ReleaseSource{}, err
+	}
 
 	// https://docs.aws.amazon.com/sdk-for-go/api/service/s3/
-	awsConfig := &aws.Config{
-		Region:      aws.String(config.Region),
-		Credentials: credentials.NewStaticCredentials(config.AccessKeyId, config.SecretAccessKey, ""),
+	// Without keys in the Kilnfile the session uses the AWS default credential
+	// chain: environment variables, shared config files and the instance role.
+	awsConfig := &aws.Config{}
+	if config.Region != "" {
+		awsConfig = awsConfig.WithRegion(config.Region)
+	}
+	if config.AccessKeyId != "" {
+		awsConfig = awsConfig.WithCredentials(credentials.NewStaticCredentials(config.AccessKeyId, config.SecretAccessKey, ""))
 	}
 	if config.Endpoint != "" { // for acceptance testing
 		awsConfig = awsConfig.WithEndpoint(config.Endpoint)
 		awsConfig = awsConfig.WithS3ForcePathStyle(true)
 	}
 
-	sess := session.Must(session.NewSession(awsConfig))
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            *awsConfig,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return S3ReleaseSource{}, fmt.Errorf("failed to create an AWS session: %w", err)
+	}
 	client := s3.New(sess)
 
-	return NewS3ReleaseSource(
+	signatures, err := signaturesFromConfig(config)
+	if err != nil {
+		return S3ReleaseSource{}, err
+	}
+
+	src := NewS3ReleaseSource(
 		config.ID,
 		config.Bucket,
 		config.PathTemplate,
@@ -92,15 +115,40 @@ func S3ReleaseSourceFromConfig(config cargo.ReleaseSourceConfig, logger *log.Log
 		s3manager.NewUploaderWithClient(client),
 		logger,
 	)
+	if src.pathTemplateErr != nil {
+		return S3ReleaseSource{}, PathTemplateError{SourceID: config.ID, Err: src.pathTemplateErr}
+	}
+
+	return src.WithSignatures(signatures), nil
+}
+
+// WithSignatures makes DownloadRelease refuse releases without a valid
+// signature.
+func (src S3ReleaseSource) WithSignatures(signatures *ReleaseSignatures) S3ReleaseSource {
+	src.signatures = signatures
+	return src
+}
+
+// VerifiesSignatures reports whether DownloadRelease refuses releases without
+// a valid signature.
+func (src S3ReleaseSource) VerifiesSignatures() bool {
+	return src.signatures != nil
 }
 
-func validateConfig(config cargo.ReleaseSourceConfig) {
+func validateConfig(config cargo.ReleaseSourceConfig) error {
 	if config.PathTemplate == "" {
-		panic(`Missing required field "path_template" in release source config. Is your Kilnfile out of date?`)
+		return MissingFieldError{SourceType: ReleaseSourceTypeS3, Field: "path_template"}
 	}
 	if config.Bucket == "" {
-		panic(`Missing required field "bucket" in release source config. Is your Kilnfile out of date?`)
+		return MissingFieldError{SourceType: ReleaseSourceTypeS3, Field: "bucket"}
 	}
+	if config.AccessKeyId != "" && config.SecretAccessKey == "" {
+		return MissingFieldError{SourceType: ReleaseSourceTypeS3, Field: "secret_access_key"}
+	}
+	if config.SecretAccessKey != "" && config.AccessKeyId == "" {
+		return MissingFieldError{SourceType: ReleaseSourceTypeS3, Field: "access_key_id"}
+	}
+	return nil
 }
 
 func (src S3ReleaseSource) ID() string {
@@ -233,34 +281,49 @@ func (src S3ReleaseSource) DownloadRelease(releaseDir string, remoteRelease rele
 
 	outputFile := filepath.Join(releaseDir, filepath.Base(remoteRelease.RemotePath))
 
-	file, err := os.Create(outputFile)
+	download, err := openPartialDownload(outputFile)
 	if err != nil {
-		return release.Local{}, fmt.Errorf("failed to create file %q: %w", outputFile, err)
+		return release.Local{}, err
 	}
-	defer file.Close()
+	defer download.Close()
 
-	_, err = src.s3Downloader.Download(file, &s3.GetObjectInput{
+	input := &s3.GetObjectInput{
 		Bucket: aws.String(src.bucket),
 		Key:    aws.String(remoteRelease.RemotePath),
-	}, setConcurrency)
-	if err != nil {
-		return release.Local{}, fmt.Errorf("failed to download file: %w\n", err)
+	}
+	if download.Offset() > 0 {
+		src.logger.Printf("resuming download of %s %s after %d bytes", remoteRelease.Name, remoteRelease.Version, download.Offset())
+		input.SetRange(fmt.Sprintf("bytes=%d-", download.Offset()))
 	}
 
-	_, err = file.Seek(0, 0)
+	_, err = src.s3Downloader.Download(download, input, setConcurrency)
 	if err != nil {
-		return release.Local{}, fmt.Errorf("error reseting file cursor: %w", err) // untested
+		requestFailure, ok := err.(s3.RequestFailure)
+		if !ok || requestFailure.StatusCode() != http.StatusRequestedRangeNotSatisfiable {
+			return release.Local{}, fmt.Errorf("failed to download file: %w\n", err)
+		}
+		// the previous attempt already wrote the whole object
 	}
 
-	hash := sha1.New()
-	_, err = io.Copy(hash, file)
+	sha1, sha256, err := download.Complete()
 	if err != nil {
-		return release.Local{}, fmt.Errorf("error hashing file contents: %w", err) // untested
+		return release.Local{}, err
 	}
 
-	sha1 := hex.EncodeToString(hash.Sum(nil))
+	local := release.Local{ID: remoteRelease.ID, LocalPath: outputFile, SHA1: sha1, SHA256: sha256}
+	if src.signatures != nil {
+		signature := aws.NewWriteAtBuffer(nil)
+		_, err = src.s3Downloader.Download(signature, &s3.GetObjectInput{
+			Bucket: aws.String(src.bucket),
+			Key:    aws.String(src.signatures.signaturePath(remoteRelease.RemotePath)),
+		})
+		err = src.signatures.verifyDownload(local, signature.Bytes(), err)
+		if err != nil {
+			return release.Local{}, err
+		}
+	}
 
-	return release.Local{ID: remoteRelease.ID, LocalPath: outputFile, SHA1: sha1}, nil
+	return local, nil
 }
 
 func (src S3ReleaseSource) UploadRelease(spec release.Requirement, file io.Reader) (release.Remote, error) {
@@ -287,10 +350,26 @@ func (src S3ReleaseSource) UploadRelease(spec release.Requirement, file io.Reade
 	}, nil
 }
 
+func (src S3ReleaseSource) UploadSignature(remote release.Remote, signature []byte) error {
+	_, err := src.s3Uploader.Upload(&s3manager.UploadInput{
+		Bucket: aws.String(src.bucket),
+		Key:    aws.String(src.signatures.signaturePath(remote.RemotePath)),
+		Body:   bytes.NewReader(signature),
+	})
+	return err
+}
+
 func (src S3ReleaseSource) RemotePath(requirement release.Requirement) (string, error) {
+	if src.pathTemplateErr != nil {
+		return "", PathTemplateError{SourceID: src.id, Err: src.pathTemplateErr}
+	}
+	return evaluatePathTemplate(src.pathTemplate, requirement)
+}
+
+func evaluatePathTemplate(pathTemplate *template.Template, requirement release.Requirement) (string, error) {
 	pathBuf := new(bytes.Buffer)
 
-	err := src.pathTemplate().Execute(pathBuf, requirement)
+	err := pathTemplate.Execute(pathBuf, requirement)
 	if err != nil {
 		return "", fmt.Errorf("unable to evaluate path_template: %w", err)
 	}
@@ -298,9 +377,8 @@ func (src S3ReleaseSource) RemotePath(requirement release.Requirement) (string,
 	return pathBuf.String(), nil
 }
 
-func (src S3ReleaseSource) pathTemplate() *template.Template {
-	return template.Must(
-		template.New("remote-path").
-			Funcs(template.FuncMap{"trimSuffix": strings.TrimSuffix}).
-			Parse(src.pathTemplateString))
+func parsePathTemplate(pathTemplate string) (*template.Template, error) {
+	return template.New("remote-path").
+		Funcs(template.FuncMap{"trimSuffix": strings.TrimSuffix}).
+		Parse(pathTemplate)
 }
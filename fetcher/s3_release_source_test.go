@@ -8,15 +8,18 @@ import (
 	"io"
 	"io/ioutil"
 	"log"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
 
 	. "github.com/onsi/gomega/gstruct"
+	"github.com/onsi/gomega/ghttp"
 	"gopkg.in/src-d/go-billy.v4/osfs"
 
 	"github.com/pivotal-cf/kiln/release"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 
 	"github.com/aws/aws-sdk-go/service/s3"
@@ -63,15 +66,9 @@ var _ = Describe("S3ReleaseSource", func() {
 		DescribeTable("bad config", func(before func(sourceConfig *cargo.ReleaseSourceConfig), expectedSubstring string) {
 			before(config)
 
-			var r interface{}
-			func() {
-				defer func() {
-					r = recover()
-				}()
-				S3ReleaseSourceFromConfig(*config, logger)
-			}()
-
-			Expect(r).To(ContainSubstring(expectedSubstring))
+			_, err := S3ReleaseSourceFromConfig(*config, logger)
+			Expect(err).To(MatchError(ContainSubstring(expectedSubstring)))
+			Expect(errors.As(err, new(MissingFieldError))).To(BeTrue())
 		},
 			Entry("path_template is missing",
 				func(c *cargo.ReleaseSourceConfig) { c.PathTemplate = "" },
@@ -82,7 +79,60 @@ var _ = Describe("S3ReleaseSource", func() {
 				func(c *cargo.ReleaseSourceConfig) { c.Bucket = "" },
 				"bucket",
 			),
+
+			Entry("secret_access_key is missing",
+				func(c *cargo.ReleaseSourceConfig) { c.SecretAccessKey = "" },
+				"secret_access_key",
+			),
+
+			Entry("access_key_id is missing",
+				func(c *cargo.ReleaseSourceConfig) { c.AccessKeyId = "" },
+				"access_key_id",
+			),
 		)
+
+		It("returns an error for a path_template it can't parse", func() {
+			config.ID = sourceID
+			config.PathTemplate = "{{.Name"
+
+			_, err := S3ReleaseSourceFromConfig(*config, logger)
+			Expect(err).To(MatchError(ContainSubstring(`release source "s3-source" has an invalid path_template`)))
+			Expect(errors.As(err, new(PathTemplateError))).To(BeTrue())
+		})
+
+		When("the keys are not in the Kilnfile", func() {
+			BeforeEach(func() {
+				config.AccessKeyId = ""
+				config.SecretAccessKey = ""
+				Expect(os.Setenv("AWS_ACCESS_KEY_ID", "env-access-key")).To(Succeed())
+				Expect(os.Setenv("AWS_SECRET_ACCESS_KEY", "env-secret")).To(Succeed())
+			})
+
+			AfterEach(func() {
+				Expect(os.Unsetenv("AWS_ACCESS_KEY_ID")).To(Succeed())
+				Expect(os.Unsetenv("AWS_SECRET_ACCESS_KEY")).To(Succeed())
+			})
+
+			It("uses the AWS default credential chain", func() {
+				server := ghttp.NewServer()
+				defer server.Close()
+				server.RouteToHandler("HEAD", "/my-bucket/my-path-template", ghttp.CombineHandlers(
+					func(w http.ResponseWriter, r *http.Request) {
+						Expect(r.Header.Get("Authorization")).To(ContainSubstring("Credential=env-access-key/"))
+					},
+					ghttp.RespondWith(http.StatusOK, nil),
+				))
+				config.Endpoint = server.URL()
+
+				releaseSource, err := S3ReleaseSourceFromConfig(*config, logger)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, found, err := releaseSource.GetMatchedRelease(release.Requirement{Name: "uaa", Version: "1.2.3"})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(found).To(BeTrue())
+				Expect(server.ReceivedRequests()).To(HaveLen(1))
+			})
+		})
 	})
 
 	Describe("DownloadReleases", func() {
@@ -134,13 +184,13 @@ var _ = Describe("S3ReleaseSource", func() {
 			Expect(err).NotTo(HaveOccurred())
 			Expect(releaseContents).To(Equal([]byte("some-bucket/" + remoteRelease.RemotePath)))
 
-			sha1, err := CalculateSum(releasePath, osfs.New(""))
+			sha1, sha256, err := CalculateSums(releasePath, osfs.New(""))
 			Expect(err).NotTo(HaveOccurred())
 
 			_, _, opts := fakeS3Downloader.DownloadArgsForCall(0)
 			verifySetsConcurrency(opts, 7)
 
-			Expect(localRelease).To(Equal(release.Local{ID: releaseID, LocalPath: releasePath, SHA1: sha1}))
+			Expect(localRelease).To(Equal(release.Local{ID: releaseID, LocalPath: releasePath, SHA1: sha1, SHA256: sha256}))
 		})
 
 		Context("when number of threads is not specified", func() {
@@ -175,6 +225,91 @@ var _ = Describe("S3ReleaseSource", func() {
 					Expect(err).To(HaveOccurred())
 					Expect(err).To(MatchError("failed to download file: 503 Service Unavailable\n"))
 				})
+
+				It("does not leave a release tarball in the release dir", func() {
+					_, _ = releaseSource.DownloadRelease(releaseDir, remoteRelease, 0)
+					Expect(filepath.Join(releaseDir, expectedLocalFilename)).NotTo(BeAnExistingFile())
+				})
+			})
+		})
+
+		Context("when a previous download was interrupted", func() {
+			const previouslyDownloaded = "some-bucket/"
+
+			BeforeEach(func() {
+				partialPath := filepath.Join(releaseDir, expectedLocalFilename+".partial")
+				Expect(ioutil.WriteFile(partialPath, []byte(previouslyDownloaded), 0644)).To(Succeed())
+
+				fakeS3Downloader.DownloadStub = func(writer io.WriterAt, objectInput *s3.GetObjectInput, setConcurrency ...func(dl *s3manager.Downloader)) (int64, error) {
+					n, err := writer.WriteAt([]byte(*objectInput.Key), 0)
+					return int64(n), err
+				}
+			})
+
+			It("only requests the remaining bytes", func() {
+				_, err := releaseSource.DownloadRelease(releaseDir, remoteRelease, 0)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, input, _ := fakeS3Downloader.DownloadArgsForCall(0)
+				Expect(input.Range).To(Equal(aws.String(fmt.Sprintf("bytes=%d-", len(previouslyDownloaded)))))
+			})
+
+			It("appends the remaining bytes and moves the release into place", func() {
+				localRelease, err := releaseSource.DownloadRelease(releaseDir, remoteRelease, 0)
+				Expect(err).NotTo(HaveOccurred())
+
+				releasePath := filepath.Join(releaseDir, expectedLocalFilename)
+				releaseContents, err := ioutil.ReadFile(releasePath)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(releaseContents).To(Equal([]byte("some-bucket/" + remoteRelease.RemotePath)))
+				Expect(releasePath + ".partial").NotTo(BeAnExistingFile())
+
+				sha1, err := CalculateSum(releasePath, osfs.New(""))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(localRelease.SHA1).To(Equal(sha1))
+			})
+		})
+
+		Context("when a previous download wrote parts out of order before it was interrupted", func() {
+			var contents string
+
+			BeforeEach(func() {
+				contents = "some-bucket/" + remoteRelease.RemotePath
+
+				fakeS3Downloader.DownloadCalls(func(writer io.WriterAt, objectInput *s3.GetObjectInput, setConcurrency ...func(dl *s3manager.Downloader)) (int64, error) {
+					_, err := writer.WriteAt([]byte(contents[8:]), 8)
+					Expect(err).NotTo(HaveOccurred())
+					_, err = writer.WriteAt([]byte(contents[:4]), 0)
+					Expect(err).NotTo(HaveOccurred())
+					return 0, errors.New("connection reset")
+				})
+				_, err := releaseSource.DownloadRelease(releaseDir, remoteRelease, 0)
+				Expect(err).To(HaveOccurred())
+
+				fakeS3Downloader.DownloadCalls(func(writer io.WriterAt, objectInput *s3.GetObjectInput, setConcurrency ...func(dl *s3manager.Downloader)) (int64, error) {
+					var start int
+					_, err := fmt.Sscanf(aws.StringValue(objectInput.Range), "bytes=%d-", &start)
+					Expect(err).NotTo(HaveOccurred())
+					n, err := writer.WriteAt([]byte(contents[start:]), 0)
+					return int64(n), err
+				})
+			})
+
+			It("resumes after the last part written without a gap", func() {
+				localRelease, err := releaseSource.DownloadRelease(releaseDir, remoteRelease, 0)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, input, _ := fakeS3Downloader.DownloadArgsForCall(1)
+				Expect(input.Range).To(Equal(aws.String("bytes=4-")))
+
+				releasePath := filepath.Join(releaseDir, expectedLocalFilename)
+				Expect(ioutil.ReadFile(releasePath)).To(BeEquivalentTo(contents))
+				Expect(releasePath + ".partial").NotTo(BeAnExistingFile())
+				Expect(releasePath + ".partial.offset").NotTo(BeAnExistingFile())
+
+				sha1, err := CalculateSum(releasePath, osfs.New(""))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(localRelease.SHA1).To(Equal(sha1))
 			})
 		})
 	})
@@ -0,0 +1,140 @@
+package fetcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"gopkg.in/yaml.v2"
+)
+
+//go:generate counterfeiter -o ./fakes/stemcell_index.go --fake-name StemcellIndex . StemcellIndex
+type StemcellIndex interface {
+	StemcellVersions(os string) ([]string, error)
+}
+
+// BOSHIOStemcellIndex lists stemcell versions using the bosh.io stemcell API,
+// or a server with the same API.
+type BOSHIOStemcellIndex struct {
+	serverURI string
+
+	// Client allows you to inject an alternate client. When not set,
+	// http.DefaultClient is used.
+	Client *http.Client
+}
+
+func NewBOSHIOStemcellIndex(customServerURI string) BOSHIOStemcellIndex {
+	if customServerURI == "" {
+		customServerURI = "https://bosh.io"
+	}
+	return BOSHIOStemcellIndex{serverURI: strings.TrimSuffix(customServerURI, "/")}
+}
+
+// StemcellVersions returns the published versions of the stemcell for os.
+// Versions are the same for every IaaS, so the Google KVM stemcell is used.
+func (index BOSHIOStemcellIndex) StemcellVersions(os string) ([]string, error) {
+	client := index.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Get(fmt.Sprintf("%s/api/v1/stemcells/bosh-google-kvm-%s-go_agent", index.serverURI, os))
+	if err != nil {
+		return nil, fmt.Errorf("bosh.io API is down with error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode >= 300 {
+		return nil, (*ResponseStatusCodeError)(resp)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err // untested
+	}
+
+	var stemcells []struct {
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(body, &stemcells); err != nil {
+		return nil, fmt.Errorf("failed to parse bosh.io stemcells for %s: %w", os, err)
+	}
+
+	versions := make([]string, 0, len(stemcells))
+	for _, stemcell := range stemcells {
+		versions = append(versions, stemcell.Version)
+	}
+	return versions, nil
+}
+
+// FileStemcellIndex lists stemcell versions from a YAML file mapping each OS
+// to a list of versions. It stands in for bosh.io when it is not reachable.
+type FileStemcellIndex struct {
+	path string
+}
+
+func NewFileStemcellIndex(path string) FileStemcellIndex {
+	return FileStemcellIndex{path: path}
+}
+
+func (index FileStemcellIndex) StemcellVersions(os string) ([]string, error) {
+	contents, err := ioutil.ReadFile(index.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stemcell index: %w", err)
+	}
+
+	var versions map[string][]string
+	err = yaml.Unmarshal(contents, &versions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse stemcell index %s: %w", index.path, err)
+	}
+
+	return versions[os], nil
+}
+
+// NewStemcellIndex returns a BOSHIOStemcellIndex for an http(s) URL and a
+// FileStemcellIndex for anything else.
+func NewStemcellIndex(location string) StemcellIndex {
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		return NewBOSHIOStemcellIndex(location)
+	}
+	return NewFileStemcellIndex(location)
+}
+
+// LatestStemcellVersion returns the newest of versions matching constraint,
+// or false when none match. An empty constraint matches every version.
+func LatestStemcellVersion(versions []string, constraint string) (string, bool, error) {
+	if constraint == "" {
+		constraint = "*"
+	}
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid stemcell version constraint %q: %w", constraint, err)
+	}
+
+	var matches []*semver.Version
+	original := make(map[*semver.Version]string)
+	for _, version := range versions {
+		v, err := semver.NewVersion(version)
+		if err != nil {
+			continue
+		}
+		if c.Check(v) {
+			matches = append(matches, v)
+			original[v] = version
+		}
+	}
+	if len(matches) == 0 {
+		return "", false, nil
+	}
+
+	sort.Sort(semver.Collection(matches))
+	return original[matches[len(matches)-1]], true, nil
+}
@@ -0,0 +1,107 @@
+package fetcher_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+
+	. "github.com/pivotal-cf/kiln/fetcher"
+)
+
+var _ = Describe("BOSHIOStemcellIndex", func() {
+	var server *ghttp.Server
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("lists the versions of the stemcell for an OS", func() {
+		server.AppendHandlers(ghttp.CombineHandlers(
+			ghttp.VerifyRequest("GET", "/api/v1/stemcells/bosh-google-kvm-ubuntu-xenial-go_agent"),
+			ghttp.RespondWith(http.StatusOK, `[{"name": "bosh-google-kvm-ubuntu-xenial-go_agent", "version": "621.61"}, {"version": "621.55"}]`),
+		))
+
+		versions, err := NewStemcellIndex(server.URL()).StemcellVersions("ubuntu-xenial")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(versions).To(Equal([]string{"621.61", "621.55"}))
+	})
+
+	It("lists no versions for an unknown OS", func() {
+		server.AppendHandlers(ghttp.RespondWith(http.StatusNotFound, ""))
+
+		versions, err := NewBOSHIOStemcellIndex(server.URL()).StemcellVersions("plan9")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(versions).To(BeEmpty())
+	})
+
+	It("returns an error when bosh.io fails", func() {
+		server.AppendHandlers(ghttp.RespondWith(http.StatusInternalServerError, ""))
+
+		_, err := NewBOSHIOStemcellIndex(server.URL()).StemcellVersions("ubuntu-xenial")
+		Expect(err).To(MatchError(ContainSubstring("got status 500")))
+	})
+})
+
+var _ = Describe("FileStemcellIndex", func() {
+	var indexPath string
+
+	BeforeEach(func() {
+		dir, err := ioutil.TempDir("", "stemcell-index")
+		Expect(err).NotTo(HaveOccurred())
+		indexPath = filepath.Join(dir, "stemcells.yml")
+		Expect(ioutil.WriteFile(indexPath, []byte(`ubuntu-xenial: ["621.55", "621.61"]`), 0644)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(filepath.Dir(indexPath))).To(Succeed())
+	})
+
+	It("lists the versions of the stemcell for an OS", func() {
+		versions, err := NewStemcellIndex(indexPath).StemcellVersions("ubuntu-xenial")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(versions).To(Equal([]string{"621.55", "621.61"}))
+	})
+
+	It("returns an error when the file does not exist", func() {
+		_, err := NewFileStemcellIndex("missing.yml").StemcellVersions("ubuntu-xenial")
+		Expect(err).To(MatchError(ContainSubstring("failed to read stemcell index")))
+	})
+})
+
+var _ = Describe("LatestStemcellVersion", func() {
+	versions := []string{"621.55", "621.61", "621.9", "456.30", "1.18", "not-a-version"}
+
+	It("picks the newest version matching the constraint", func() {
+		version, found, err := LatestStemcellVersion(versions, "~621")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(found).To(BeTrue())
+		Expect(version).To(Equal("621.61"))
+	})
+
+	It("picks the newest version without a constraint", func() {
+		version, found, err := LatestStemcellVersion(versions, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(found).To(BeTrue())
+		Expect(version).To(Equal("621.61"))
+	})
+
+	It("finds nothing when no version matches", func() {
+		_, found, err := LatestStemcellVersion(versions, "~700")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(found).To(BeFalse())
+	})
+
+	It("returns an error for an invalid constraint", func() {
+		_, _, err := LatestStemcellVersion(versions, "~>>")
+		Expect(err).To(MatchError(ContainSubstring(`invalid stemcell version constraint "~>>"`)))
+	})
+})
@@ -6,6 +6,12 @@ import (
 )
 
 type Logger struct {
+	PrintfStub        func(string, ...interface{})
+	printfMutex       sync.RWMutex
+	printfArgsForCall []struct {
+		arg1 string
+		arg2 []interface{}
+	}
 	PrintlnStub        func(...interface{})
 	printlnMutex       sync.RWMutex
 	printlnArgsForCall []struct {
@@ -15,6 +21,38 @@ type Logger struct {
 	invocationsMutex sync.RWMutex
 }
 
+func (fake *Logger) Printf(arg1 string, arg2 ...interface{}) {
+	fake.printfMutex.Lock()
+	fake.printfArgsForCall = append(fake.printfArgsForCall, struct {
+		arg1 string
+		arg2 []interface{}
+	}{arg1, arg2})
+	fake.recordInvocation("Printf", []interface{}{arg1, arg2})
+	fake.printfMutex.Unlock()
+	if fake.PrintfStub != nil {
+		fake.PrintfStub(arg1, arg2...)
+	}
+}
+
+func (fake *Logger) PrintfCallCount() int {
+	fake.printfMutex.RLock()
+	defer fake.printfMutex.RUnlock()
+	return len(fake.printfArgsForCall)
+}
+
+func (fake *Logger) PrintfCalls(stub func(string, ...interface{})) {
+	fake.printfMutex.Lock()
+	defer fake.printfMutex.Unlock()
+	fake.PrintfStub = stub
+}
+
+func (fake *Logger) PrintfArgsForCall(i int) (string, []interface{}) {
+	fake.printfMutex.RLock()
+	defer fake.printfMutex.RUnlock()
+	argsForCall := fake.printfArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
 func (fake *Logger) Println(arg1 ...interface{}) {
 	fake.printlnMutex.Lock()
 	fake.printlnArgsForCall = append(fake.printlnArgsForCall, struct {
@@ -49,6 +87,8 @@ func (fake *Logger) PrintlnArgsForCall(i int) []interface{} {
 func (fake *Logger) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
+	fake.printfMutex.RLock()
+	defer fake.printfMutex.RUnlock()
 	fake.printlnMutex.RLock()
 	defer fake.printlnMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
@@ -4,6 +4,7 @@ import "github.com/pivotal-cf/kiln/builder"
 
 //go:generate counterfeiter -o ./fakes/logger.go --fake-name Logger . logger
 type logger interface {
+	Printf(format string, v ...interface{})
 	Println(v ...interface{})
 }
 
@@ -3,14 +3,11 @@ package baking
 import (
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"os"
-	"path"
 	"path/filepath"
 	"regexp"
 
 	"github.com/pivotal-cf/kiln/builder"
-	"gopkg.in/yaml.v2"
 )
 
 type StemcellService struct {
@@ -80,40 +77,3 @@ func (ss StemcellService) FromTarball(path string) (interface{}, error) {
 
 	return stemcell.Metadata, nil
 }
-
-func (ss StemcellService) FromKilnfile(kilnfilePath string) (map[string]interface{}, error) {
-	kilnfileLockPath := fmt.Sprintf("%s.lock", kilnfilePath)
-	kilnfileLockBasename := path.Base(kilnfileLockPath)
-	ss.logger.Println(fmt.Sprintf("Reading stemcell criteria from %s", kilnfileLockBasename))
-	kilnfileLock, err := os.Open(kilnfileLockPath)
-	if err != nil {
-		return nil, err
-	}
-
-	type stemcellMetadata struct {
-		Version         string `yaml:"version"`
-		OperatingSystem string `yaml:"os"`
-	}
-
-	stemcellCriteria := struct {
-		Metadata stemcellMetadata `yaml:"stemcell_criteria"`
-	}{}
-
-	lockFileContent, err := ioutil.ReadAll(kilnfileLock)
-	if err != nil {
-		return nil, err
-	}
-
-	err = yaml.Unmarshal(lockFileContent, &stemcellCriteria)
-	if err != nil {
-		return nil, err
-	}
-
-	stemcell := stemcellCriteria.Metadata
-
-	stemcellManifest := map[string]interface{}{
-		stemcell.OperatingSystem: stemcellCriteria.Metadata,
-	}
-
-	return stemcellManifest, err
-}
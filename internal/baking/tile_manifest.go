@@ -0,0 +1,468 @@
+package baking
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/pivotal-cf/kiln/builder"
+)
+
+const (
+	TileManifestFormatKiln      = "kiln"
+	TileManifestFormatCycloneDX = "cyclonedx"
+	TileManifestFormatSPDX      = "spdx"
+)
+
+// TileManifestFormats are the formats a tile manifest can be written in.
+var TileManifestFormats = []string{
+	TileManifestFormatKiln,
+	TileManifestFormatCycloneDX,
+	TileManifestFormatSPDX,
+}
+
+// tileManifestExtensions is appended to the tile path to name each manifest.
+var tileManifestExtensions = map[string]string{
+	TileManifestFormatKiln:      ".manifest.json",
+	TileManifestFormatCycloneDX: ".cdx.json",
+	TileManifestFormatSPDX:      ".spdx.json",
+}
+
+// TileManifest lists what went into a tile: its releases, the stemcells it
+// runs on and the files embedded in it.
+type TileManifest struct {
+	Name             string                 `json:"name"`
+	Version          string                 `json:"version"`
+	Releases         []TileManifestRelease  `json:"releases"`
+	StemcellCriteria []TileManifestStemcell `json:"stemcell_criteria"`
+	EmbeddedFiles    []TileManifestFile     `json:"embedded_files"`
+}
+
+type TileManifestRelease struct {
+	Name            string `json:"name"                       yaml:"name"`
+	Version         string `json:"version"                    yaml:"version"`
+	File            string `json:"file"                       yaml:"file"`
+	SHA1            string `json:"sha1"                       yaml:"sha1"`
+	StemcellOS      string `json:"stemcell_os,omitempty"      yaml:"-"`
+	StemcellVersion string `json:"stemcell_version,omitempty" yaml:"-"`
+}
+
+type TileManifestStemcell struct {
+	OS      string `json:"os"      yaml:"os"`
+	Version string `json:"version" yaml:"version"`
+}
+
+type TileManifestFile struct {
+	Path   string `json:"path"`
+	SHA1   string `json:"sha1"`
+	SHA256 string `json:"sha256"`
+}
+
+type TileManifestWriter struct {
+	logger logger
+}
+
+func NewTileManifestWriter(logger logger) TileManifestWriter {
+	return TileManifestWriter{logger: logger}
+}
+
+// Write writes a manifest next to writeInput.OutputFile for each format.
+func (w TileManifestWriter) Write(formats []string, generatedMetadataContents []byte, interpolateInput builder.InterpolateInput, writeInput builder.WriteInput) error {
+	manifest, err := NewTileManifest(generatedMetadataContents, interpolateInput, writeInput)
+	if err != nil {
+		return err
+	}
+
+	created := time.Now().UTC()
+	if writeInput.Reproducible {
+		created = writeInput.SourceDate.UTC()
+	}
+
+	for _, format := range formats {
+		var document interface{}
+		switch format {
+		case TileManifestFormatKiln:
+			document = manifest
+		case TileManifestFormatCycloneDX:
+			document = manifest.CycloneDX(created)
+		case TileManifestFormatSPDX:
+			document, err = manifest.SPDX(created)
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unknown tile manifest format %q; use one of %s", format, strings.Join(TileManifestFormats, ", "))
+		}
+
+		contents, err := json.MarshalIndent(document, "", "  ")
+		if err != nil {
+			return err // not tested
+		}
+
+		path := writeInput.OutputFile + tileManifestExtensions[format]
+		w.logger.Printf("Writing %s tile manifest to %s...", format, path)
+
+		err = ioutil.WriteFile(path, append(contents, '\n'), 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// NewTileManifest collects the manifest of a tile from the inputs used to bake
+// it. Release and stemcell manifests are read the way they are interpolated
+// into the metadata, so any value with the usual YAML keys works.
+func NewTileManifest(generatedMetadataContents []byte, interpolateInput builder.InterpolateInput, writeInput builder.WriteInput) (TileManifest, error) {
+	var metadata struct {
+		Name           string `yaml:"name"`
+		ProductVersion string `yaml:"product_version"`
+	}
+	err := yaml.Unmarshal(generatedMetadataContents, &metadata)
+	if err != nil {
+		return TileManifest{}, fmt.Errorf("failed to read tile name and version from metadata: %w", err)
+	}
+
+	manifest := TileManifest{
+		Name:             metadata.Name,
+		Version:          metadata.ProductVersion,
+		Releases:         []TileManifestRelease{},
+		StemcellCriteria: []TileManifestStemcell{},
+		EmbeddedFiles:    []TileManifestFile{},
+	}
+	if manifest.Version == "" {
+		manifest.Version = interpolateInput.Version
+	}
+
+	for _, releaseManifest := range interpolateInput.ReleaseManifests {
+		var rel TileManifestRelease
+		err := convertManifest(releaseManifest, &rel)
+		if err != nil {
+			return TileManifest{}, fmt.Errorf("failed to read release manifest: %w", err)
+		}
+		if m, ok := releaseManifest.(builder.ReleaseManifest); ok {
+			rel.StemcellOS, rel.StemcellVersion = m.StemcellOS, m.StemcellVersion
+		}
+		manifest.Releases = append(manifest.Releases, rel)
+	}
+	sort.Slice(manifest.Releases, func(i, j int) bool {
+		return manifest.Releases[i].Name < manifest.Releases[j].Name
+	})
+
+	stemcellManifests := make([]interface{}, 0, len(interpolateInput.StemcellManifests)+1)
+	for _, stemcellManifest := range interpolateInput.StemcellManifests {
+		stemcellManifests = append(stemcellManifests, stemcellManifest)
+	}
+	if interpolateInput.StemcellManifest != nil {
+		stemcellManifests = append(stemcellManifests, interpolateInput.StemcellManifest)
+	}
+	for _, stemcellManifest := range stemcellManifests {
+		var stemcell TileManifestStemcell
+		err := convertManifest(stemcellManifest, &stemcell)
+		if err != nil {
+			return TileManifest{}, fmt.Errorf("failed to read stemcell manifest: %w", err)
+		}
+		manifest.StemcellCriteria = append(manifest.StemcellCriteria, stemcell)
+	}
+	sort.Slice(manifest.StemcellCriteria, func(i, j int) bool {
+		return manifest.StemcellCriteria[i].OS < manifest.StemcellCriteria[j].OS
+	})
+
+	for _, embedPath := range writeInput.EmbedPaths {
+		files, err := embeddedFiles(embedPath)
+		if err != nil {
+			return TileManifest{}, fmt.Errorf("failed to read embedded files: %w", err)
+		}
+		manifest.EmbeddedFiles = append(manifest.EmbeddedFiles, files...)
+	}
+	sort.Slice(manifest.EmbeddedFiles, func(i, j int) bool {
+		return manifest.EmbeddedFiles[i].Path < manifest.EmbeddedFiles[j].Path
+	})
+
+	return manifest, nil
+}
+
+func convertManifest(in, out interface{}) error {
+	contents, err := yaml.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(contents, out)
+}
+
+// embeddedFiles hashes the files under embedPath, naming them by their path in
+// the tile as the tile writer does.
+func embeddedFiles(embedPath string) ([]TileManifestFile, error) {
+	var files []TileManifestFile
+	err := filepath.Walk(embedPath, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(embedPath, filePath)
+		if err != nil {
+			return err // not tested
+		}
+
+		file, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		sha1Hash, sha256Hash := sha1.New(), sha256.New()
+		_, err = io.Copy(io.MultiWriter(sha1Hash, sha256Hash), file)
+		if err != nil {
+			return err // not tested
+		}
+
+		files = append(files, TileManifestFile{
+			Path:   filepath.ToSlash(filepath.Join("embed", filepath.Base(embedPath), relativePath)),
+			SHA1:   fmt.Sprintf("%x", sha1Hash.Sum(nil)),
+			SHA256: fmt.Sprintf("%x", sha256Hash.Sum(nil)),
+		})
+		return nil
+	})
+	return files, err
+}
+
+type cycloneDXDocument struct {
+	BOMFormat   string               `json:"bomFormat"`
+	SpecVersion string               `json:"specVersion"`
+	Version     int                  `json:"version"`
+	Metadata    cycloneDXMetadata    `json:"metadata"`
+	Components  []cycloneDXComponent `json:"components"`
+}
+
+type cycloneDXMetadata struct {
+	Timestamp string             `json:"timestamp"`
+	Tools     []cycloneDXTool    `json:"tools"`
+	Component cycloneDXComponent `json:"component"`
+}
+
+type cycloneDXTool struct {
+	Name string `json:"name"`
+}
+
+type cycloneDXComponent struct {
+	Type       string              `json:"type"`
+	BOMRef     string              `json:"bom-ref"`
+	Name       string              `json:"name"`
+	Version    string              `json:"version,omitempty"`
+	Hashes     []cycloneDXHash     `json:"hashes,omitempty"`
+	Properties []cycloneDXProperty `json:"properties,omitempty"`
+}
+
+type cycloneDXHash struct {
+	Algorithm string `json:"alg"`
+	Content   string `json:"content"`
+}
+
+type cycloneDXProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// CycloneDX describes the tile as a CycloneDX 1.4 bill of materials.
+func (manifest TileManifest) CycloneDX(created time.Time) interface{} {
+	document := cycloneDXDocument{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: "1.4",
+		Version:     1,
+		Metadata: cycloneDXMetadata{
+			Timestamp: created.Format(time.RFC3339),
+			Tools:     []cycloneDXTool{{Name: "kiln"}},
+			Component: cycloneDXComponent{
+				Type:    "application",
+				BOMRef:  "tile/" + manifest.Name,
+				Name:    manifest.Name,
+				Version: manifest.Version,
+			},
+		},
+		Components: []cycloneDXComponent{},
+	}
+
+	for _, rel := range manifest.Releases {
+		component := cycloneDXComponent{
+			Type:    "library",
+			BOMRef:  "release/" + rel.Name,
+			Name:    rel.Name,
+			Version: rel.Version,
+			Hashes:  []cycloneDXHash{{Algorithm: "SHA-1", Content: rel.SHA1}},
+			Properties: []cycloneDXProperty{
+				{Name: "kiln:file", Value: rel.File},
+			},
+		}
+		if rel.StemcellOS != "" {
+			component.Properties = append(component.Properties,
+				cycloneDXProperty{Name: "kiln:stemcell_os", Value: rel.StemcellOS},
+				cycloneDXProperty{Name: "kiln:stemcell_version", Value: rel.StemcellVersion},
+			)
+		}
+		document.Components = append(document.Components, component)
+	}
+
+	for _, stemcell := range manifest.StemcellCriteria {
+		document.Components = append(document.Components, cycloneDXComponent{
+			Type:    "operating-system",
+			BOMRef:  "stemcell/" + stemcell.OS,
+			Name:    stemcell.OS,
+			Version: stemcell.Version,
+		})
+	}
+
+	for _, file := range manifest.EmbeddedFiles {
+		document.Components = append(document.Components, cycloneDXComponent{
+			Type:   "file",
+			BOMRef: "file/" + file.Path,
+			Name:   file.Path,
+			Hashes: []cycloneDXHash{
+				{Algorithm: "SHA-1", Content: file.SHA1},
+				{Algorithm: "SHA-256", Content: file.SHA256},
+			},
+		})
+	}
+
+	return document
+}
+
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Files             []spdxFile         `json:"files"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	SPDXID                string         `json:"SPDXID"`
+	Name                  string         `json:"name"`
+	VersionInfo           string         `json:"versionInfo,omitempty"`
+	PackageFileName       string         `json:"packageFileName,omitempty"`
+	DownloadLocation      string         `json:"downloadLocation"`
+	FilesAnalyzed         bool           `json:"filesAnalyzed"`
+	Checksums             []spdxChecksum `json:"checksums,omitempty"`
+	PrimaryPackagePurpose string         `json:"primaryPackagePurpose"`
+}
+
+type spdxFile struct {
+	SPDXID    string         `json:"SPDXID"`
+	FileName  string         `json:"fileName"`
+	Checksums []spdxChecksum `json:"checksums"`
+}
+
+type spdxChecksum struct {
+	Algorithm string `json:"algorithm"`
+	Value     string `json:"checksumValue"`
+}
+
+type spdxRelationship struct {
+	Element        string `json:"spdxElementId"`
+	Type           string `json:"relationshipType"`
+	RelatedElement string `json:"relatedSpdxElement"`
+}
+
+var spdxIDInvalidCharacters = regexp.MustCompile(`[^a-zA-Z0-9.-]+`)
+
+func spdxID(kind, name string) string {
+	return "SPDXRef-" + kind + "-" + spdxIDInvalidCharacters.ReplaceAllString(name, "-")
+}
+
+// SPDX describes the tile as an SPDX 2.3 document. The document namespace is
+// derived from the manifest, so the same tile always gets the same namespace.
+func (manifest TileManifest) SPDX(created time.Time) (interface{}, error) {
+	contents, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err // not tested
+	}
+
+	tileID := spdxID("Tile", manifest.Name)
+	document := spdxDocument{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              manifest.Name + "-" + manifest.Version,
+		DocumentNamespace: fmt.Sprintf("https://spdx.org/spdxdocs/%s-%s-%x", manifest.Name, manifest.Version, sha256.Sum256(contents)),
+		CreationInfo: spdxCreationInfo{
+			Created:  created.Format(time.RFC3339),
+			Creators: []string{"Tool: kiln"},
+		},
+		Packages: []spdxPackage{{
+			SPDXID:                tileID,
+			Name:                  manifest.Name,
+			VersionInfo:           manifest.Version,
+			DownloadLocation:      "NOASSERTION",
+			PrimaryPackagePurpose: "APPLICATION",
+		}},
+		Files: []spdxFile{},
+		Relationships: []spdxRelationship{
+			{Element: "SPDXRef-DOCUMENT", Type: "DESCRIBES", RelatedElement: tileID},
+		},
+	}
+
+	for _, rel := range manifest.Releases {
+		id := spdxID("Release", rel.Name)
+		document.Packages = append(document.Packages, spdxPackage{
+			SPDXID:                id,
+			Name:                  rel.Name,
+			VersionInfo:           rel.Version,
+			PackageFileName:       rel.File,
+			DownloadLocation:      "NOASSERTION",
+			Checksums:             []spdxChecksum{{Algorithm: "SHA1", Value: rel.SHA1}},
+			PrimaryPackagePurpose: "LIBRARY",
+		})
+		document.Relationships = append(document.Relationships, spdxRelationship{Element: tileID, Type: "CONTAINS", RelatedElement: id})
+	}
+
+	for _, stemcell := range manifest.StemcellCriteria {
+		id := spdxID("Stemcell", stemcell.OS)
+		document.Packages = append(document.Packages, spdxPackage{
+			SPDXID:                id,
+			Name:                  stemcell.OS,
+			VersionInfo:           stemcell.Version,
+			DownloadLocation:      "NOASSERTION",
+			PrimaryPackagePurpose: "OPERATING-SYSTEM",
+		})
+		document.Relationships = append(document.Relationships, spdxRelationship{Element: tileID, Type: "DEPENDS_ON", RelatedElement: id})
+	}
+
+	for index, file := range manifest.EmbeddedFiles {
+		id := spdxID("File", fmt.Sprintf("%d", index+1))
+		document.Files = append(document.Files, spdxFile{
+			SPDXID:   id,
+			FileName: "./" + file.Path,
+			Checksums: []spdxChecksum{
+				{Algorithm: "SHA1", Value: file.SHA1},
+				{Algorithm: "SHA256", Value: file.SHA256},
+			},
+		})
+		document.Relationships = append(document.Relationships, spdxRelationship{Element: tileID, Type: "CONTAINS", RelatedElement: id})
+	}
+
+	return document, nil
+}
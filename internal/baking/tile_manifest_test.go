@@ -0,0 +1,197 @@
+package baking_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pivotal-cf/kiln/builder"
+	. "github.com/pivotal-cf/kiln/internal/baking"
+	"github.com/pivotal-cf/kiln/internal/baking/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TileManifestWriter", func() {
+	var (
+		logger           *fakes.Logger
+		writer           TileManifestWriter
+		tmpdir           string
+		metadata         []byte
+		interpolateInput builder.InterpolateInput
+		writeInput       builder.WriteInput
+	)
+
+	BeforeEach(func() {
+		logger = &fakes.Logger{}
+		writer = NewTileManifestWriter(logger)
+
+		var err error
+		tmpdir, err = ioutil.TempDir("", "")
+		Expect(err).NotTo(HaveOccurred())
+
+		embedDirectory := filepath.Join(tmpdir, "scripts")
+		Expect(os.Mkdir(embedDirectory, 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(embedDirectory, "install.sh"), []byte("hello\n"), 0755)).To(Succeed())
+
+		metadata = []byte("name: cool-product\nproduct_version: 1.2.3\n")
+
+		interpolateInput = builder.InterpolateInput{
+			Version: "1.2.3",
+			ReleaseManifests: map[string]interface{}{
+				"diego": builder.ReleaseManifest{Name: "diego", Version: "0.1467.1", File: "diego-release-0.1467.1.tgz", SHA1: "diego-sha", StemcellOS: "ubuntu-xenial", StemcellVersion: "621.55"},
+				"cf":    builder.ReleaseManifest{Name: "cf", Version: "235", File: "cf-release-235.tgz", SHA1: "cf-sha"},
+			},
+			StemcellManifests: map[string]interface{}{
+				"ubuntu-xenial": builder.StemcellManifest{OperatingSystem: "ubuntu-xenial", Version: "621.55"},
+				"windows2019":   builder.StemcellManifest{OperatingSystem: "windows2019", Version: "2019.20"},
+			},
+		}
+
+		writeInput = builder.WriteInput{
+			OutputFile:   filepath.Join(tmpdir, "cool-product-1.2.3.pivotal"),
+			EmbedPaths:   []string{embedDirectory},
+			Reproducible: true,
+			SourceDate:   time.Date(2020, time.March, 4, 5, 6, 7, 0, time.UTC),
+		}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmpdir)).To(Succeed())
+	})
+
+	readJSON := func(path string) map[string]interface{} {
+		contents, err := ioutil.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+
+		var document map[string]interface{}
+		Expect(json.Unmarshal(contents, &document)).To(Succeed())
+		return document
+	}
+
+	It("writes a kiln manifest next to the tile", func() {
+		err := writer.Write([]string{"kiln"}, metadata, interpolateInput, writeInput)
+		Expect(err).NotTo(HaveOccurred())
+
+		contents, err := ioutil.ReadFile(writeInput.OutputFile + ".manifest.json")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(contents).To(MatchJSON(`{
+			"name": "cool-product",
+			"version": "1.2.3",
+			"releases": [
+				{"name": "cf", "version": "235", "file": "cf-release-235.tgz", "sha1": "cf-sha"},
+				{"name": "diego", "version": "0.1467.1", "file": "diego-release-0.1467.1.tgz", "sha1": "diego-sha", "stemcell_os": "ubuntu-xenial", "stemcell_version": "621.55"}
+			],
+			"stemcell_criteria": [
+				{"os": "ubuntu-xenial", "version": "621.55"},
+				{"os": "windows2019", "version": "2019.20"}
+			],
+			"embedded_files": [
+				{
+					"path": "embed/scripts/install.sh",
+					"sha1": "f572d396fae9206628714fb2ce00f72e94f2258f",
+					"sha256": "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"
+				}
+			]
+		}`))
+
+		Expect(logger.PrintfCallCount()).To(Equal(1))
+		format, v := logger.PrintfArgsForCall(0)
+		Expect(fmt.Sprintf(format, v...)).To(Equal("Writing kiln tile manifest to " + writeInput.OutputFile + ".manifest.json..."))
+	})
+
+	It("writes a CycloneDX bill of materials dated at the source date", func() {
+		err := writer.Write([]string{"cyclonedx"}, metadata, interpolateInput, writeInput)
+		Expect(err).NotTo(HaveOccurred())
+
+		document := readJSON(writeInput.OutputFile + ".cdx.json")
+		Expect(document).To(HaveKeyWithValue("bomFormat", "CycloneDX"))
+		Expect(document).To(HaveKeyWithValue("specVersion", "1.4"))
+		Expect(document["metadata"]).To(HaveKeyWithValue("timestamp", "2020-03-04T05:06:07Z"))
+		Expect(document["metadata"]).To(HaveKeyWithValue("component", HaveKeyWithValue("name", "cool-product")))
+
+		components := document["components"].([]interface{})
+		Expect(components).To(HaveLen(5))
+		Expect(components[0]).To(And(
+			HaveKeyWithValue("type", "library"),
+			HaveKeyWithValue("name", "cf"),
+			HaveKeyWithValue("version", "235"),
+			HaveKeyWithValue("hashes", ConsistOf(HaveKeyWithValue("content", "cf-sha"))),
+		))
+		Expect(components[2]).To(And(
+			HaveKeyWithValue("type", "operating-system"),
+			HaveKeyWithValue("name", "ubuntu-xenial"),
+			HaveKeyWithValue("version", "621.55"),
+		))
+		Expect(components[4]).To(And(
+			HaveKeyWithValue("type", "file"),
+			HaveKeyWithValue("name", "embed/scripts/install.sh"),
+		))
+	})
+
+	It("writes an SPDX document that is the same for the same tile", func() {
+		err := writer.Write([]string{"spdx"}, metadata, interpolateInput, writeInput)
+		Expect(err).NotTo(HaveOccurred())
+
+		document := readJSON(writeInput.OutputFile + ".spdx.json")
+		Expect(document).To(HaveKeyWithValue("spdxVersion", "SPDX-2.3"))
+		Expect(document).To(HaveKeyWithValue("name", "cool-product-1.2.3"))
+		Expect(document["creationInfo"]).To(HaveKeyWithValue("created", "2020-03-04T05:06:07Z"))
+		Expect(document["packages"]).To(HaveLen(5))
+		Expect(document["packages"]).To(ContainElement(And(
+			HaveKeyWithValue("SPDXID", "SPDXRef-Release-diego"),
+			HaveKeyWithValue("versionInfo", "0.1467.1"),
+			HaveKeyWithValue("packageFileName", "diego-release-0.1467.1.tgz"),
+		)))
+		Expect(document["files"]).To(ConsistOf(HaveKeyWithValue("fileName", "./embed/scripts/install.sh")))
+		Expect(document["relationships"]).To(ContainElement(map[string]interface{}{
+			"spdxElementId":      "SPDXRef-Tile-cool-product",
+			"relationshipType":   "DEPENDS_ON",
+			"relatedSpdxElement": "SPDXRef-Stemcell-windows2019",
+		}))
+
+		first, err := ioutil.ReadFile(writeInput.OutputFile + ".spdx.json")
+		Expect(err).NotTo(HaveOccurred())
+
+		err = writer.Write([]string{"spdx"}, metadata, interpolateInput, writeInput)
+		Expect(err).NotTo(HaveOccurred())
+
+		second, err := ioutil.ReadFile(writeInput.OutputFile + ".spdx.json")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(second).To(Equal(first))
+	})
+
+	It("reads the stemcell criteria from a deprecated stemcell tarball", func() {
+		interpolateInput.StemcellManifests = nil
+		interpolateInput.StemcellManifest = builder.StemcellManifest{OperatingSystem: "ubuntu-trusty", Version: "3586.40"}
+
+		manifest, err := NewTileManifest(metadata, interpolateInput, writeInput)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(manifest.StemcellCriteria).To(Equal([]TileManifestStemcell{{OS: "ubuntu-trusty", Version: "3586.40"}}))
+	})
+
+	Context("failure cases", func() {
+		It("returns an error for an unknown format", func() {
+			err := writer.Write([]string{"swid"}, metadata, interpolateInput, writeInput)
+			Expect(err).To(MatchError(`unknown tile manifest format "swid"; use one of kiln, cyclonedx, spdx`))
+		})
+
+		It("returns an error when an embedded path does not exist", func() {
+			writeInput.EmbedPaths = []string{filepath.Join(tmpdir, "missing")}
+
+			err := writer.Write([]string{"kiln"}, metadata, interpolateInput, writeInput)
+			Expect(err).To(MatchError(ContainSubstring("failed to read embedded files")))
+		})
+
+		It("returns an error when the manifest cannot be written", func() {
+			writeInput.OutputFile = filepath.Join(tmpdir, "missing", "cool-product-1.2.3.pivotal")
+
+			err := writer.Write([]string{"kiln"}, metadata, interpolateInput, writeInput)
+			Expect(err).To(MatchError(ContainSubstring("no such file or directory")))
+		})
+	})
+})
@@ -0,0 +1,129 @@
+package cargo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"gopkg.in/src-d/go-billy.v4"
+
+	"github.com/pivotal-cf/kiln/internal/redact"
+)
+
+const (
+	CredentialServerURLEnv   = "KILN_CREDENTIAL_SERVER_URL"
+	CredentialServerTokenEnv = "KILN_CREDENTIAL_SERVER_TOKEN"
+)
+
+// credentialProvider resolves the $(env ...), $(file ...) and
+// $(credential ...) functions in a Kilnfile.
+//
+// Credentials are fetched with a GET from the server named by
+// KILN_CREDENTIAL_SERVER_URL, sending KILN_CREDENTIAL_SERVER_TOKEN as a bearer
+// token. The key is looked up in the JSON response, in its "data" object as
+// Vault returns it, or in the first element of its "data" list as CredHub
+// returns it.
+type credentialProvider struct {
+	fs        billy.Filesystem
+	lookupEnv func(string) (string, bool)
+	client    *http.Client
+}
+
+func newCredentialProvider(fs billy.Filesystem) credentialProvider {
+	return credentialProvider{
+		fs:        fs,
+		lookupEnv: os.LookupEnv,
+		client:    http.DefaultClient,
+	}
+}
+
+func (provider credentialProvider) Env(name string) (string, error) {
+	val, ok := provider.lookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %q is not set", name)
+	}
+	redact.Add(val)
+	return val, nil
+}
+
+func (provider credentialProvider) File(path string) (string, error) {
+	f, err := provider.fs.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("couldn't read credential file: %w", err)
+	}
+	defer f.Close()
+
+	contents, err := ioutil.ReadAll(f)
+	if err != nil {
+		return "", fmt.Errorf("couldn't read credential file: %w", err) // untested
+	}
+	val := strings.TrimRight(string(contents), "\r\n")
+	redact.Add(val)
+	return val, nil
+}
+
+func (provider credentialProvider) Credential(path, key string) (string, error) {
+	serverURL, ok := provider.lookupEnv(CredentialServerURLEnv)
+	if !ok || serverURL == "" {
+		return "", fmt.Errorf("%s must be set to look up credential %q", CredentialServerURLEnv, path)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(serverURL, "/")+"/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return "", fmt.Errorf("couldn't look up credential %q: %w", path, err)
+	}
+	if token, _ := provider.lookupEnv(CredentialServerTokenEnv); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := provider.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("couldn't look up credential %q: %w", path, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("couldn't look up credential %q: the credential server responded with %s", path, res.Status)
+	}
+
+	var body interface{}
+	err = json.NewDecoder(res.Body).Decode(&body)
+	if err != nil {
+		return "", fmt.Errorf("couldn't parse credential %q: %w", path, err)
+	}
+
+	val, found := findCredentialKey(body, key)
+	if !found {
+		return "", fmt.Errorf("credential %q has no key %q", path, key)
+	}
+	if s, ok := val.(string); ok {
+		redact.Add(s)
+		return s, nil
+	}
+	encoded, err := json.Marshal(val)
+	if err != nil {
+		return "", fmt.Errorf("couldn't parse credential %q: %w", path, err) // untestable
+	}
+	redact.Add(variableValues(val)...)
+	return string(encoded), nil
+}
+
+func findCredentialKey(body interface{}, key string) (interface{}, bool) {
+	switch body := body.(type) {
+	case map[string]interface{}:
+		if val, ok := body[key]; ok {
+			return val, true
+		}
+		if data, ok := body["data"]; ok {
+			return findCredentialKey(data, key)
+		}
+	case []interface{}:
+		if len(body) > 0 {
+			return findCredentialKey(body[0], key)
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,116 @@
+package cargo_test
+
+import (
+	"net/http"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+
+	. "github.com/pivotal-cf/kiln/internal/cargo"
+)
+
+var _ = Describe("Kilnfile credentials", func() {
+	const kilnfileLockContents = `
+releases: []
+stemcell_criteria:
+  os: some-os
+  version: "4.5.6"
+`
+
+	var (
+		filesystem billy.Filesystem
+		server     *ghttp.Server
+	)
+
+	BeforeEach(func() {
+		filesystem = memfs.New()
+		Expect(writeFile(filesystem, "Kilnfile.lock", kilnfileLockContents)).To(Succeed())
+
+		server = ghttp.NewServer()
+		Expect(os.Setenv(CredentialServerURLEnv, server.URL()+"/v1/")).To(Succeed())
+		Expect(os.Setenv(CredentialServerTokenEnv, "some-token")).To(Succeed())
+	})
+
+	AfterEach(func() {
+		server.Close()
+		Expect(os.Unsetenv(CredentialServerURLEnv)).To(Succeed())
+		Expect(os.Unsetenv(CredentialServerTokenEnv)).To(Succeed())
+		Expect(os.Unsetenv("KILN_TEST_ACCESS_KEY")).To(Succeed())
+	})
+
+	loadSource := func(fields string) (ReleaseSourceConfig, error) {
+		Expect(writeFile(filesystem, "Kilnfile", "release_sources:\n- type: s3\n  bucket: b\n  path_template: p\n"+fields)).To(Succeed())
+		kilnfile, _, err := KilnfileLoader{}.LoadKilnfiles(filesystem, "Kilnfile", nil, nil)
+		if err != nil {
+			return ReleaseSourceConfig{}, err
+		}
+		return kilnfile.ReleaseSources[0], nil
+	}
+
+	It("reads environment variables", func() {
+		Expect(os.Setenv("KILN_TEST_ACCESS_KEY", "some-access-key")).To(Succeed())
+
+		source, err := loadSource(`  access_key_id: $(env "KILN_TEST_ACCESS_KEY")` + "\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(source.AccessKeyId).To(Equal("some-access-key"))
+	})
+
+	It("errors when an environment variable is not set", func() {
+		_, err := loadSource(`  access_key_id: $(env "KILN_TEST_ACCESS_KEY")` + "\n")
+		Expect(err).To(MatchError(ContainSubstring(`environment variable "KILN_TEST_ACCESS_KEY" is not set`)))
+	})
+
+	It("reads files without their trailing newline", func() {
+		Expect(writeFile(filesystem, "/run/secrets/s3-secret", "some: secret\n")).To(Succeed())
+
+		source, err := loadSource(`  secret_access_key: $(file "/run/secrets/s3-secret")` + "\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(source.SecretAccessKey).To(Equal("some: secret"))
+	})
+
+	It("looks up credentials on the credential server", func() {
+		server.AppendHandlers(
+			ghttp.CombineHandlers(
+				ghttp.VerifyRequest("GET", "/v1/secret/s3"),
+				ghttp.VerifyHeaderKV("Authorization", "Bearer some-token"),
+				ghttp.RespondWith(http.StatusOK, `{"data": {"data": {"access_key_id": "vault-key", "secret_access_key": "vault-secret"}}}`),
+			),
+			ghttp.CombineHandlers(
+				ghttp.VerifyRequest("GET", "/v1/api/v1/data", "name=/s3-secret"),
+				ghttp.RespondWith(http.StatusOK, `{"data": [{"type": "value", "value": "credhub-secret"}]}`),
+			),
+		)
+
+		source, err := loadSource(`  access_key_id: $(credential "secret/s3" "access_key_id")
+  secret_access_key: $(credential "api/v1/data?name=/s3-secret")
+`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(source.AccessKeyId).To(Equal("vault-key"))
+		Expect(source.SecretAccessKey).To(Equal("credhub-secret"))
+	})
+
+	It("errors when the credential server does not have the credential", func() {
+		server.AppendHandlers(ghttp.RespondWith(http.StatusNotFound, ""))
+
+		_, err := loadSource(`  access_key_id: $(credential "secret/s3" "access_key_id")` + "\n")
+		Expect(err).To(MatchError(ContainSubstring(`couldn't look up credential "secret/s3": the credential server responded with 404 Not Found`)))
+	})
+
+	It("errors when the credential has no such key", func() {
+		server.AppendHandlers(ghttp.RespondWith(http.StatusOK, `{"data": {"password": "p"}}`))
+
+		_, err := loadSource(`  access_key_id: $(credential "secret/s3" "access_key_id")` + "\n")
+		Expect(err).To(MatchError(ContainSubstring(`credential "secret/s3" has no key "access_key_id"`)))
+	})
+
+	It("errors when no credential server is configured", func() {
+		Expect(os.Unsetenv(CredentialServerURLEnv)).To(Succeed())
+
+		_, err := loadSource(`  access_key_id: $(credential "secret/s3")` + "\n")
+		Expect(err).To(MatchError(ContainSubstring(CredentialServerURLEnv + ` must be set to look up credential "secret/s3"`)))
+	})
+})
@@ -0,0 +1,132 @@
+package cargo
+
+import "sort"
+
+// Kinds of change found when comparing two Kilnfile.lock files.
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeBumped  = "bumped"
+	ChangeChanged = "changed"
+)
+
+// ReleaseChange is a release that was added, removed, bumped to another
+// version or changed with the same version, either rebuilt with a different
+// SHA1 or moved to another remote.
+type ReleaseChange struct {
+	Name            string `json:"name"`
+	Change          string `json:"change"`
+	OldVersion      string `json:"old_version,omitempty"`
+	NewVersion      string `json:"new_version,omitempty"`
+	OldSHA1         string `json:"old_sha1,omitempty"`
+	NewSHA1         string `json:"new_sha1,omitempty"`
+	OldRemoteSource string `json:"old_remote_source,omitempty"`
+	NewRemoteSource string `json:"new_remote_source,omitempty"`
+	OldRemotePath   string `json:"old_remote_path,omitempty"`
+	NewRemotePath   string `json:"new_remote_path,omitempty"`
+}
+
+// Moved reports whether the release is found in another remote after the
+// change.
+func (change ReleaseChange) Moved() bool {
+	if change.Change == ChangeAdded || change.Change == ChangeRemoved {
+		return false
+	}
+	return change.OldRemoteSource != change.NewRemoteSource || change.OldRemotePath != change.NewRemotePath
+}
+
+// StemcellChange is a stemcell operating system that was added, removed or
+// bumped to another version.
+type StemcellChange struct {
+	OS         string `json:"os"`
+	Change     string `json:"change"`
+	OldVersion string `json:"old_version,omitempty"`
+	NewVersion string `json:"new_version,omitempty"`
+}
+
+type KilnfileLockDiff struct {
+	Releases  []ReleaseChange  `json:"releases"`
+	Stemcells []StemcellChange `json:"stemcells"`
+}
+
+// DiffKilnfileLocks lists the changes between two Kilnfile.lock files, sorted
+// by release name and stemcell OS.
+func DiffKilnfileLocks(from, to KilnfileLock) KilnfileLockDiff {
+	diff := KilnfileLockDiff{
+		Releases:  []ReleaseChange{},
+		Stemcells: []StemcellChange{},
+	}
+
+	oldReleases := make(map[string]ReleaseLock)
+	for _, rel := range from.Releases {
+		oldReleases[rel.Name] = rel
+	}
+	newReleases := make(map[string]ReleaseLock)
+	for _, rel := range to.Releases {
+		newReleases[rel.Name] = rel
+	}
+
+	for name, oldRelease := range oldReleases {
+		newRelease, found := newReleases[name]
+		change := ReleaseChange{
+			Name:            name,
+			OldVersion:      oldRelease.Version,
+			OldSHA1:         oldRelease.SHA1,
+			OldRemoteSource: oldRelease.RemoteSource,
+			OldRemotePath:   oldRelease.RemotePath,
+			NewVersion:      newRelease.Version,
+			NewSHA1:         newRelease.SHA1,
+			NewRemoteSource: newRelease.RemoteSource,
+			NewRemotePath:   newRelease.RemotePath,
+		}
+		switch {
+		case !found:
+			change.Change = ChangeRemoved
+		case oldRelease.Version != newRelease.Version:
+			change.Change = ChangeBumped
+		case oldRelease.SHA1 != newRelease.SHA1,
+			oldRelease.RemoteSource != newRelease.RemoteSource,
+			oldRelease.RemotePath != newRelease.RemotePath:
+			change.Change = ChangeChanged
+		default:
+			continue
+		}
+		diff.Releases = append(diff.Releases, change)
+	}
+	for name, newRelease := range newReleases {
+		if _, found := oldReleases[name]; found {
+			continue
+		}
+		diff.Releases = append(diff.Releases, ReleaseChange{
+			Name:            name,
+			Change:          ChangeAdded,
+			NewVersion:      newRelease.Version,
+			NewSHA1:         newRelease.SHA1,
+			NewRemoteSource: newRelease.RemoteSource,
+			NewRemotePath:   newRelease.RemotePath,
+		})
+	}
+	sort.Slice(diff.Releases, func(i, j int) bool {
+		return diff.Releases[i].Name < diff.Releases[j].Name
+	})
+
+	for _, oldStemcell := range from.Stemcells {
+		if oldStemcell.OS == "" {
+			continue
+		}
+		newStemcell, found := to.Stemcells.Find(oldStemcell.OS)
+		switch {
+		case !found:
+			diff.Stemcells = append(diff.Stemcells, StemcellChange{OS: oldStemcell.OS, Change: ChangeRemoved, OldVersion: oldStemcell.Version})
+		case oldStemcell.Version != newStemcell.Version:
+			diff.Stemcells = append(diff.Stemcells, StemcellChange{OS: oldStemcell.OS, Change: ChangeBumped, OldVersion: oldStemcell.Version, NewVersion: newStemcell.Version})
+		}
+	}
+	for _, newStemcell := range to.Stemcells {
+		if _, found := from.Stemcells.Find(newStemcell.OS); newStemcell.OS != "" && !found {
+			diff.Stemcells = append(diff.Stemcells, StemcellChange{OS: newStemcell.OS, Change: ChangeAdded, NewVersion: newStemcell.Version})
+		}
+	}
+
+	return diff
+}
@@ -0,0 +1,99 @@
+package cargo_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/pivotal-cf/kiln/internal/cargo"
+)
+
+var _ = Describe("DiffKilnfileLocks", func() {
+	var from, to KilnfileLock
+
+	BeforeEach(func() {
+		from = KilnfileLock{
+			Releases: []ReleaseLock{
+				{Name: "uaa", Version: "73.3.0", SHA1: "uaa-sha"},
+				{Name: "bpm", Version: "1.1.6", SHA1: "bpm-sha"},
+				{Name: "routing", Version: "0.198.0", SHA1: "routing-sha"},
+				{Name: "nats", Version: "34", SHA1: "nats-sha"},
+			},
+			Stemcells: StemcellCriteria{{OS: "ubuntu-xenial", Version: "621.55"}},
+		}
+		to = KilnfileLock{
+			Releases: []ReleaseLock{
+				{Name: "uaa", Version: "74.0.0", SHA1: "new-uaa-sha"},
+				{Name: "bpm", Version: "1.1.6", SHA1: "bpm-sha"},
+				{Name: "routing", Version: "0.198.0", SHA1: "compiled-routing-sha"},
+				{Name: "silk", Version: "2.28.0", SHA1: "silk-sha"},
+			},
+			Stemcells: StemcellCriteria{{OS: "ubuntu-xenial", Version: "621.61"}},
+		}
+	})
+
+	It("lists added, removed, bumped and rebuilt releases by name", func() {
+		diff := DiffKilnfileLocks(from, to)
+
+		Expect(diff.Releases).To(Equal([]ReleaseChange{
+			{Name: "nats", Change: ChangeRemoved, OldVersion: "34", OldSHA1: "nats-sha"},
+			{Name: "routing", Change: ChangeChanged, OldVersion: "0.198.0", NewVersion: "0.198.0", OldSHA1: "routing-sha", NewSHA1: "compiled-routing-sha"},
+			{Name: "silk", Change: ChangeAdded, NewVersion: "2.28.0", NewSHA1: "silk-sha"},
+			{Name: "uaa", Change: ChangeBumped, OldVersion: "73.3.0", NewVersion: "74.0.0", OldSHA1: "uaa-sha", NewSHA1: "new-uaa-sha"},
+		}))
+	})
+
+	It("lists a release that moved to another remote as changed", func() {
+		from.Releases = []ReleaseLock{{Name: "bpm", Version: "1.1.6", SHA1: "bpm-sha", RemoteSource: "bosh.io", RemotePath: "bpm-path"}}
+		to.Releases = []ReleaseLock{{Name: "bpm", Version: "1.1.6", SHA1: "bpm-sha", RemoteSource: "compiled", RemotePath: "compiled-bpm-path"}}
+
+		diff := DiffKilnfileLocks(from, to)
+
+		Expect(diff.Releases).To(Equal([]ReleaseChange{
+			{
+				Name: "bpm", Change: ChangeChanged,
+				OldVersion: "1.1.6", NewVersion: "1.1.6",
+				OldSHA1: "bpm-sha", NewSHA1: "bpm-sha",
+				OldRemoteSource: "bosh.io", NewRemoteSource: "compiled",
+				OldRemotePath: "bpm-path", NewRemotePath: "compiled-bpm-path",
+			},
+		}))
+		Expect(diff.Releases[0].Moved()).To(BeTrue())
+	})
+
+	It("lists a stemcell version bump", func() {
+		diff := DiffKilnfileLocks(from, to)
+
+		Expect(diff.Stemcells).To(Equal([]StemcellChange{
+			{OS: "ubuntu-xenial", Change: ChangeBumped, OldVersion: "621.55", NewVersion: "621.61"},
+		}))
+	})
+
+	It("lists a change of stemcell OS as a removal and an addition", func() {
+		to.Stemcells = StemcellCriteria{{OS: "ubuntu-jammy", Version: "1.18"}}
+
+		diff := DiffKilnfileLocks(from, to)
+
+		Expect(diff.Stemcells).To(Equal([]StemcellChange{
+			{OS: "ubuntu-xenial", Change: ChangeRemoved, OldVersion: "621.55"},
+			{OS: "ubuntu-jammy", Change: ChangeAdded, NewVersion: "1.18"},
+		}))
+	})
+
+	It("compares stemcells by OS when there are several", func() {
+		from.Stemcells = append(from.Stemcells, Stemcell{OS: "ubuntu-jammy", Version: "1.18"})
+		to.Stemcells = StemcellCriteria{{OS: "ubuntu-jammy", Version: "1.20"}, {OS: "ubuntu-xenial", Version: "621.55"}}
+
+		diff := DiffKilnfileLocks(from, to)
+
+		Expect(diff.Stemcells).To(Equal([]StemcellChange{
+			{OS: "ubuntu-jammy", Change: ChangeBumped, OldVersion: "1.18", NewVersion: "1.20"},
+		}))
+	})
+
+	It("finds no changes between identical locks", func() {
+		diff := DiffKilnfileLocks(from, from)
+
+		Expect(diff.Releases).To(BeEmpty())
+		Expect(diff.Stemcells).To(BeEmpty())
+	})
+})
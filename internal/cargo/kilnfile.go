@@ -1,8 +1,89 @@
 package cargo
 
+import (
+	"fmt"
+	"net/url"
+)
+
 type KilnfileLock struct {
-	Releases []ReleaseLock `yaml:"releases"`
-	Stemcell Stemcell      `yaml:"stemcell_criteria"`
+	Releases  []ReleaseLock    `yaml:"releases"`
+	Stemcells StemcellCriteria `yaml:"stemcell_criteria"`
+}
+
+// ReleaseStemcell returns the stemcell the named release is compiled against:
+// the one matching its stemcell_os or, when it does not set one, the default.
+func (lock KilnfileLock) ReleaseStemcell(releaseName string) (Stemcell, error) {
+	for _, rel := range lock.Releases {
+		if rel.Name != releaseName || rel.StemcellOS == "" {
+			continue
+		}
+		stemcell, found := lock.Stemcells.Find(rel.StemcellOS)
+		if !found {
+			return Stemcell{}, fmt.Errorf("release %q has stemcell_os %q, which is not in stemcell_criteria", rel.Name, rel.StemcellOS)
+		}
+		return stemcell, nil
+	}
+	return lock.Stemcells.Default(), nil
+}
+
+// StemcellCriteria are the stemcells a tile is built with, one per OS. The
+// first is the default for releases that do not set a stemcell_os. A single
+// stemcell is written as a mapping, so Kilnfile.lock files with one OS look
+// the same as they always have.
+type StemcellCriteria []Stemcell
+
+// Default returns the first stemcell, or an empty one when there are none.
+func (criteria StemcellCriteria) Default() Stemcell {
+	if len(criteria) == 0 {
+		return Stemcell{}
+	}
+	return criteria[0]
+}
+
+func (criteria StemcellCriteria) Find(os string) (Stemcell, bool) {
+	for _, stemcell := range criteria {
+		if stemcell.OS == os {
+			return stemcell, true
+		}
+	}
+	return Stemcell{}, false
+}
+
+// Set replaces the stemcell with the same OS, or adds stemcell when there is
+// none.
+func (criteria *StemcellCriteria) Set(stemcell Stemcell) {
+	for i := range *criteria {
+		if (*criteria)[i].OS == stemcell.OS {
+			(*criteria)[i] = stemcell
+			return
+		}
+	}
+	*criteria = append(*criteria, stemcell)
+}
+
+func (criteria StemcellCriteria) MarshalYAML() (interface{}, error) {
+	if len(criteria) <= 1 {
+		return criteria.Default(), nil
+	}
+	return []Stemcell(criteria), nil
+}
+
+func (criteria *StemcellCriteria) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var stemcells []Stemcell
+	if err := unmarshal(&stemcells); err == nil {
+		*criteria = stemcells
+		return nil
+	}
+
+	var stemcell Stemcell
+	if err := unmarshal(&stemcell); err != nil {
+		return err
+	}
+	*criteria = nil
+	if stemcell != (Stemcell{}) {
+		*criteria = StemcellCriteria{stemcell}
+	}
+	return nil
 }
 
 type ReleaseKiln struct {
@@ -15,6 +96,24 @@ type Kilnfile struct {
 	Slug            string                `yaml:"slug"`
 	PreGaUserGroups []string              `yaml:"pre_ga_user_groups"`
 	Releases        []ReleaseKiln         `yaml:"releases"`
+	Bake            BakeConfig            `yaml:"bake"`
+}
+
+// BakeConfig holds defaults for the kiln bake flags of the same names.
+type BakeConfig struct {
+	Metadata                  string   `yaml:"metadata"`
+	OutputFile                string   `yaml:"output_file"`
+	Icon                      string   `yaml:"icon"`
+	ReleasesDirectories       []string `yaml:"releases_directories"`
+	BOSHVariablesDirectories  []string `yaml:"bosh_variables_directories"`
+	FormsDirectories          []string `yaml:"forms_directories"`
+	InstanceGroupsDirectories []string `yaml:"instance_groups_directories"`
+	JobsDirectories           []string `yaml:"jobs_directories"`
+	PropertiesDirectories     []string `yaml:"properties_directories"`
+	RuntimeConfigsDirectories []string `yaml:"runtime_configs_directories"`
+	MigrationsDirectories     []string `yaml:"migrations_directories"`
+	Embed                     []string `yaml:"embed"`
+	VariablesFiles            []string `yaml:"variables_files"`
 }
 
 type ReleaseSourceConfig struct {
@@ -27,12 +126,53 @@ type ReleaseSourceConfig struct {
 	SecretAccessKey string `yaml:"secret_access_key"`
 	PathTemplate    string `yaml:"path_template"`
 	Endpoint        string `yaml:"endpoint"`
+	Org             string `yaml:"org"`
+	GithubToken     string `yaml:"github_token"`
+	Path            string `yaml:"path"`
+	URL             string `yaml:"url"`
+	Username        string `yaml:"username"`
+	Password        string `yaml:"password"`
+	BearerToken     string `yaml:"bearer_token"`
+
+	Verification *VerificationConfig `yaml:"verification,omitempty"`
+}
+
+// Secrets are the credentials of the release source, including any embedded
+// in its url, which kiln masks in its output.
+func (config ReleaseSourceConfig) Secrets() []string {
+	secrets := []string{config.AccessKeyId, config.SecretAccessKey, config.GithubToken, config.Password, config.BearerToken}
+	if u, err := url.Parse(config.URL); err == nil && u.User != nil {
+		password, _ := u.User.Password()
+		secrets = append(secrets, password, u.User.String())
+	}
+	return secrets
+}
+
+// VerificationConfig makes a release source check the detached signature
+// stored next to each release tarball it downloads.
+type VerificationConfig struct {
+	Type            string `yaml:"type"`
+	PublicKey       string `yaml:"public_key"`
+	SignatureSuffix string `yaml:"signature_suffix,omitempty"`
 }
 
 type ReleaseLock struct {
 	Name         string `yaml:"name"`
 	SHA1         string `yaml:"sha1"`
+	SHA256       string `yaml:"sha256,omitempty"`
 	Version      string `yaml:"version"`
 	RemoteSource string `yaml:"remote_source"`
 	RemotePath   string `yaml:"remote_path"`
+	StemcellOS   string `yaml:"stemcell_os,omitempty"`
+}
+
+// SetChecksums records the checksums of the release tarball. An empty sha256
+// means it was not calculated, so the recorded SHA256 is kept when the SHA1 is
+// unchanged and cleared otherwise.
+func (lock *ReleaseLock) SetChecksums(sha1, sha256 string) {
+	if sha256 == "" && sha1 == lock.SHA1 {
+		sha256 = lock.SHA256
+	}
+	lock.SHA1 = sha1
+	lock.SHA256 = sha256
 }
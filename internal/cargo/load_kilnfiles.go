@@ -3,9 +3,14 @@ package cargo
 import (
 	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
 
 	"github.com/pivotal-cf/kiln/builder"
 	"github.com/pivotal-cf/kiln/internal/baking"
+	"github.com/pivotal-cf/kiln/internal/redact"
 	"gopkg.in/src-d/go-billy.v4"
 	"gopkg.in/yaml.v2"
 )
@@ -20,7 +25,7 @@ func (err ConfigFileError) Unwrap() error {
 }
 
 func (err ConfigFileError) Error() string {
-	return fmt.Sprintf("encountered a configuration file error with %s: %s", err.HumanReadableConfigFileName, err.err.Error())
+	return redact.String(fmt.Sprintf("encountered a configuration file error with %s: %s", err.HumanReadableConfigFileName, err.err.Error()))
 }
 
 type KilnfileLoader struct {
@@ -30,8 +35,9 @@ func (k KilnfileLoader) LoadKilnfiles(fs billy.Filesystem, kilnfilePath string,
 	templateVariablesService := baking.NewTemplateVariablesService(fs)
 	templateVariables, err := templateVariablesService.FromPathsAndPairs(variablesFiles, variables)
 	if err != nil {
-		return Kilnfile{}, KilnfileLock{}, fmt.Errorf("error processing --variable or --variables-file arguments - are you logged into lpass? (error: %w)", err)
+		return Kilnfile{}, KilnfileLock{}, fmt.Errorf("error processing --variable or --variables-file arguments: %w", err)
 	}
+	redact.Add(variableValues(templateVariables)...)
 
 	kf, err := fs.Open(kilnfilePath)
 	if err != nil {
@@ -45,7 +51,8 @@ func (k KilnfileLoader) LoadKilnfiles(fs billy.Filesystem, kilnfilePath string,
 
 	interpolator := builder.NewInterpolator()
 	interpolatedMetadata, err := interpolator.Interpolate(builder.InterpolateInput{
-		Variables: templateVariables,
+		Variables:   templateVariables,
+		Credentials: newCredentialProvider(fs),
 	}, kilnfileYAML)
 	if err != nil {
 		return Kilnfile{}, KilnfileLock{}, ConfigFileError{err: err, HumanReadableConfigFileName: "interpolating variable files with Kilnfile"}
@@ -57,6 +64,15 @@ func (k KilnfileLoader) LoadKilnfiles(fs billy.Filesystem, kilnfilePath string,
 		return Kilnfile{}, KilnfileLock{}, ConfigFileError{err: err, HumanReadableConfigFileName: "Kilnfile specification " + kilnfilePath}
 	}
 
+	for _, source := range kilnfile.ReleaseSources {
+		redact.Add(source.Secrets()...)
+	}
+
+	err = ValidateKilnfile(kilnfileYAML, kilnfile)
+	if err != nil {
+		return Kilnfile{}, KilnfileLock{}, ConfigFileError{err: err, HumanReadableConfigFileName: "Kilnfile specification " + kilnfilePath}
+	}
+
 	lockFileName := kilnfileLockPath(kilnfilePath)
 	lockFile, err := fs.Open(lockFileName)
 	if err != nil {
@@ -64,31 +80,205 @@ func (k KilnfileLoader) LoadKilnfiles(fs billy.Filesystem, kilnfilePath string,
 	}
 	defer lockFile.Close()
 
+	kilnfileLockYAML, err := ioutil.ReadAll(lockFile)
+	if err != nil {
+		return Kilnfile{}, KilnfileLock{}, fmt.Errorf("unable to read file %q: %w", lockFileName, err)
+	}
+
 	var kilnfileLock KilnfileLock
-	err = yaml.NewDecoder(lockFile).Decode(&kilnfileLock)
+	err = yaml.Unmarshal(kilnfileLockYAML, &kilnfileLock)
+	if err != nil {
+		return Kilnfile{}, KilnfileLock{}, ConfigFileError{err: err, HumanReadableConfigFileName: "Kilnfile.lock " + lockFileName}
+	}
+
+	err = ValidateKilnfileLock(kilnfileLockYAML, kilnfileLock)
 	if err != nil {
 		return Kilnfile{}, KilnfileLock{}, ConfigFileError{err: err, HumanReadableConfigFileName: "Kilnfile.lock " + lockFileName}
 	}
 	return kilnfile, kilnfileLock, nil
 }
 
+// variableValues lists the strings in the values of --variable and
+// --variables-file so they can be masked in kiln's output.
+func variableValues(value interface{}) []string {
+	switch value := value.(type) {
+	case string:
+		return []string{value}
+	case map[string]interface{}:
+		var values []string
+		for _, v := range value {
+			values = append(values, variableValues(v)...)
+		}
+		return values
+	case map[interface{}]interface{}:
+		var values []string
+		for _, v := range value {
+			values = append(values, variableValues(v)...)
+		}
+		return values
+	case []interface{}:
+		var values []string
+		for _, v := range value {
+			values = append(values, variableValues(v)...)
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// LoadBakeConfig reads the bake section of a Kilnfile without interpolating
+// it. Relative paths are resolved against the directory containing the
+// Kilnfile so bake can be run from anywhere.
+func (KilnfileLoader) LoadBakeConfig(fs billy.Filesystem, kilnfilePath string) (BakeConfig, error) {
+	kf, err := fs.Open(kilnfilePath)
+	if err != nil {
+		return BakeConfig{}, fmt.Errorf("unable to open file %q: %w", kilnfilePath, err)
+	}
+	defer kf.Close()
+	kilnfileYAML, err := ioutil.ReadAll(kf)
+	if err != nil {
+		return BakeConfig{}, fmt.Errorf("unable to read file %q: %w", kilnfilePath, err)
+	}
+
+	var kilnfile Kilnfile
+	err = yaml.Unmarshal(kilnfileYAML, &kilnfile)
+	if err != nil {
+		return BakeConfig{}, ConfigFileError{err: err, HumanReadableConfigFileName: "Kilnfile specification " + kilnfilePath}
+	}
+
+	root, _ := parseNode(kilnfileYAML)
+	errs := checkKeys(nil, root, reflect.TypeOf(kilnfile))
+	if len(errs) > 0 {
+		return BakeConfig{}, ConfigFileError{err: errs, HumanReadableConfigFileName: "Kilnfile specification " + kilnfilePath}
+	}
+
+	config := kilnfile.Bake
+	dir := filepath.Dir(kilnfilePath)
+	resolve := func(p string) string {
+		if p == "" || filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+	resolveAll := func(paths []string) []string {
+		for i, p := range paths {
+			paths[i] = resolve(p)
+		}
+		return paths
+	}
+
+	config.Metadata = resolve(config.Metadata)
+	config.OutputFile = resolve(config.OutputFile)
+	config.Icon = resolve(config.Icon)
+	config.ReleasesDirectories = resolveAll(config.ReleasesDirectories)
+	config.BOSHVariablesDirectories = resolveAll(config.BOSHVariablesDirectories)
+	config.FormsDirectories = resolveAll(config.FormsDirectories)
+	config.InstanceGroupsDirectories = resolveAll(config.InstanceGroupsDirectories)
+	config.JobsDirectories = resolveAll(config.JobsDirectories)
+	config.PropertiesDirectories = resolveAll(config.PropertiesDirectories)
+	config.RuntimeConfigsDirectories = resolveAll(config.RuntimeConfigsDirectories)
+	config.MigrationsDirectories = resolveAll(config.MigrationsDirectories)
+	config.Embed = resolveAll(config.Embed)
+	config.VariablesFiles = resolveAll(config.VariablesFiles)
+
+	return config, nil
+}
+
+// LoadStemcellCriteria reads the stemcell_criteria of the Kilnfile.lock
+// next to the Kilnfile, so bake renders the same stemcells the other
+// commands lock.
+func (KilnfileLoader) LoadStemcellCriteria(fs billy.Filesystem, kilnfilePath string) (StemcellCriteria, error) {
+	lockFileName := kilnfileLockPath(kilnfilePath)
+	lockFile, err := fs.Open(lockFileName)
+	if err != nil {
+		return nil, err
+	}
+	defer lockFile.Close()
+
+	kilnfileLockYAML, err := ioutil.ReadAll(lockFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read file %q: %w", lockFileName, err)
+	}
+
+	var kilnfileLock KilnfileLock
+	err = yaml.Unmarshal(kilnfileLockYAML, &kilnfileLock)
+	if err != nil {
+		return nil, ConfigFileError{err: err, HumanReadableConfigFileName: "Kilnfile.lock " + lockFileName}
+	}
+
+	return kilnfileLock.Stemcells, nil
+}
+
+// SaveKilnfileLock writes the Kilnfile.lock to a temporary file next to it
+// and renames it into place, so a failed write never leaves a truncated
+// lockfile. Comments and key order in the existing file are kept.
 func (KilnfileLoader) SaveKilnfileLock(fs billy.Filesystem, kilnfilePath string, updatedKilnfileLock KilnfileLock) error {
-	updatedLockFileYAML, err := yaml.Marshal(updatedKilnfileLock)
+	lockfilePath := kilnfileLockPath(kilnfilePath)
+
+	var existingLockFileYAML []byte
+	mode := os.FileMode(0644)
+	if info, err := fs.Stat(lockfilePath); err == nil {
+		mode = info.Mode().Perm()
+		existingLockFileYAML, err = readKilnfileLock(fs, lockfilePath)
+		if err != nil {
+			return err
+		}
+	}
+
+	updatedLockFileYAML, err := marshalKilnfileLock(existingLockFileYAML, updatedKilnfileLock)
 	if err != nil {
 		return fmt.Errorf("error marshaling the Kilnfile.lock: %w", err) // untestable
 	}
 
-	lockfilePath := kilnfileLockPath(kilnfilePath)
-	lockFile, err := fs.Create(lockfilePath) // overwrites the file
+	tempFilePath := fmt.Sprintf("%s.%d.tmp", lockfilePath, time.Now().UnixNano())
+	tempFile, err := fs.OpenFile(tempFilePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
 	if err != nil {
-		return fmt.Errorf("error reopening the Kilnfile.lock for writing: %w", err)
+		return fmt.Errorf("error creating a temporary file for the Kilnfile.lock: %w", err)
 	}
 
-	_, err = lockFile.Write(updatedLockFileYAML)
+	err = writeAndSync(tempFile, updatedLockFileYAML)
+	if closeErr := tempFile.Close(); err == nil && closeErr != nil {
+		err = closeErr // untested
+	}
 	if err != nil {
+		_ = fs.Remove(tempFilePath)
 		return fmt.Errorf("error writing to Kilnfile.lock: %w", err)
 	}
 
+	err = fs.Rename(tempFilePath, lockfilePath)
+	if err != nil {
+		_ = fs.Remove(tempFilePath)
+		return fmt.Errorf("error replacing the Kilnfile.lock: %w", err)
+	}
+
+	return nil
+}
+
+func readKilnfileLock(fs billy.Filesystem, lockfilePath string) ([]byte, error) {
+	lockFile, err := fs.Open(lockfilePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the Kilnfile.lock: %w", err) // untested
+	}
+	defer lockFile.Close()
+
+	contents, err := ioutil.ReadAll(lockFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the Kilnfile.lock: %w", err) // untested
+	}
+	return contents, nil
+}
+
+// writeAndSync writes contents to file and flushes it to disk when the file
+// supports it, as files from osfs do.
+func writeAndSync(file billy.File, contents []byte) error {
+	_, err := file.Write(contents)
+	if err != nil {
+		return err
+	}
+	if syncer, ok := file.(interface{ Sync() error }); ok {
+		return syncer.Sync()
+	}
 	return nil
 }
 
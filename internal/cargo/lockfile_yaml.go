@@ -0,0 +1,215 @@
+package cargo
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	yamlnode "gopkg.in/yaml.v3"
+)
+
+// marshalKilnfileLock encodes lock the way yaml.Marshal does, then merges it
+// into the YAML of the existing Kilnfile.lock so that its comments, key
+// order and quoting survive. Without an existing file, or when it cannot be
+// parsed, the plain encoding is returned.
+func marshalKilnfileLock(existing []byte, lock KilnfileLock) ([]byte, error) {
+	updated, err := yaml.Marshal(lock)
+	if err != nil {
+		return nil, err
+	}
+
+	var existingDocument yamlnode.Node
+	if len(bytes.TrimSpace(existing)) == 0 || yamlnode.Unmarshal(existing, &existingDocument) != nil || len(existingDocument.Content) == 0 {
+		return updated, nil
+	}
+
+	var updatedDocument yamlnode.Node
+	err = yamlnode.Unmarshal(updated, &updatedDocument)
+	if err != nil {
+		return nil, err // untestable
+	}
+
+	existingDocument.Content[0] = mergeYAMLNodes(existingDocument.Content[0], updatedDocument.Content[0])
+
+	var out bytes.Buffer
+	encoder := yamlnode.NewEncoder(&out)
+	encoder.SetIndent(2)
+	err = encoder.Encode(&existingDocument)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode Kilnfile.lock: %w", err) // untestable
+	}
+	_ = encoder.Close()
+
+	merged := out.Bytes()
+	if !hasIndentedSequences(existing) {
+		merged = compactSequenceIndentation(merged)
+	}
+	if bytes.HasPrefix(bytes.TrimLeft(existing, "\n"), []byte("---")) {
+		merged = append([]byte("---\n"), merged...)
+	}
+	return merged, nil
+}
+
+// mergeYAMLNodes returns updated, reusing the nodes of existing it matches so
+// their comments, key order and scalar styles are kept. Mapping keys missing
+// from updated are dropped and new keys are appended. Sequence items are
+// matched by their name or os, falling back to their position.
+func mergeYAMLNodes(existing, updated *yamlnode.Node) *yamlnode.Node {
+	if existing == nil || existing.Kind != updated.Kind {
+		copyYAMLComments(updated, existing)
+		return updated
+	}
+
+	switch updated.Kind {
+	case yamlnode.MappingNode:
+		updatedValues := make(map[string]*yamlnode.Node)
+		for i := 0; i+1 < len(updated.Content); i += 2 {
+			updatedValues[updated.Content[i].Value] = updated.Content[i+1]
+		}
+
+		var content []*yamlnode.Node
+		seen := make(map[string]bool)
+		for i := 0; i+1 < len(existing.Content); i += 2 {
+			key := existing.Content[i]
+			value, found := updatedValues[key.Value]
+			if !found {
+				continue
+			}
+			seen[key.Value] = true
+			content = append(content, key, mergeYAMLNodes(existing.Content[i+1], value))
+		}
+		for i := 0; i+1 < len(updated.Content); i += 2 {
+			if !seen[updated.Content[i].Value] {
+				content = append(content, updated.Content[i], updated.Content[i+1])
+			}
+		}
+		existing.Content = content
+		return existing
+
+	case yamlnode.SequenceNode:
+		existingItems := make(map[string]*yamlnode.Node)
+		for i, item := range existing.Content {
+			existingItems[sequenceItemKey(item, i)] = item
+		}
+
+		content := make([]*yamlnode.Node, 0, len(updated.Content))
+		for i, item := range updated.Content {
+			content = append(content, mergeYAMLNodes(existingItems[sequenceItemKey(item, i)], item))
+		}
+		existing.Content = content
+		return existing
+
+	case yamlnode.ScalarNode:
+		if existing.Value == updated.Value && existing.Tag == updated.Tag {
+			return existing
+		}
+		if updated.Tag == "!!str" && (existing.Style&(yamlnode.DoubleQuotedStyle|yamlnode.SingleQuotedStyle)) != 0 {
+			updated.Style = existing.Style
+		}
+		copyYAMLComments(updated, existing)
+		return updated
+	}
+
+	return updated
+}
+
+func copyYAMLComments(to, from *yamlnode.Node) {
+	if from == nil {
+		return
+	}
+	to.HeadComment = from.HeadComment
+	to.LineComment = from.LineComment
+	to.FootComment = from.FootComment
+}
+
+// sequenceItemKey identifies a release by its name and a stemcell by its os.
+func sequenceItemKey(item *yamlnode.Node, index int) string {
+	if item.Kind == yamlnode.MappingNode {
+		for _, field := range []string{"name", "os"} {
+			for i := 0; i+1 < len(item.Content); i += 2 {
+				if item.Content[i].Value == field {
+					return field + "=" + item.Content[i+1].Value
+				}
+			}
+		}
+	}
+	return fmt.Sprintf("index=%d", index)
+}
+
+// hasIndentedSequences reports whether the first sequence under a mapping key
+// is indented past the key, rather than written with its dashes under the
+// key as yaml.Marshal does.
+func hasIndentedSequences(in []byte) bool {
+	keyIndent := -1
+	for _, line := range strings.Split(string(in), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := len(line) - len(trimmed)
+		if keyIndent >= 0 && isSequenceItem(trimmed) {
+			return indent > keyIndent
+		}
+		keyIndent = -1
+		if strings.HasSuffix(withoutLineComment(trimmed), ":") {
+			keyIndent = indent
+		}
+	}
+	return false
+}
+
+// compactSequenceIndentation moves the dashes of sequences under mapping keys
+// back to the indentation of the key, which yaml.v3 cannot do itself.
+// Comment lines move with the line after them.
+func compactSequenceIndentation(in []byte) []byte {
+	var (
+		dashColumns []int
+		comments    []int
+		afterKey    bool
+	)
+	lines := strings.Split(string(in), "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "#") {
+			comments = append(comments, i)
+			continue
+		}
+		indent := len(line) - len(trimmed)
+
+		for len(dashColumns) > 0 {
+			top := dashColumns[len(dashColumns)-1]
+			if indent > top || (indent == top && isSequenceItem(trimmed)) {
+				break
+			}
+			dashColumns = dashColumns[:len(dashColumns)-1]
+		}
+		if afterKey && isSequenceItem(trimmed) {
+			dashColumns = append(dashColumns, indent)
+		}
+
+		by := 2 * len(dashColumns)
+		for _, comment := range append(comments, i) {
+			if strings.HasPrefix(lines[comment], strings.Repeat(" ", by)) {
+				lines[comment] = lines[comment][by:]
+			}
+		}
+		comments = nil
+		afterKey = strings.HasSuffix(withoutLineComment(trimmed), ":")
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+func isSequenceItem(trimmedLine string) bool {
+	return trimmedLine == "-" || strings.HasPrefix(trimmedLine, "- ")
+}
+
+func withoutLineComment(line string) string {
+	if i := strings.Index(line, " #"); i >= 0 {
+		return strings.TrimRight(line[:i], " ")
+	}
+	return line
+}
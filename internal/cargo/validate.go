@@ -0,0 +1,420 @@
+package cargo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	yamlnode "gopkg.in/yaml.v3"
+)
+
+// SchemaError is a mistake in a Kilnfile or Kilnfile.lock. Line and Column
+// point at the offending key or value when it could be found.
+type SchemaError struct {
+	Line, Column int
+	Message      string
+}
+
+func (err SchemaError) Error() string {
+	if err.Line == 0 {
+		return err.Message
+	}
+	return fmt.Sprintf("line %d, column %d: %s", err.Line, err.Column, err.Message)
+}
+
+// SchemaErrors are all the mistakes found in a single file.
+type SchemaErrors []SchemaError
+
+func (errs SchemaErrors) Error() string {
+	messages := make([]string, 0, len(errs))
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+// releaseSourceIDFields maps each release source type to its required fields.
+// The first field is used as the ID when a release source does not set one.
+var releaseSourceIDFields = map[string][]string{
+	"bosh.io":   nil,
+	"s3":        {"bucket", "path_template"},
+	"github":    {"org"},
+	"directory": {"path", "path_template"},
+	"http":      {"url", "path_template"},
+}
+
+// verificationTypes are the signature formats a release source can verify.
+var verificationTypes = []string{"cosign", "gpg"}
+
+// releaseSourcesWithSignatures are the release source types that can fetch
+// signatures stored next to their release tarballs.
+var releaseSourcesWithSignatures = []string{"s3", "directory", "http"}
+
+// ValidateKilnfile checks the Kilnfile for unknown keys, incomplete release
+// sources, and release sources sharing an ID. kilnfileYAML is the file before
+// interpolation, so positions match what the author wrote; kilnfile holds the
+// interpolated values.
+func ValidateKilnfile(kilnfileYAML []byte, kilnfile Kilnfile) error {
+	// Template expressions may leave the uninterpolated file unparsable; the
+	// checks below still work without positions.
+	root, _ := parseNode(kilnfileYAML)
+
+	errs := checkKeys(nil, root, reflect.TypeOf(kilnfile))
+
+	sourceNodes := sequenceItems(mappingValue(root, "release_sources"))
+	sourceForID := make(map[string]*yamlnode.Node)
+	for index, config := range kilnfile.ReleaseSources {
+		node := nodeAt(sourceNodes, index)
+
+		requiredFields, knownType := releaseSourceIDFields[config.Type]
+		if !knownType {
+			message := fmt.Sprintf("release source has unknown type %q", config.Type)
+			if config.Type == "" {
+				message = `release source is missing required field "type"`
+			}
+			typeNode := node
+			if valueNode := mappingValue(node, "type"); valueNode != nil {
+				typeNode = valueNode
+			}
+			errs = append(errs, schemaError(typeNode, message+suggestion(config.Type, releaseSourceTypes())))
+			continue
+		}
+
+		values := releaseSourceFieldValues(config)
+		for _, field := range requiredFields {
+			if values[field] == "" {
+				errs = append(errs, schemaError(node, fmt.Sprintf("%s release source is missing required field %q", config.Type, field)))
+			}
+		}
+
+		errs = checkVerification(errs, mappingValue(node, "verification"), node, config)
+
+		id := config.ID
+		if id == "" && len(requiredFields) > 0 {
+			id = values[requiredFields[0]]
+		}
+		if id == "" {
+			id = config.Type
+		}
+		if previous, seen := sourceForID[id]; seen {
+			message := fmt.Sprintf("release sources must have unique IDs; %q is already used", id)
+			if previous != nil {
+				message += fmt.Sprintf(" by the release source on line %d", previous.Line)
+			}
+			errs = append(errs, schemaError(node, message))
+			continue
+		}
+		sourceForID[id] = node
+	}
+
+	releaseNodes := sequenceItems(mappingValue(root, "releases"))
+	for index, rel := range kilnfile.Releases {
+		if rel.Name == "" {
+			errs = append(errs, schemaError(nodeAt(releaseNodes, index), `release is missing required field "name"`))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func checkVerification(errs SchemaErrors, node, sourceNode *yamlnode.Node, config ReleaseSourceConfig) SchemaErrors {
+	verification := config.Verification
+	if verification == nil {
+		return errs
+	}
+	if node == nil {
+		node = sourceNode
+	}
+
+	if !containsString(releaseSourcesWithSignatures, config.Type) {
+		return append(errs, schemaError(node, fmt.Sprintf("%s release sources do not support verification", config.Type)))
+	}
+	if !containsString(verificationTypes, verification.Type) {
+		message := fmt.Sprintf("verification has unknown type %q", verification.Type)
+		if verification.Type == "" {
+			message = `verification is missing required field "type"`
+		}
+		errs = append(errs, schemaError(node, message+suggestion(verification.Type, verificationTypes)))
+	}
+	if verification.PublicKey == "" {
+		errs = append(errs, schemaError(node, `verification is missing required field "public_key"`))
+	}
+	return errs
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateKilnfileLock checks the Kilnfile.lock for unknown keys, for
+// releases that are unnamed or locked more than once, and for stemcell OSes
+// that are listed more than once or used by a release without being listed.
+func ValidateKilnfileLock(kilnfileLockYAML []byte, kilnfileLock KilnfileLock) error {
+	root, err := parseNode(kilnfileLockYAML)
+	if err != nil {
+		return err
+	}
+
+	errs := checkKeys(nil, root, reflect.TypeOf(kilnfileLock))
+
+	releaseNodes := sequenceItems(mappingValue(root, "releases"))
+	releaseForName := make(map[string]*yamlnode.Node)
+	for index, rel := range kilnfileLock.Releases {
+		node := nodeAt(releaseNodes, index)
+		if rel.Name == "" {
+			errs = append(errs, schemaError(node, `release is missing required field "name"`))
+			continue
+		}
+		if previous, seen := releaseForName[rel.Name]; seen {
+			message := fmt.Sprintf("release %q is locked more than once", rel.Name)
+			if previous != nil {
+				message += fmt.Sprintf("; it is also locked on line %d", previous.Line)
+			}
+			errs = append(errs, schemaError(node, message))
+			continue
+		}
+		releaseForName[rel.Name] = node
+
+		if rel.SHA256 != "" && !isHexDigest(rel.SHA256, sha256.Size) {
+			sha256Node := node
+			if valueNode := mappingValue(node, "sha256"); valueNode != nil {
+				sha256Node = valueNode
+			}
+			errs = append(errs, schemaError(sha256Node, fmt.Sprintf("release %q has sha256 %q, which is not a hex encoded SHA256 checksum", rel.Name, rel.SHA256)))
+		}
+
+		if _, found := kilnfileLock.Stemcells.Find(rel.StemcellOS); rel.StemcellOS != "" && !found {
+			stemcellOSNode := node
+			if valueNode := mappingValue(node, "stemcell_os"); valueNode != nil {
+				stemcellOSNode = valueNode
+			}
+			errs = append(errs, schemaError(stemcellOSNode, fmt.Sprintf("release %q has stemcell_os %q, which is not in stemcell_criteria", rel.Name, rel.StemcellOS)))
+		}
+	}
+
+	stemcellNodes := sequenceItems(mappingValue(root, "stemcell_criteria"))
+	stemcellForOS := make(map[string]*yamlnode.Node)
+	for index, stemcell := range kilnfileLock.Stemcells {
+		node := nodeAt(stemcellNodes, index)
+		if previous, seen := stemcellForOS[stemcell.OS]; seen {
+			message := fmt.Sprintf("stemcell_criteria has more than one %q stemcell", stemcell.OS)
+			if previous != nil {
+				message += fmt.Sprintf("; it is also listed on line %d", previous.Line)
+			}
+			errs = append(errs, schemaError(node, message))
+			continue
+		}
+		stemcellForOS[stemcell.OS] = node
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func isHexDigest(s string, size int) bool {
+	decoded, err := hex.DecodeString(s)
+	return err == nil && len(decoded) == size
+}
+
+func parseNode(in []byte) (*yamlnode.Node, error) {
+	var document yamlnode.Node
+	err := yamlnode.Unmarshal(in, &document)
+	if err != nil {
+		return nil, err
+	}
+	if document.Kind == yamlnode.DocumentNode && len(document.Content) > 0 {
+		return document.Content[0], nil
+	}
+	return nil, nil
+}
+
+// checkKeys reports keys in node that do not match a yaml tag on the
+// corresponding field of t, recursing into nested structs and slices.
+func checkKeys(errs SchemaErrors, node *yamlnode.Node, t reflect.Type) SchemaErrors {
+	if node == nil {
+		return errs
+	}
+	if node.Kind == yamlnode.AliasNode {
+		node = node.Alias
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return checkKeys(errs, node, t.Elem())
+	case reflect.Slice, reflect.Array:
+		if node.Kind == yamlnode.MappingNode {
+			// a single item, like a stemcell_criteria mapping
+			return checkKeys(errs, node, t.Elem())
+		}
+		if node.Kind != yamlnode.SequenceNode {
+			return errs
+		}
+		for _, item := range node.Content {
+			errs = checkKeys(errs, item, t.Elem())
+		}
+	case reflect.Struct:
+		if node.Kind != yamlnode.MappingNode {
+			return errs
+		}
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Value == "<<" {
+				errs = checkKeys(errs, value, t)
+				continue
+			}
+			fieldType, known := fields[key.Value]
+			if !known {
+				names := make([]string, 0, len(fields))
+				for name := range fields {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				errs = append(errs, schemaError(key, fmt.Sprintf("unknown field %q%s", key.Value, suggestion(key.Value, names))))
+				continue
+			}
+			errs = checkKeys(errs, value, fieldType)
+		}
+	}
+
+	return errs
+}
+
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+func releaseSourceFieldValues(config ReleaseSourceConfig) map[string]string {
+	values := make(map[string]string)
+	v := reflect.ValueOf(config)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if v.Field(i).Kind() != reflect.String {
+			continue
+		}
+		name := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		values[name] = v.Field(i).String()
+	}
+	return values
+}
+
+func releaseSourceTypes() []string {
+	types := make([]string, 0, len(releaseSourceIDFields))
+	for sourceType := range releaseSourceIDFields {
+		types = append(types, sourceType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+func mappingValue(node *yamlnode.Node, key string) *yamlnode.Node {
+	if node == nil || node.Kind != yamlnode.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func sequenceItems(node *yamlnode.Node) []*yamlnode.Node {
+	if node == nil || node.Kind != yamlnode.SequenceNode {
+		return nil
+	}
+	return node.Content
+}
+
+func nodeAt(nodes []*yamlnode.Node, index int) *yamlnode.Node {
+	if index < len(nodes) {
+		return nodes[index]
+	}
+	return nil
+}
+
+func schemaError(node *yamlnode.Node, message string) SchemaError {
+	if node == nil {
+		return SchemaError{Message: message}
+	}
+	return SchemaError{Line: node.Line, Column: node.Column, Message: message}
+}
+
+// suggestion returns a "did you mean" hint naming the closest candidate, if
+// any candidate is close enough to be a likely typo.
+func suggestion(got string, candidates []string) string {
+	if got == "" {
+		return ""
+	}
+
+	best, bestDistance := "", -1
+	for _, candidate := range candidates {
+		distance := levenshtein(strings.ToLower(got), candidate)
+		if bestDistance < 0 || distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+
+	if bestDistance < 0 || bestDistance > 2 && bestDistance > len(best)/3 {
+		return ""
+	}
+	return fmt.Sprintf(" (did you mean %q?)", best)
+}
+
+func levenshtein(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min3(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,187 @@
+package cargo_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v2"
+
+	. "github.com/pivotal-cf/kiln/internal/cargo"
+)
+
+var _ = Describe("ValidateKilnfile", func() {
+	validate := func(kilnfileYAML string) error {
+		var kilnfile Kilnfile
+		Expect(yaml.Unmarshal([]byte(kilnfileYAML), &kilnfile)).To(Succeed())
+		return ValidateKilnfile([]byte(kilnfileYAML), kilnfile)
+	}
+
+	It("accepts a valid Kilnfile", func() {
+		Expect(validate(`---
+slug: my-tile
+release_sources:
+  - type: bosh.io
+  - type: s3
+    bucket: my-bucket
+    path_template: "{{.Name}}-{{.Version}}.tgz"
+  - type: github
+    org: cloudfoundry
+releases:
+  - name: uaa
+    version: ~74
+`)).To(Succeed())
+	})
+
+	It("reports every unknown key", func() {
+		err := validate(`---
+slugg: my-tile
+release_sources:
+  - type: s3
+    compiled: true
+    bucket: my-bucket
+    path_template: some-template
+`)
+		Expect(err).To(Equal(SchemaErrors{
+			{Line: 2, Column: 1, Message: `unknown field "slugg" (did you mean "slug"?)`},
+			{Line: 5, Column: 5, Message: `unknown field "compiled"`},
+		}))
+	})
+
+	It("reports release sources of an unknown type", func() {
+		err := validate(`---
+release_sources:
+  - type: s4
+    bucket: my-bucket
+`)
+		Expect(err).To(MatchError(`line 3, column 11: release source has unknown type "s4" (did you mean "s3"?)`))
+	})
+
+	It("reports missing required fields", func() {
+		err := validate(`---
+release_sources:
+  - type: s3
+    bucket: my-bucket
+  - type: http
+    path_template: some-template
+`)
+		Expect(err).To(Equal(SchemaErrors{
+			{Line: 3, Column: 5, Message: `s3 release source is missing required field "path_template"`},
+			{Line: 5, Column: 5, Message: `http release source is missing required field "url"`},
+		}))
+	})
+
+	It("reports release sources sharing an ID", func() {
+		err := validate(`---
+release_sources:
+  - type: s3
+    bucket: my-bucket
+    path_template: some-template
+  - type: directory
+    id: my-bucket
+    path: /some/path
+    path_template: some-template
+`)
+		Expect(err).To(MatchError(`line 6, column 5: release sources must have unique IDs; "my-bucket" is already used by the release source on line 3`))
+	})
+	It("reports verification settings that can't be used", func() {
+		err := validate(`---
+release_sources:
+  - type: s3
+    bucket: my-bucket
+    path_template: some-template
+    verification:
+      type: cosgin
+  - type: bosh.io
+    verification:
+      type: gpg
+      public_key: releases.asc
+`)
+		Expect(err).To(Equal(SchemaErrors{
+			{Line: 7, Column: 7, Message: `verification has unknown type "cosgin" (did you mean "cosign"?)`},
+			{Line: 7, Column: 7, Message: `verification is missing required field "public_key"`},
+			{Line: 10, Column: 7, Message: `bosh.io release sources do not support verification`},
+		}))
+	})
+})
+
+var _ = Describe("ValidateKilnfileLock", func() {
+	validate := func(lockYAML string) error {
+		var lock KilnfileLock
+		Expect(yaml.Unmarshal([]byte(lockYAML), &lock)).To(Succeed())
+		return ValidateKilnfileLock([]byte(lockYAML), lock)
+	}
+
+	It("reports unknown keys in nested structures", func() {
+		err := validate(`---
+releases:
+- name: uaa
+  version: "1.2.3"
+stemcell_criteria:
+  os: ubuntu-xenial
+  verison: "621.1"
+`)
+		Expect(err).To(MatchError(`line 7, column 3: unknown field "verison" (did you mean "version"?)`))
+	})
+
+	It("reports releases locked more than once", func() {
+		err := validate(`---
+releases:
+- name: uaa
+  version: "1.2.3"
+- name: uaa
+  version: "1.2.4"
+`)
+		Expect(err).To(MatchError(`line 5, column 3: release "uaa" is locked more than once; it is also locked on line 3`))
+	})
+
+	It("accepts a list of stemcells used by releases", func() {
+		err := validate(`---
+releases:
+- name: uaa
+  version: "1.2.3"
+  stemcell_os: ubuntu-jammy
+- name: nats
+  version: "34"
+stemcell_criteria:
+- os: ubuntu-xenial
+  version: "621.1"
+- os: ubuntu-jammy
+  version: "1.18"
+`)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("reports a stemcell OS listed more than once", func() {
+		err := validate(`---
+stemcell_criteria:
+- os: ubuntu-xenial
+  version: "621.1"
+- os: ubuntu-xenial
+  version: "621.2"
+`)
+		Expect(err).To(MatchError(`line 5, column 3: stemcell_criteria has more than one "ubuntu-xenial" stemcell; it is also listed on line 3`))
+	})
+
+	It("reports a release using a stemcell that is not listed", func() {
+		err := validate(`---
+releases:
+- name: uaa
+  version: "1.2.3"
+  stemcell_os: ubuntu-jammy
+stemcell_criteria:
+  os: ubuntu-xenial
+  version: "621.1"
+`)
+		Expect(err).To(MatchError(`line 5, column 16: release "uaa" has stemcell_os "ubuntu-jammy", which is not in stemcell_criteria`))
+	})
+
+	It("reports a sha256 that is not a SHA256 checksum", func() {
+		err := validate(`---
+releases:
+- name: uaa
+  version: "1.2.3"
+  sha1: 0123456789abcdef0123456789abcdef01234567
+  sha256: 0123456789abcdef0123456789abcdef01234567
+`)
+		Expect(err).To(MatchError(`line 6, column 11: release "uaa" has sha256 "0123456789abcdef0123456789abcdef01234567", which is not a hex encoded SHA256 checksum`))
+	})
+})
@@ -0,0 +1,14 @@
+package redact_test
+
+import (
+	"testing"
+
+	"github.com/matt-royal/biloba"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestRedact(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecsWithCustomReporters(t, "internal/redact", biloba.DefaultReporters())
+}
@@ -0,0 +1,124 @@
+// Package redact masks secrets, such as the credentials of release sources,
+// in kiln's output and error messages.
+package redact
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	Mask = "[redacted]"
+
+	// minimumLength keeps short values, like a "1" passed with --variable,
+	// from masking unrelated output.
+	minimumLength = 4
+)
+
+// Secrets is a set of values to mask. The zero value is empty and ready to
+// use.
+type Secrets struct {
+	mu       sync.RWMutex
+	values   []string
+	replacer *strings.Replacer
+}
+
+var secrets Secrets
+
+// Add registers values to mask with the package's secrets.
+func Add(values ...string) { secrets.Add(values...) }
+
+// String masks the package's secrets in s.
+func String(s string) string { return secrets.String(s) }
+
+// Writer masks the package's secrets in everything written to w.
+func Writer(w io.Writer) io.Writer { return secrets.Writer(w) }
+
+// Error masks the package's secrets in the message of err.
+func Error(err error) error { return secrets.Error(err) }
+
+func (s *Secrets) Add(values ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	added := false
+	for _, value := range values {
+		if len(value) < minimumLength || containsString(s.values, value) {
+			continue
+		}
+		s.values = append(s.values, value)
+		added = true
+	}
+	if !added {
+		return
+	}
+
+	// Longer secrets are replaced first so a secret containing another is
+	// masked entirely.
+	sort.Slice(s.values, func(i, j int) bool { return len(s.values[i]) > len(s.values[j]) })
+	pairs := make([]string, 0, 2*len(s.values))
+	for _, value := range s.values {
+		pairs = append(pairs, value, Mask)
+	}
+	s.replacer = strings.NewReplacer(pairs...)
+}
+
+func (s *Secrets) String(str string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.replacer == nil {
+		return str
+	}
+	return s.replacer.Replace(str)
+}
+
+// Writer masks secrets in each call to Write, so a secret split across two
+// writes is not masked. A log.Logger writes each message with one call.
+func (s *Secrets) Writer(w io.Writer) io.Writer {
+	return writer{secrets: s, w: w}
+}
+
+func (s *Secrets) Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	return redactedError{secrets: s, err: err}
+}
+
+type writer struct {
+	secrets *Secrets
+	w       io.Writer
+}
+
+func (w writer) Write(p []byte) (int, error) {
+	_, err := io.WriteString(w.w, w.secrets.String(string(p)))
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+type redactedError struct {
+	secrets *Secrets
+	err     error
+}
+
+func (err redactedError) Error() string {
+	return err.secrets.String(err.err.Error())
+}
+
+func (err redactedError) Unwrap() error {
+	return err.err
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
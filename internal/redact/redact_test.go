@@ -0,0 +1,60 @@
+package redact_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/pivotal-cf/kiln/internal/redact"
+)
+
+var _ = Describe("Secrets", func() {
+	var secrets *redact.Secrets
+
+	BeforeEach(func() {
+		secrets = new(redact.Secrets)
+	})
+
+	It("masks every occurrence of a secret", func() {
+		secrets.Add("AKIAEXAMPLE", "some-secret-key")
+
+		Expect(secrets.String("access_key_id: AKIAEXAMPLE\nsecret_access_key: some-secret-key # AKIAEXAMPLE")).To(Equal(
+			"access_key_id: [redacted]\nsecret_access_key: [redacted] # [redacted]",
+		))
+	})
+
+	It("masks secrets containing other secrets entirely", func() {
+		secrets.Add("token", "token-with-suffix")
+
+		Expect(secrets.String("bearer token-with-suffix")).To(Equal("bearer [redacted]"))
+	})
+
+	It("ignores short values", func() {
+		secrets.Add("", "1", "abc")
+
+		Expect(secrets.String("abc 1")).To(Equal("abc 1"))
+	})
+
+	It("masks secrets written to a logger", func() {
+		var out bytes.Buffer
+		logger := log.New(secrets.Writer(&out), "", 0)
+		secrets.Add("some-secret-key")
+
+		logger.Printf("failed to use %q", "some-secret-key")
+		Expect(out.String()).To(Equal("failed to use \"[redacted]\"\n"))
+	})
+
+	It("masks secrets in errors and keeps the wrapped error", func() {
+		secrets.Add("some-secret-key")
+		cause := errors.New("bad key some-secret-key")
+
+		err := secrets.Error(fmt.Errorf("failed: %w", cause))
+		Expect(err).To(MatchError("failed: bad key [redacted]"))
+		Expect(errors.Is(err, cause)).To(BeTrue())
+		Expect(secrets.Error(nil)).To(BeNil())
+	})
+})
@@ -12,6 +12,7 @@ import (
 	"github.com/pivotal-cf/kiln/helper"
 	"github.com/pivotal-cf/kiln/internal/baking"
 	"github.com/pivotal-cf/kiln/internal/cargo"
+	"github.com/pivotal-cf/kiln/internal/redact"
 
 	"github.com/pivotal-cf/jhanda"
 	"gopkg.in/src-d/go-billy.v4/osfs"
@@ -20,8 +21,9 @@ import (
 var version = "unknown"
 
 func main() {
-	errLogger := log.New(os.Stderr, "", 0)
-	outLogger := log.New(os.Stdout, "", 0)
+	log.SetOutput(redact.Writer(os.Stderr))
+	errLogger := log.New(redact.Writer(os.Stderr), "", 0)
+	outLogger := log.New(redact.Writer(os.Stdout), "", 0)
 
 	var global struct {
 		Help    bool `short:"h" long:"help"    description:"prints this usage information"   default:"false"`
@@ -61,16 +63,25 @@ func main() {
 	releasesService := baking.NewReleasesService(errLogger, releaseManifestReader)
 	localReleaseDirectory := fetcher.NewLocalReleaseDirectory(outLogger, releasesService)
 	kilnfileLoader := cargo.KilnfileLoader{}
-	mrsProvider := commands.MultiReleaseSourceProvider(func(kilnfile cargo.Kilnfile, allowOnlyPublishable bool) fetcher.MultiReleaseSource {
-		repo := fetcher.NewReleaseSourceRepo(kilnfile, outLogger)
-		return repo.MultiReleaseSource(allowOnlyPublishable)
+	mrsProvider := commands.MultiReleaseSourceProvider(func(kilnfile cargo.Kilnfile, allowOnlyPublishable bool) (fetcher.MultiReleaseSource, error) {
+		repo, err := fetcher.NewReleaseSourceRepo(kilnfile, outLogger)
+		if err != nil {
+			return nil, err
+		}
+		return repo.MultiReleaseSource(allowOnlyPublishable), nil
 	})
 	ruFinder := commands.ReleaseUploaderFinder(func(kilnfile cargo.Kilnfile, sourceID string) (fetcher.ReleaseUploader, error) {
-		repo := fetcher.NewReleaseSourceRepo(kilnfile, outLogger)
+		repo, err := fetcher.NewReleaseSourceRepo(kilnfile, outLogger)
+		if err != nil {
+			return nil, err
+		}
 		return repo.FindReleaseUploader(sourceID)
 	})
 	rpFinder := commands.RemotePatherFinder(func(kilnfile cargo.Kilnfile, sourceID string) (fetcher.RemotePather, error) {
-		repo := fetcher.NewReleaseSourceRepo(kilnfile, outLogger)
+		repo, err := fetcher.NewReleaseSourceRepo(kilnfile, outLogger)
+		if err != nil {
+			return nil, err
+		}
 		return repo.FindRemotePather(sourceID)
 	})
 
@@ -78,6 +89,8 @@ func main() {
 	commandSet["help"] = commands.NewHelp(os.Stdout, globalFlagsUsage, commandSet)
 	commandSet["version"] = commands.NewVersion(outLogger, version)
 	commandSet["bake"] = bakeCommand(fs, releasesService, outLogger, errLogger)
+	commandSet["update-all"] = commands.NewUpdateAll(outLogger, fs, mrsProvider, kilnfileLoader)
+	commandSet["verify-lock"] = commands.NewVerifyLock(outLogger, fs, mrsProvider, kilnfileLoader)
 	commandSet["update-release"] = commands.NewUpdateRelease(outLogger, fs, mrsProvider, kilnfileLoader)
 	commandSet["fetch"] = commands.NewFetch(outLogger, mrsProvider, localReleaseDirectory)
 	commandSet["upload-release"] = commands.UploadRelease{
@@ -93,10 +106,16 @@ func main() {
 		KilnfileLoader:             kilnfileLoader,
 		Logger:                     outLogger,
 		MultiReleaseSourceProvider: mrsProvider,
+		StemcellIndexFinder:        fetcher.NewStemcellIndex,
 	}
 
 	commandSet["find-release-version"] = commands.NewFindReleaseVersion(outLogger, mrsProvider)
 
+	commandSet["cache"] = commands.NewCache(outLogger)
+	commandSet["validate"] = commands.NewValidate(outLogger, fs)
+	commandSet["inspect"] = commands.NewInspect(outLogger, fs)
+	commandSet["diff"] = commands.NewDiff(outLogger, fs)
+	commandSet["release-notes"] = commands.NewReleaseNotes(outLogger, fs, kilnfileLoader)
 
 	commandSet["compile-built-releases"] = commands.CompileBuiltReleases{
 		BoshDirectorFactory:        commands.BoshDirectorFactory,
@@ -163,5 +182,8 @@ func bakeCommand(fs billy.Filesystem, releasesService baking.ReleasesService, ou
 		iconService,
 		metadataService,
 		checksummer,
+		baking.NewTileManifestWriter(errLogger),
+		fs,
+		cargo.KilnfileLoader{},
 	)
 }
@@ -6,3 +6,11 @@ type CollectionPropertyBlueprint struct {
 	PropertyBlueprints []SimplePropertyBlueprint `yaml:"property_blueprints"`
 	NamedManifests     []NamedManifest           `yaml:"named_manifests"`
 }
+
+func (cp CollectionPropertyBlueprint) Validate() error {
+	err := cp.SimplePropertyBlueprint.Validate()
+	for _, propertyBlueprint := range cp.PropertyBlueprints {
+		err = combineErrors(err, propertyBlueprint.Validate())
+	}
+	return err
+}
@@ -11,3 +11,27 @@ type FormType struct {
 
 	// TODO: validations: https://github.com/pivotal-cf/installation/blob/039a2ef3f751ef5915c425da8150a29af4b764dd/web/app/models/persistence/metadata/form_type.rb#L13-L24
 }
+
+// references lists the property blueprints the form's inputs refer to.
+// Collection subfields are relative to their collection, so they are not
+// included.
+func (ft FormType) references() []string {
+	var references []string
+	for _, propertyInput := range ft.PropertyInputs {
+		switch input := propertyInput.(type) {
+		case SimplePropertyInput:
+			references = append(references, input.Reference)
+		case CollectionPropertyInput:
+			references = append(references, input.Reference)
+		case SelectorPropertyInput:
+			references = append(references, input.Reference)
+			for _, option := range input.SelectorPropertyInputs {
+				references = append(references, option.Reference)
+				for _, optionInput := range option.PropertyInputs {
+					references = append(references, optionInput.Reference)
+				}
+			}
+		}
+	}
+	return references
+}
@@ -1,5 +1,11 @@
 package proofing
 
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
 type JobType struct {
 	Name          string `yaml:"name"`
 	ResourceLabel string `yaml:"resource_label"`
@@ -25,5 +31,46 @@ type JobType struct {
 	// TODO: validations: https://github.com/pivotal-cf/installation/blob/039a2ef3f751ef5915c425da8150a29af4b764dd/web/app/models/persistence/metadata/job_type.rb#L11-L15
 	// TODO: more validations: https://github.com/pivotal-cf/installation/blob/039a2ef3f751ef5915c425da8150a29af4b764dd/web/app/models/persistence/metadata/job_type.rb#L33-L55
 	// TODO: find_object: https://github.com/pivotal-cf/installation/blob/039a2ef3f751ef5915c425da8150a29af4b764dd/web/app/models/persistence/metadata/job_type.rb#L57-L58
-	// TODO: max_in_flight can be int or percentage
+}
+
+func (jt JobType) Validate() error {
+	var err error
+	err = ValidatePresence(err, jt, "Name")
+	err = ValidatePresence(err, jt, "ResourceLabel")
+	err = ValidatePresence(err, jt, "Templates")
+	err = combineErrors(err, jt.validateMaxInFlight())
+
+	for _, template := range jt.Templates {
+		err = combineErrors(err, template.Validate())
+	}
+
+	for _, propertyBlueprint := range jt.PropertyBlueprints {
+		err = combineErrors(err, validatePropertyBlueprint(propertyBlueprint))
+	}
+
+	return err
+}
+
+var maxInFlightPercentagePattern = regexp.MustCompile(`^(\d+)%$`)
+
+// validateMaxInFlight allows a positive number of instances or a percentage
+// of them, like "50%".
+func (jt JobType) validateMaxInFlight() error {
+	switch value := jt.MaxInFlight.(type) {
+	case nil:
+		return NewValidationError(jt, fmt.Sprintf("%q max in flight must be present", jt.Name))
+	case int:
+		if value > 0 {
+			return nil
+		}
+	case string:
+		if matches := maxInFlightPercentagePattern.FindStringSubmatch(value); matches != nil {
+			percentage, _ := strconv.Atoi(matches[1])
+			if percentage > 0 && percentage <= 100 {
+				return nil
+			}
+		}
+	}
+
+	return NewValidationError(jt, fmt.Sprintf("%q max in flight must be a positive integer or a percentage between 1%% and 100%%, got %v", jt.Name, jt.MaxInFlight))
 }
@@ -57,3 +57,59 @@ var _ = Describe("JobType", func() {
 		})
 	})
 })
+
+var _ = Describe("JobType Validate", func() {
+	var jobType JobType
+
+	BeforeEach(func() {
+		jobType = JobType{
+			Name:          "some-name",
+			ResourceLabel: "some-resource-label",
+			MaxInFlight:   1,
+			Templates:     []Template{{Name: "some-template", Release: "some-release"}},
+		}
+	})
+
+	It("is valid", func() {
+		Expect(jobType.Validate()).To(Succeed())
+	})
+
+	It("validates the presence of fields", func() {
+		jobType = JobType{MaxInFlight: 1}
+		Expect(jobType.Validate()).To(MatchError(`- job type name must be present
+- job type resource label must be present
+- job type templates must be present`))
+	})
+
+	It("validates the templates", func() {
+		jobType.Templates[0].Release = ""
+		Expect(jobType.Validate()).To(MatchError("template release must be present"))
+	})
+
+	Describe("max_in_flight", func() {
+		It("allows percentages", func() {
+			jobType.MaxInFlight = "20%"
+			Expect(jobType.Validate()).To(Succeed())
+		})
+
+		It("must be present", func() {
+			jobType.MaxInFlight = nil
+			Expect(jobType.Validate()).To(MatchError(`job type "some-name" max in flight must be present`))
+		})
+
+		It("must be positive", func() {
+			jobType.MaxInFlight = 0
+			Expect(jobType.Validate()).To(MatchError(`job type "some-name" max in flight must be a positive integer or a percentage between 1% and 100%, got 0`))
+		})
+
+		It("must be a percentage when it is a string", func() {
+			jobType.MaxInFlight = "some-max-in-flight"
+			Expect(jobType.Validate()).To(MatchError(ContainSubstring("got some-max-in-flight")))
+		})
+
+		It("must be a percentage of at most 100%", func() {
+			jobType.MaxInFlight = "150%"
+			Expect(jobType.Validate()).To(MatchError(ContainSubstring("got 150%")))
+		})
+	})
+})
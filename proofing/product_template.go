@@ -62,3 +62,86 @@ func (pt ProductTemplate) AllPropertyBlueprints() []NormalizedPropertyBlueprint
 
 	return propertyBlueprints
 }
+
+// Validate checks that required fields are present and that job types,
+// errands and forms only refer to releases, job types and property
+// blueprints that exist in the product template.
+func (pt ProductTemplate) Validate() error {
+	var err error
+	err = ValidatePresence(err, pt, "Name")
+	err = ValidatePresence(err, pt, "ProductVersion")
+	err = ValidatePresence(err, pt, "MetadataVersion")
+	err = ValidatePresence(err, pt, "Label")
+
+	releaseNames := make(map[string]bool)
+	for _, release := range pt.Releases {
+		err = combineErrors(err, release.Validate())
+		releaseNames[release.Name] = true
+	}
+
+	for _, propertyBlueprint := range pt.PropertyBlueprints {
+		err = combineErrors(err, validatePropertyBlueprint(propertyBlueprint))
+	}
+
+	jobTypeNames := make(map[string]bool)
+	for _, jobType := range pt.JobTypes {
+		err = combineErrors(err, jobType.Validate())
+		if jobType.Name != "" && jobTypeNames[jobType.Name] {
+			err = combineErrors(err, NewValidationError(jobType, fmt.Sprintf("name %q must be unique", jobType.Name)))
+		}
+		jobTypeNames[jobType.Name] = true
+
+		for _, template := range jobType.Templates {
+			if template.Release != "" && !releaseNames[template.Release] {
+				err = combineErrors(err, NewValidationError(jobType, fmt.Sprintf("%q template %q references release %q, which is not in releases", jobType.Name, template.Name, template.Release)))
+			}
+		}
+	}
+
+	for _, errands := range [][]ErrandTemplate{pt.PostDeployErrands, pt.PreDeleteErrands} {
+		for _, errand := range errands {
+			if !errand.Colocated && !jobTypeNames[errand.Name] {
+				err = combineErrors(err, NewValidationError(errand, fmt.Sprintf("%q does not reference a job type", errand.Name)))
+			}
+		}
+	}
+
+	references := pt.propertyReferences()
+	for _, formType := range pt.FormTypes {
+		for _, reference := range formType.references() {
+			if !references[reference] {
+				err = combineErrors(err, NewValidationError(formType, fmt.Sprintf("%q property input %q does not reference a property blueprint", formType.Name, reference)))
+			}
+		}
+	}
+
+	return err
+}
+
+// propertyReferences are the names property inputs may use to refer to
+// property blueprints, including the options of selectors.
+func (pt ProductTemplate) propertyReferences() map[string]bool {
+	references := make(map[string]bool)
+	for _, pb := range pt.AllPropertyBlueprints() {
+		references[pb.Property] = true
+	}
+
+	addOptions := func(prefix string, propertyBlueprints PropertyBlueprints) {
+		for _, pb := range propertyBlueprints {
+			selector, ok := pb.(SelectorPropertyBlueprint)
+			if !ok {
+				continue
+			}
+			for _, optionTemplate := range selector.OptionTemplates {
+				references[fmt.Sprintf("%s.%s.%s", prefix, selector.Name, optionTemplate.Name)] = true
+			}
+		}
+	}
+
+	addOptions(".properties", pt.PropertyBlueprints)
+	for _, jobType := range pt.JobTypes {
+		addOptions("."+jobType.Name, jobType.PropertyBlueprints)
+	}
+
+	return references
+}
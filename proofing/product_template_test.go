@@ -107,3 +107,66 @@ var _ = Describe("ProductTemplate", func() {
 		})
 	})
 })
+
+var _ = Describe("ProductTemplate Validate", func() {
+	var productTemplate ProductTemplate
+
+	BeforeEach(func() {
+		f, err := os.Open("fixtures/valid_metadata.yml")
+		defer f.Close()
+		Expect(err).NotTo(HaveOccurred())
+
+		productTemplate, err = Parse(f)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("is valid", func() {
+		Expect(productTemplate.Validate()).To(Succeed())
+	})
+
+	It("validates the presence of required fields", func() {
+		productTemplate.Name = ""
+		productTemplate.ProductVersion = ""
+		Expect(productTemplate.Validate()).To(MatchError(`- product template name must be present
+- product template product version must be present`))
+	})
+
+	It("includes the validations of releases and job types", func() {
+		productTemplate.Releases[0].File = ""
+		productTemplate.JobTypes[0].ResourceLabel = ""
+		Expect(productTemplate.Validate()).To(MatchError(`- release file must be present
+- job type resource label must be present`))
+	})
+
+	It("validates that job templates reference releases", func() {
+		productTemplate.JobTypes[0].Templates[0].Release = "missing-release"
+		Expect(productTemplate.Validate()).To(MatchError(`job type "web" template "web-server" references release "missing-release", which is not in releases`))
+	})
+
+	It("validates that job type names are unique", func() {
+		productTemplate.JobTypes[1].Name = "web"
+		err := productTemplate.Validate()
+		Expect(err).To(MatchError(ContainSubstring(`job type name "web" must be unique`)))
+	})
+
+	It("validates that errands reference job types", func() {
+		productTemplate.PostDeployErrands[0].Name = "missing-errand"
+		Expect(productTemplate.Validate()).To(MatchError(`errand template "missing-errand" does not reference a job type`))
+	})
+
+	It("validates that property inputs reference property blueprints", func() {
+		productTemplate.FormTypes[0].PropertyInputs[0] = SimplePropertyInput{Reference: ".properties.missing"}
+		Expect(productTemplate.Validate()).To(MatchError(`form type "config" property input ".properties.missing" does not reference a property blueprint`))
+	})
+
+	It("returns structured errors", func() {
+		productTemplate.Name = ""
+		productTemplate.Label = ""
+
+		err := productTemplate.Validate()
+		compoundError, ok := err.(*CompoundError)
+		Expect(ok).To(BeTrue())
+		Expect(*compoundError).To(HaveLen(2))
+		Expect((*compoundError)[0]).To(BeAssignableToTypeOf(ValidationError{}))
+	})
+})
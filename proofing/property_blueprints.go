@@ -8,6 +8,14 @@ type PropertyBlueprint interface {
 
 type PropertyBlueprints []PropertyBlueprint
 
+func validatePropertyBlueprint(pb PropertyBlueprint) error {
+	validator, ok := pb.(interface{ Validate() error })
+	if !ok {
+		return nil
+	}
+	return validator.Validate()
+}
+
 type NormalizedPropertyBlueprint struct {
 	Property     string
 	Configurable bool
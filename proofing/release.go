@@ -1,6 +1,7 @@
 package proofing
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 )
@@ -29,6 +30,24 @@ func (ce *CompoundError) Error() string {
 	return strings.Join(messages, "\n")
 }
 
+func (ce *CompoundError) Is(target error) bool {
+	for _, e := range *ce {
+		if errors.Is(e, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (ce *CompoundError) As(target interface{}) bool {
+	for _, e := range *ce {
+		if errors.As(e, target) {
+			return true
+		}
+	}
+	return false
+}
+
 func (r Release) Validate() error {
 	var err error
 	err = ValidatePresence(err, r, "Name")
@@ -23,3 +23,22 @@ func (sp SelectorPropertyBlueprint) Normalize(prefix string) []NormalizedPropert
 
 	return propertyBlueprints
 }
+
+func (sp SelectorPropertyBlueprint) Validate() error {
+	err := sp.SimplePropertyBlueprint.Validate()
+
+	names := make(map[string]bool)
+	for _, optionTemplate := range sp.OptionTemplates {
+		err = ValidatePresence(err, optionTemplate, "Name")
+		if optionTemplate.Name != "" && names[optionTemplate.Name] {
+			err = combineErrors(err, NewValidationError(sp, fmt.Sprintf("%q option template name %q must be unique", sp.Name, optionTemplate.Name)))
+		}
+		names[optionTemplate.Name] = true
+
+		for _, propertyBlueprint := range optionTemplate.PropertyBlueprints {
+			err = combineErrors(err, propertyBlueprint.Validate())
+		}
+	}
+
+	return err
+}
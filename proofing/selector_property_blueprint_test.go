@@ -70,3 +70,33 @@ var _ = Describe("SelectorPropertyBlueprint", func() {
 		})
 	})
 })
+
+var _ = Describe("SelectorPropertyBlueprint Validate", func() {
+	var selectorPropertyBlueprint SelectorPropertyBlueprint
+
+	BeforeEach(func() {
+		selectorPropertyBlueprint = SelectorPropertyBlueprint{
+			SimplePropertyBlueprint: SimplePropertyBlueprint{Name: "some-selector", Type: "selector"},
+			OptionTemplates: []SelectorPropertyOptionTemplate{
+				{Name: "some-option", PropertyBlueprints: []SimplePropertyBlueprint{{Name: "some-property", Type: "string"}}},
+				{Name: "other-option"},
+			},
+		}
+	})
+
+	It("is valid", func() {
+		Expect(selectorPropertyBlueprint.Validate()).To(Succeed())
+	})
+
+	It("validates that option template names are unique", func() {
+		selectorPropertyBlueprint.OptionTemplates[1].Name = "some-option"
+		Expect(selectorPropertyBlueprint.Validate()).To(MatchError(`selector property blueprint "some-selector" option template name "some-option" must be unique`))
+	})
+
+	It("validates the option templates and their property blueprints", func() {
+		selectorPropertyBlueprint.OptionTemplates[0].PropertyBlueprints[0].Type = ""
+		selectorPropertyBlueprint.OptionTemplates[1].Name = ""
+		Expect(selectorPropertyBlueprint.Validate()).To(MatchError(`- simple property blueprint type must be present
+- selector property option template name must be present`))
+	})
+})
@@ -31,6 +31,13 @@ func (sp SimplePropertyBlueprint) Normalize(prefix string) []NormalizedPropertyB
 	}
 }
 
+func (sp SimplePropertyBlueprint) Validate() error {
+	var err error
+	err = ValidatePresence(err, sp, "Name")
+	err = ValidatePresence(err, sp, "Type")
+	return err
+}
+
 type PropertyBlueprintOption struct {
 	Label string `yaml:"label"`
 	Name  string `yaml:"name"`
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"unicode"
 )
 
 type ValidationError struct {
@@ -19,23 +20,54 @@ func NewValidationError(kind interface{}, message string) ValidationError {
 }
 
 func (ve ValidationError) Error() string {
-	t := reflect.TypeOf(ve.Kind)
-	return fmt.Sprintf("%s %s", strings.ToLower(t.Name()), ve.Message)
+	return fmt.Sprintf("%s %s", lowerWords(reflect.TypeOf(ve.Kind).Name()), ve.Message)
+}
+
+// lowerWords turns a Go name like JobType into "job type".
+func lowerWords(name string) string {
+	var words []string
+	start := 0
+	for i, r := range name {
+		if i > 0 && unicode.IsUpper(r) {
+			words = append(words, name[start:i])
+			start = i
+		}
+	}
+	words = append(words, name[start:])
+
+	return strings.ToLower(strings.Join(words, " "))
 }
 
 func ValidatePresence(err error, v interface{}, field string) error {
 	value := reflect.ValueOf(v).FieldByName(field)
 	if value.Len() == 0 {
-		validationError := NewValidationError(v, fmt.Sprintf("%s must be present", strings.ToLower(field)))
-		switch e := err.(type) {
-		case *CompoundError:
-			e.Add(validationError)
-		case ValidationError:
-			err = &CompoundError{err, validationError}
-		default:
-			err = validationError
-		}
+		validationError := NewValidationError(v, fmt.Sprintf("%s must be present", lowerWords(field)))
+		err = combineErrors(err, validationError)
 	}
 
 	return err
 }
+
+// combineErrors adds next to err, flattening compound errors so they are
+// reported as a single list.
+func combineErrors(err, next error) error {
+	if next == nil {
+		return err
+	}
+
+	switch e := err.(type) {
+	case nil:
+		return next
+	case *CompoundError:
+		if n, ok := next.(*CompoundError); ok {
+			for _, nextErr := range *n {
+				e.Add(nextErr)
+			}
+		} else {
+			e.Add(next)
+		}
+		return e
+	default:
+		return combineErrors(&CompoundError{err}, next)
+	}
+}
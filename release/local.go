@@ -4,4 +4,5 @@ type Local struct {
 	ID
 	LocalPath string
 	SHA1      string
+	SHA256    string
 }